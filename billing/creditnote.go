@@ -0,0 +1,140 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+)
+
+var (
+	ErrCreditNoteNotFound      = errors.New("credit note not found")
+	ErrCreditReasonCantBeEmpty = errors.New("credit note reason can't be empty")
+	ErrInvoiceNotCreditable    = errors.New("only issued, partially paid or paid invoices can be credited")
+	ErrCreditItemNotFound      = errors.New("the item to credit isn't on the invoice")
+	ErrCreditQuantity          = errors.New("credited quantity must be between one and the quantity not credited yet")
+	ErrNothingToCredit         = errors.New("the invoice has been credited in full")
+	ErrCreditExceedsInvoice    = errors.New("credit exceeds the remaining creditable amount of the invoice")
+	ErrInvoiceCredited         = errors.New("a credited invoice can't be voided, credit the rest of it instead")
+)
+
+// CreditNote reverses an issued invoice, in full or some of its lines, as
+// the invoice itself can't change. It has its own numbering series and
+// lowers what the client owes. It's in the currency of the invoice.
+type CreditNote struct {
+	ID        int64
+	UUID      string
+	Number    string // legal number, e.g. CN-2024-000001
+	InvoiceID int64
+	ClientID  int64
+	Reason    string
+	Currency  money.Currency
+	Totals
+	Items     CreditNoteItems
+	By        Actor
+	CreatedAt time.Time
+}
+
+// CreditNoteItem reverses a quantity of a line of the invoice. The product
+// and prices are those of the invoice line.
+type CreditNoteItem struct {
+	ID            int64
+	CreditNoteID  int64
+	InvoiceItemID int64
+	ProductID     int64
+	ProductName   string
+	Quantity      int
+	UnitPrice     money.Money
+	TaxRate       int
+	Totals
+}
+
+// CreditNoteItems collection of credit note items.
+type CreditNoteItems []CreditNoteItem
+
+// creditable reports whether an invoice in st can be credited.
+func (st Status) creditable() bool {
+	return st == StatusIssued || st == StatusPartiallyPaid || st == StatusPaid
+}
+
+// buildCreditNote sets the lines and totals of cn from the invoice items
+// and the quantity of each item credited so far. Without lines the whole
+// quantity not credited yet is credited. The amounts of a line are the
+// share of its quantity in the invoice line, the lines crediting all of an
+// item add up to its totals exactly.
+func buildCreditNote(cn *CreditNote, items ItemList, credited map[int64]int, creditable int64) error {
+	if len(cn.Items) == 0 {
+		for _, it := range items {
+			if left := it.Quantity - credited[it.ID]; left > 0 {
+				cn.Items = append(cn.Items, CreditNoteItem{InvoiceItemID: it.ID, Quantity: left})
+			}
+		}
+		if len(cn.Items) == 0 {
+			return ErrNothingToCredit
+		}
+	}
+	done := make(map[int64]int, len(credited))
+	for id, q := range credited {
+		done[id] = q
+	}
+	var sum Totals
+	for i := range cn.Items {
+		line := &cn.Items[i]
+		it, ok := findItem(items, line.InvoiceItemID)
+		if !ok {
+			return fmt.Errorf("%w: item %d", ErrCreditItemNotFound, line.InvoiceItemID)
+		}
+		from := done[it.ID]
+		if line.Quantity <= 0 || line.Quantity > it.Quantity-from {
+			return fmt.Errorf("%w: item %d has %d", ErrCreditQuantity, it.ID, it.Quantity-from)
+		}
+		to := from + line.Quantity
+		done[it.ID] = to
+		line.ProductID = it.ProductID
+		line.ProductName = it.ProductName
+		line.UnitPrice = it.UnitPrice
+		line.TaxRate = it.TaxRate
+		// The subtotal of the item is net of tax if its price included it,
+		// so the base is shared rather than the unit price multiplied.
+		base := share(it.Subtotal-it.Discount, from, to, it.Quantity)
+		line.Discount = share(it.Discount, from, to, it.Quantity)
+		line.Subtotal = base + line.Discount
+		line.Tax = share(it.Tax, from, to, it.Quantity)
+		line.Total = base + line.Tax
+		var err error
+		if sum, err = sum.add(line.Totals); err != nil {
+			return err
+		}
+	}
+	if sum.Total > creditable {
+		return fmt.Errorf("%w: %d left", ErrCreditExceedsInvoice, creditable)
+	}
+	cn.Totals = sum
+	return nil
+}
+
+// findItem returns the item id of items.
+func findItem(items ItemList, id int64) (InvoiceItem, bool) {
+	for _, it := range items {
+		if it.ID == id {
+			return it, true
+		}
+	}
+	return InvoiceItem{}, false
+}
+
+// share returns the part of amount of the units from+1 to to of n, rounded
+// down on the whole range so the shares of all the units add up to amount.
+func share(amount int64, from, to, n int) int64 {
+	return prorate(amount, to, n) - prorate(amount, from, n)
+}
+
+// prorate returns amount*q/n rounded down, amount and q non-negative and q
+// at most n, without overflow.
+func prorate(amount int64, q, n int) int64 {
+	hi, lo := bits.Mul64(uint64(amount), uint64(q))
+	quo, _ := bits.Div64(hi, lo, uint64(n))
+	return int64(quo)
+}
@@ -0,0 +1,112 @@
+package billing
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/adrianolmedo/genesis/money"
+)
+
+func TestBuildCreditNote(t *testing.T) {
+	// 3 units of 1000 with 200 of discount and 16% of tax.
+	item := InvoiceItem{ID: 1, ProductID: 9, ProductName: "Coca-Cola", Quantity: 3, UnitPrice: money.New(1000, "EUR"), TaxRate: 1600}
+	item.Discount = 200
+	inv := &Invoice{Header: &InvoiceHeader{}, Items: ItemList{item}}
+	if err := inv.computeTotals(); err != nil {
+		t.Fatal(err)
+	}
+	item = inv.Items[0]
+	items := ItemList{item, {ID: 2, ProductID: 8, Quantity: 1, UnitPrice: money.New(500, "EUR"), Totals: Totals{Subtotal: 500, Total: 500}}}
+
+	t.Run("partial-lines-add-up", func(t *testing.T) {
+		credited := map[int64]int{}
+		var sum Totals
+		for range item.Quantity {
+			cn := &CreditNote{Items: CreditNoteItems{{InvoiceItemID: 1, Quantity: 1}}}
+			if err := buildCreditNote(cn, items, credited, item.Total+500-sum.Total); err != nil {
+				t.Fatal(err)
+			}
+			line := cn.Items[0]
+			if line.Total != line.Subtotal-line.Discount+line.Tax || line.ProductName != "Coca-Cola" {
+				t.Fatalf("unexpected line %+v", line)
+			}
+			sum, _ = sum.add(cn.Totals)
+			credited[1]++
+		}
+		if sum != item.Totals {
+			t.Errorf("want the credited lines to add up to %+v, got %+v", item.Totals, sum)
+		}
+	})
+	t.Run("full", func(t *testing.T) {
+		cn := &CreditNote{}
+		if err := buildCreditNote(cn, items, map[int64]int{1: 1}, item.Total+500); err != nil {
+			t.Fatal(err)
+		}
+		if len(cn.Items) != 2 || cn.Items[0].Quantity != 2 || cn.Items[1].Quantity != 1 {
+			t.Fatalf("want the quantities left credited, got %+v", cn.Items)
+		}
+	})
+	tt := []struct {
+		name       string
+		cn         CreditNote
+		credited   map[int64]int
+		creditable int64
+		wantErr    error
+	}{
+		{
+			name:       "nothing-left",
+			credited:   map[int64]int{1: 3, 2: 1},
+			creditable: 0,
+			wantErr:    ErrNothingToCredit,
+		},
+		{
+			name:       "unknown-item",
+			cn:         CreditNote{Items: CreditNoteItems{{InvoiceItemID: 3, Quantity: 1}}},
+			creditable: 10000,
+			wantErr:    ErrCreditItemNotFound,
+		},
+		{
+			name:       "zero-quantity",
+			cn:         CreditNote{Items: CreditNoteItems{{InvoiceItemID: 1}}},
+			creditable: 10000,
+			wantErr:    ErrCreditQuantity,
+		},
+		{
+			name:       "more-than-left",
+			cn:         CreditNote{Items: CreditNoteItems{{InvoiceItemID: 1, Quantity: 2}}},
+			credited:   map[int64]int{1: 2},
+			creditable: 10000,
+			wantErr:    ErrCreditQuantity,
+		},
+		{
+			name:       "same-item-twice",
+			cn:         CreditNote{Items: CreditNoteItems{{InvoiceItemID: 1, Quantity: 2}, {InvoiceItemID: 1, Quantity: 2}}},
+			creditable: 10000,
+			wantErr:    ErrCreditQuantity,
+		},
+		{
+			name:       "exceeds-creditable",
+			cn:         CreditNote{Items: CreditNoteItems{{InvoiceItemID: 2, Quantity: 1}}},
+			creditable: 499,
+			wantErr:    ErrCreditExceedsInvoice,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := buildCreditNote(&tc.cn, items, tc.credited, tc.creditable)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("want error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestProrate(t *testing.T) {
+	const big = 1 << 62
+	if got := prorate(big, 3, 4); got != big/4*3 {
+		t.Errorf("want %d, got %d", big/4*3, got)
+	}
+	if got := share(200, 0, 1, 3) + share(200, 1, 2, 3) + share(200, 2, 3, 3); got != 200 {
+		t.Errorf("want the shares to add up to 200, got %d", got)
+	}
+}
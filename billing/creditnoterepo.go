@@ -0,0 +1,240 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adrianolmedo/genesis"
+	"github.com/adrianolmedo/genesis/ledger"
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pborman/uuid"
+)
+
+// CreateCreditNote creates a credit note of the invoice cn.InvoiceID,
+// numbered with the next number of its series in numbers. The invoice is
+// locked while what's left to credit of it is read, and changes to paid
+// if the credit note settles it.
+func (r *Repo) CreateCreditNote(ctx context.Context, cn *CreditNote, numbers NumberFormat) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+	q := r.q.WithTx(tx)
+
+	header, err := q.InvoiceHeaderByIDForUpdate(ctx, cn.InvoiceID)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return ErrInvoiceHeaderNotFound
+	}
+	if err != nil {
+		return err
+	}
+	status := Status(header.Status)
+	if !status.creditable() {
+		return fmt.Errorf("%w: invoice %d is %s", ErrInvoiceNotCreditable, header.ID, status)
+	}
+	items, err := q.InvoiceItemByHeader(ctx, header.ID)
+	if err != nil {
+		return err
+	}
+	quantities, err := q.CreditNoteQuantityByItem(ctx, header.ID)
+	if err != nil {
+		return err
+	}
+	credited := make(map[int64]int, len(quantities))
+	for _, row := range quantities {
+		credited[row.InvoiceItemID] = int(row.Quantity)
+	}
+	creditedTotal, err := q.CreditNoteCreditedToInvoice(ctx, header.ID)
+	if err != nil {
+		return err
+	}
+	cn.Currency = money.Currency(header.Currency)
+	if err := buildCreditNote(cn, toDomainItems(items, cn.Currency), credited, header.Total-creditedTotal); err != nil {
+		return err
+	}
+
+	if cn.CreatedAt.IsZero() {
+		cn.CreatedAt = time.Now()
+	}
+	seq, err := q.InvoiceSequenceNext(ctx, numbers.Series(cn.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("credit note number: %w", err)
+	}
+	cn.Number = numbers.Number(cn.CreatedAt, seq)
+	cn.UUID = genesis.NextUUID()
+	cn.ClientID = header.ClientID
+	id, err := q.CreditNoteCreate(ctx, dbgen.CreditNoteCreateParams{
+		Uuid:                      uuid.Parse(cn.UUID),
+		Number:                    cn.Number,
+		InvoiceHeaderID:           cn.InvoiceID,
+		ClientID:                  cn.ClientID,
+		Reason:                    cn.Reason,
+		Currency:                  string(cn.Currency),
+		Subtotal:                  cn.Subtotal,
+		Discount:                  cn.Discount,
+		Tax:                       cn.Tax,
+		Total:                     cn.Total,
+		CreatedByUserID:           nullID(cn.By.UserID),
+		CreatedByServiceAccountID: nullID(cn.By.ServiceAccountID),
+		CreatedAt:                 cn.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("credit note: %w", err)
+	}
+	cn.ID = id
+	for i := range cn.Items {
+		it := &cn.Items[i]
+		itemID, err := q.CreditNoteItemCreate(ctx, dbgen.CreditNoteItemCreateParams{
+			CreditNoteID:  id,
+			InvoiceItemID: it.InvoiceItemID,
+			ProductID:     it.ProductID,
+			ProductName:   it.ProductName,
+			Quantity:      int32(it.Quantity),
+			UnitPrice:     it.UnitPrice.Amount,
+			TaxRate:       int32(it.TaxRate),
+			Subtotal:      it.Subtotal,
+			Discount:      it.Discount,
+			Tax:           it.Tax,
+			Total:         it.Total,
+		})
+		if err != nil {
+			return fmt.Errorf("credit note items: %w", err)
+		}
+		it.ID = itemID
+		it.CreditNoteID = id
+	}
+	e, err := creditNoteEntry(cn, invoiceRate(header.Currency, header.BaseCurrency, header.ExchangeRate.Int64))
+	if err == nil {
+		err = ledger.Post(ctx, q, e)
+	}
+	if err != nil {
+		return fmt.Errorf("credit note ledger: %w", err)
+	}
+
+	paid, err := q.PaymentAllocatedToInvoice(ctx, header.ID)
+	if err != nil {
+		return err
+	}
+	inv := payable{
+		ID:       header.ID,
+		Status:   status,
+		Total:    header.Total,
+		Credited: creditedTotal + cn.Total,
+		Paid:     paid,
+	}
+	if to := statusAfterPayment(inv); to != status {
+		_, err := setStatus(ctx, q, header.ID, status, to, StatusChange{
+			By:        cn.By,
+			Reason:    "credit note " + cn.Number,
+			ChangedAt: cn.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return ledger.Err(tx.Commit(ctx))
+}
+
+// CreditNoteByID get a CreditNote, with its items, by its ID.
+func (r *Repo) CreditNoteByID(ctx context.Context, id int64) (*CreditNote, error) {
+	row, err := r.q.CreditNoteByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCreditNoteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	items, err := r.q.CreditNoteItemByCreditNote(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	cn := toDomainCreditNote(row)
+	cn.Items = toDomainCreditNoteItems(items, cn.Currency)
+	return cn, nil
+}
+
+// CreditNotesByInvoice returns the credit notes of an invoice, without
+// their items, oldest first.
+func (r *Repo) CreditNotesByInvoice(ctx context.Context, invoiceID int64) ([]CreditNote, error) {
+	if _, err := r.q.InvoiceHeaderByID(ctx, invoiceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvoiceHeaderNotFound
+		}
+		return nil, err
+	}
+	rows, err := r.q.CreditNoteByInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	cns := make([]CreditNote, 0, len(rows))
+	for _, row := range rows {
+		cns = append(cns, *toDomainCreditNote(row))
+	}
+	return cns, nil
+}
+
+// DeleteAllCreditNotes deletes all credit notes and their items
+// (permanently).
+func (r *Repo) DeleteAllCreditNotes(ctx context.Context) error {
+	err := r.q.CreditNoteDeleteAll(ctx)
+	if err != nil {
+		return fmt.Errorf("can't truncate table: %v", err)
+	}
+	return nil
+}
+
+// toDomainCreditNote converts a dbgen.CreditNote to a CreditNote.
+func toDomainCreditNote(row dbgen.CreditNote) *CreditNote {
+	return &CreditNote{
+		ID:        row.ID,
+		UUID:      row.Uuid.String(),
+		Number:    row.Number,
+		InvoiceID: row.InvoiceHeaderID,
+		ClientID:  row.ClientID,
+		Reason:    row.Reason,
+		Currency:  money.Currency(row.Currency),
+		Totals: Totals{
+			Subtotal: row.Subtotal,
+			Discount: row.Discount,
+			Tax:      row.Tax,
+			Total:    row.Total,
+		},
+		By: Actor{
+			UserID:           row.CreatedByUserID.Int64,
+			ServiceAccountID: row.CreatedByServiceAccountID.Int64,
+		},
+		CreatedAt: row.CreatedAt,
+	}
+}
+
+// toDomainCreditNoteItems converts dbgen.CreditNoteItem rows, of a credit
+// note in currency, to CreditNoteItems.
+func toDomainCreditNoteItems(rows []dbgen.CreditNoteItem, currency money.Currency) CreditNoteItems {
+	items := make(CreditNoteItems, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, CreditNoteItem{
+			ID:            row.ID,
+			CreditNoteID:  row.CreditNoteID,
+			InvoiceItemID: row.InvoiceItemID,
+			ProductID:     row.ProductID,
+			ProductName:   row.ProductName,
+			Quantity:      int(row.Quantity),
+			UnitPrice:     money.New(row.UnitPrice, currency),
+			TaxRate:       int(row.TaxRate),
+			Totals: Totals{
+				Subtotal: row.Subtotal,
+				Discount: row.Discount,
+				Tax:      row.Tax,
+				Total:    row.Total,
+			},
+		})
+	}
+	return items
+}
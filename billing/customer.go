@@ -0,0 +1,46 @@
+package billing
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrCustomerNotFound = errors.New("customer not found")
+	ErrCustomerDeleted  = errors.New("the customer was deleted, it can't be billed")
+
+	// ErrClientIDCantBeEmpty the client of an invoice, a subscription or
+	// payment terms is missing.
+	ErrClientIDCantBeEmpty = errors.New("client id can't be empty")
+)
+
+// Customer the client of the invoices, as billing reads it.
+type Customer struct {
+	ID      int64
+	Name    string // billing name
+	Email   string
+	Address string
+	Deleted bool
+}
+
+// Customers reads the clients of the invoices, billing doesn't own them.
+// Customer returns ErrCustomerNotFound if there is none with the id.
+type Customers interface {
+	Customer(ctx context.Context, id int64) (*Customer, error)
+}
+
+// BillTo the client an invoice is billed to, copied from the customer when
+// it's generated.
+type BillTo struct {
+	Name    string
+	Email   string
+	Address string
+}
+
+// billable returns who a new invoice of c is billed to.
+func (c Customer) billable() (BillTo, error) {
+	if c.Deleted {
+		return BillTo{}, ErrCustomerDeleted
+	}
+	return BillTo{Name: c.Name, Email: c.Email, Address: c.Address}, nil
+}
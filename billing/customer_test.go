@@ -0,0 +1,21 @@
+package billing
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCustomerBillable(t *testing.T) {
+	c := Customer{ID: 1, Name: "Acme S.A. de C.V.", Email: "billing@acme.mx", Address: "Av. Reforma 1"}
+	to, err := c.billable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (BillTo{Name: c.Name, Email: c.Email, Address: c.Address}); to != want {
+		t.Errorf("want %+v, got %+v", want, to)
+	}
+	c.Deleted = true
+	if _, err := c.billable(); !errors.Is(err, ErrCustomerDeleted) {
+		t.Errorf("want error %v, got %v", ErrCustomerDeleted, err)
+	}
+}
@@ -0,0 +1,49 @@
+package billing
+
+import (
+	"context"
+	"time"
+
+	"github.com/adrianolmedo/genesis/logger"
+)
+
+// Dunner queues the reminders of the overdue invoices and sends them
+// through Notifier in the background of the process. Every replica can run
+// one, a reminder is queued once and locked while sent.
+type Dunner struct {
+	Service  *Service
+	Notifier Notifier
+	Interval time.Duration
+}
+
+// Run dunning at once and then every Interval, until ctx is done.
+func (d Dunner) Run(ctx context.Context) {
+	d.dun(ctx, time.Now())
+	t := time.NewTicker(d.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			d.dun(ctx, now)
+		}
+	}
+}
+
+func (d Dunner) dun(ctx context.Context, now time.Time) {
+	n, err := d.Service.QueueReminders(ctx, now)
+	if n > 0 {
+		logger.Info("reminders queued", "count", n)
+	}
+	if err != nil {
+		logger.Error("queueing reminders failed", "err", err.Error())
+	}
+	sent, err := d.Service.SendReminders(ctx, now, d.Notifier)
+	for _, r := range sent {
+		logger.Info("reminder sent", "invoice", r.InvoiceID, "daysAfterDue", r.DaysAfterDue, "feeInvoice", r.FeeInvoiceID)
+	}
+	if err != nil {
+		logger.Error("sending reminders failed", "err", err.Error())
+	}
+}
@@ -0,0 +1,207 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+)
+
+var (
+	ErrPaymentTermsNotFound  = errors.New("payment terms not found")
+	ErrInvalidNetDays        = errors.New("payment terms must be between 0 and 365 days")
+	ErrDunningStepNotFound   = errors.New("dunning step not found")
+	ErrDunningStepExists     = errors.New("a dunning step with the same days after due exists")
+	ErrInvalidDaysAfterDue   = errors.New("days after due can't be negative")
+	ErrInvalidLateFee        = errors.New("late fee can't be negative")
+	ErrInvalidLateFeeRate    = errors.New("late fee rate must be between 0 and 10000 basis points")
+	ErrFeeProductCantBeEmpty = errors.New("a step with a late fee needs the product to invoice it")
+	ErrFeeProductNotFound    = errors.New("product of the late fee not found")
+)
+
+// maxNetDays is a year.
+const maxNetDays = 365
+
+// PaymentTerms of a client, its invoices are due NetDays after their date.
+type PaymentTerms struct {
+	ClientID  int64
+	NetDays   int
+	UpdatedAt time.Time
+}
+
+func (t PaymentTerms) validate() error {
+	if t.ClientID == 0 {
+		return ErrClientIDCantBeEmpty
+	}
+	if t.NetDays < 0 || t.NetDays > maxNetDays {
+		return ErrInvalidNetDays
+	}
+	return nil
+}
+
+// DunningStep a step of the dunning policy, a reminder of the invoices
+// unpaid DaysAfterDue after their due date. The late fee, LateFee plus
+// LateFeeRate of the amount due, is invoiced with the product FeeProductID.
+// LateFee is in the base currency, it's converted to the currency of the
+// invoice at its exchange rate.
+type DunningStep struct {
+	ID           int64
+	DaysAfterDue int
+	LateFee      int64
+	LateFeeRate  int // basis points, 150 = 1.5%
+	FeeProductID int64
+	CreatedAt    time.Time
+}
+
+func (s DunningStep) validate() error {
+	if s.DaysAfterDue < 0 || s.DaysAfterDue > math.MaxInt32 {
+		return ErrInvalidDaysAfterDue
+	}
+	if s.LateFee < 0 {
+		return ErrInvalidLateFee
+	}
+	if s.LateFeeRate < 0 || s.LateFeeRate > MaxTaxRate {
+		return ErrInvalidLateFeeRate
+	}
+	if (s.LateFee > 0 || s.LateFeeRate > 0) && s.FeeProductID == 0 {
+		return ErrFeeProductCantBeEmpty
+	}
+	return nil
+}
+
+// lateFee returns the late fee of the step for the amount due of an
+// invoice of the exchange rate to the base currency, the rate rounded half
+// up.
+func (s DunningStep) lateFee(due int64, rate money.Rate) (int64, error) {
+	if due > 0 && int64(s.LateFeeRate) > math.MaxInt64/due {
+		return 0, ErrAmountOverflow
+	}
+	fee, err := roundHalfUp(due*int64(s.LateFeeRate), MaxTaxRate)
+	if err != nil {
+		return 0, err
+	}
+	fixed := s.LateFee
+	if fixed > 0 {
+		inverse, err := rate.Invert()
+		if err != nil {
+			return 0, err
+		}
+		m, err := inverse.Convert(money.New(fixed, rate.To))
+		if err != nil {
+			return 0, err
+		}
+		fixed = m.Amount
+	}
+	return addAmount(fee, fixed)
+}
+
+// ReminderStatus of a reminder, pending until it's sent.
+type ReminderStatus string
+
+const (
+	ReminderPending  ReminderStatus = "pending"
+	ReminderSent     ReminderStatus = "sent"
+	ReminderFailed   ReminderStatus = "failed"   // after maxReminderAttempts
+	ReminderCanceled ReminderStatus = "canceled" // the invoice was settled first
+)
+
+// maxReminderAttempts is how many times a reminder is tried to be sent.
+const maxReminderAttempts = 5
+
+// Reminder of an invoice overdue, of a step of the dunning policy. The
+// amount due and the late fee are those when it was queued.
+type Reminder struct {
+	ID            int64
+	InvoiceID     int64
+	InvoiceNumber string
+	ClientID      int64
+	DueAt         time.Time
+	DaysAfterDue  int
+	Currency      money.Currency // of the invoice, and of the amounts
+	AmountDue     int64
+	LateFee       int64
+	FeeProductID  int64
+
+	// FeeInvoiceID is the invoice of the late fee, 0 until it's sent.
+	FeeInvoiceID     int64
+	FeeInvoiceNumber string
+
+	Status    ReminderStatus
+	Attempts  int
+	LastError string
+	CreatedAt time.Time
+	SentAt    *time.Time
+
+	// Read with the reminder to invoice the late fee.
+	Jurisdiction   string // of the invoice
+	FeeProductName string
+	FeeTaxCategory string
+}
+
+// Notifier sends the reminders to the clients, e.g. by email. A reminder
+// it fails to send is tried again on the next run.
+type Notifier interface {
+	Remind(ctx context.Context, r Reminder) error
+}
+
+// overdue an invoice that reached a step of the dunning policy.
+type overdue struct {
+	invoice payable
+	key     string // idempotency key of the invoice
+	step    DunningStep
+}
+
+// feeKeyPrefix starts the idempotency key of the late fee invoices.
+const feeKeyPrefix = "dunning:"
+
+// reminder returns the reminder of o queued at now, nil if the invoice
+// doesn't owe anything. The late fee invoices aren't charged late fees.
+func (o overdue) reminder(now time.Time) (*Reminder, error) {
+	due := o.invoice.Due()
+	if due <= 0 {
+		return nil, nil
+	}
+	r := &Reminder{
+		InvoiceID:    o.invoice.ID,
+		ClientID:     o.invoice.ClientID,
+		DaysAfterDue: o.step.DaysAfterDue,
+		Currency:     o.invoice.Currency,
+		AmountDue:    due,
+		Status:       ReminderPending,
+		CreatedAt:    now,
+	}
+	if strings.HasPrefix(o.key, feeKeyPrefix) {
+		return r, nil
+	}
+	fee, err := o.step.lateFee(due, o.invoice.Rate)
+	if err != nil {
+		return nil, err
+	}
+	if fee > 0 {
+		r.LateFee, r.FeeProductID = fee, o.step.FeeProductID
+	}
+	return r, nil
+}
+
+// feeInvoice returns the invoice of the late fee of r, generated once.
+func feeInvoice(r Reminder) *Invoice {
+	return &Invoice{
+		Header: &InvoiceHeader{
+			ClientID:       r.ClientID,
+			Jurisdiction:   r.Jurisdiction,
+			Currency:       r.Currency,
+			IdempotencyKey: fmt.Sprintf("%s%d:%d", feeKeyPrefix, r.InvoiceID, r.DaysAfterDue),
+		},
+		Items: ItemList{{
+			ProductID:   r.FeeProductID,
+			ProductName: fmt.Sprintf("%s, invoice %s", r.FeeProductName, r.InvoiceNumber),
+			Quantity:    1,
+			UnitPrice:   money.New(r.LateFee, r.Currency),
+			TaxCategory: r.FeeTaxCategory,
+		}},
+	}
+}
@@ -0,0 +1,175 @@
+package billing
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+)
+
+func TestDunningStepValidate(t *testing.T) {
+	tt := []struct {
+		name    string
+		step    DunningStep
+		wantErr error
+	}{
+		{"reminder-only", DunningStep{DaysAfterDue: 3}, nil},
+		{"on-due-date", DunningStep{}, nil},
+		{"with-fee", DunningStep{DaysAfterDue: 14, LateFee: 500, FeeProductID: 1}, nil},
+		{"with-rate", DunningStep{DaysAfterDue: 30, LateFeeRate: 150, FeeProductID: 1}, nil},
+		{"negative-days", DunningStep{DaysAfterDue: -1}, ErrInvalidDaysAfterDue},
+		{"negative-fee", DunningStep{LateFee: -1, FeeProductID: 1}, ErrInvalidLateFee},
+		{"rate-over-100", DunningStep{LateFeeRate: MaxTaxRate + 1, FeeProductID: 1}, ErrInvalidLateFeeRate},
+		{"fee-no-product", DunningStep{LateFee: 500}, ErrFeeProductCantBeEmpty},
+		{"rate-no-product", DunningStep{LateFeeRate: 150}, ErrFeeProductCantBeEmpty},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.step.validate(); !errors.Is(err, tc.wantErr) {
+				t.Errorf("want %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLateFee(t *testing.T) {
+	tt := []struct {
+		name    string
+		step    DunningStep
+		due     int64
+		want    int64
+		wantErr error
+	}{
+		{"fixed", DunningStep{LateFee: 500}, 10000, 500, nil},
+		{"rate", DunningStep{LateFeeRate: 150}, 10000, 150, nil},
+		{"rate-half-up", DunningStep{LateFeeRate: 150}, 1033, 15, nil}, // 15.495
+		{"rate-half", DunningStep{LateFeeRate: 5000}, 1, 1, nil},       // 0.5
+		{"fixed-and-rate", DunningStep{LateFee: 500, LateFeeRate: 100}, 25000, 750, nil},
+		{"none", DunningStep{}, 10000, 0, nil},
+		{"overflow", DunningStep{LateFeeRate: 100}, math.MaxInt64 / 10, 0, ErrAmountOverflow},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.step.lateFee(tc.due, money.Identity("EUR"))
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want %v, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("want %d, got %d", tc.want, got)
+			}
+		})
+	}
+
+	// The fixed fee is in the base currency, 5.00 EUR of an invoice in USD
+	// at 0.80 EUR is 6.25 USD.
+	usd := money.Rate{From: "USD", To: "EUR", Value: 80_000_000}
+	got, err := DunningStep{LateFee: 500, LateFeeRate: 100}.lateFee(10000, usd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 725 {
+		t.Errorf("want 725, got %d", got)
+	}
+}
+
+func TestOverdueReminder(t *testing.T) {
+	now := time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)
+	step := DunningStep{DaysAfterDue: 14, LateFee: 500, FeeProductID: 9}
+
+	t.Run("with-fee", func(t *testing.T) {
+		o := overdue{invoice: payable{ID: 1, ClientID: 2, Currency: "EUR", Rate: money.Identity("EUR"), Total: 12000, Credited: 1000, Paid: 1000}, step: step}
+		r, err := o.reminder(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.AmountDue != 10000 || r.LateFee != 500 || r.FeeProductID != 9 || r.Currency != "EUR" {
+			t.Errorf("want 10000 due and a fee of 500 with the product 9, got %+v", r)
+		}
+		if r.InvoiceID != 1 || r.ClientID != 2 || r.DaysAfterDue != 14 || r.Status != ReminderPending || !r.CreatedAt.Equal(now) {
+			t.Errorf("unexpected reminder %+v", r)
+		}
+	})
+
+	t.Run("settled", func(t *testing.T) {
+		o := overdue{invoice: payable{ID: 1, Total: 12000, Paid: 12000}, step: step}
+		r, err := o.reminder(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r != nil {
+			t.Errorf("want no reminder of a settled invoice, got %+v", r)
+		}
+	})
+
+	t.Run("no-fee", func(t *testing.T) {
+		o := overdue{invoice: payable{ID: 1, Total: 12000}, step: DunningStep{DaysAfterDue: 3}}
+		r, err := o.reminder(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.LateFee != 0 || r.FeeProductID != 0 {
+			t.Errorf("want no fee, got %+v", r)
+		}
+	})
+
+	t.Run("fee-invoice", func(t *testing.T) {
+		o := overdue{invoice: payable{ID: 5, Total: 500}, key: "dunning:1:14", step: step}
+		r, err := o.reminder(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.AmountDue != 500 || r.LateFee != 0 || r.FeeProductID != 0 {
+			t.Errorf("want a reminder of a late fee invoice without fee, got %+v", r)
+		}
+	})
+}
+
+func TestFeeInvoice(t *testing.T) {
+	inv := feeInvoice(Reminder{
+		InvoiceID:      1,
+		InvoiceNumber:  "INV-000001",
+		ClientID:       2,
+		DaysAfterDue:   14,
+		Currency:       "EUR",
+		LateFee:        500,
+		FeeProductID:   9,
+		Jurisdiction:   "MX",
+		FeeProductName: "Late fee",
+		FeeTaxCategory: "exempt",
+	})
+	h := inv.Header
+	if h.ClientID != 2 || h.Jurisdiction != "MX" || h.Currency != "EUR" || h.IdempotencyKey != "dunning:1:14" {
+		t.Errorf("unexpected header %+v", h)
+	}
+	if len(inv.Items) != 1 {
+		t.Fatalf("want 1 item, got %d", len(inv.Items))
+	}
+	it := inv.Items[0]
+	if it.ProductID != 9 || it.ProductName != "Late fee, invoice INV-000001" || it.Quantity != 1 || it.UnitPrice != money.New(500, "EUR") || it.TaxCategory != "exempt" {
+		t.Errorf("unexpected item %+v", it)
+	}
+}
+
+func TestPaymentTermsValidate(t *testing.T) {
+	tt := []struct {
+		name    string
+		terms   PaymentTerms
+		wantErr error
+	}{
+		{"net-30", PaymentTerms{ClientID: 1, NetDays: 30}, nil},
+		{"on-receipt", PaymentTerms{ClientID: 1}, nil},
+		{"a-year", PaymentTerms{ClientID: 1, NetDays: maxNetDays}, nil},
+		{"no-client", PaymentTerms{NetDays: 30}, ErrClientIDCantBeEmpty},
+		{"negative", PaymentTerms{ClientID: 1, NetDays: -1}, ErrInvalidNetDays},
+		{"over-a-year", PaymentTerms{ClientID: 1, NetDays: maxNetDays + 1}, ErrInvalidNetDays},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.terms.validate(); !errors.Is(err, tc.wantErr) {
+				t.Errorf("want %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
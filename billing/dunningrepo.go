@@ -0,0 +1,286 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SetPaymentTerms creates or replaces the payment terms of a client.
+func (r *Repo) SetPaymentTerms(ctx context.Context, t *PaymentTerms) error {
+	row, err := r.q.PaymentTermsUpsert(ctx, dbgen.PaymentTermsUpsertParams{
+		ClientID:  t.ClientID,
+		NetDays:   int32(t.NetDays),
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	t.UpdatedAt = row.UpdatedAt
+	return nil
+}
+
+// PaymentTerms returns the payment terms of a client.
+func (r *Repo) PaymentTerms(ctx context.Context, clientID int64) (*PaymentTerms, error) {
+	row, err := r.q.PaymentTermsByClient(ctx, clientID)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPaymentTermsNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &PaymentTerms{ClientID: row.ClientID, NetDays: int(row.NetDays), UpdatedAt: row.UpdatedAt}, nil
+}
+
+// CreateDunningStep creates a step of the dunning policy, one per days
+// after due.
+func (r *Repo) CreateDunningStep(ctx context.Context, s *DunningStep) error {
+	row, err := r.q.DunningStepCreate(ctx, dbgen.DunningStepCreateParams{
+		DaysAfterDue: int32(s.DaysAfterDue),
+		LateFee:      s.LateFee,
+		LateFeeRate:  int32(s.LateFeeRate),
+		FeeProductID: nullID(s.FeeProductID),
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case uniqueViolation:
+			return ErrDunningStepExists
+		case foreignKeyViolation:
+			return ErrFeeProductNotFound
+		}
+	}
+	if err != nil {
+		return err
+	}
+	s.ID = row.ID
+	s.CreatedAt = row.CreatedAt
+	return nil
+}
+
+// DunningSteps returns the steps of the dunning policy, by days after due.
+func (r *Repo) DunningSteps(ctx context.Context) ([]DunningStep, error) {
+	rows, err := r.q.DunningStepAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]DunningStep, 0, len(rows))
+	for _, row := range rows {
+		steps = append(steps, DunningStep{
+			ID:           row.ID,
+			DaysAfterDue: int(row.DaysAfterDue),
+			LateFee:      row.LateFee,
+			LateFeeRate:  int(row.LateFeeRate),
+			FeeProductID: row.FeeProductID.Int64,
+			CreatedAt:    row.CreatedAt,
+		})
+	}
+	return steps, nil
+}
+
+// DeleteDunningStep deletes a step of the dunning policy, the reminders
+// queued of it are kept.
+func (r *Repo) DeleteDunningStep(ctx context.Context, id int64) error {
+	n, err := r.q.DunningStepDelete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDunningStepNotFound
+	}
+	return nil
+}
+
+// Overdue returns up to limit invoices overdue at now with the last step
+// of the dunning policy they reached, not reminded yet.
+func (r *Repo) Overdue(ctx context.Context, now time.Time, limit int) ([]overdue, error) {
+	rows, err := r.q.DunningOverdue(ctx, dbgen.DunningOverdueParams{Now: now, Batch: int32(limit)})
+	if err != nil {
+		return nil, err
+	}
+	list := make([]overdue, 0, len(rows))
+	for _, row := range rows {
+		list = append(list, overdue{
+			invoice: payable{
+				ID:       row.ID,
+				ClientID: row.ClientID,
+				Currency: money.Currency(row.Currency),
+				Rate:     invoiceRate(row.Currency, row.BaseCurrency, row.ExchangeRate.Int64),
+				Total:    row.Total,
+				Credited: row.Credited,
+				Paid:     row.Paid,
+			},
+			key: row.IdempotencyKey.String,
+			step: DunningStep{
+				DaysAfterDue: int(row.DaysAfterDue),
+				LateFee:      row.LateFee,
+				LateFeeRate:  int(row.LateFeeRate),
+				FeeProductID: row.FeeProductID.Int64,
+			},
+		})
+	}
+	return list, nil
+}
+
+// QueueReminder queues a reminder, once per invoice and step even if
+// several replicas queue it at the same time. It reports whether it was
+// queued.
+func (r *Repo) QueueReminder(ctx context.Context, rem *Reminder) (bool, error) {
+	id, err := r.q.DunningReminderCreate(ctx, dbgen.DunningReminderCreateParams{
+		InvoiceHeaderID: rem.InvoiceID,
+		DaysAfterDue:    int32(rem.DaysAfterDue),
+		AmountDue:       rem.AmountDue,
+		LateFee:         rem.LateFee,
+		FeeProductID:    nullID(rem.FeeProductID),
+		CreatedAt:       rem.CreatedAt,
+	})
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	rem.ID = id
+	return true, nil
+}
+
+// PendingReminders returns the IDs of up to limit reminders to send,
+// oldest first.
+func (r *Repo) PendingReminders(ctx context.Context, limit int) ([]int64, error) {
+	return r.q.DunningReminderPending(ctx, int32(limit))
+}
+
+// sendFunc sends a reminder, it may set the late fee invoice of it.
+type sendFunc func(rem *Reminder) error
+
+// SendReminder sends the pending reminder id with send, with the reminder
+// locked so no other replica sends it at the same time. A reminder of an
+// invoice that isn't owed anymore is canceled instead. It returns nil if
+// the reminder isn't pending or it's locked, else the reminder updated and
+// the error of send, the reminder fails after maxReminderAttempts.
+func (r *Repo) SendReminder(ctx context.Context, id int64, now time.Time, send sendFunc) (*Reminder, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+	q := r.q.WithTx(tx)
+
+	row, err := q.DunningReminderForUpdate(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rem := toDomainPendingReminder(row)
+	var sendErr error
+	switch Status(row.InvoiceStatus) {
+	case StatusIssued, StatusPartiallyPaid:
+		sendErr = send(&rem)
+		rem.Attempts++
+		switch {
+		case sendErr == nil:
+			rem.Status, rem.LastError, rem.SentAt = ReminderSent, "", &now
+		case rem.Attempts >= maxReminderAttempts:
+			rem.Status, rem.LastError = ReminderFailed, sendErr.Error()
+		default:
+			rem.LastError = sendErr.Error()
+		}
+	default:
+		rem.Status = ReminderCanceled
+	}
+	err = q.DunningReminderUpdate(ctx, dbgen.DunningReminderUpdateParams{
+		ID:           rem.ID,
+		Status:       string(rem.Status),
+		Attempts:     int32(rem.Attempts),
+		LastError:    rem.LastError,
+		FeeInvoiceID: nullID(rem.FeeInvoiceID),
+		SentAt:       nullTime(rem.SentAt),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &rem, sendErr
+}
+
+// Reminders returns the reminders of an invoice, by days after due.
+func (r *Repo) Reminders(ctx context.Context, invoiceID int64) ([]Reminder, error) {
+	rows, err := r.q.DunningReminderByInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Reminder, 0, len(rows))
+	for _, row := range rows {
+		list = append(list, toDomainReminder(row))
+	}
+	return list, nil
+}
+
+// DeleteAllDunning deletes the reminders, the dunning policy and the
+// payment terms. This is used for testing purposes to reset the state of
+// the dunning tables.
+func (r *Repo) DeleteAllDunning(ctx context.Context) error {
+	err := r.q.DunningDeleteAll(ctx)
+	if err != nil {
+		return fmt.Errorf("can't truncate table: %v", err)
+	}
+	return nil
+}
+
+// toDomainReminder converts a dbgen.DunningReminder row to a Reminder.
+func toDomainReminder(row dbgen.DunningReminder) Reminder {
+	return Reminder{
+		ID:           row.ID,
+		InvoiceID:    row.InvoiceHeaderID,
+		DaysAfterDue: int(row.DaysAfterDue),
+		AmountDue:    row.AmountDue,
+		LateFee:      row.LateFee,
+		FeeProductID: row.FeeProductID.Int64,
+		FeeInvoiceID: row.FeeInvoiceID.Int64,
+		Status:       ReminderStatus(row.Status),
+		Attempts:     int(row.Attempts),
+		LastError:    row.LastError,
+		CreatedAt:    row.CreatedAt,
+		SentAt:       timePtr(row.SentAt),
+	}
+}
+
+// toDomainPendingReminder converts a reminder locked to send, with its
+// invoice and the product of its late fee, to a Reminder.
+func toDomainPendingReminder(row dbgen.DunningReminderForUpdateRow) Reminder {
+	rem := toDomainReminder(dbgen.DunningReminder{
+		ID:              row.ID,
+		InvoiceHeaderID: row.InvoiceHeaderID,
+		DaysAfterDue:    row.DaysAfterDue,
+		AmountDue:       row.AmountDue,
+		LateFee:         row.LateFee,
+		FeeProductID:    row.FeeProductID,
+		FeeInvoiceID:    row.FeeInvoiceID,
+		Status:          row.Status,
+		Attempts:        row.Attempts,
+		LastError:       row.LastError,
+		CreatedAt:       row.CreatedAt,
+		SentAt:          row.SentAt,
+	})
+	rem.InvoiceNumber = row.Number
+	rem.ClientID = row.ClientID
+	rem.DueAt = row.DueAt.Time
+	rem.Currency = money.Currency(row.Currency)
+	rem.FeeInvoiceNumber = row.FeeInvoiceNumber
+	rem.Jurisdiction = row.Jurisdiction
+	rem.FeeProductName = row.FeeProductName
+	rem.FeeTaxCategory = row.FeeTaxCategory
+	return rem
+}
@@ -0,0 +1,51 @@
+package billing
+
+import (
+	"errors"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+)
+
+var (
+	ErrExchangeRateNotFound = errors.New("no exchange rate of the currency of the invoice to the base currency at the date")
+	ErrExchangeRateExists   = errors.New("a rate of the currencies from the same date exists")
+	ErrSameCurrency         = errors.New("an exchange rate must be of two different currencies")
+	ErrValidFromCantBeEmpty = errors.New("exchange rate valid from date can't be empty")
+)
+
+// ExchangeRate of a currency to another one from a date, until the next
+// rate of the pair. The rates are managed locally, an invoice is converted
+// to its base currency at the rate valid when it's issued. A rate is also
+// read inverted, of To to From.
+type ExchangeRate struct {
+	ID        int64
+	Rate      money.Rate
+	ValidFrom time.Time
+	CreatedAt time.Time
+}
+
+// validate checks the fields of a new rate.
+func (r ExchangeRate) validate() error {
+	switch {
+	case !r.Rate.From.Valid() || !r.Rate.To.Valid():
+		return money.ErrUnknownCurrency
+	case r.Rate.From == r.Rate.To:
+		return ErrSameCurrency
+	case r.Rate.Value <= 0:
+		return money.ErrInvalidRate
+	case r.ValidFrom.IsZero():
+		return ErrValidFromCantBeEmpty
+	}
+	return nil
+}
+
+// invoiceRate returns the rate of an invoice in currency to its base
+// currency, of the rate value captured when it was issued. The rate of an
+// invoice not issued is zero.
+func invoiceRate(currency, base string, value int64) money.Rate {
+	if value == 0 {
+		return money.Rate{}
+	}
+	return money.Rate{From: money.Currency(currency), To: money.Currency(base), Value: value}
+}
@@ -0,0 +1,41 @@
+package billing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+)
+
+func TestExchangeRateValidate(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tt := []struct {
+		name    string
+		rate    ExchangeRate
+		wantErr error
+	}{
+		{"valid", ExchangeRate{Rate: money.Rate{From: "EUR", To: "USD", Value: 108_250_000}, ValidFrom: day}, nil},
+		{"unknown-currency", ExchangeRate{Rate: money.Rate{From: "EUR", To: "XYZ", Value: 1}, ValidFrom: day}, money.ErrUnknownCurrency},
+		{"same-currency", ExchangeRate{Rate: money.Rate{From: "EUR", To: "EUR", Value: 1}, ValidFrom: day}, ErrSameCurrency},
+		{"zero-rate", ExchangeRate{Rate: money.Rate{From: "EUR", To: "USD"}, ValidFrom: day}, money.ErrInvalidRate},
+		{"no-date", ExchangeRate{Rate: money.Rate{From: "EUR", To: "USD", Value: 1}}, ErrValidFromCantBeEmpty},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.rate.validate(); !errors.Is(err, tc.wantErr) {
+				t.Errorf("want %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestInvoiceRate(t *testing.T) {
+	if r := invoiceRate("USD", "EUR", 0); !r.IsZero() {
+		t.Errorf("want no rate of an invoice not issued, got %+v", r)
+	}
+	r := invoiceRate("USD", "EUR", 92_345_000)
+	if r.From != "USD" || r.To != "EUR" || r.Decimal() != "0.92345000" {
+		t.Errorf("want USD to EUR at 0.92345000, got %+v", r)
+	}
+}
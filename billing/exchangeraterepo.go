@@ -0,0 +1,111 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// CreateExchangeRate creates an exchange rate, the database rejects
+// another one of the pair from the same date.
+func (r *Repo) CreateExchangeRate(ctx context.Context, e *ExchangeRate) error {
+	row, err := r.q.ExchangeRateCreate(ctx, dbgen.ExchangeRateCreateParams{
+		FromCurrency: string(e.Rate.From),
+		ToCurrency:   string(e.Rate.To),
+		Rate:         e.Rate.Value,
+		ValidFrom:    e.ValidFrom,
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return ErrExchangeRateExists
+	}
+	if err != nil {
+		return err
+	}
+	e.ID = row.ID
+	e.CreatedAt = row.CreatedAt
+	return nil
+}
+
+// ExchangeRates returns all the exchange rates, the latest of each pair
+// first.
+func (r *Repo) ExchangeRates(ctx context.Context) ([]ExchangeRate, error) {
+	rows, err := r.q.ExchangeRateAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rates := make([]ExchangeRate, 0, len(rows))
+	for _, row := range rows {
+		rates = append(rates, toDomainExchangeRate(row))
+	}
+	return rates, nil
+}
+
+// DeleteAllExchangeRates deletes all the exchange rates (permanently).
+func (r *Repo) DeleteAllExchangeRates(ctx context.Context) error {
+	err := r.q.ExchangeRateDeleteAll(ctx)
+	if err != nil {
+		return fmt.Errorf("can't truncate table: %v", err)
+	}
+	return nil
+}
+
+// exchangeRate returns the rate of from to to valid at a time, 1 if they
+// are the same currency. A rate of the inverse pair is inverted.
+func exchangeRate(ctx context.Context, q *dbgen.Queries, from, to money.Currency, at time.Time) (money.Rate, error) {
+	if from == to {
+		return money.Identity(from), nil
+	}
+	row, err := q.ExchangeRateAt(ctx, dbgen.ExchangeRateAtParams{
+		FromCurrency: string(from),
+		ToCurrency:   string(to),
+		At:           at,
+	})
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return money.Rate{}, fmt.Errorf("%w: %s to %s", ErrExchangeRateNotFound, from, to)
+	}
+	if err != nil {
+		return money.Rate{}, err
+	}
+	rate := toDomainExchangeRate(row).Rate
+	if rate.From != from {
+		return rate.Invert()
+	}
+	return rate, nil
+}
+
+// captureRate sets the exchange rate of the draft invoice of row to its
+// base currency, the one valid at a time.
+func captureRate(ctx context.Context, q *dbgen.Queries, row dbgen.InvoiceHeader, at time.Time) error {
+	rate, err := exchangeRate(ctx, q, money.Currency(row.Currency), money.Currency(row.BaseCurrency), at)
+	if err != nil {
+		return err
+	}
+	return q.InvoiceHeaderSetExchangeRate(ctx, dbgen.InvoiceHeaderSetExchangeRateParams{
+		ExchangeRate: pgtype.Int8{Int64: rate.Value, Valid: true},
+		ID:           row.ID,
+	})
+}
+
+// toDomainExchangeRate converts a dbgen.ExchangeRate to an ExchangeRate.
+func toDomainExchangeRate(row dbgen.ExchangeRate) ExchangeRate {
+	return ExchangeRate{
+		ID: row.ID,
+		Rate: money.Rate{
+			From:  money.Currency(row.FromCurrency),
+			To:    money.Currency(row.ToCurrency),
+			Value: row.Rate,
+		},
+		ValidFrom: row.ValidFrom,
+		CreatedAt: row.CreatedAt,
+	}
+}
@@ -2,7 +2,11 @@ package billing
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"time"
+
+	"github.com/adrianolmedo/genesis/money"
 )
 
 // ErrInvoiceHeaderNotFound a header related with a invoice it's not found.
@@ -14,27 +18,116 @@ var ErrInvoiceItemNotFound = errors.New("invoice item not found")
 // ErrItemListCantBeEmpty indicate that an invoice must have items.
 var ErrItemListCantBeEmpty = errors.New("item list can't be empty")
 
+// ErrInvoiceExists an invoice with the same idempotency key was generated.
+var ErrInvoiceExists = errors.New("an invoice with the same idempotency key exists")
+
+var (
+	ErrInvalidSort      = errors.New("invoices can't be sorted by that field")
+	ErrInvalidDateRange = errors.New("the start of the date range must be before its end")
+	ErrInvalidQuantity  = errors.New("quantity must be greater than zero")
+	ErrInvalidUnitPrice = errors.New("unit price can't be negative")
+	ErrInvalidDiscount  = errors.New("discount must be between zero and the subtotal of the item")
+	ErrInvalidTaxRate   = errors.New("tax rate must be between 0 and 10000 basis points")
+	ErrAmountOverflow   = errors.New("amount too large")
+	ErrCurrencyMismatch = errors.New("the prices of the items must be in the currency of the invoice")
+)
+
+// MaxTaxRate is 100% in basis points.
+const MaxTaxRate = 10000
+
+// maxQuantity fits the quantity column.
+const maxQuantity = math.MaxInt32
+
 // Invoice aggregate, represents an invoice with its header and items.
 type Invoice struct {
 	Header *InvoiceHeader
 	Items  ItemList
+	Taxes  []TaxLine // breakdown by category and rate
 }
 
-// InvoiceHeader entity model.
+// Invoices collection of Invoice.
+type Invoices []Invoice
+
+// IsEmpty return true if is empty.
+func (is Invoices) IsEmpty() bool {
+	return len(is) == 0
+}
+
+// ListFilter narrows the invoices listed, its zero fields don't filter.
+type ListFilter struct {
+	ClientID int64
+	Status   Status
+	From     time.Time // created at or after
+	To       time.Time // created before
+}
+
+// sortFields the invoices can be listed by.
+var sortFields = []string{"id", "client_id", "created_at", "total"}
+
+// Totals amounts of an invoice or of one of its items, in minor units of
+// the currency of the invoice (e.g. cents) so they are exact.
+type Totals struct {
+	Subtotal int64
+	Discount int64
+	Tax      int64
+	Total    int64
+}
+
+// InvoiceHeader entity model, its totals are the sum of the items.
 type InvoiceHeader struct {
 	ID       int64
 	UUID     string
+	Number   string // legal number, e.g. INV-2024-000001
 	ClientID int64
+	Status   Status
+	Totals
+
+	// BillTo is the customer ClientID as it was when the invoice was
+	// generated.
+	BillTo BillTo
+
+	// Jurisdiction whose tax rates apply, e.g. MX or US-CA.
+	Jurisdiction string
+
+	// PricesIncludeTax tells if the unit prices include the tax, the tax
+	// is then the part of them at the rate over 100% + rate.
+	PricesIncludeTax bool
+	TaxRounding      TaxRounding
+
+	// IdempotencyKey if not empty is unique, an invoice generated again
+	// with it fails with ErrInvoiceExists.
+	IdempotencyKey string
+
+	// DueAt is when the invoice must be paid, the payment terms of the
+	// client after its date. Zero on the invoices older than the terms.
+	DueAt time.Time
+
+	// Currency of the prices and totals. BaseCurrency is the one of the
+	// books, ExchangeRate converts the first to it and is captured when
+	// the invoice is issued, zero before.
+	Currency     money.Currency
+	BaseCurrency money.Currency
+	ExchangeRate money.Rate
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
-// InvoiceItem entity model.
+// InvoiceItem entity model. The name and unit price of the product are
+// copied on generation, the invoice doesn't change if the product does.
+// Discount is given as an amount, the other totals are computed.
 type InvoiceItem struct {
 	ID              int64
 	InvoiceHeaderID int64
 	ProductID       int64
+	ProductName     string
+	Quantity        int
+	UnitPrice       money.Money
+	TaxCategory     string // of the product, untaxed if empty
+	TaxName         string // of the rate, e.g. VAT 16%
+	TaxRate         int    // basis points, 1600 = 16%
+	TaxExempt       bool   // the client is exempt of the category
+	Totals
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -47,3 +140,124 @@ type ItemList []InvoiceItem
 func (il ItemList) IsEmpty() bool {
 	return len(il) == 0
 }
+
+// currency returns the currency of the first price with one, empty if
+// none has.
+func (il ItemList) currency() money.Currency {
+	for _, it := range il {
+		if it.UnitPrice.Currency != "" {
+			return it.UnitPrice.Currency
+		}
+	}
+	return ""
+}
+
+// computeTotals computes the totals of the items, the tax breakdown and
+// the totals of the header. The tax applies to the subtotal less the
+// discount of each item, rounded half up per line or per rate of the
+// invoice as the header says.
+func (inv *Invoice) computeTotals() error {
+	amounts := make([]int64, len(inv.Items))
+	for i := range inv.Items {
+		amount, err := inv.Items[i].amount()
+		if err != nil {
+			return err
+		}
+		amounts[i] = amount
+	}
+	taxes, lines, err := computeTaxes(inv, amounts)
+	if err != nil {
+		return err
+	}
+	var sum Totals
+	for i := range inv.Items {
+		it := &inv.Items[i]
+		it.Tax = taxes[i]
+		it.Total = amounts[i]
+		if inv.Header.PricesIncludeTax {
+			// The net subtotal, so that total = subtotal - discount + tax.
+			it.Subtotal = amounts[i] - it.Tax + it.Discount
+		} else if it.Total, err = addAmount(amounts[i], it.Tax); err != nil {
+			return err
+		}
+		if sum, err = sum.add(it.Totals); err != nil {
+			return err
+		}
+	}
+	inv.Header.Totals = sum
+	inv.Taxes = lines
+	return nil
+}
+
+// amount validates the item, sets its subtotal and returns the subtotal
+// less the discount, what the tax applies to.
+func (it *InvoiceItem) amount() (int64, error) {
+	if it.Quantity <= 0 || it.Quantity > maxQuantity {
+		return 0, ErrInvalidQuantity
+	}
+	if it.UnitPrice.Amount < 0 {
+		return 0, ErrInvalidUnitPrice
+	}
+	if it.TaxRate < 0 || it.TaxRate > MaxTaxRate {
+		return 0, ErrInvalidTaxRate
+	}
+	if it.UnitPrice.Amount > math.MaxInt64/int64(it.Quantity) {
+		return 0, ErrAmountOverflow
+	}
+	subtotal := int64(it.Quantity) * it.UnitPrice.Amount
+	if it.Discount < 0 || it.Discount > subtotal {
+		return 0, ErrInvalidDiscount
+	}
+	it.Subtotal = subtotal
+	return subtotal - it.Discount, nil
+}
+
+// setCurrency sets the currency of the invoice, of the prices of its items
+// if it has none, and checks the items are priced in it. The prices
+// without a currency are in the one of the invoice.
+func (inv *Invoice) setCurrency() error {
+	h := inv.Header
+	if h.Currency == "" {
+		h.Currency = inv.Items.currency()
+	}
+	if !h.Currency.Valid() {
+		return fmt.Errorf("%w: %q", money.ErrUnknownCurrency, h.Currency)
+	}
+	for i := range inv.Items {
+		it := &inv.Items[i]
+		if it.UnitPrice.Currency == "" {
+			it.UnitPrice.Currency = h.Currency
+		}
+		if it.UnitPrice.Currency != h.Currency {
+			return fmt.Errorf("%w: %s of %s", ErrCurrencyMismatch, it.UnitPrice, h.Currency)
+		}
+	}
+	return nil
+}
+
+// add returns the sum of the totals.
+func (t Totals) add(o Totals) (Totals, error) {
+	var sum Totals
+	var err error
+	if sum.Subtotal, err = addAmount(t.Subtotal, o.Subtotal); err != nil {
+		return Totals{}, err
+	}
+	if sum.Discount, err = addAmount(t.Discount, o.Discount); err != nil {
+		return Totals{}, err
+	}
+	if sum.Tax, err = addAmount(t.Tax, o.Tax); err != nil {
+		return Totals{}, err
+	}
+	if sum.Total, err = addAmount(t.Total, o.Total); err != nil {
+		return Totals{}, err
+	}
+	return sum, nil
+}
+
+// addAmount adds two non-negative amounts, checking the overflow.
+func addAmount(a, b int64) (int64, error) {
+	if a > math.MaxInt64-b {
+		return 0, ErrAmountOverflow
+	}
+	return a + b, nil
+}
@@ -0,0 +1,91 @@
+package billing
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/adrianolmedo/genesis/money"
+)
+
+func TestInvoiceItemComputeTotals(t *testing.T) {
+	tt := []struct {
+		name    string
+		item    InvoiceItem
+		want    Totals
+		wantErr error
+	}{
+		{
+			name: "without-tax",
+			item: InvoiceItem{Quantity: 3, UnitPrice: money.New(250, "EUR")},
+			want: Totals{Subtotal: 750, Total: 750},
+		},
+		{
+			name: "with-discount-and-tax",
+			item: InvoiceItem{Quantity: 2, UnitPrice: money.New(1000, "EUR"), TaxRate: 1600, Totals: Totals{Discount: 500}},
+			want: Totals{Subtotal: 2000, Discount: 500, Tax: 240, Total: 1740},
+		},
+		{
+			name: "tax-rounded-half-up",
+			item: InvoiceItem{Quantity: 1, UnitPrice: money.New(5, "EUR"), TaxRate: 1000}, // 0.5
+			want: Totals{Subtotal: 5, Tax: 1, Total: 6},
+		},
+		{
+			name: "tax-rounded-down",
+			item: InvoiceItem{Quantity: 1, UnitPrice: money.New(4, "EUR"), TaxRate: 1000}, // 0.4
+			want: Totals{Subtotal: 4, Tax: 0, Total: 4},
+		},
+		{
+			name: "free",
+			item: InvoiceItem{Quantity: 1, UnitPrice: money.New(0, "EUR"), TaxRate: 1600},
+			want: Totals{},
+		},
+		{name: "zero-quantity", item: InvoiceItem{UnitPrice: money.New(100, "EUR")}, wantErr: ErrInvalidQuantity},
+		{name: "negative-price", item: InvoiceItem{Quantity: 1, UnitPrice: money.New(-1, "EUR")}, wantErr: ErrInvalidUnitPrice},
+		{name: "negative-tax-rate", item: InvoiceItem{Quantity: 1, TaxRate: -1}, wantErr: ErrInvalidTaxRate},
+		{name: "tax-rate-over-100", item: InvoiceItem{Quantity: 1, TaxRate: MaxTaxRate + 1}, wantErr: ErrInvalidTaxRate},
+		{
+			name:    "discount-over-subtotal",
+			item:    InvoiceItem{Quantity: 1, UnitPrice: money.New(100, "EUR"), Totals: Totals{Discount: 101}},
+			wantErr: ErrInvalidDiscount,
+		},
+		{
+			name:    "negative-discount",
+			item:    InvoiceItem{Quantity: 1, UnitPrice: money.New(100, "EUR"), Totals: Totals{Discount: -1}},
+			wantErr: ErrInvalidDiscount,
+		},
+		{
+			name:    "subtotal-overflow",
+			item:    InvoiceItem{Quantity: 2, UnitPrice: money.New(math.MaxInt64/2+1, "EUR")},
+			wantErr: ErrAmountOverflow,
+		},
+	}
+	for _, tc := range tt {
+		inv := &Invoice{Header: &InvoiceHeader{}, Items: ItemList{tc.item}}
+		err := inv.computeTotals()
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: want error %v, got %v", tc.name, tc.wantErr, err)
+			continue
+		}
+		if it := inv.Items[0]; err == nil && it.Totals != tc.want {
+			t.Errorf("%s: want %+v, got %+v", tc.name, tc.want, it.Totals)
+		}
+	}
+}
+
+func TestInvoiceComputeTotals(t *testing.T) {
+	inv := &Invoice{
+		Header: &InvoiceHeader{ClientID: 1},
+		Items: ItemList{
+			{Quantity: 2, UnitPrice: money.New(1000, "EUR"), TaxRate: 1600, Totals: Totals{Discount: 500}},
+			{Quantity: 1, UnitPrice: money.New(300, "EUR")},
+		},
+	}
+	if err := inv.computeTotals(); err != nil {
+		t.Fatal(err)
+	}
+	want := Totals{Subtotal: 2300, Discount: 500, Tax: 240, Total: 2040}
+	if inv.Header.Totals != want {
+		t.Errorf("want %+v, got %+v", want, inv.Header.Totals)
+	}
+}
@@ -0,0 +1,138 @@
+package billing
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/adrianolmedo/genesis/ledger"
+	"github.com/adrianolmedo/genesis/money"
+)
+
+// Sources of the journal entries the billing events post to the ledger,
+// the source id is of the invoice, payment or credit note.
+const (
+	SourceInvoice     = "invoice"      // issued
+	SourceInvoiceVoid = "invoice_void" // voided, the reverse of its issue
+	SourcePayment     = "payment"
+	SourceCreditNote  = "credit_note"
+)
+
+// The ledger is kept in the base currency, the amounts of a document are
+// converted at the exchange rate of its invoice captured when it was
+// issued. The revenue is what's left of the receivable less the tax, so an
+// entry balances once rounded.
+
+// invoiceEntry the receivable of an issued invoice, its net amount as
+// revenue and its tax payable.
+func invoiceEntry(id int64, number string, t Totals, rate money.Rate, at time.Time) (*ledger.Entry, error) {
+	total, err := toBase(rate, t.Total)
+	if err != nil {
+		return nil, err
+	}
+	tax, err := toBase(rate, t.Tax)
+	if err != nil {
+		return nil, err
+	}
+	e := &ledger.Entry{Source: SourceInvoice, SourceID: id, Memo: "Invoice " + number, PostedAt: at}
+	e.Debit(ledger.AccountsReceivable, total)
+	e.Credit(ledger.Revenue, total-tax)
+	e.Credit(ledger.TaxPayable, tax)
+	return e, nil
+}
+
+// voidEntry cancels the entry of an invoice issued and voided, in full: an
+// invoice with credit notes can't be voided.
+func voidEntry(id int64, number string, t Totals, rate money.Rate, at time.Time) (*ledger.Entry, error) {
+	e, err := invoiceEntry(id, number, t, rate, at)
+	if err != nil {
+		return nil, err
+	}
+	return e.Reverse(SourceInvoiceVoid, "Void invoice "+number, at), nil
+}
+
+// paymentEntry the cash received, applied to the receivables of the
+// invoices and the rest as credit of the client. An allocation is
+// converted at the rate of its invoice, the rest at the rate of the first
+// invoice, the one paid. The invoices are those allocated, once paid: the
+// allocation that settles one credits exactly its open receivable, the
+// difference of rounding its allocations and credit notes one by one is
+// an exchange difference.
+func paymentEntry(p *Payment, invoices []payable) (*ledger.Entry, error) {
+	e := &ledger.Entry{Source: SourcePayment, SourceID: p.ID, Memo: "Payment " + strconv.FormatInt(p.ID, 10), PostedAt: p.CreatedAt}
+	var allocated, settled int64
+	for _, a := range p.Allocations {
+		i := slices.IndexFunc(invoices, func(inv payable) bool { return inv.ID == a.InvoiceID })
+		if i < 0 {
+			return nil, ErrInvoiceHeaderNotFound
+		}
+		amount, err := toBase(invoices[i].Rate, a.Amount)
+		if err != nil {
+			return nil, err
+		}
+		allocated += amount
+		if invoices[i].Due() <= 0 {
+			settled += invoices[i].Receivable
+		} else {
+			settled += amount
+		}
+	}
+	var credit int64
+	if len(invoices) > 0 {
+		var err error
+		if credit, err = toBase(invoices[0].Rate, p.Credit()); err != nil {
+			return nil, err
+		}
+	}
+	e.Debit(ledger.Cash, allocated+credit)
+	e.Credit(ledger.AccountsReceivable, settled)
+	e.Credit(ledger.CustomerCredit, credit)
+	e.Credit(ledger.ExchangeDifference, allocated-settled)
+	return e, nil
+}
+
+// creditNoteEntry the reverse of the part of an invoice credited, at the
+// rate of the invoice.
+func creditNoteEntry(cn *CreditNote, rate money.Rate) (*ledger.Entry, error) {
+	total, err := toBase(rate, cn.Total)
+	if err != nil {
+		return nil, err
+	}
+	tax, err := toBase(rate, cn.Tax)
+	if err != nil {
+		return nil, err
+	}
+	e := &ledger.Entry{Source: SourceCreditNote, SourceID: cn.ID, Memo: "Credit note " + cn.Number, PostedAt: cn.CreatedAt}
+	e.Debit(ledger.Revenue, total-tax)
+	e.Debit(ledger.TaxPayable, tax)
+	e.Credit(ledger.AccountsReceivable, total)
+	return e, nil
+}
+
+// receivable returns what an invoice of total owes in the base currency,
+// as posted to the ledger: its total less its credit notes and payment
+// allocations, each one converted on its own.
+func receivable(rate money.Rate, total int64, credits, allocations []int64) (int64, error) {
+	open, err := toBase(rate, total)
+	if err != nil {
+		return 0, err
+	}
+	for _, amount := range slices.Concat(credits, allocations) {
+		base, err := toBase(rate, amount)
+		if err != nil {
+			return 0, err
+		}
+		open -= base
+	}
+	return open, nil
+}
+
+// toBase converts amount, in the currency rate is from, to the base
+// currency.
+func toBase(rate money.Rate, amount int64) (int64, error) {
+	m, err := rate.Convert(money.New(amount, rate.From))
+	if err != nil {
+		return 0, err
+	}
+	return m.Amount, nil
+}
@@ -0,0 +1,143 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/ledger"
+	"github.com/adrianolmedo/genesis/money"
+)
+
+func TestLedgerEntries(t *testing.T) {
+	at := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	eur := money.Identity("EUR")
+	usd := money.Rate{From: "USD", To: "EUR", Value: 92_345_000} // 0.92345
+	must := func(e *ledger.Entry, err error) *ledger.Entry {
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	mustAmount := func(n int64, err error) int64 {
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	tt := []struct {
+		name  string
+		entry *ledger.Entry
+		want  []ledger.Line
+	}{
+		{
+			name:  "invoice",
+			entry: must(invoiceEntry(1, "INV-1", Totals{Subtotal: 1100, Discount: 100, Tax: 160, Total: 1160}, eur, at)),
+			want: []ledger.Line{
+				{Account: ledger.AccountsReceivable, Debit: 1160},
+				{Account: ledger.Revenue, Credit: 1000},
+				{Account: ledger.TaxPayable, Credit: 160},
+			},
+		},
+		{
+			name:  "invoice-without-tax",
+			entry: must(invoiceEntry(1, "INV-1", Totals{Subtotal: 1000, Total: 1000}, eur, at)),
+			want: []ledger.Line{
+				{Account: ledger.AccountsReceivable, Debit: 1000},
+				{Account: ledger.Revenue, Credit: 1000},
+			},
+		},
+		{
+			name:  "void",
+			entry: must(voidEntry(1, "INV-1", Totals{Subtotal: 1000, Tax: 160, Total: 1160}, eur, at)),
+			want: []ledger.Line{
+				{Account: ledger.AccountsReceivable, Credit: 1160},
+				{Account: ledger.Revenue, Debit: 1000},
+				{Account: ledger.TaxPayable, Debit: 160},
+			},
+		},
+		{
+			name: "payment-with-credit",
+			entry: must(paymentEntry(&Payment{ID: 1, Amount: 1500, CreatedAt: at, Allocations: []Allocation{
+				{InvoiceID: 1, Amount: 600},
+				{InvoiceID: 2, Amount: 400},
+			}}, []payable{{ID: 1, Rate: eur, Total: 600, Paid: 600, Receivable: 600}, {ID: 2, Rate: eur, Total: 1000, Paid: 400, Receivable: 1000}})),
+			want: []ledger.Line{
+				{Account: ledger.Cash, Debit: 1500},
+				{Account: ledger.AccountsReceivable, Credit: 1000},
+				{Account: ledger.CustomerCredit, Credit: 500},
+			},
+		},
+		{
+			name:  "credit-note",
+			entry: must(creditNoteEntry(&CreditNote{ID: 1, Number: "CN-1", Totals: Totals{Subtotal: 300, Tax: 48, Total: 348}, CreatedAt: at}, eur)),
+			want: []ledger.Line{
+				{Account: ledger.Revenue, Debit: 300},
+				{Account: ledger.TaxPayable, Debit: 48},
+				{Account: ledger.AccountsReceivable, Credit: 348},
+			},
+		},
+		{
+			// 11.60 USD is 10.71 EUR and its tax 1.48 EUR, the revenue is
+			// the rest so the entry balances.
+			name:  "invoice-in-another-currency",
+			entry: must(invoiceEntry(1, "INV-1", Totals{Subtotal: 1000, Tax: 160, Total: 1160}, usd, at)),
+			want: []ledger.Line{
+				{Account: ledger.AccountsReceivable, Debit: 1071},
+				{Account: ledger.Revenue, Credit: 923},
+				{Account: ledger.TaxPayable, Credit: 148},
+			},
+		},
+		{
+			name: "payment-in-another-currency",
+			entry: must(paymentEntry(&Payment{ID: 1, Amount: 1500, Currency: "USD", CreatedAt: at, Allocations: []Allocation{
+				{InvoiceID: 1, Amount: 1160},
+			}}, []payable{{ID: 1, Currency: "USD", Rate: usd, Total: 1160, Paid: 1160, Receivable: 1071}})),
+			want: []ledger.Line{
+				{Account: ledger.Cash, Debit: 1385},
+				{Account: ledger.AccountsReceivable, Credit: 1071},
+				{Account: ledger.CustomerCredit, Credit: 314},
+			},
+		},
+		{
+			// Paid in halves of 5.80 USD, 5.36 EUR each, the second one
+			// settles the 5.35 EUR left of the 10.71 EUR receivable.
+			name: "payment-settling-with-rounding",
+			entry: must(paymentEntry(&Payment{ID: 2, Amount: 580, Currency: "USD", CreatedAt: at, Allocations: []Allocation{
+				{InvoiceID: 1, Amount: 580},
+			}}, []payable{{ID: 1, Currency: "USD", Rate: usd, Total: 1160, Paid: 1160, Receivable: mustAmount(receivable(usd, 1160, nil, []int64{580}))}})),
+			want: []ledger.Line{
+				{Account: ledger.Cash, Debit: 536},
+				{Account: ledger.AccountsReceivable, Credit: 535},
+				{Account: ledger.ExchangeDifference, Credit: 1},
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.entry.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if !tc.entry.PostedAt.Equal(at) {
+				t.Errorf("want posted at %s, got %s", at, tc.entry.PostedAt)
+			}
+			if len(tc.entry.Lines) != len(tc.want) {
+				t.Fatalf("want lines %+v, got %+v", tc.want, tc.entry.Lines)
+			}
+			for i, l := range tc.entry.Lines {
+				if l != tc.want[i] {
+					t.Errorf("want line %+v, got %+v", tc.want[i], l)
+				}
+			}
+		})
+	}
+
+	// A free invoice moves nothing, it isn't posted.
+	if e := must(invoiceEntry(1, "INV-1", Totals{}, eur, at)); !e.Empty() {
+		t.Errorf("want an empty entry, got %+v", e.Lines)
+	}
+
+	// An invoice not issued has no rate, it can't be posted.
+	if _, err := invoiceEntry(1, "INV-1", Totals{Total: 1000}, money.Rate{}, at); err == nil {
+		t.Error("want an error of an invoice without exchange rate")
+	}
+}
@@ -0,0 +1,150 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidNumberFormat the format of the invoice numbers can't be used.
+var ErrInvalidNumberFormat = errors.New("invalid invoice number format")
+
+// DefaultNumberFormat numbers the invoices per year, INV-2024-000001.
+const DefaultNumberFormat = "INV-{YYYY}-{seq:06}"
+
+// DefaultCreditNoteNumberFormat numbers the credit notes per year,
+// CN-2024-000001.
+const DefaultCreditNoteNumberFormat = "CN-{YYYY}-{seq:06}"
+
+// maxSeqWidth limits the padding of {seq:0N}, an int64 has 19 digits.
+const maxSeqWidth = 19
+
+// NumberFormat of the invoice numbers, e.g. INV-{YYYY}-{seq:06}. The
+// placeholders are {YYYY}, {YY}, {MM} and {DD} for the date of the invoice
+// in UTC, and {seq} or {seq:0N} (padded to N digits) for the counter.
+//
+// Each series has its own gapless counter, a series is the format with the
+// date rendered: INV-{YYYY}-{seq:06} starts a new series, back at 1, every
+// year, and changing the prefix starts a new series too.
+type NumberFormat struct {
+	layout string
+	parts  []numberPart
+}
+
+// numberPart literal text, a date placeholder or the counter.
+type numberPart struct {
+	text  string // literal, or placeholder as written
+	date  string // Go layout of a date placeholder
+	seq   bool
+	width int // padding of the counter
+}
+
+// ParseNumberFormat parses the layout of the invoice numbers, it must have
+// one {seq} placeholder.
+func ParseNumberFormat(layout string) (NumberFormat, error) {
+	f := NumberFormat{layout: layout}
+	seqs := 0
+	rest := layout
+	for rest != "" {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			f.parts = append(f.parts, numberPart{text: rest})
+			break
+		}
+		if open > 0 {
+			f.parts = append(f.parts, numberPart{text: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return NumberFormat{}, fmt.Errorf("%w: unclosed placeholder in %q", ErrInvalidNumberFormat, layout)
+		}
+		p, err := parsePlaceholder(rest[open : open+end+1])
+		if err != nil {
+			return NumberFormat{}, err
+		}
+		if p.seq {
+			seqs++
+		}
+		f.parts = append(f.parts, p)
+		rest = rest[open+end+1:]
+	}
+	if strings.ContainsRune(strings.Join(f.literals(), ""), '}') {
+		return NumberFormat{}, fmt.Errorf("%w: unopened placeholder in %q", ErrInvalidNumberFormat, layout)
+	}
+	if seqs != 1 {
+		return NumberFormat{}, fmt.Errorf("%w: %q must have one {seq} placeholder", ErrInvalidNumberFormat, layout)
+	}
+	return f, nil
+}
+
+// parsePlaceholder parses a placeholder with its braces.
+func parsePlaceholder(s string) (numberPart, error) {
+	switch s {
+	case "{YYYY}":
+		return numberPart{text: s, date: "2006"}, nil
+	case "{YY}":
+		return numberPart{text: s, date: "06"}, nil
+	case "{MM}":
+		return numberPart{text: s, date: "01"}, nil
+	case "{DD}":
+		return numberPart{text: s, date: "02"}, nil
+	case "{seq}":
+		return numberPart{text: s, seq: true}, nil
+	}
+	if w, ok := strings.CutPrefix(s, "{seq:0"); ok {
+		width, err := strconv.Atoi(strings.TrimSuffix(w, "}"))
+		if err == nil && width > 0 && width <= maxSeqWidth {
+			return numberPart{text: s, seq: true, width: width}, nil
+		}
+	}
+	return numberPart{}, fmt.Errorf("%w: unknown placeholder %s", ErrInvalidNumberFormat, s)
+}
+
+// literals returns the literal text of the format.
+func (f NumberFormat) literals() []string {
+	var lits []string
+	for _, p := range f.parts {
+		if p.date == "" && !p.seq {
+			lits = append(lits, p.text)
+		}
+	}
+	return lits
+}
+
+// String returns the layout of the format.
+func (f NumberFormat) String() string {
+	return f.layout
+}
+
+// Series returns the series of an invoice created at t, the counter of the
+// series numbers it.
+func (f NumberFormat) Series(t time.Time) string {
+	return f.render(t, func(p numberPart) string { return p.text })
+}
+
+// Number returns the number of the invoice created at t with the counter
+// seq of its series.
+func (f NumberFormat) Number(t time.Time, seq int64) string {
+	return f.render(t, func(p numberPart) string {
+		return fmt.Sprintf("%0*d", p.width, seq)
+	})
+}
+
+// render renders the format at t, seq renders the counter.
+func (f NumberFormat) render(t time.Time, seq func(numberPart) string) string {
+	t = t.UTC()
+	var b strings.Builder
+	for _, p := range f.parts {
+		switch {
+		case p.seq:
+			b.WriteString(seq(p))
+		case p.date != "":
+			b.WriteString(t.Format(p.date))
+		default:
+			b.WriteString(p.text)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package billing
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNumberFormat(t *testing.T) {
+	at := time.Date(2024, 3, 7, 23, 0, 0, 0, time.FixedZone("UTC-3", -3*60*60))
+	tt := []struct {
+		layout     string
+		seq        int64
+		wantSeries string
+		wantNumber string
+	}{
+		{DefaultNumberFormat, 1, "INV-2024-{seq:06}", "INV-2024-000001"},
+		{"INV-{YYYY}-{seq:06}", 1234567, "INV-2024-{seq:06}", "INV-2024-1234567"},
+		{"{YY}{MM}{DD}/{seq}", 42, "240308/{seq}", "240308/42"},
+		{"A-{seq:03}", 7, "A-{seq:03}", "A-007"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.layout, func(t *testing.T) {
+			f, err := ParseNumberFormat(tc.layout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Series(at); got != tc.wantSeries {
+				t.Errorf("want series %q, got %q", tc.wantSeries, got)
+			}
+			if got := f.Number(at, tc.seq); got != tc.wantNumber {
+				t.Errorf("want number %q, got %q", tc.wantNumber, got)
+			}
+		})
+	}
+}
+
+func TestParseNumberFormatInvalid(t *testing.T) {
+	for _, layout := range []string{
+		"",
+		"INV-{YYYY}",
+		"{seq}-{seq}",
+		"INV-{yyyy}-{seq}",
+		"INV-{seq:6}",
+		"INV-{seq:00}",
+		"INV-{seq:020}",
+		"INV-{seq",
+		"INV}-{seq}",
+	} {
+		if _, err := ParseNumberFormat(layout); !errors.Is(err, ErrInvalidNumberFormat) {
+			t.Errorf("%q: want error %v, got %v", layout, ErrInvalidNumberFormat, err)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+)
+
+var (
+	ErrPaymentAmount            = errors.New("payment amount must be greater than zero")
+	ErrInvalidPaymentMethod     = errors.New("invalid payment method")
+	ErrReceivedAtCantBeEmpty    = errors.New("payment received date can't be empty")
+	ErrAllocationAmount         = errors.New("allocated amount must be greater than zero")
+	ErrDuplicateAllocation      = errors.New("an invoice can be allocated once per payment")
+	ErrAllocationExceedsPayment = errors.New("allocated amounts exceed the payment amount")
+	ErrAllocationExceedsBalance = errors.New("allocated amount exceeds the balance of the invoice")
+	ErrInvoiceNotPayable        = errors.New("only issued or partially paid invoices can be paid")
+	ErrPaymentClientMismatch    = errors.New("the invoices of a payment must be of the same client")
+	ErrPaymentCurrencyMismatch  = errors.New("a payment must be in the currency of its invoices")
+)
+
+// PaymentMethod how a payment was received.
+type PaymentMethod string
+
+// Payment methods.
+const (
+	MethodCash     PaymentMethod = "cash"
+	MethodTransfer PaymentMethod = "transfer"
+	MethodCard     PaymentMethod = "card"
+	MethodCheck    PaymentMethod = "check"
+	MethodOther    PaymentMethod = "other"
+)
+
+// paymentMethods known, to validate the input.
+var paymentMethods = []PaymentMethod{MethodCash, MethodTransfer, MethodCard, MethodCheck, MethodOther}
+
+// ParsePaymentMethod returns the PaymentMethod named s.
+func ParsePaymentMethod(s string) (PaymentMethod, error) {
+	m := PaymentMethod(s)
+	if !slices.Contains(paymentMethods, m) {
+		return "", ErrInvalidPaymentMethod
+	}
+	return m, nil
+}
+
+// Payment received from a client, allocated across one or more of its
+// invoices. The amount not allocated is credit held for the client. The
+// amounts are in minor units of the currency of the invoices (e.g. cents).
+type Payment struct {
+	ID          int64
+	ClientID    int64
+	Amount      int64
+	Currency    money.Currency // of the invoices if empty
+	Method      PaymentMethod
+	Reference   string // e.g. the id of the bank transfer
+	ReceivedAt  time.Time
+	By          Actor
+	Allocations []Allocation
+	CreatedAt   time.Time
+}
+
+// Allocation part of a payment applied to an invoice.
+type Allocation struct {
+	InvoiceID int64
+	Amount    int64
+}
+
+// Allocated returns the amount of the payment applied to invoices.
+func (p Payment) Allocated() int64 {
+	var sum int64
+	for _, a := range p.Allocations {
+		sum += a.Amount
+	}
+	return sum
+}
+
+// Credit returns the amount of the payment not applied to invoices.
+func (p Payment) Credit() int64 {
+	return p.Amount - p.Allocated()
+}
+
+// invoiceIDs returns the invoices the payment is allocated to.
+func (p Payment) invoiceIDs() []int64 {
+	ids := make([]int64, 0, len(p.Allocations))
+	for _, a := range p.Allocations {
+		ids = append(ids, a.InvoiceID)
+	}
+	return ids
+}
+
+// Balance of a client in a currency: what it has been invoiced, what it has
+// paid of it, what it still owes and the credit it holds from overpayments.
+type Balance struct {
+	ClientID    int64
+	Currency    money.Currency
+	Invoiced    int64 // issued invoices, void ones excluded
+	Credited    int64 // credit notes of the invoices
+	Paid        int64 // payments allocated to invoices
+	Outstanding int64
+	Credit      int64
+}
+
+// newBalance returns the Balance of a client from its totals. overcredited
+// is what the invoices credited after being paid were paid in excess, it's
+// credit of the client as the payments not allocated are.
+func newBalance(clientID, invoiced, credited, allocated, received, overcredited int64) Balance {
+	return Balance{
+		ClientID:    clientID,
+		Invoiced:    invoiced,
+		Credited:    credited,
+		Paid:        allocated,
+		Outstanding: invoiced - credited - allocated + overcredited,
+		Credit:      received - allocated + overcredited,
+	}
+}
+
+// validatePayment checks a payment before its allocations are known to fit
+// the balances of the invoices.
+func validatePayment(p *Payment) error {
+	if p.Amount <= 0 {
+		return ErrPaymentAmount
+	}
+	if _, err := ParsePaymentMethod(string(p.Method)); err != nil {
+		return err
+	}
+	if p.ReceivedAt.IsZero() {
+		return ErrReceivedAtCantBeEmpty
+	}
+	seen := make(map[int64]bool, len(p.Allocations))
+	var sum int64
+	for _, a := range p.Allocations {
+		if a.Amount <= 0 {
+			return ErrAllocationAmount
+		}
+		if seen[a.InvoiceID] {
+			return fmt.Errorf("%w: invoice %d", ErrDuplicateAllocation, a.InvoiceID)
+		}
+		seen[a.InvoiceID] = true
+		var err error
+		if sum, err = addAmount(sum, a.Amount); err != nil {
+			return err
+		}
+	}
+	if sum > p.Amount {
+		return ErrAllocationExceedsPayment
+	}
+	return nil
+}
+
+// payable is an invoice a payment can be allocated to, with what it still
+// owes.
+type payable struct {
+	ID       int64
+	ClientID int64
+	Status   Status
+	Currency money.Currency
+	Rate     money.Rate // to the base currency, captured when issued
+	Total    int64
+	Credited int64 // by credit notes
+	Paid     int64
+
+	// Receivable is what it owes in the base currency, as posted to the
+	// ledger, read only to pay it.
+	Receivable int64
+}
+
+// Due returns what the invoice still owes, negative if it was credited
+// after being paid.
+func (i payable) Due() int64 {
+	return i.Total - i.Credited - i.Paid
+}
+
+// allocate applies p to invoices. Without allocations the payment is
+// applied to the invoices in order, each up to what it owes, and the rest
+// is credit. The given allocations can't exceed what each invoice owes.
+// The payment is in the currency of the invoices, all in the same one. The
+// Paid of the invoices is updated.
+func allocate(p *Payment, invoices []payable) error {
+	for _, inv := range invoices {
+		if inv.Status != StatusIssued && inv.Status != StatusPartiallyPaid {
+			return fmt.Errorf("%w: invoice %d is %s", ErrInvoiceNotPayable, inv.ID, inv.Status)
+		}
+		if inv.ClientID != invoices[0].ClientID {
+			return ErrPaymentClientMismatch
+		}
+		if inv.Currency != invoices[0].Currency || (p.Currency != "" && p.Currency != inv.Currency) {
+			return fmt.Errorf("%w: invoice %d is in %s", ErrPaymentCurrencyMismatch, inv.ID, inv.Currency)
+		}
+	}
+	if len(p.Allocations) == 0 {
+		left := p.Amount
+		for _, inv := range invoices {
+			amount := min(left, inv.Due())
+			if amount <= 0 {
+				continue
+			}
+			p.Allocations = append(p.Allocations, Allocation{InvoiceID: inv.ID, Amount: amount})
+			left -= amount
+		}
+	}
+	for _, a := range p.Allocations {
+		i := slices.IndexFunc(invoices, func(inv payable) bool { return inv.ID == a.InvoiceID })
+		if i < 0 {
+			return ErrInvoiceHeaderNotFound
+		}
+		if a.Amount > invoices[i].Due() {
+			return fmt.Errorf("%w: invoice %d owes %d", ErrAllocationExceedsBalance, a.InvoiceID, invoices[i].Due())
+		}
+		invoices[i].Paid += a.Amount
+	}
+	if len(invoices) > 0 {
+		p.ClientID, p.Currency = invoices[0].ClientID, invoices[0].Currency
+	}
+	return nil
+}
+
+// statusAfterPayment returns the status of an invoice once paid, or
+// credited, the amounts of inv. An issued invoice that owes nothing is
+// settled, paid.
+func statusAfterPayment(inv payable) Status {
+	if inv.Status != StatusIssued && inv.Status != StatusPartiallyPaid {
+		return inv.Status
+	}
+	switch {
+	case inv.Due() <= 0:
+		return StatusPaid
+	case inv.Paid > 0:
+		return StatusPartiallyPaid
+	}
+	return inv.Status
+}
@@ -0,0 +1,180 @@
+package billing
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidatePayment(t *testing.T) {
+	now := time.Now()
+	tt := []struct {
+		name    string
+		p       Payment
+		wantErr error
+	}{
+		{name: "successful", p: Payment{Amount: 100, Method: MethodCash, ReceivedAt: now}},
+		{
+			name: "allocated",
+			p: Payment{Amount: 100, Method: MethodTransfer, ReceivedAt: now, Allocations: []Allocation{
+				{InvoiceID: 1, Amount: 60}, {InvoiceID: 2, Amount: 40},
+			}},
+		},
+		{name: "zero-amount", p: Payment{Method: MethodCash, ReceivedAt: now}, wantErr: ErrPaymentAmount},
+		{name: "unknown-method", p: Payment{Amount: 100, Method: "barter", ReceivedAt: now}, wantErr: ErrInvalidPaymentMethod},
+		{name: "no-received-date", p: Payment{Amount: 100, Method: MethodCash}, wantErr: ErrReceivedAtCantBeEmpty},
+		{
+			name: "zero-allocation",
+			p: Payment{Amount: 100, Method: MethodCash, ReceivedAt: now, Allocations: []Allocation{
+				{InvoiceID: 1},
+			}},
+			wantErr: ErrAllocationAmount,
+		},
+		{
+			name: "duplicated-invoice",
+			p: Payment{Amount: 100, Method: MethodCash, ReceivedAt: now, Allocations: []Allocation{
+				{InvoiceID: 1, Amount: 10}, {InvoiceID: 1, Amount: 10},
+			}},
+			wantErr: ErrDuplicateAllocation,
+		},
+		{
+			name: "exceeds-payment",
+			p: Payment{Amount: 100, Method: MethodCash, ReceivedAt: now, Allocations: []Allocation{
+				{InvoiceID: 1, Amount: 60}, {InvoiceID: 2, Amount: 41},
+			}},
+			wantErr: ErrAllocationExceedsPayment,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validatePayment(&tc.p)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("want error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	t.Run("overpayment-is-credit", func(t *testing.T) {
+		p := &Payment{Amount: 1500}
+		invoices := []payable{{ID: 1, ClientID: 7, Status: StatusIssued, Total: 1000}}
+		if err := allocate(p, invoices); err != nil {
+			t.Fatal(err)
+		}
+		if len(p.Allocations) != 1 || p.Allocations[0].Amount != 1000 {
+			t.Fatalf("unexpected allocations %+v", p.Allocations)
+		}
+		if p.Credit() != 500 || p.ClientID != 7 {
+			t.Errorf("want credit 500 of client 7, got %d of client %d", p.Credit(), p.ClientID)
+		}
+		if got := statusAfterPayment(invoices[0]); got != StatusPaid {
+			t.Errorf("want status %s, got %s", StatusPaid, got)
+		}
+	})
+	t.Run("partial", func(t *testing.T) {
+		p := &Payment{Amount: 300}
+		invoices := []payable{{ID: 1, ClientID: 7, Status: StatusPartiallyPaid, Total: 1000, Paid: 200}}
+		if err := allocate(p, invoices); err != nil {
+			t.Fatal(err)
+		}
+		if p.Credit() != 0 || invoices[0].Paid != 500 {
+			t.Errorf("want 500 paid and no credit, got %d paid and %d credit", invoices[0].Paid, p.Credit())
+		}
+		if got := statusAfterPayment(invoices[0]); got != StatusPartiallyPaid {
+			t.Errorf("want status %s, got %s", StatusPartiallyPaid, got)
+		}
+	})
+	t.Run("credited", func(t *testing.T) {
+		p := &Payment{Amount: 1000}
+		invoices := []payable{{ID: 1, ClientID: 7, Status: StatusIssued, Total: 1000, Credited: 400}}
+		if err := allocate(p, invoices); err != nil {
+			t.Fatal(err)
+		}
+		if p.Credit() != 400 || statusAfterPayment(invoices[0]) != StatusPaid {
+			t.Errorf("want 400 credit and invoice paid, got %d credit and %+v", p.Credit(), invoices[0])
+		}
+	})
+	t.Run("across-invoices", func(t *testing.T) {
+		p := &Payment{Amount: 1000, Allocations: []Allocation{{InvoiceID: 2, Amount: 400}, {InvoiceID: 1, Amount: 600}}}
+		invoices := []payable{
+			{ID: 1, ClientID: 7, Status: StatusIssued, Total: 600},
+			{ID: 2, ClientID: 7, Status: StatusIssued, Total: 800},
+		}
+		if err := allocate(p, invoices); err != nil {
+			t.Fatal(err)
+		}
+		if statusAfterPayment(invoices[0]) != StatusPaid || statusAfterPayment(invoices[1]) != StatusPartiallyPaid {
+			t.Errorf("unexpected invoices %+v", invoices)
+		}
+	})
+	tt := []struct {
+		name     string
+		p        Payment
+		invoices []payable
+		wantErr  error
+	}{
+		{
+			name:     "draft",
+			p:        Payment{Amount: 100},
+			invoices: []payable{{ID: 1, Status: StatusDraft, Total: 100}},
+			wantErr:  ErrInvoiceNotPayable,
+		},
+		{
+			name:     "paid",
+			p:        Payment{Amount: 100},
+			invoices: []payable{{ID: 1, Status: StatusPaid, Total: 100, Paid: 100}},
+			wantErr:  ErrInvoiceNotPayable,
+		},
+		{
+			name: "other-client",
+			p:    Payment{Amount: 100},
+			invoices: []payable{
+				{ID: 1, ClientID: 7, Status: StatusIssued, Total: 100},
+				{ID: 2, ClientID: 8, Status: StatusIssued, Total: 100},
+			},
+			wantErr: ErrPaymentClientMismatch,
+		},
+		{
+			name: "other-currency",
+			p:    Payment{Amount: 100},
+			invoices: []payable{
+				{ID: 1, ClientID: 7, Status: StatusIssued, Currency: "EUR", Total: 100},
+				{ID: 2, ClientID: 7, Status: StatusIssued, Currency: "USD", Total: 100},
+			},
+			wantErr: ErrPaymentCurrencyMismatch,
+		},
+		{
+			name:     "payment-in-other-currency",
+			p:        Payment{Amount: 100, Currency: "USD"},
+			invoices: []payable{{ID: 1, ClientID: 7, Status: StatusIssued, Currency: "EUR", Total: 100}},
+			wantErr:  ErrPaymentCurrencyMismatch,
+		},
+		{
+			name:     "exceeds-balance",
+			p:        Payment{Amount: 100, Allocations: []Allocation{{InvoiceID: 1, Amount: 60}}},
+			invoices: []payable{{ID: 1, Status: StatusPartiallyPaid, Total: 100, Paid: 50}},
+			wantErr:  ErrAllocationExceedsBalance,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := allocate(&tc.p, tc.invoices); !errors.Is(err, tc.wantErr) {
+				t.Errorf("want error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNewBalance(t *testing.T) {
+	b := newBalance(7, 10000, 0, 7000, 7500, 0)
+	if b.Outstanding != 3000 || b.Credit != 500 {
+		t.Errorf("want 3000 outstanding and 500 credit, got %+v", b)
+	}
+
+	// An invoice of 1000 paid and then credited 200 leaves 200 of credit.
+	b = newBalance(7, 1000, 200, 1000, 1000, 200)
+	if b.Outstanding != 0 || b.Credit != 200 {
+		t.Errorf("want nothing outstanding and 200 credit, got %+v", b)
+	}
+}
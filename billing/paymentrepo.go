@@ -0,0 +1,158 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/adrianolmedo/genesis/ledger"
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CreatePayment stores a payment and its allocations, applied to invoices
+// in order if it has none (see allocate), and moves the invoices paid in
+// full or in part to paid or partially paid. The invoices are locked while
+// their balances are read, the concurrent payments of an invoice apply one
+// after the other.
+func (r *Repo) CreatePayment(ctx context.Context, p *Payment, invoices []int64) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+	q := r.q.WithTx(tx)
+
+	// Lock in order of id, two payments of the same invoices can't wait
+	// for each other.
+	locked := slices.Clone(invoices)
+	slices.Sort(locked)
+	payables := make([]payable, len(invoices))
+	for _, id := range slices.Compact(locked) {
+		row, err := q.InvoiceHeaderByIDForUpdate(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: %d", ErrInvoiceHeaderNotFound, id)
+		}
+		if err != nil {
+			return err
+		}
+		allocations, err := q.PaymentAllocationAmountsByInvoice(ctx, id)
+		if err != nil {
+			return err
+		}
+		credits, err := q.CreditNoteTotalsByInvoice(ctx, id)
+		if err != nil {
+			return err
+		}
+		rate := invoiceRate(row.Currency, row.BaseCurrency, row.ExchangeRate.Int64)
+		open, err := receivable(rate, row.Total, credits, allocations)
+		if err != nil {
+			return err
+		}
+		for i := range invoices {
+			if invoices[i] == id {
+				payables[i] = payable{
+					ID:         id,
+					ClientID:   row.ClientID,
+					Status:     Status(row.Status),
+					Currency:   money.Currency(row.Currency),
+					Rate:       rate,
+					Total:      row.Total,
+					Credited:   sum(credits),
+					Paid:       sum(allocations),
+					Receivable: open,
+				}
+			}
+		}
+	}
+	if err := allocate(p, payables); err != nil {
+		return err
+	}
+
+	id, err := q.PaymentCreate(ctx, dbgen.PaymentCreateParams{
+		ClientID:                  p.ClientID,
+		Amount:                    p.Amount,
+		Currency:                  string(p.Currency),
+		Method:                    string(p.Method),
+		Reference:                 p.Reference,
+		ReceivedAt:                p.ReceivedAt,
+		CreatedByUserID:           nullID(p.By.UserID),
+		CreatedByServiceAccountID: nullID(p.By.ServiceAccountID),
+		CreatedAt:                 p.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	p.ID = id
+	for _, a := range p.Allocations {
+		err := q.PaymentAllocationCreate(ctx, dbgen.PaymentAllocationCreateParams{
+			PaymentID:       id,
+			InvoiceHeaderID: a.InvoiceID,
+			Amount:          a.Amount,
+			CreatedAt:       p.CreatedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("payment allocation: %w", err)
+		}
+	}
+	e, err := paymentEntry(p, payables)
+	if err == nil {
+		err = ledger.Post(ctx, q, e)
+	}
+	if err != nil {
+		return fmt.Errorf("payment ledger: %w", err)
+	}
+	for _, inv := range payables {
+		to := statusAfterPayment(inv)
+		if to == inv.Status {
+			continue
+		}
+		_, err := setStatus(ctx, q, inv.ID, inv.Status, to, StatusChange{
+			By:        p.By,
+			Reason:    fmt.Sprintf("payment %d", id),
+			ChangedAt: p.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return ledger.Err(tx.Commit(ctx))
+}
+
+// Balance returns the Balance of a client in a currency, of its invoices
+// and payments in it.
+func (r *Repo) Balance(ctx context.Context, clientID int64, currency money.Currency) (*Balance, error) {
+	row, err := r.q.PaymentClientBalance(ctx, dbgen.PaymentClientBalanceParams{
+		ClientID: clientID,
+		Currency: string(currency),
+	})
+	if err != nil {
+		return nil, err
+	}
+	b := newBalance(clientID, row.Invoiced, row.Credited, row.Allocated, row.Received, row.Overcredited)
+	b.Currency = currency
+	return &b, nil
+}
+
+// DeleteAllPayments deletes all payments and their allocations
+// (permanently).
+func (r *Repo) DeleteAllPayments(ctx context.Context) error {
+	err := r.q.PaymentDeleteAll(ctx)
+	if err != nil {
+		return fmt.Errorf("can't truncate table: %v", err)
+	}
+	return nil
+}
+
+// sum of the amounts.
+func sum(amounts []int64) int64 {
+	var s int64
+	for _, a := range amounts {
+		s += a
+	}
+	return s
+}
@@ -0,0 +1,65 @@
+package pdf
+
+import (
+	"container/list"
+	"sync"
+)
+
+// key of a rendered file, the invoice at a version for a client.
+type key struct {
+	ID       int64
+	Version  int64 // unix nanoseconds of the last change
+	Customer Customer
+}
+
+// cache of the rendered files, the least recently used is evicted when
+// it's full. The old versions of an invoice are evicted as any other.
+type cache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // of *entry, most recent first
+	items map[key]*list.Element
+}
+
+// entry of the cache.
+type entry struct {
+	key  key
+	file *File
+}
+
+// newCache returns a cache of up to size files.
+func newCache(size int) *cache {
+	return &cache{size: size, order: list.New(), items: make(map[key]*list.Element)}
+}
+
+// get returns the file of k, if it's cached.
+func (c *cache) get(k key) (*File, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[k]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*entry).file, true
+}
+
+// add caches the file of k.
+func (c *cache) add(k key, f *File) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[k]; ok {
+		e.Value.(*entry).file = f
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[k] = c.order.PushFront(&entry{key: k, file: f})
+	if c.order.Len() > c.size {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.items, last.Value.(*entry).key)
+	}
+}
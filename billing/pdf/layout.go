@@ -0,0 +1,169 @@
+package pdf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidLayout a line of the layout rendered by the template isn't a
+// valid directive.
+var ErrInvalidLayout = errors.New("invalid layout")
+
+// Vertical margins of the pages, where the cursor starts and breaks pages.
+const (
+	marginTop    = 792
+	marginBottom = 60
+)
+
+// layout draws the directives rendered by a template, one per line:
+//
+//	font regular|bold <size>
+//	color <r> <g> <b>                     (0 to 255)
+//	text <x> <y> left|right|center <text>
+//	line <x1> <y1> <x2> <y2>
+//	rect <x> <y> <width> <height>         (filled, from the lower left corner)
+//	cursor <y>                            (moves the cursor to y)
+//	down <dy>                             (moves the cursor down, breaking the page at the bottom margin)
+//	page                                  (starts a new page, the cursor goes to the top)
+//
+// The coordinates are in points from the lower left corner of the page,
+// a y of "." is the cursor. Blank lines and lines starting with # are
+// skipped.
+type layout struct {
+	doc    *document
+	font   font
+	size   float64
+	color  color
+	cursor float64
+}
+
+// draw draws the directives of src on the document.
+func (l *layout) draw(src string) error {
+	l.font, l.size, l.cursor = regular, 10, marginTop
+	sc := bufio.NewScanner(strings.NewReader(src))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := l.directive(line); err != nil {
+			return fmt.Errorf("%w: line %d: %q: %v", ErrInvalidLayout, n, line, err)
+		}
+	}
+	return sc.Err()
+}
+
+// directive draws a line of the layout.
+func (l *layout) directive(line string) error {
+	name, rest, _ := strings.Cut(line, " ")
+	switch name {
+	case "font":
+		name, size, _ := strings.Cut(rest, " ")
+		f, ok := fonts[name]
+		if !ok {
+			return errors.New("unknown font")
+		}
+		args, err := l.args(size, 1)
+		if err != nil {
+			return err
+		}
+		l.font, l.size = f, args[0]
+	case "color":
+		args, err := l.args(rest, 3)
+		if err != nil {
+			return err
+		}
+		for i, v := range args {
+			if v < 0 || v > 255 {
+				return errors.New("color component out of range")
+			}
+			l.color[i] = uint8(v)
+		}
+	case "text":
+		f := strings.SplitN(rest, " ", 4)
+		if len(f) < 3 {
+			return errors.New("want x, y, alignment and text")
+		}
+		args, err := l.args(strings.Join(f[:2], " "), 2, 1)
+		if err != nil {
+			return err
+		}
+		s := encode("")
+		if len(f) == 4 {
+			s = encode(f[3])
+		}
+		x := args[0]
+		switch f[2] {
+		case "left":
+		case "right":
+			x -= textWidth(s, l.font, l.size)
+		case "center":
+			x -= textWidth(s, l.font, l.size) / 2
+		default:
+			return errors.New("unknown alignment")
+		}
+		if len(s) > 0 {
+			l.doc.text(x, args[1], l.font, l.size, l.color, s)
+		}
+	case "line":
+		args, err := l.args(rest, 4, 1, 3)
+		if err != nil {
+			return err
+		}
+		l.doc.line(args[0], args[1], args[2], args[3], l.color)
+	case "rect":
+		args, err := l.args(rest, 4, 1)
+		if err != nil {
+			return err
+		}
+		l.doc.rect(args[0], args[1], args[2], args[3], l.color)
+	case "cursor":
+		args, err := l.args(rest, 1)
+		if err != nil {
+			return err
+		}
+		l.cursor = args[0]
+	case "down":
+		args, err := l.args(rest, 1)
+		if err != nil {
+			return err
+		}
+		l.cursor -= args[0]
+		if l.cursor < marginBottom {
+			l.doc.addPage()
+			l.cursor = marginTop
+		}
+	case "page":
+		l.doc.addPage()
+		l.cursor = marginTop
+	default:
+		return errors.New("unknown directive")
+	}
+	return nil
+}
+
+// args parses the n numbers of s, the ones at the positions of cursor can
+// be "." for the cursor.
+func (l *layout) args(s string, n int, cursor ...int) ([]float64, error) {
+	f := strings.Fields(s)
+	if len(f) != n {
+		return nil, fmt.Errorf("want %d arguments, got %d", n, len(f))
+	}
+	args := make([]float64, n)
+	for i, v := range f {
+		if v == "." && slices.Contains(cursor, i) {
+			args[i] = l.cursor
+			continue
+		}
+		x, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't a number", v)
+		}
+		args[i] = x
+	}
+	return args, nil
+}
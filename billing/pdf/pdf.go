@@ -0,0 +1,115 @@
+// Package pdf renders invoices as PDF documents in pure Go, with the
+// standard fonts of the readers and a layout given by a template that can
+// be branded. The output of an invoice is the same on every run, and the
+// rendered files are cached by the version of the invoice.
+package pdf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing"
+)
+
+// Customer the client of an invoice, as printed.
+type Customer struct {
+	Name    string
+	Email   string
+	Address string
+}
+
+// Document the data the template is executed with.
+type Document struct {
+	Header   billing.InvoiceHeader
+	Items    billing.ItemList
+	Taxes    []billing.TaxLine
+	Customer Customer
+}
+
+// NewDocument returns the Document of an invoice, header and items, and its
+// client. The line breaks of the texts are replaced by spaces, they'd end
+// the directives of the layout.
+func NewDocument(inv *billing.Invoice, c Customer) Document {
+	d := Document{
+		Header:   *inv.Header,
+		Items:    make(billing.ItemList, len(inv.Items)),
+		Taxes:    make([]billing.TaxLine, len(inv.Taxes)),
+		Customer: Customer{Name: oneLine(c.Name), Email: oneLine(c.Email), Address: oneLine(c.Address)},
+	}
+	for i, it := range inv.Items {
+		it.ProductName = oneLine(it.ProductName)
+		it.TaxName = oneLine(it.TaxName)
+		d.Items[i] = it
+	}
+	for i, t := range inv.Taxes {
+		t.Name = oneLine(t.Name)
+		d.Taxes[i] = t
+	}
+	return d
+}
+
+// oneLine replaces the line breaks of s by spaces.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// version of an invoice, it changes when it's modified.
+func (d Document) version() time.Time {
+	if !d.Header.UpdatedAt.IsZero() {
+		return d.Header.UpdatedAt
+	}
+	return d.Header.CreatedAt
+}
+
+// File a rendered PDF.
+type File struct {
+	Body []byte
+	ETag string // quoted, for the ETag header
+}
+
+// Renderer renders invoices with a template, caching the files.
+type Renderer struct {
+	tmpl  *Template
+	cache *cache
+}
+
+// NewRenderer returns a Renderer with the template t, caching up to size
+// files, none if size is 0.
+func NewRenderer(t *Template, size int) *Renderer {
+	return &Renderer{tmpl: t, cache: newCache(size)}
+}
+
+// Render returns the PDF of the document, from the cache if the invoice
+// hasn't changed since it was rendered.
+func (r *Renderer) Render(d Document) (*File, error) {
+	k := key{ID: d.Header.ID, Version: d.version().UnixNano(), Customer: d.Customer}
+	if f, ok := r.cache.get(k); ok {
+		return f, nil
+	}
+	body, err := r.render(d)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	f := &File{Body: body, ETag: `"` + hex.EncodeToString(sum[:16]) + `"`}
+	r.cache.add(k, f)
+	return f, nil
+}
+
+// render executes the template and draws its layout.
+func (r *Renderer) render(d Document) ([]byte, error) {
+	var src bytes.Buffer
+	if err := r.tmpl.t.Execute(&src, d); err != nil {
+		return nil, fmt.Errorf("invoice template: %w", err)
+	}
+	doc := &document{title: d.Header.Number, created: d.Header.CreatedAt}
+	l := layout{doc: doc}
+	if err := l.draw(src.String()); err != nil {
+		return nil, err
+	}
+	return doc.bytes(), nil
+}
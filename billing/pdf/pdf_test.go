@@ -0,0 +1,201 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/money"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// invoice of the golden files.
+func invoice() *billing.Invoice {
+	created := time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+	return &billing.Invoice{
+		Header: &billing.InvoiceHeader{
+			ID:           42,
+			Number:       "INV-2024-000042",
+			ClientID:     7,
+			Status:       billing.StatusIssued,
+			Totals:       billing.Totals{Subtotal: 1250000, Discount: 5000, Tax: 199200, Total: 1444200},
+			Jurisdiction: "MX",
+			TaxRounding:  billing.RoundPerLine,
+			Currency:     "MXN",
+			CreatedAt:    created,
+		},
+		Items: billing.ItemList{
+			{ProductName: "Laptop (14\")", Quantity: 1, UnitPrice: money.New(1200000, "MXN"), TaxCategory: "standard", TaxName: "IVA 16%", TaxRate: 1600,
+				Totals: billing.Totals{Subtotal: 1200000, Discount: 5000, Tax: 191200, Total: 1386200}},
+			{ProductName: "Café\nmolido", Quantity: 2, UnitPrice: money.New(25000, "MXN"), TaxCategory: "reduced", TaxName: "IVA 8%", TaxRate: 800,
+				Totals: billing.Totals{Subtotal: 50000, Tax: 4000, Total: 54000}},
+		},
+		Taxes: []billing.TaxLine{
+			{Category: "standard", Name: "IVA 16%", Rate: 1600, Base: 1195000, Tax: 191200},
+			{Category: "reduced", Name: "IVA 8%", Rate: 800, Base: 50000, Tax: 4000},
+		},
+	}
+}
+
+func TestRenderGolden(t *testing.T) {
+	r := NewRenderer(DefaultTemplate(), 0)
+	doc := NewDocument(invoice(), Customer{Name: "Ana Pérez", Email: "ana@example.com", Address: "Av. Reforma 1, 06600 CDMX"})
+	f, err := r.Render(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := r.Render(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Body, again.Body) || f.ETag != again.ETag {
+		t.Fatal("want the same output on every render")
+	}
+	assertXref(t, f.Body)
+
+	golden := filepath.Join("testdata", "invoice.golden.pdf")
+	if *update {
+		if err := os.WriteFile(golden, f.Body, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Body, want) {
+		t.Errorf("the output differs from %s, run the tests with -update if the change is expected", golden)
+	}
+}
+
+func TestRenderPageBreak(t *testing.T) {
+	inv := invoice()
+	item := inv.Items[1]
+	for range 60 {
+		inv.Items = append(inv.Items, item)
+	}
+	f, err := NewRenderer(DefaultTemplate(), 0).Render(NewDocument(inv, Customer{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertXref(t, f.Body)
+	if !bytes.Contains(f.Body, []byte("/Count 2 ")) {
+		t.Error("want the items to break to a second page")
+	}
+}
+
+func TestRenderCache(t *testing.T) {
+	r := NewRenderer(DefaultTemplate(), 1)
+	inv := invoice()
+	customer := Customer{Name: "Ana Pérez"}
+	f, err := r.Render(NewDocument(inv, customer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, _ := r.Render(NewDocument(inv, customer))
+	if cached != f {
+		t.Error("want the cached file of the same version")
+	}
+	inv.Header.Status = billing.StatusPaid
+	inv.Header.UpdatedAt = inv.Header.CreatedAt.Add(time.Hour)
+	paid, err := r.Render(NewDocument(inv, customer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paid == f || paid.ETag == f.ETag {
+		t.Error("want a new file for a new version")
+	}
+	if _, ok := r.cache.get(key{ID: 42, Version: inv.Header.CreatedAt.UnixNano(), Customer: customer}); ok {
+		t.Error("want the least recently used file evicted")
+	}
+}
+
+func TestLayoutErrors(t *testing.T) {
+	tt := []string{
+		"font italic 10",
+		"font bold",
+		"color 256 0 0",
+		"text 10 10 justify Hi",
+		"text 10 Hi",
+		"line 10 . 20",
+		"rect . 10 20 20", // the cursor is only a y
+		"wave 1 2",
+	}
+	for _, src := range tt {
+		l := layout{doc: &document{}}
+		if err := l.draw(src); !errors.Is(err, ErrInvalidLayout) {
+			t.Errorf("%q: want error %v, got %v", src, ErrInvalidLayout, err)
+		}
+	}
+}
+
+func TestLayoutText(t *testing.T) {
+	doc := &document{}
+	l := layout{doc: doc}
+	err := l.draw("font bold 10\ncursor 700\ndown 20\ntext 100 . right (a)\ntext 100 . center €\n# comment\n\ntext 10 10 left")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Bold "(a)" is 333+556+333 thousandths wide, the euro is taken as a digit.
+	want := "BT 0 0 0 rg /F2 10 Tf 87.78 680 Td (\\(a\\)) Tj ET\nBT 0 0 0 rg /F2 10 Tf 97.22 680 Td (\x80) Tj ET\n"
+	if got := doc.page().String(); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for v, want := range map[int64]string{0: "0.00", 5: "0.05", 123456: "1,234.56", -100000099: "-1,000,000.99"} {
+		if got, _ := formatMoney(v); got != want {
+			t.Errorf("money(%d): want %s, got %s", v, want, got)
+		}
+	}
+	for m, want := range map[money.Money]string{
+		money.New(123456, "EUR"):  "1,234.56",
+		money.New(1234567, "JPY"): "1,234,567",
+		money.New(-1234, "KWD"):   "-1.234",
+	} {
+		if got, _ := formatMoney(m); got != want {
+			t.Errorf("money(%v): want %s, got %s", m, want, got)
+		}
+	}
+	if got, _ := formatMoney(int64(1234), "JPY"); got != "1,234" {
+		t.Errorf("money(1234, JPY): want 1,234, got %s", got)
+	}
+	if _, err := formatMoney("12"); err == nil {
+		t.Error("want an error of money of a string")
+	}
+	for bp, want := range map[int]string{0: "0%", 1600: "16%", 825: "8.25%", 750: "7.5%"} {
+		if got := percent(bp); got != want {
+			t.Errorf("percent(%d): want %s, got %s", bp, want, got)
+		}
+	}
+}
+
+// assertXref checks that the cross-reference table points to the objects.
+func assertXref(t *testing.T, pdf []byte) {
+	t.Helper()
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	if m == nil {
+		t.Fatal("no startxref")
+	}
+	off, _ := strconv.Atoi(string(m[1]))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[off:], -1)
+	if len(entries) == 0 {
+		t.Fatal("no objects in the xref table")
+	}
+	for i, e := range entries {
+		at, _ := strconv.Atoi(string(e[1]))
+		if !bytes.HasPrefix(pdf[at:], fmt.Appendf(nil, "%d 0 obj\n", i+1)) {
+			t.Errorf("object %d isn't at %d", i+1, at)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package pdf
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+)
+
+//go:embed invoice.tmpl
+var defaultTemplate string
+
+// Template renders the layout of an invoice, see layout for its
+// directives. It's executed with a Document and these functions:
+//
+//	money   formats an amount, a money.Money or an int64 of minor units of
+//	        the currency given after it, 123456 EUR is 1,234.56
+//	percent formats a rate in basis points, 1600 is 16%
+//	date    formats a time as 2006-01-02, in UTC
+//	upper   converts a string to upper case
+type Template struct {
+	t *template.Template
+}
+
+// funcs the functions of the templates.
+var funcs = template.FuncMap{
+	"money":   formatMoney,
+	"percent": percent,
+	"date":    func(t time.Time) string { return t.UTC().Format(time.DateOnly) },
+	"upper":   strings.ToUpper,
+}
+
+// ParseTemplate parses the text of a template, named name in its errors.
+func ParseTemplate(name, text string) (*Template, error) {
+	t, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{t: t}, nil
+}
+
+// DefaultTemplate returns the template of the invoices shipped with the
+// application, a starting point to brand them.
+func DefaultTemplate() *Template {
+	t, err := ParseTemplate("invoice.tmpl", defaultTemplate)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// formatMoney formats an amount with the decimals of its currency and
+// thousands separators. An int64 is in minor units of currency, two
+// decimals without it.
+func formatMoney(v any, currency ...money.Currency) (string, error) {
+	var m money.Money
+	switch v := v.(type) {
+	case money.Money:
+		m = v
+	case int64:
+		m = money.New(v, "")
+		if len(currency) > 0 {
+			m.Currency = currency[0]
+		}
+	default:
+		return "", fmt.Errorf("money of %T, want an amount", v)
+	}
+	s := m.Decimal()
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	if frac != "" {
+		b.WriteString("." + frac)
+	}
+	return b.String(), nil
+}
+
+// percent formats a rate in basis points without trailing zeros.
+func percent(bp int) string {
+	s := strconv.FormatFloat(float64(bp)/100, 'f', 2, 64)
+	return strings.TrimRight(strings.TrimRight(s, "0"), ".") + "%"
+}
@@ -0,0 +1,194 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Size of an A4 page in points.
+const (
+	pageWidth  = 595
+	pageHeight = 842
+)
+
+// font of the standard ones of every PDF reader, nothing is embedded.
+type font int
+
+const (
+	regular font = iota
+	bold
+)
+
+// fonts by the name used in the layouts, the index is the resource name.
+var fonts = map[string]font{"regular": regular, "bold": bold}
+
+// baseFonts the standard font of each font.
+var baseFonts = [...]string{regular: "Helvetica", bold: "Helvetica-Bold"}
+
+// widths of the printable ASCII characters, from space, in thousandths of
+// the font size (Adobe's font metrics), the others are taken as digits.
+var widths = [...][95]int{
+	regular: {
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	},
+	bold: {
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	},
+}
+
+// textWidth returns the width in points of s, encoded, in f at size.
+func textWidth(s []byte, f font, size float64) float64 {
+	w := 0
+	for _, c := range s {
+		if c >= ' ' && c <= '~' {
+			w += widths[f][c-' ']
+		} else {
+			w += 556
+		}
+	}
+	return float64(w) * size / 1000
+}
+
+// encode converts s to the WinAnsi encoding of the standard fonts, the
+// characters it lacks are replaced by '?'.
+func encode(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '€':
+			b = append(b, 0x80)
+		case r >= ' ' && r <= '~', r >= 0xA0 && r <= 0xFF:
+			b = append(b, byte(r))
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+// literal returns b as a PDF string literal.
+func literal(b []byte) string {
+	var sb strings.Builder
+	sb.WriteByte('(')
+	for _, c := range b {
+		if c == '(' || c == ')' || c == '\\' {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(c)
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
+
+// num formats a coordinate or a size, to the hundredth.
+func num(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// document a PDF being drawn, a content stream per page.
+type document struct {
+	title   string
+	created time.Time
+	pages   []*bytes.Buffer
+}
+
+// page returns the content of the current page.
+func (d *document) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.addPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// addPage starts a new page.
+func (d *document) addPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+// text draws s with its baseline starting at x, y.
+func (d *document) text(x, y float64, f font, size float64, c color, s []byte) {
+	fmt.Fprintf(d.page(), "BT %s rg /F%d %s Tf %s %s Td %s Tj ET\n", c, f+1, num(size), num(x), num(y), literal(s))
+}
+
+// line draws a line from x1, y1 to x2, y2.
+func (d *document) line(x1, y1, x2, y2 float64, c color) {
+	fmt.Fprintf(d.page(), "%s RG 0.5 w %s %s m %s %s l S\n", c, num(x1), num(y1), num(x2), num(y2))
+}
+
+// rect fills the rectangle of lower left corner x, y.
+func (d *document) rect(x, y, w, h float64, c color) {
+	fmt.Fprintf(d.page(), "%s rg %s %s %s %s re f\n", c, num(x), num(y), num(w), num(h))
+}
+
+// color RGB, each component from 0 to 255.
+type color [3]uint8
+
+// String returns the components of the color operators.
+func (c color) String() string {
+	return fmt.Sprintf("%s %s %s", num(float64(c[0])/255), num(float64(c[1])/255), num(float64(c[2])/255))
+}
+
+// bytes serializes the document. The output only depends on what was
+// drawn, the title and the creation date, so it's the same on every run.
+func (d *document) bytes() []byte {
+	if len(d.pages) == 0 {
+		d.addPage()
+	}
+	var objs []string
+	add := func(s string) int {
+		objs = append(objs, s)
+		return len(objs)
+	}
+	catalog := add("") // the pages aren't numbered yet
+	pagesID := add("")
+	var fontRes strings.Builder
+	for f, name := range baseFonts {
+		id := add(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+		fmt.Fprintf(&fontRes, " /F%d %d 0 R", f+1, id)
+	}
+	info := add(fmt.Sprintf("<< /Title %s /Producer (genesis) /CreationDate (D:%s) >>",
+		literal(encode(d.title)), d.created.UTC().Format("20060102150405Z")))
+	var kids []string
+	for _, content := range d.pages {
+		stream := add(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.Bytes()))
+		id := add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources << /Font <<%s >> >> /Contents %d 0 R >>",
+			pagesID, pageWidth, pageHeight, fontRes.String(), stream))
+		kids = append(kids, fmt.Sprintf("%d 0 R", id))
+	}
+	objs[catalog-1] = fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID)
+	objs[pagesID-1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objs))
+	for i, o := range objs {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(objs)+1, catalog, info, xref)
+	return b.Bytes()
+}
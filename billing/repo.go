@@ -2,12 +2,20 @@ package billing
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/adrianolmedo/genesis"
+	"github.com/adrianolmedo/genesis/ledger"
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/pgsql"
 	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pborman/uuid"
 )
@@ -25,17 +33,24 @@ func NewRepo(db *pgxpool.Pool) *Repo {
 	}
 }
 
-// CreateInvoice creates a new invoice with its header and items.
-func (r *Repo) CreateInvoice(ctx context.Context, inv *Invoice) error {
+// CreateInvoice creates a new invoice with its header and items, numbered
+// with the next number of its series in numbers. The counter of the series
+// is incremented in the same transaction, so the numbers have no gaps.
+func (r *Repo) CreateInvoice(ctx context.Context, inv *Invoice, numbers NumberFormat) error {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback(ctx)
-		}
-	}()
+	defer tx.Rollback(ctx) // no-op after commit
+
+	if inv.Header.CreatedAt.IsZero() {
+		inv.Header.CreatedAt = time.Now()
+	}
+	seq, err := r.q.WithTx(tx).InvoiceSequenceNext(ctx, numbers.Series(inv.Header.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("invoice number: %w", err)
+	}
+	inv.Header.Number = numbers.Number(inv.Header.CreatedAt, seq)
 
 	// Create invoice header
 	if err := r.CreateHeader(ctx, tx, inv.Header); err != nil {
@@ -46,20 +61,76 @@ func (r *Repo) CreateInvoice(ctx context.Context, inv *Invoice) error {
 	if err := r.CreateItem(ctx, tx, inv.Header.ID, inv.Items); err != nil {
 		return fmt.Errorf("invoice items: %w", err)
 	}
+
+	// Create the tax breakdown
+	for _, t := range inv.Taxes {
+		err := r.q.WithTx(tx).InvoiceTaxCreate(ctx, dbgen.InvoiceTaxCreateParams{
+			InvoiceHeaderID: inv.Header.ID,
+			Category:        t.Category,
+			Name:            t.Name,
+			Rate:            int32(t.Rate),
+			Exempt:          t.Exempt,
+			Base:            t.Base,
+			Tax:             t.Tax,
+		})
+		if err != nil {
+			return fmt.Errorf("invoice taxes: %w", immutableErr(err))
+		}
+	}
 	return tx.Commit(ctx)
 }
 
-// CreateHeader creates a new invoice header in the database.
+// CreateHeader creates a new invoice header in the database, a draft if it
+// has no status.
 func (r *Repo) CreateHeader(ctx context.Context, tx pgx.Tx, m *InvoiceHeader) error {
 	m.UUID = genesis.NextUUID()
+	if m.Status == "" {
+		m.Status = StatusDraft
+	}
+	if m.TaxRounding == "" {
+		m.TaxRounding = RoundPerLine
+	}
+	if m.BaseCurrency == "" {
+		m.BaseCurrency = m.Currency
+	}
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now()
+	}
 	row, err := r.q.WithTx(tx).InvoiceHeaderCreate(ctx, dbgen.InvoiceHeaderCreateParams{
-		Uuid:     uuid.Parse(m.UUID),
-		ClientID: m.ClientID,
+		Uuid:             uuid.Parse(m.UUID),
+		Number:           m.Number,
+		ClientID:         m.ClientID,
+		Status:           string(m.Status),
+		Subtotal:         m.Subtotal,
+		Discount:         m.Discount,
+		Tax:              m.Tax,
+		Total:            m.Total,
+		Jurisdiction:     m.Jurisdiction,
+		PricesIncludeTax: m.PricesIncludeTax,
+		TaxRounding:      string(m.TaxRounding),
+		IdempotencyKey:   pgtype.Text{String: m.IdempotencyKey, Valid: m.IdempotencyKey != ""},
+		DueAt:            sql.NullTime{Time: m.DueAt, Valid: !m.DueAt.IsZero()},
+		BillToName:       m.BillTo.Name,
+		BillToEmail:      m.BillTo.Email,
+		BillToAddress:    m.BillTo.Address,
+		Currency:         string(m.Currency),
+		BaseCurrency:     string(m.BaseCurrency),
+		CreatedAt:        m.CreatedAt,
 	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.ConstraintName {
+		case "invoice_header_idempotency_key_uq":
+			return ErrInvoiceExists
+		case "invoice_header_client_id_fk":
+			return ErrCustomerNotFound
+		}
+	}
 	if err != nil {
 		return err
 	}
 	m.ID = row.ID
+	m.CreatedAt = row.CreatedAt
 	return nil
 }
 
@@ -69,16 +140,220 @@ func (r *Repo) CreateItem(ctx context.Context, tx pgx.Tx, headerID int64, items
 		row, err := r.q.WithTx(tx).InvoiceItemCreate(ctx, dbgen.InvoiceItemCreateParams{
 			InvoiceHeaderID: headerID,
 			ProductID:       items[i].ProductID,
+			ProductName:     items[i].ProductName,
+			Quantity:        int32(items[i].Quantity),
+			UnitPrice:       items[i].UnitPrice.Amount,
+			TaxCategory:     items[i].TaxCategory,
+			TaxName:         items[i].TaxName,
+			TaxRate:         int32(items[i].TaxRate),
+			TaxExempt:       items[i].TaxExempt,
+			Subtotal:        items[i].Subtotal,
+			Discount:        items[i].Discount,
+			Tax:             items[i].Tax,
+			Total:           items[i].Total,
 		})
 		if err != nil {
-			return err
+			return immutableErr(err)
 		}
 		items[i].ID = row.ID
+		items[i].InvoiceHeaderID = headerID
 		items[i].CreatedAt = row.CreatedAt
 	}
 	return nil
 }
 
+// ByID get an Invoice, header and items, by its ID.
+func (r *Repo) ByID(ctx context.Context, id int64) (*Invoice, error) {
+	row, err := r.q.InvoiceHeaderByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvoiceHeaderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	items, err := r.q.InvoiceItemByHeader(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	taxes, err := r.q.InvoiceTaxByHeader(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &Invoice{
+		Header: toDomainHeader(row),
+		Items:  toDomainItems(items, money.Currency(row.Currency)),
+		Taxes:  toDomainTaxLines(taxes),
+	}, nil
+}
+
+// InvoiceIDByKey returns the id of the invoice generated with an
+// idempotency key.
+func (r *Repo) InvoiceIDByKey(ctx context.Context, key string) (int64, error) {
+	id, err := r.q.InvoiceHeaderIDByIdempotencyKey(ctx, pgtype.Text{String: key, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrInvoiceHeaderNotFound
+	}
+	return id, err
+}
+
+// List returns a paginated list of invoices, only their headers, matching
+// by.
+func (r *Repo) List(ctx context.Context, f pgsql.Filter, by ListFilter) (rows Invoices, totalRows int64, err error) {
+	count := dbgen.InvoiceHeaderListCountParams{
+		ClientID:    pgtype.Int8{Int64: by.ClientID, Valid: by.ClientID != 0},
+		Status:      pgtype.Text{String: string(by.Status), Valid: by.Status != ""},
+		CreatedFrom: sql.NullTime{Time: by.From, Valid: !by.From.IsZero()},
+		CreatedTo:   sql.NullTime{Time: by.To, Valid: !by.To.IsZero()},
+	}
+	rowsDB, err := r.q.InvoiceHeaderList(ctx, dbgen.InvoiceHeaderListParams{
+		ClientID:    count.ClientID,
+		Status:      count.Status,
+		CreatedFrom: count.CreatedFrom,
+		CreatedTo:   count.CreatedTo,
+		Sort:        f.Sort(),
+		Direction:   f.Direction(),
+		PageOffset:  int32(f.Offset()),
+		PageLimit:   int32(f.Limit()),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	totalRows, err = r.q.InvoiceHeaderListCount(ctx, count)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows = make(Invoices, 0, len(rowsDB))
+	for _, row := range rowsDB {
+		rows = append(rows, Invoice{Header: toDomainHeader(row)})
+	}
+	return rows, totalRows, nil
+}
+
+// Transition changes the status of an invoice to to and records the change
+// in its history. The header is locked while checking the transition, so
+// concurrent changes apply one after the other.
+func (r *Repo) Transition(ctx context.Context, id int64, to Status, c StatusChange) (*InvoiceHeader, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+	q := r.q.WithTx(tx)
+
+	row, err := q.InvoiceHeaderByIDForUpdate(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvoiceHeaderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	from := Status(row.Status)
+	if err := checkTransition(from, to); err != nil {
+		return nil, err
+	}
+	if to == StatusVoid {
+		// A credit note reversed part of it already, voiding would reverse
+		// that part twice.
+		credits, err := q.CreditNoteTotalsByInvoice(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if len(credits) > 0 {
+			return nil, ErrInvoiceCredited
+		}
+	}
+	row, err = setStatus(ctx, q, id, from, to, c)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, ledger.Err(err)
+	}
+	return toDomainHeader(row), nil
+}
+
+// setStatus changes the status of an invoice locked by the transaction of
+// q, records the change in its history and posts its issue or void to the
+// ledger. The exchange rate of the invoice is captured when it's issued,
+// the ledger is in its base currency.
+func setStatus(ctx context.Context, q *dbgen.Queries, id int64, from, to Status, c StatusChange) (dbgen.InvoiceHeader, error) {
+	if from == StatusDraft && to == StatusIssued {
+		row, err := q.InvoiceHeaderByID(ctx, id)
+		if err != nil {
+			return row, err
+		}
+		if err := captureRate(ctx, q, row, c.ChangedAt); err != nil {
+			return row, fmt.Errorf("invoice %d: %w", id, err)
+		}
+	}
+	row, err := q.InvoiceHeaderSetStatus(ctx, dbgen.InvoiceHeaderSetStatusParams{
+		Status:    string(to),
+		UpdatedAt: sql.NullTime{Time: c.ChangedAt, Valid: true},
+		ID:        id,
+	})
+	if err != nil {
+		return row, immutableErr(err)
+	}
+	err = q.InvoiceStatusHistoryCreate(ctx, dbgen.InvoiceStatusHistoryCreateParams{
+		InvoiceHeaderID:           id,
+		FromStatus:                string(from),
+		ToStatus:                  string(to),
+		ChangedByUserID:           nullID(c.By.UserID),
+		ChangedByServiceAccountID: nullID(c.By.ServiceAccountID),
+		Reason:                    c.Reason,
+		ChangedAt:                 c.ChangedAt,
+	})
+	if err != nil {
+		return row, fmt.Errorf("invoice status history: %w", err)
+	}
+	totals := Totals{Subtotal: row.Subtotal, Discount: row.Discount, Tax: row.Tax, Total: row.Total}
+	rate := invoiceRate(row.Currency, row.BaseCurrency, row.ExchangeRate.Int64)
+	var e *ledger.Entry
+	switch {
+	case from == StatusDraft && to == StatusIssued:
+		e, err = invoiceEntry(id, row.Number, totals, rate, c.ChangedAt)
+	case to == StatusVoid:
+		e, err = voidEntry(id, row.Number, totals, rate, c.ChangedAt)
+	}
+	if err == nil && e != nil {
+		err = ledger.Post(ctx, q, e)
+	}
+	if err != nil {
+		return row, fmt.Errorf("invoice %d ledger: %w", id, err)
+	}
+	return row, nil
+}
+
+// History returns the status changes of an invoice, oldest first.
+func (r *Repo) History(ctx context.Context, id int64) ([]StatusChange, error) {
+	if _, err := r.q.InvoiceHeaderByID(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvoiceHeaderNotFound
+		}
+		return nil, err
+	}
+	rows, err := r.q.InvoiceStatusHistoryByInvoice(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]StatusChange, 0, len(rows))
+	for _, row := range rows {
+		changes = append(changes, StatusChange{
+			ID:        row.ID,
+			InvoiceID: row.InvoiceHeaderID,
+			From:      Status(row.FromStatus),
+			To:        Status(row.ToStatus),
+			By: Actor{
+				UserID:           row.ChangedByUserID.Int64,
+				ServiceAccountID: row.ChangedByServiceAccountID.Int64,
+			},
+			Reason:    row.Reason,
+			ChangedAt: row.ChangedAt,
+		})
+	}
+	return changes, nil
+}
+
 // DeleteAll delete all invoice headers (permanantly).
 func (r *Repo) DeleteAll(ctx context.Context) error {
 	err := r.q.InvoiceHeaderDeleteAll(ctx)
@@ -97,3 +372,99 @@ func (r *Repo) DeleteAllItems(ctx context.Context) error {
 	}
 	return nil
 }
+
+// immutableErrCode is raised by the database when an issued invoice would
+// be modified.
+const immutableErrCode = "GN001"
+
+// immutableErr maps the error of the database rejecting a change to an
+// issued invoice to ErrInvoiceImmutable.
+func immutableErr(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == immutableErrCode {
+		return fmt.Errorf("%w: %s", ErrInvoiceImmutable, pgErr.Message)
+	}
+	return err
+}
+
+// nullID converts an ID to a nullable column, 0 is NULL.
+func nullID(id int64) pgtype.Int8 {
+	return pgtype.Int8{Int64: id, Valid: id != 0}
+}
+
+// toDomainHeader converts a dbgen.InvoiceHeader to an InvoiceHeader.
+func toDomainHeader(row dbgen.InvoiceHeader) *InvoiceHeader {
+	return &InvoiceHeader{
+		ID:       row.ID,
+		UUID:     row.Uuid.String(),
+		Number:   row.Number,
+		ClientID: row.ClientID,
+		Status:   Status(row.Status),
+		Totals: Totals{
+			Subtotal: row.Subtotal,
+			Discount: row.Discount,
+			Tax:      row.Tax,
+			Total:    row.Total,
+		},
+		BillTo: BillTo{
+			Name:    row.BillToName,
+			Email:   row.BillToEmail,
+			Address: row.BillToAddress,
+		},
+		Jurisdiction:     row.Jurisdiction,
+		PricesIncludeTax: row.PricesIncludeTax,
+		TaxRounding:      TaxRounding(row.TaxRounding),
+		IdempotencyKey:   row.IdempotencyKey.String,
+		DueAt:            row.DueAt.Time,
+		Currency:         money.Currency(row.Currency),
+		BaseCurrency:     money.Currency(row.BaseCurrency),
+		ExchangeRate:     invoiceRate(row.Currency, row.BaseCurrency, row.ExchangeRate.Int64),
+		CreatedAt:        row.CreatedAt,
+		UpdatedAt:        row.UpdatedAt.Time,
+	}
+}
+
+// toDomainItems converts dbgen.InvoiceItem rows, of an invoice in
+// currency, to an ItemList.
+func toDomainItems(rows []dbgen.InvoiceItem, currency money.Currency) ItemList {
+	items := make(ItemList, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, InvoiceItem{
+			ID:              row.ID,
+			InvoiceHeaderID: row.InvoiceHeaderID,
+			ProductID:       row.ProductID,
+			ProductName:     row.ProductName,
+			Quantity:        int(row.Quantity),
+			UnitPrice:       money.New(row.UnitPrice, currency),
+			TaxCategory:     row.TaxCategory,
+			TaxName:         row.TaxName,
+			TaxRate:         int(row.TaxRate),
+			TaxExempt:       row.TaxExempt,
+			Totals: Totals{
+				Subtotal: row.Subtotal,
+				Discount: row.Discount,
+				Tax:      row.Tax,
+				Total:    row.Total,
+			},
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt.Time,
+		})
+	}
+	return items
+}
+
+// toDomainTaxLines converts dbgen.InvoiceTax rows to a tax breakdown.
+func toDomainTaxLines(rows []dbgen.InvoiceTax) []TaxLine {
+	lines := make([]TaxLine, 0, len(rows))
+	for _, row := range rows {
+		lines = append(lines, TaxLine{
+			Category: row.Category,
+			Name:     row.Name,
+			Rate:     int(row.Rate),
+			Exempt:   row.Exempt,
+			Base:     row.Base,
+			Tax:      row.Tax,
+		})
+	}
+	return lines
+}
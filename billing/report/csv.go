@@ -0,0 +1,64 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WriteCSV writes the aging as CSV, a row by client and the total last.
+func (a *Aging) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"client_id", "name", "invoices", "current", "1-30", "31-60", "61-90", "90+", "total"})
+	for _, r := range a.Rows {
+		cw.Write(append([]string{itoa(r.ClientID), r.Name, strconv.Itoa(r.Invoices)}, r.Buckets.fields()...))
+	}
+	cw.Write(append([]string{"", "Total", ""}, a.Total.fields()...))
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteCSV writes the revenue by product as CSV, the total last.
+func (p *ProductsReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"product_id", "product", "quantity", "net", "tax", "total"})
+	for _, r := range p.Rows {
+		cw.Write(append([]string{itoa(r.ProductID), r.ProductName, itoa(r.Quantity)}, r.Amounts.fields()...))
+	}
+	cw.Write(append([]string{"", "Total", ""}, p.Total.fields()...))
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteCSV writes the revenue by month as CSV, the total last.
+func (m *MonthsReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"month", "invoices", "credit_notes", "invoiced", "credited", "net", "tax", "total"})
+	for _, r := range m.Rows {
+		cw.Write(r.fields(r.Month))
+	}
+	cw.Write(m.Total.fields("Total"))
+	cw.Flush()
+	return cw.Error()
+}
+
+// Filename the name of the CSV file of a report, with the date of at.
+func Filename(name string, at time.Time) string {
+	return name + "-" + at.Format(time.DateOnly) + ".csv"
+}
+
+func (b Buckets) fields() []string {
+	return []string{itoa(b.Current), itoa(b.Days1To30), itoa(b.Days31To60), itoa(b.Days61To90), itoa(b.Over90), itoa(b.Total)}
+}
+
+func (r MonthRevenue) fields(month string) []string {
+	return append([]string{month, strconv.Itoa(r.Invoices), strconv.Itoa(r.CreditNotes), itoa(r.Invoiced), itoa(r.Credited)},
+		r.Amounts.fields()...)
+}
+
+func (a Amounts) fields() []string {
+	return []string{itoa(a.Net), itoa(a.Tax), itoa(a.Total)}
+}
+
+func itoa(n int64) string { return strconv.FormatInt(n, 10) }
@@ -0,0 +1,115 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Repo reads the reports from the billing tables.
+type Repo struct {
+	q *dbgen.Queries // methods generated by sqlc
+}
+
+// NewRepo creates a new reports repository instance.
+func NewRepo(db dbgen.DBTX) *Repo {
+	return &Repo{
+		q: dbgen.New(db),
+	}
+}
+
+// Aging returns what each client owed at at in currency.
+func (r *Repo) Aging(ctx context.Context, at time.Time, clientID int64, currency money.Currency) ([]AgingRow, error) {
+	rows, err := r.q.ReportAging(ctx, dbgen.ReportAgingParams{
+		At:       at,
+		Currency: string(currency),
+		ClientID: nullID(clientID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]AgingRow, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, AgingRow{
+			ClientID: row.ClientID,
+			Name:     row.Name,
+			Invoices: int(row.Invoices),
+			Buckets: Buckets{
+				Current:    row.NotDue,
+				Days1To30:  row.Overdue130,
+				Days31To60: row.Overdue3160,
+				Days61To90: row.Overdue6190,
+				Over90:     row.Overdue90,
+				Total:      row.Total,
+			},
+		})
+	}
+	return out, nil
+}
+
+// RevenueByProduct returns the revenue of each product.
+func (r *Repo) RevenueByProduct(ctx context.Context, f Filter) ([]ProductRevenue, error) {
+	rows, err := r.q.ReportRevenueByProduct(ctx, dbgen.ReportRevenueByProductParams{
+		Currency:    string(f.Currency),
+		CreatedFrom: nullTime(f.From),
+		CreatedTo:   nullTime(f.To),
+		ClientID:    nullID(f.ClientID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ProductRevenue, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, ProductRevenue{
+			ProductID:   row.ProductID,
+			ProductName: row.ProductName,
+			Quantity:    row.Quantity,
+			Amounts:     Amounts{Net: row.Net, Tax: row.Tax, Total: row.Total},
+		})
+	}
+	return out, nil
+}
+
+// RevenueByMonth returns the revenue of each month.
+func (r *Repo) RevenueByMonth(ctx context.Context, f Filter) ([]MonthRevenue, error) {
+	rows, err := r.q.ReportRevenueByMonth(ctx, dbgen.ReportRevenueByMonthParams{
+		Currency:    string(f.Currency),
+		CreatedFrom: nullTime(f.From),
+		CreatedTo:   nullTime(f.To),
+		ClientID:    nullID(f.ClientID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MonthRevenue, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, MonthRevenue{
+			Month:       row.Month,
+			Invoices:    int(row.Invoices),
+			CreditNotes: int(row.CreditNotes),
+			Invoiced:    row.Invoiced,
+			Credited:    row.Credited,
+			Amounts: Amounts{
+				Net:   row.Invoiced - row.Credited,
+				Tax:   row.Tax,
+				Total: row.Total,
+			},
+		})
+	}
+	return out, nil
+}
+
+// nullID the zero id doesn't filter.
+func nullID(id int64) pgtype.Int8 {
+	return pgtype.Int8{Int64: id, Valid: id != 0}
+}
+
+// nullTime the zero time doesn't filter.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,201 @@
+// Package report computes the receivables aging and the revenue reports
+// finance asks for, from the invoices, the credit notes and the payments.
+// The amounts are in minor units, as in billing, of a currency: the
+// documents in other currencies aren't added up with them.
+package report
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+)
+
+// ErrInvalidDateRange the start of a range is inclusive and its end
+// exclusive, an empty range has nothing to report.
+var ErrInvalidDateRange = errors.New("the start of the date range must be before its end")
+
+// Filter of the revenue reports, the zero values don't filter.
+type Filter struct {
+	ClientID int64
+	From     time.Time      // inclusive
+	To       time.Time      // exclusive
+	Currency money.Currency // of the documents, the default one if empty
+}
+
+func (f Filter) validate() error {
+	if !f.Currency.Valid() {
+		return money.ErrUnknownCurrency
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return ErrInvalidDateRange
+	}
+	return nil
+}
+
+// Buckets the amount due by the days it's overdue.
+type Buckets struct {
+	Current    int64 // not due yet
+	Days1To30  int64
+	Days31To60 int64
+	Days61To90 int64
+	Over90     int64
+	Total      int64
+}
+
+func (b *Buckets) add(o Buckets) {
+	b.Current += o.Current
+	b.Days1To30 += o.Days1To30
+	b.Days31To60 += o.Days31To60
+	b.Days61To90 += o.Days61To90
+	b.Over90 += o.Over90
+	b.Total += o.Total
+}
+
+// AgingRow what a client owed.
+type AgingRow struct {
+	ClientID int64
+	Name     string // billed to, of its last invoice
+	Invoices int    // issued and unpaid
+	Buckets
+}
+
+// Aging the receivables at a time, by client, the clients who owed the
+// most first. The credit notes and payments after it don't count.
+type Aging struct {
+	At       time.Time
+	Currency money.Currency
+	Rows     []AgingRow
+	Total    Buckets
+}
+
+// Amounts of revenue, Net without the taxes.
+type Amounts struct {
+	Net   int64
+	Tax   int64
+	Total int64
+}
+
+func (a *Amounts) add(o Amounts) {
+	a.Net += o.Net
+	a.Tax += o.Tax
+	a.Total += o.Total
+}
+
+func newAging(at time.Time, currency money.Currency, rows []AgingRow) *Aging {
+	a := &Aging{At: at, Currency: currency, Rows: rows}
+	for _, r := range rows {
+		a.Total.add(r.Buckets)
+	}
+	return a
+}
+
+// ProductRevenue what a product was invoiced, less what was credited.
+type ProductRevenue struct {
+	ProductID   int64
+	ProductName string // of its last document
+	Quantity    int64
+	Amounts
+}
+
+// ProductsReport the revenue by product, the best selling first.
+type ProductsReport struct {
+	Filter Filter
+	Rows   []ProductRevenue
+	Total  Amounts
+}
+
+func newProductsReport(f Filter, rows []ProductRevenue) *ProductsReport {
+	p := &ProductsReport{Filter: f, Rows: rows}
+	for _, r := range rows {
+		p.Total.add(r.Amounts)
+	}
+	return p
+}
+
+// MonthRevenue what was invoiced and credited in a month.
+type MonthRevenue struct {
+	Month       string // 2006-01, in UTC
+	Invoices    int
+	CreditNotes int
+	Invoiced    int64 // net
+	Credited    int64 // net
+	Amounts           // of the invoices less the credit notes
+}
+
+// MonthsReport the revenue by month, the months without documents are
+// omitted.
+type MonthsReport struct {
+	Filter Filter
+	Rows   []MonthRevenue
+	Total  MonthRevenue // without Month
+}
+
+func newMonthsReport(f Filter, rows []MonthRevenue) *MonthsReport {
+	m := &MonthsReport{Filter: f, Rows: rows}
+	for _, r := range rows {
+		m.Total.Invoices += r.Invoices
+		m.Total.CreditNotes += r.CreditNotes
+		m.Total.Invoiced += r.Invoiced
+		m.Total.Credited += r.Credited
+		m.Total.add(r.Amounts)
+	}
+	return m
+}
+
+// Service computes the reports.
+type Service struct {
+	repo     *Repo
+	currency money.Currency // of the reports asked without one
+	now      func() time.Time
+}
+
+// NewService returns the reports of repo, in currency by default.
+func NewService(repo *Repo, currency money.Currency) *Service {
+	return &Service{repo: repo, currency: currency, now: time.Now}
+}
+
+// Aging returns the receivables in currency, the default one if empty, at
+// at, now if zero, of a client if clientID isn't 0.
+func (s *Service) Aging(ctx context.Context, at time.Time, clientID int64, currency money.Currency) (*Aging, error) {
+	currency = cmp.Or(currency, s.currency)
+	if !currency.Valid() {
+		return nil, money.ErrUnknownCurrency
+	}
+	if at.IsZero() {
+		at = s.now()
+	}
+	rows, err := s.repo.Aging(ctx, at, clientID, currency)
+	if err != nil {
+		return nil, err
+	}
+	return newAging(at, currency, rows), nil
+}
+
+// RevenueByProduct returns the revenue of each product in the range of f.
+func (s *Service) RevenueByProduct(ctx context.Context, f Filter) (*ProductsReport, error) {
+	f.Currency = cmp.Or(f.Currency, s.currency)
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.RevenueByProduct(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	return newProductsReport(f, rows), nil
+}
+
+// RevenueByMonth returns the revenue of each month in the range of f.
+func (s *Service) RevenueByMonth(ctx context.Context, f Filter) (*MonthsReport, error) {
+	f.Currency = cmp.Or(f.Currency, s.currency)
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.RevenueByMonth(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	return newMonthsReport(f, rows), nil
+}
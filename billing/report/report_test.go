@@ -0,0 +1,103 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+)
+
+func TestFilterValidate(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tt := []struct {
+		name    string
+		f       Filter
+		wantErr error
+	}{
+		{name: "no-range", f: Filter{Currency: "EUR"}},
+		{name: "only-from", f: Filter{From: day, Currency: "EUR"}},
+		{name: "only-to", f: Filter{To: day, Currency: "EUR"}},
+		{name: "a-month", f: Filter{From: day, To: day.AddDate(0, 1, 0), Currency: "EUR"}},
+		{name: "empty-range", f: Filter{From: day, To: day, Currency: "EUR"}, wantErr: ErrInvalidDateRange},
+		{name: "reversed-range", f: Filter{From: day, To: day.AddDate(0, 0, -1), Currency: "EUR"}, wantErr: ErrInvalidDateRange},
+		{name: "unknown-currency", f: Filter{Currency: "XXX"}, wantErr: money.ErrUnknownCurrency},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.f.validate(); !errors.Is(err, tc.wantErr) {
+				t.Errorf("want error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestAgingCSV(t *testing.T) {
+	a := newAging(time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), "EUR", []AgingRow{
+		{ClientID: 2, Name: "Gómez, Luis", Invoices: 2, Buckets: Buckets{Days31To60: 1500, Over90: 500, Total: 2000}},
+		{ClientID: 1, Name: "Ana Pérez", Invoices: 1, Buckets: Buckets{Current: 1200, Total: 1200}},
+	})
+	want := Buckets{Current: 1200, Days31To60: 1500, Over90: 500, Total: 3200}
+	if a.Total != want {
+		t.Errorf("want total %+v, got %+v", want, a.Total)
+	}
+	var buf bytes.Buffer
+	if err := a.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	wantCSV := "client_id,name,invoices,current,1-30,31-60,61-90,90+,total\n" +
+		"2,\"Gómez, Luis\",2,0,0,1500,0,500,2000\n" +
+		"1,Ana Pérez,1,1200,0,0,0,0,1200\n" +
+		",Total,,1200,0,1500,0,500,3200\n"
+	if got := buf.String(); got != wantCSV {
+		t.Errorf("want csv\n%s\ngot\n%s", wantCSV, got)
+	}
+	if got := Filename("aging", a.At); got != "aging-2024-03-31.csv" {
+		t.Errorf("want aging-2024-03-31.csv, got %s", got)
+	}
+}
+
+func TestProductsReportCSV(t *testing.T) {
+	p := newProductsReport(Filter{}, []ProductRevenue{
+		{ProductID: 1, ProductName: "Coca-Cola", Quantity: 10, Amounts: Amounts{Net: 3000, Tax: 480, Total: 3480}},
+		{ProductID: 2, ProductName: "Big-Cola", Quantity: -1, Amounts: Amounts{Net: -200, Tax: -32, Total: -232}},
+	})
+	want := Amounts{Net: 2800, Tax: 448, Total: 3248}
+	if p.Total != want {
+		t.Errorf("want total %+v, got %+v", want, p.Total)
+	}
+	var buf bytes.Buffer
+	if err := p.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	wantCSV := "product_id,product,quantity,net,tax,total\n" +
+		"1,Coca-Cola,10,3000,480,3480\n" +
+		"2,Big-Cola,-1,-200,-32,-232\n" +
+		",Total,,2800,448,3248\n"
+	if got := buf.String(); got != wantCSV {
+		t.Errorf("want csv\n%s\ngot\n%s", wantCSV, got)
+	}
+}
+
+func TestMonthsReportCSV(t *testing.T) {
+	m := newMonthsReport(Filter{}, []MonthRevenue{
+		{Month: "2024-01", Invoices: 2, Invoiced: 5000, Amounts: Amounts{Net: 5000, Tax: 800, Total: 5800}},
+		{Month: "2024-02", Invoices: 1, CreditNotes: 1, Invoiced: 1000, Credited: 300, Amounts: Amounts{Net: 700, Tax: 112, Total: 812}},
+	})
+	want := MonthRevenue{Invoices: 3, CreditNotes: 1, Invoiced: 6000, Credited: 300, Amounts: Amounts{Net: 5700, Tax: 912, Total: 6612}}
+	if m.Total != want {
+		t.Errorf("want total %+v, got %+v", want, m.Total)
+	}
+	var buf bytes.Buffer
+	if err := m.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	wantCSV := "month,invoices,credit_notes,invoiced,credited,net,tax,total\n" +
+		"2024-01,2,0,5000,0,5000,800,5800\n" +
+		"2024-02,1,1,1000,300,700,112,812\n" +
+		"Total,3,1,6000,300,5700,912,6612\n"
+	if got := buf.String(); got != wantCSV {
+		t.Errorf("want csv\n%s\ngot\n%s", wantCSV, got)
+	}
+}
@@ -0,0 +1,42 @@
+package billing
+
+import (
+	"context"
+	"time"
+
+	"github.com/adrianolmedo/genesis/logger"
+)
+
+// Scheduler renews the subscriptions due in the background of the process.
+// Every replica can run one, the subscriptions are locked while renewed
+// and each period is invoiced once.
+type Scheduler struct {
+	Service  *Service
+	Interval time.Duration
+}
+
+// Run renews the subscriptions due at once and then every Interval, until
+// ctx is done.
+func (sc Scheduler) Run(ctx context.Context) {
+	sc.renew(ctx, time.Now())
+	t := time.NewTicker(sc.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			sc.renew(ctx, now)
+		}
+	}
+}
+
+func (sc Scheduler) renew(ctx context.Context, now time.Time) {
+	renewals, err := sc.Service.RenewDue(ctx, now)
+	for _, r := range renewals {
+		logger.Info("subscription renewed", "subscription", r.Subscription.ID, "period", r.Period, "invoice", r.Invoice.Header.ID)
+	}
+	if err != nil {
+		logger.Error("subscription renewal failed", "err", err.Error())
+	}
+}
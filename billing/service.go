@@ -1,26 +1,621 @@
 package billing
 
-import "context"
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/pgsql"
+)
 
 type Service struct {
 	repo *Repo
+	opts Options
+}
+
+// Options of the billing Service.
+type Options struct {
+	// InvoiceNumbers is the format of the invoice numbers.
+	InvoiceNumbers NumberFormat
+
+	// CreditNoteNumbers is the format of the credit note numbers, a
+	// series of its own.
+	CreditNoteNumbers NumberFormat
+
+	// Jurisdiction whose tax rates apply to the invoices that don't name
+	// one.
+	Jurisdiction string
+
+	// PricesIncludeTax tells if the prices of the products include the tax.
+	PricesIncludeTax bool
+
+	// TaxRounding where the tax of the invoices is rounded.
+	TaxRounding TaxRounding
+
+	// PaymentTermsDays is how many days after their date the invoices are
+	// due, of the clients without payment terms.
+	PaymentTermsDays int
+
+	// Currency of the books, the base currency of the invoices, and of
+	// the invoices whose items have no currency.
+	Currency money.Currency
+
+	// DraftRenewals keeps the invoices of the subscriptions renewed as
+	// drafts, to be reviewed and issued by hand, instead of issuing them.
+	DraftRenewals bool
+
+	// Customers reads the clients the invoices are billed to. Without it
+	// the clients aren't checked nor copied to the invoices.
+	Customers Customers
 }
 
-func NewService(r *Repo) *Service {
-	return &Service{repo: r}
+// NewService returns the billing Service.
+func NewService(r *Repo, opts Options) *Service {
+	return &Service{repo: r, opts: opts}
 }
 
 func (s Service) Generate(ctx context.Context, inv *Invoice) error {
-	err := generateInvoice(inv)
+	if err := s.prepare(ctx, inv); err != nil {
+		return err
+	}
+	return s.repo.CreateInvoice(ctx, inv, s.opts.InvoiceNumbers)
+}
+
+// Preview computes the taxes and totals of an invoice as Generate does,
+// without creating it.
+func (s Service) Preview(ctx context.Context, inv *Invoice) error {
+	return s.prepare(ctx, inv)
+}
+
+// prepare sets the defaults of the header of a new invoice, billed to its
+// client in the currency of its items and due by its payment terms, taxes
+// its items by the rules of its client and jurisdiction and computes the
+// totals.
+func (s Service) prepare(ctx context.Context, inv *Invoice) error {
+	h := inv.Header
+	if s.opts.Customers != nil {
+		c, err := s.customer(ctx, h.ClientID)
+		if err != nil {
+			return err
+		}
+		if h.BillTo, err = c.billable(); err != nil {
+			return err
+		}
+	}
+	if h.Jurisdiction == "" {
+		h.Jurisdiction = s.opts.Jurisdiction
+	}
+	h.Currency = cmp.Or(h.Currency, inv.Items.currency(), s.opts.Currency)
+	h.BaseCurrency = cmp.Or(s.opts.Currency, h.Currency)
+	h.PricesIncludeTax = s.opts.PricesIncludeTax
+	h.TaxRounding = cmp.Or(s.opts.TaxRounding, RoundPerLine)
+	if h.CreatedAt.IsZero() {
+		h.CreatedAt = time.Now()
+	}
+	if h.DueAt.IsZero() {
+		terms, err := s.PaymentTerms(ctx, h.ClientID)
+		if err != nil {
+			return err
+		}
+		h.DueAt = h.CreatedAt.AddDate(0, 0, terms.NetDays)
+	}
+	rules, err := s.repo.TaxRules(ctx, h.ClientID, h.Jurisdiction)
 	if err != nil {
 		return err
 	}
-	return s.repo.CreateInvoice(ctx, inv)
+	return generateInvoice(inv, rules)
 }
 
-func generateInvoice(inv *Invoice) error {
+// generateInvoice taxes the items by the rules and computes the totals of
+// a new invoice.
+func generateInvoice(inv *Invoice, rules TaxRules) error {
 	if inv.Items.IsEmpty() {
 		return ErrItemListCantBeEmpty
 	}
+	if err := inv.setCurrency(); err != nil {
+		return err
+	}
+	inv.Header.Status = StatusDraft
+	if err := rules.apply(inv); err != nil {
+		return err
+	}
+	return inv.computeTotals()
+}
+
+// Find an Invoice, header and items, by its ID.
+func (s Service) Find(ctx context.Context, id int64) (*Invoice, error) {
+	return s.repo.ByID(ctx, id)
+}
+
+// List get a page of invoices matching by, only their headers.
+func (s Service) List(ctx context.Context, f pgsql.Filter, by ListFilter) (rows Invoices, total int64, err error) {
+	if err := validateList(f, by); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.List(ctx, f, by)
+}
+
+// Issue a draft invoice, it can't be modified anymore.
+func (s Service) Issue(ctx context.Context, by Actor, id int64, reason string) (*InvoiceHeader, error) {
+	return s.transition(ctx, by, id, StatusIssued, reason)
+}
+
+// Void cancels an issued invoice, it's kept but has no effect.
+func (s Service) Void(ctx context.Context, by Actor, id int64, reason string) (*InvoiceHeader, error) {
+	return s.transition(ctx, by, id, StatusVoid, reason)
+}
+
+// Pay records a payment of the client of the invoice id. Without
+// allocations the payment is applied to that invoice, else it's allocated
+// as given to invoices of the same client. What isn't allocated is credit
+// of the client. The invoices paid in full or in part change to paid or
+// partially paid.
+func (s Service) Pay(ctx context.Context, by Actor, id int64, p *Payment) error {
+	if err := validatePayment(p); err != nil {
+		return err
+	}
+	invoices := []int64{id}
+	for _, other := range p.invoiceIDs() {
+		if other != id {
+			invoices = append(invoices, other)
+		}
+	}
+	p.By = by
+	p.CreatedAt = time.Now()
+	return s.repo.CreatePayment(ctx, p, invoices)
+}
+
+// Balance returns what a client has been invoiced, has paid and still owes,
+// and the credit it holds, in a currency, the base one if empty.
+func (s Service) Balance(ctx context.Context, clientID int64, currency money.Currency) (*Balance, error) {
+	currency = cmp.Or(currency, s.opts.Currency)
+	if !currency.Valid() {
+		return nil, money.ErrUnknownCurrency
+	}
+	return s.repo.Balance(ctx, clientID, currency)
+}
+
+// Credit reverses the invoice id, in full if cn has no items, else the
+// quantities of the invoice items given. It can't credit more than what's
+// left of the invoice.
+func (s Service) Credit(ctx context.Context, by Actor, id int64, cn *CreditNote) error {
+	if cn.Reason == "" {
+		return ErrCreditReasonCantBeEmpty
+	}
+	cn.InvoiceID = id
+	cn.By = by
+	cn.CreatedAt = time.Now()
+	return s.repo.CreateCreditNote(ctx, cn, s.opts.CreditNoteNumbers)
+}
+
+// FindCreditNote get a CreditNote, with its items, by its ID.
+func (s Service) FindCreditNote(ctx context.Context, id int64) (*CreditNote, error) {
+	return s.repo.CreditNoteByID(ctx, id)
+}
+
+// CreditNotes returns the credit notes of an invoice, oldest first.
+func (s Service) CreditNotes(ctx context.Context, invoiceID int64) ([]CreditNote, error) {
+	return s.repo.CreditNotesByInvoice(ctx, invoiceID)
+}
+
+// CreateTaxCategory creates a category to tax products alike.
+func (s Service) CreateTaxCategory(ctx context.Context, c *TaxCategory) error {
+	if c.Code == "" {
+		return ErrTaxCategoryCodeCantBeEmpty
+	}
+	if c.Name == "" {
+		return ErrTaxNameCantBeEmpty
+	}
+	return s.repo.CreateTaxCategory(ctx, c)
+}
+
+// TaxCategories returns all the tax categories.
+func (s Service) TaxCategories(ctx context.Context) ([]TaxCategory, error) {
+	return s.repo.TaxCategories(ctx)
+}
+
+// CreateTaxRate creates the rate of a category in a jurisdiction for a
+// period, that can't overlap another one of them.
+func (s Service) CreateTaxRate(ctx context.Context, t *TaxRate) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+	return s.repo.CreateTaxRate(ctx, t)
+}
+
+// TaxRates returns all the tax rates.
+func (s Service) TaxRates(ctx context.Context) ([]TaxRate, error) {
+	return s.repo.TaxRates(ctx)
+}
+
+// CreateTaxExemption exempts a client of the taxes of a category, or of
+// all of them, in a jurisdiction for a period.
+func (s Service) CreateTaxExemption(ctx context.Context, e *TaxExemption) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	return s.repo.CreateTaxExemption(ctx, e)
+}
+
+// TaxExemptions returns the tax exemptions of a client.
+func (s Service) TaxExemptions(ctx context.Context, clientID int64) ([]TaxExemption, error) {
+	return s.repo.TaxExemptions(ctx, clientID)
+}
+
+// CreateExchangeRate creates the rate of a currency to another one from a
+// date, until the next one of the pair.
+func (s Service) CreateExchangeRate(ctx context.Context, e *ExchangeRate) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	return s.repo.CreateExchangeRate(ctx, e)
+}
+
+// ExchangeRates returns all the exchange rates.
+func (s Service) ExchangeRates(ctx context.Context) ([]ExchangeRate, error) {
+	return s.repo.ExchangeRates(ctx)
+}
+
+// History returns the status changes of an invoice, oldest first.
+func (s Service) History(ctx context.Context, id int64) ([]StatusChange, error) {
+	return s.repo.History(ctx, id)
+}
+
+// transition changes the status of an invoice to to, returns a
+// *TransitionError if the transition table doesn't allow it.
+func (s Service) transition(ctx context.Context, by Actor, id int64, to Status, reason string) (*InvoiceHeader, error) {
+	return s.repo.Transition(ctx, id, to, StatusChange{
+		By:        by,
+		Reason:    reason,
+		ChangedAt: time.Now(),
+	})
+}
+
+// validateList checks the sort field and the filter of a list.
+func validateList(f pgsql.Filter, by ListFilter) error {
+	if !slices.Contains(sortFields, f.Sort()) {
+		return ErrInvalidSort
+	}
+	if by.Status != "" {
+		if _, err := ParseStatus(string(by.Status)); err != nil {
+			return err
+		}
+	}
+	if !by.From.IsZero() && !by.To.IsZero() && !by.From.Before(by.To) {
+		return ErrInvalidDateRange
+	}
 	return nil
 }
+
+// CreatePlan creates a subscription plan of products.
+func (s Service) CreatePlan(ctx context.Context, p *Plan) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	return s.repo.CreatePlan(ctx, p)
+}
+
+// Plans returns all the subscription plans.
+func (s Service) Plans(ctx context.Context) ([]Plan, error) {
+	return s.repo.Plans(ctx)
+}
+
+// customer returns the customer of the client id, ErrCustomerNotFound if
+// it doesn't exist.
+func (s Service) customer(ctx context.Context, id int64) (*Customer, error) {
+	if id == 0 {
+		return nil, ErrClientIDCantBeEmpty
+	}
+	return s.opts.Customers.Customer(ctx, id)
+}
+
+// Subscribe subscribes a client to a plan from the anchor, now if it's
+// zero. Each period is invoiced when it starts, those already started if
+// the anchor is in the past.
+func (s Service) Subscribe(ctx context.Context, sub *Subscription) error {
+	if sub.ClientID == 0 {
+		return ErrClientIDCantBeEmpty
+	}
+	if s.opts.Customers != nil {
+		c, err := s.customer(ctx, sub.ClientID)
+		if err != nil {
+			return err
+		}
+		if _, err := c.billable(); err != nil {
+			return err
+		}
+	}
+	if sub.Quantity <= 0 || sub.Quantity > maxQuantity {
+		return ErrInvalidQuantity
+	}
+	plan, err := s.repo.Plan(ctx, sub.PlanID)
+	if err != nil {
+		return err
+	}
+	if sub.Anchor.IsZero() {
+		sub.Anchor = time.Now()
+	}
+	sub.Interval = plan.Interval
+	sub.Next = 0
+	sub.NextBillAt = sub.Anchor
+	return s.repo.CreateSubscription(ctx, sub)
+}
+
+// FindSubscription get a Subscription by its ID.
+func (s Service) FindSubscription(ctx context.Context, id int64) (*Subscription, error) {
+	return s.repo.Subscription(ctx, id)
+}
+
+// Subscriptions returns the subscriptions of a client.
+func (s Service) Subscriptions(ctx context.Context, clientID int64) ([]Subscription, error) {
+	return s.repo.Subscriptions(ctx, clientID)
+}
+
+// ChangeSubscription changes the plan, of the same interval, and quantity
+// of a subscription now. The rest of the period invoiced is prorated, the
+// adjustments returned are invoiced with the next period.
+func (s Service) ChangeSubscription(ctx context.Context, id, planID int64, quantity int) (*Subscription, []Adjustment, error) {
+	if quantity <= 0 || quantity > maxQuantity {
+		return nil, nil, ErrInvalidQuantity
+	}
+	return s.repo.ChangeSubscription(ctx, id, planID, quantity, time.Now())
+}
+
+// CancelSubscription cancels a subscription now, the period invoiced isn't
+// refunded.
+func (s Service) CancelSubscription(ctx context.Context, id int64) (*Subscription, error) {
+	return s.repo.CancelSubscription(ctx, id, time.Now())
+}
+
+// Renewal the invoice of a period of a subscription.
+type Renewal struct {
+	Subscription Subscription
+	Period       int
+	Start, End   time.Time
+	Invoice      *Invoice
+}
+
+// dueBatch is how many subscriptions are renewed in a run at most.
+const dueBatch = 100
+
+// RenewDue generates the invoices of the periods of the active
+// subscriptions started at now or before, one per period even if several
+// replicas renew at the same time. A subscription that fails doesn't stop
+// the others, the errors are returned with the renewals done.
+func (s Service) RenewDue(ctx context.Context, now time.Time) ([]Renewal, error) {
+	subs, err := s.repo.DueSubscriptions(ctx, now, dueBatch)
+	if err != nil {
+		return nil, err
+	}
+	var renewals []Renewal
+	var errs []error
+	for _, sub := range subs {
+		for {
+			var r Renewal
+			renewed, err := s.repo.RenewSubscription(ctx, sub.ID, now, func(sub Subscription, items []PlanItem, pending []Adjustment) (int64, int64, error) {
+				var carry int64
+				var err error
+				r, carry, err = s.renew(ctx, sub, items, pending, now)
+				if err != nil {
+					return 0, 0, err
+				}
+				return r.Invoice.Header.ID, carry, nil
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("subscription %d: %w", sub.ID, err))
+				break
+			}
+			if !renewed {
+				break // not due anymore or being renewed by another replica
+			}
+			renewals = append(renewals, r)
+		}
+	}
+	return renewals, errors.Join(errs...)
+}
+
+// renew generates the invoice of the next period of sub at now, once, and
+// issues it unless the renewals are kept as drafts.
+func (s Service) renew(ctx context.Context, sub Subscription, items []PlanItem, pending []Adjustment, now time.Time) (Renewal, int64, error) {
+	inv, carry, err := renewal(sub, sub.Next, items, pending)
+	if err != nil {
+		return Renewal{}, 0, err
+	}
+	inv.Header.CreatedAt = now
+	if s.opts.DraftRenewals {
+		inv, err = s.generateOnce(ctx, inv)
+	} else {
+		inv, err = s.issueOnce(ctx, inv, StatusChange{
+			Reason:    fmt.Sprintf("subscription %d, period %d", sub.ID, sub.Next),
+			ChangedAt: now,
+		})
+	}
+	if err != nil {
+		return Renewal{}, 0, err
+	}
+	start, end := sub.Period(sub.Next)
+	return Renewal{Subscription: sub, Period: sub.Next, Start: start, End: end, Invoice: inv}, carry, nil
+}
+
+// generateOnce generates inv, of an idempotency key. If it was generated
+// already, by a run that failed after, it returns that invoice.
+func (s Service) generateOnce(ctx context.Context, inv *Invoice) (*Invoice, error) {
+	err := s.Generate(ctx, inv)
+	if !errors.Is(err, ErrInvoiceExists) {
+		return inv, err
+	}
+	id, err := s.repo.InvoiceIDByKey(ctx, inv.Header.IdempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ByID(ctx, id)
+}
+
+// issueOnce generates inv once, like generateOnce, and issues it if it's
+// still a draft, e.g. if an attempt before failed to issue it.
+func (s Service) issueOnce(ctx context.Context, inv *Invoice, c StatusChange) (*Invoice, error) {
+	inv, err := s.generateOnce(ctx, inv)
+	if err != nil {
+		return nil, err
+	}
+	if inv.Header.Status != StatusDraft {
+		return inv, nil
+	}
+	h, err := s.repo.Transition(ctx, inv.Header.ID, StatusIssued, c)
+	if err != nil {
+		return nil, err
+	}
+	inv.Header = h
+	return inv, nil
+}
+
+// PreviewDue returns the invoices RenewDue would generate at now, of the
+// next period of each subscription due, computed but not created.
+func (s Service) PreviewDue(ctx context.Context, now time.Time) ([]Renewal, error) {
+	subs, err := s.repo.DueSubscriptions(ctx, now, dueBatch)
+	if err != nil {
+		return nil, err
+	}
+	renewals := make([]Renewal, 0, len(subs))
+	for _, sub := range subs {
+		plan, err := s.repo.Plan(ctx, sub.PlanID)
+		if err != nil {
+			return nil, fmt.Errorf("subscription %d: %w", sub.ID, err)
+		}
+		pending, err := s.repo.PendingAdjustments(ctx, sub.ID)
+		if err != nil {
+			return nil, fmt.Errorf("subscription %d: %w", sub.ID, err)
+		}
+		inv, _, err := renewal(sub, sub.Next, plan.Items, pending)
+		if err != nil {
+			return nil, fmt.Errorf("subscription %d: %w", sub.ID, err)
+		}
+		inv.Header.CreatedAt = now
+		if err := s.Preview(ctx, inv); err != nil {
+			return nil, fmt.Errorf("subscription %d: %w", sub.ID, err)
+		}
+		start, end := sub.Period(sub.Next)
+		renewals = append(renewals, Renewal{Subscription: sub, Period: sub.Next, Start: start, End: end, Invoice: inv})
+	}
+	return renewals, nil
+}
+
+// SetPaymentTerms sets the payment terms of a client, of its invoices
+// generated from now.
+func (s Service) SetPaymentTerms(ctx context.Context, t *PaymentTerms) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+	return s.repo.SetPaymentTerms(ctx, t)
+}
+
+// PaymentTerms returns the payment terms of a client, the default ones if
+// it has none.
+func (s Service) PaymentTerms(ctx context.Context, clientID int64) (*PaymentTerms, error) {
+	t, err := s.repo.PaymentTerms(ctx, clientID)
+	if errors.Is(err, ErrPaymentTermsNotFound) {
+		return &PaymentTerms{ClientID: clientID, NetDays: s.opts.PaymentTermsDays}, nil
+	}
+	return t, err
+}
+
+// CreateDunningStep adds a step to the dunning policy.
+func (s Service) CreateDunningStep(ctx context.Context, step *DunningStep) error {
+	if err := step.validate(); err != nil {
+		return err
+	}
+	return s.repo.CreateDunningStep(ctx, step)
+}
+
+// DunningSteps returns the steps of the dunning policy, by days after due.
+func (s Service) DunningSteps(ctx context.Context) ([]DunningStep, error) {
+	return s.repo.DunningSteps(ctx)
+}
+
+// DeleteDunningStep removes a step from the dunning policy.
+func (s Service) DeleteDunningStep(ctx context.Context, id int64) error {
+	return s.repo.DeleteDunningStep(ctx, id)
+}
+
+// Reminders returns the reminders of an invoice, the sent ones and those
+// to send.
+func (s Service) Reminders(ctx context.Context, invoiceID int64) ([]Reminder, error) {
+	inv, err := s.repo.ByID(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.repo.Reminders(ctx, invoiceID)
+	for i := range list {
+		list[i].Currency = inv.Header.Currency
+	}
+	return list, err
+}
+
+// QueueReminders queues a reminder of each invoice overdue at now, of the
+// last step of the dunning policy it reached. A step is queued once per
+// invoice, and the steps an invoice skipped, e.g. while no one ran this,
+// aren't. It returns how many were queued.
+func (s Service) QueueReminders(ctx context.Context, now time.Time) (int, error) {
+	list, err := s.repo.Overdue(ctx, now, dueBatch)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	var errs []error
+	for _, o := range list {
+		rem, err := o.reminder(now)
+		if err == nil && rem != nil {
+			var queued bool
+			if queued, err = s.repo.QueueReminder(ctx, rem); queued {
+				n++
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invoice %d: %w", o.invoice.ID, err))
+		}
+	}
+	return n, errors.Join(errs...)
+}
+
+// SendReminders sends the reminders queued through n, generating and
+// issuing the invoice of their late fee first, so it's owed and dunned as
+// any other. A reminder that fails is tried again
+// on the next run. The errors are returned with the reminders sent.
+func (s Service) SendReminders(ctx context.Context, now time.Time, n Notifier) ([]Reminder, error) {
+	ids, err := s.repo.PendingReminders(ctx, dueBatch)
+	if err != nil {
+		return nil, err
+	}
+	send := func(rem *Reminder) error {
+		if rem.LateFee > 0 && rem.FeeInvoiceID == 0 {
+			inv := feeInvoice(*rem)
+			inv.Header.CreatedAt = now
+			inv, err := s.issueOnce(ctx, inv, StatusChange{
+				Reason:    "late fee of invoice " + rem.InvoiceNumber,
+				ChangedAt: now,
+			})
+			if err != nil {
+				return fmt.Errorf("late fee: %w", err)
+			}
+			rem.FeeInvoiceID, rem.FeeInvoiceNumber = inv.Header.ID, inv.Header.Number
+		}
+		return n.Remind(ctx, *rem)
+	}
+	var sent []Reminder
+	var errs []error
+	for _, id := range ids {
+		rem, err := s.repo.SendReminder(ctx, id, now, send)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reminder %d: %w", id, err))
+			continue
+		}
+		if rem != nil && rem.Status == ReminderSent {
+			sent = append(sent, *rem)
+		}
+	}
+	return sent, errors.Join(errs...)
+}
@@ -1,8 +1,13 @@
 package billing
 
 import (
+	"errors"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/pgsql"
 )
 
 func TestGenerateInvoice(t *testing.T) {
@@ -19,7 +24,7 @@ func TestGenerateInvoice(t *testing.T) {
 					ClientID: 1,
 				},
 				Items: ItemList{
-					InvoiceItem{ProductID: 1},
+					InvoiceItem{ProductID: 1, Quantity: 2, UnitPrice: money.New(150, "EUR")},
 				},
 			},
 			errExpected:    false,
@@ -36,6 +41,33 @@ func TestGenerateInvoice(t *testing.T) {
 			errExpected:    true,
 			wantErrContain: "item list can't be empty",
 		},
+		{
+			name: "zero-quantity",
+			input: &Invoice{
+				Header: &InvoiceHeader{
+					ClientID: 1,
+				},
+				Items: ItemList{
+					InvoiceItem{ProductID: 1, UnitPrice: money.New(150, "EUR")},
+				},
+			},
+			errExpected:    true,
+			wantErrContain: "quantity must be greater than zero",
+		},
+		{
+			name: "currency-mismatch",
+			input: &Invoice{
+				Header: &InvoiceHeader{
+					ClientID: 1,
+				},
+				Items: ItemList{
+					InvoiceItem{ProductID: 1, Quantity: 1, UnitPrice: money.New(150, "EUR")},
+					InvoiceItem{ProductID: 2, Quantity: 1, UnitPrice: money.New(150, "USD")},
+				},
+			},
+			errExpected:    true,
+			wantErrContain: "the prices of the items must be in the currency of the invoice",
+		},
 		{
 			name: "nil-item-list",
 			input: &Invoice{
@@ -48,7 +80,7 @@ func TestGenerateInvoice(t *testing.T) {
 		},
 	}
 	for _, tc := range tt {
-		err := generateInvoice(tc.input)
+		err := generateInvoice(tc.input, TaxRules{})
 		if (err != nil) != tc.errExpected {
 			t.Fatalf("%s: unexpected error value %v", tc.name, err)
 		}
@@ -56,5 +88,38 @@ func TestGenerateInvoice(t *testing.T) {
 		if err != nil && !strings.Contains(err.Error(), tc.wantErrContain) {
 			t.Fatalf("want error string %q to contain %q", err.Error(), tc.wantErrContain)
 		}
+		if err == nil && tc.input.Header.Status != StatusDraft {
+			t.Errorf("%s: want status %s, got %s", tc.name, StatusDraft, tc.input.Header.Status)
+		}
+		if err == nil && tc.input.Header.Currency != "EUR" {
+			t.Errorf("%s: want currency EUR, got %s", tc.name, tc.input.Header.Currency)
+		}
+	}
+}
+
+func TestValidateList(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tt := []struct {
+		name    string
+		sort    string
+		by      ListFilter
+		wantErr error
+	}{
+		{name: "no-filter", sort: "created_at"},
+		{name: "all-filters", sort: "total", by: ListFilter{ClientID: 1, Status: StatusIssued, From: day, To: day.AddDate(0, 1, 0)}},
+		{name: "unknown-sort", sort: "password", wantErr: ErrInvalidSort},
+		{name: "unknown-status", sort: "id", by: ListFilter{Status: "lost"}, wantErr: ErrInvalidStatus},
+		{name: "empty-date-range", sort: "id", by: ListFilter{From: day, To: day}, wantErr: ErrInvalidDateRange},
+		{name: "open-date-range", sort: "id", by: ListFilter{From: day}},
+	}
+	for _, tc := range tt {
+		f, err := pgsql.NewFilter(0, 0, tc.sort, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = validateList(f, tc.by)
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: want error %v, got %v", tc.name, tc.wantErr, err)
+		}
 	}
 }
@@ -0,0 +1,97 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var (
+	ErrInvalidStatus     = errors.New("invalid invoice status")
+	ErrInvalidTransition = errors.New("invalid invoice status transition")
+	ErrInvoiceImmutable  = errors.New("only draft invoices can be modified")
+)
+
+// Status of an invoice.
+type Status string
+
+// Statuses of an invoice.
+const (
+	StatusDraft         Status = "draft"
+	StatusIssued        Status = "issued"
+	StatusPartiallyPaid Status = "partially_paid"
+	StatusPaid          Status = "paid"
+	StatusVoid          Status = "void"
+)
+
+// statuses known, to validate the input.
+var statuses = []Status{StatusDraft, StatusIssued, StatusPartiallyPaid, StatusPaid, StatusVoid}
+
+// transitions the statuses an invoice can change to from each status. Paid
+// and void invoices are final.
+var transitions = map[Status][]Status{
+	StatusDraft:         {StatusIssued},
+	StatusIssued:        {StatusPartiallyPaid, StatusPaid, StatusVoid},
+	StatusPartiallyPaid: {StatusPaid},
+}
+
+// ParseStatus returns the Status named s.
+func ParseStatus(s string) (Status, error) {
+	st := Status(s)
+	if !slices.Contains(statuses, st) {
+		return "", ErrInvalidStatus
+	}
+	return st, nil
+}
+
+// CanTransition reports whether an invoice in st can change to to.
+func (st Status) CanTransition(to Status) bool {
+	return slices.Contains(transitions[st], to)
+}
+
+// Editable reports whether the amounts and items of an invoice in st can
+// change, only drafts can, an issued invoice is kept as sent.
+func (st Status) Editable() bool {
+	return st == StatusDraft
+}
+
+// TransitionError an invoice can't change from its status to another, it
+// matches ErrInvalidTransition.
+type TransitionError struct {
+	From Status
+	To   Status
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%v: %s to %s", ErrInvalidTransition, e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// checkTransition returns a *TransitionError if from can't change to to.
+func checkTransition(from, to Status) error {
+	if !from.CanTransition(to) {
+		return &TransitionError{From: from, To: to}
+	}
+	return nil
+}
+
+// Actor who changes an invoice, a user or a service account.
+type Actor struct {
+	UserID           int64 // 0 if a service account
+	ServiceAccountID int64 // 0 if a user
+}
+
+// StatusChange record of the status history of an invoice.
+type StatusChange struct {
+	ID        int64
+	InvoiceID int64
+	From      Status
+	To        Status
+	By        Actor
+	Reason    string
+	ChangedAt time.Time
+}
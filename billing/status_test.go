@@ -0,0 +1,57 @@
+package billing
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckTransition(t *testing.T) {
+	tt := []struct {
+		from, to Status
+		valid    bool
+	}{
+		{StatusDraft, StatusIssued, true},
+		{StatusIssued, StatusPartiallyPaid, true},
+		{StatusIssued, StatusPaid, true},
+		{StatusIssued, StatusVoid, true},
+		{StatusPartiallyPaid, StatusPaid, true},
+		{StatusDraft, StatusPaid, false},
+		{StatusDraft, StatusVoid, false},
+		{StatusIssued, StatusDraft, false},
+		{StatusIssued, StatusIssued, false},
+		{StatusPartiallyPaid, StatusVoid, false},
+		{StatusPaid, StatusVoid, false},
+		{StatusPaid, StatusIssued, false},
+		{StatusVoid, StatusIssued, false},
+		{"lost", StatusIssued, false},
+	}
+	for _, tc := range tt {
+		t.Run(string(tc.from)+"-"+string(tc.to), func(t *testing.T) {
+			err := checkTransition(tc.from, tc.to)
+			if tc.valid {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var terr *TransitionError
+			if !errors.As(err, &terr) {
+				t.Fatalf("want *TransitionError, got %v", err)
+			}
+			if terr.From != tc.from || terr.To != tc.to {
+				t.Errorf("want %s to %s, got %s to %s", tc.from, tc.to, terr.From, terr.To)
+			}
+			if !errors.Is(err, ErrInvalidTransition) {
+				t.Errorf("want error matching %v", ErrInvalidTransition)
+			}
+		})
+	}
+}
+
+func TestStatusEditable(t *testing.T) {
+	for _, st := range statuses {
+		if got, want := st.Editable(), st == StatusDraft; got != want {
+			t.Errorf("%s: want editable %v, got %v", st, want, got)
+		}
+	}
+}
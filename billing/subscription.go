@@ -0,0 +1,261 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+)
+
+var (
+	ErrPlanNotFound         = errors.New("subscription plan not found")
+	ErrPlanNameCantBeEmpty  = errors.New("subscription plan name can't be empty")
+	ErrPlanItemsCantBeEmpty = errors.New("subscription plan must have products")
+	ErrPlanProductNotFound  = errors.New("product of the plan not found")
+	ErrDuplicatePlanProduct = errors.New("a product can be once in a plan")
+	ErrInvalidInterval      = errors.New("interval must be between 1 and 12 months")
+	ErrPlanIntervalDiffers  = errors.New("the new plan must renew at the same interval")
+	ErrPlanCurrencyMismatch = errors.New("the products of a plan, and the plans of a subscription, must be priced in the same currency")
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrSubscriptionCanceled = errors.New("subscription is canceled")
+)
+
+// maxInterval is a year, in months.
+const maxInterval = 12
+
+// SubscriptionStatus of a subscription, only the active ones are invoiced.
+type SubscriptionStatus string
+
+const (
+	SubscriptionActive   SubscriptionStatus = "active"
+	SubscriptionCanceled SubscriptionStatus = "canceled"
+)
+
+// Plan the products a client subscribes to, invoiced in advance every
+// Interval months at the price of the products then.
+type Plan struct {
+	ID        int64
+	Name      string
+	Interval  int // months
+	Items     []PlanItem
+	CreatedAt time.Time
+}
+
+// PlanItem a product of a plan. The name, price and tax category are those
+// of the product, read with the plan.
+type PlanItem struct {
+	ProductID   int64
+	Quantity    int
+	ProductName string
+	UnitPrice   money.Money
+	TaxCategory string
+}
+
+// planCurrency returns the currency the items of a plan are priced in,
+// all in the same one.
+func planCurrency(items []PlanItem) (money.Currency, error) {
+	if len(items) == 0 {
+		return "", ErrPlanItemsCantBeEmpty
+	}
+	for _, it := range items {
+		if it.UnitPrice.Currency != items[0].UnitPrice.Currency {
+			return "", fmt.Errorf("%w: %s and %s", ErrPlanCurrencyMismatch, items[0].UnitPrice.Currency, it.UnitPrice.Currency)
+		}
+	}
+	return items[0].UnitPrice.Currency, nil
+}
+
+func (p Plan) validate() error {
+	if p.Name == "" {
+		return ErrPlanNameCantBeEmpty
+	}
+	if p.Interval < 1 || p.Interval > maxInterval {
+		return ErrInvalidInterval
+	}
+	if len(p.Items) == 0 {
+		return ErrPlanItemsCantBeEmpty
+	}
+	seen := make(map[int64]bool, len(p.Items))
+	for _, it := range p.Items {
+		if it.Quantity <= 0 || it.Quantity > maxQuantity {
+			return ErrInvalidQuantity
+		}
+		if seen[it.ProductID] {
+			return ErrDuplicatePlanProduct
+		}
+		seen[it.ProductID] = true
+	}
+	return nil
+}
+
+// Subscription of a client to a plan. Its periods start on the anchor and
+// renew on its day of the month, or on the last day of the shorter months.
+// Period n is invoiced when it starts, Next is the first one not invoiced.
+type Subscription struct {
+	ID       int64
+	ClientID int64
+	PlanID   int64
+	Quantity int // of the plan, e.g. seats
+	Interval int // months, of the plan
+	Status   SubscriptionStatus
+
+	// Jurisdiction of the invoices, the default one if empty.
+	Jurisdiction string
+
+	Anchor     time.Time
+	Next       int
+	NextBillAt time.Time // start of period Next
+
+	CreatedAt  time.Time
+	CanceledAt *time.Time
+}
+
+// Period returns the start and end of the period n.
+func (s Subscription) Period(n int) (start, end time.Time) {
+	return addMonths(s.Anchor, n*s.Interval), addMonths(s.Anchor, (n+1)*s.Interval)
+}
+
+// key identifies the invoice of period n, it's generated once.
+func (s Subscription) key(n int) string {
+	return fmt.Sprintf("subscription:%d:%d", s.ID, n)
+}
+
+// addMonths returns t n months later, on the same day or on the last day
+// of the month if it has fewer days.
+func addMonths(t time.Time, n int) time.Time {
+	y, m, d := t.Date()
+	first := time.Date(y, m+time.Month(n), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(d, last)-1)
+}
+
+// Adjustment an amount of a product to invoice with the next period of a
+// subscription, a charge if positive else a credit. The changes in the
+// middle of a period are prorated with adjustments, and the credit that
+// exceeds an invoice is carried to the next one without a product.
+type Adjustment struct {
+	ID             int64
+	SubscriptionID int64
+	ProductID      int64 // 0 if none, only for credits
+	Description    string
+	Amount         int64
+	TaxCategory    string // of the product, read with the adjustment
+	InvoiceID      int64  // 0 while pending
+	CreatedAt      time.Time
+}
+
+// prorateChange returns the adjustments of changing the items of sub from
+// old to new at a time of the last period invoiced, its rest at the new
+// price less its rest at the old one, by product, to the second. Nothing
+// is prorated before the first period or once the next one is due. The
+// plans must be priced in the same currency.
+func prorateChange(sub Subscription, old, new []PlanItem, oldQty, newQty int, at time.Time) ([]Adjustment, error) {
+	from, err := planCurrency(old)
+	if err != nil {
+		return nil, err
+	}
+	to, err := planCurrency(new)
+	if err != nil {
+		return nil, err
+	}
+	if from != to {
+		return nil, fmt.Errorf("%w: %s and %s", ErrPlanCurrencyMismatch, from, to)
+	}
+	if sub.Next == 0 {
+		return nil, nil
+	}
+	start, end := sub.Period(sub.Next - 1)
+	if at.Before(start) || !at.Before(end) {
+		return nil, nil
+	}
+	rest, length := int(end.Sub(at)/time.Second), int(end.Sub(start)/time.Second)
+	var products []int64
+	names := map[int64]string{}
+	amounts := map[int64]int64{}
+	add := func(items []PlanItem, qty int, sign int64) error {
+		for _, it := range items {
+			if _, ok := names[it.ProductID]; !ok {
+				products = append(products, it.ProductID)
+				names[it.ProductID] = it.ProductName
+			}
+			units := int64(it.Quantity) * int64(qty)
+			if it.UnitPrice.Amount > math.MaxInt64/units {
+				return ErrAmountOverflow
+			}
+			amounts[it.ProductID] += sign * prorate(it.UnitPrice.Amount*units, rest, length)
+		}
+		return nil
+	}
+	if err := add(old, oldQty, -1); err != nil {
+		return nil, err
+	}
+	if err := add(new, newQty, 1); err != nil {
+		return nil, err
+	}
+	var adjustments []Adjustment
+	for _, id := range products {
+		if amounts[id] == 0 {
+			continue
+		}
+		adjustments = append(adjustments, Adjustment{
+			SubscriptionID: sub.ID,
+			ProductID:      id,
+			Description:    fmt.Sprintf("%s, prorated %s to %s", names[id], at.Format(time.DateOnly), end.Format(time.DateOnly)),
+			Amount:         amounts[id],
+		})
+	}
+	return adjustments, nil
+}
+
+// renewal returns the invoice of the period n of sub: its items, the
+// charges pending and the credits pending as discounts of the lines, up to
+// their subtotal. It also returns the credit left, to carry to the next
+// period. The invoice and the adjustments are in the currency of the plan.
+func renewal(sub Subscription, n int, items []PlanItem, pending []Adjustment) (*Invoice, int64, error) {
+	currency, err := planCurrency(items)
+	if err != nil {
+		return nil, 0, err
+	}
+	inv := &Invoice{
+		Header: &InvoiceHeader{
+			ClientID:       sub.ClientID,
+			Jurisdiction:   sub.Jurisdiction,
+			Currency:       currency,
+			IdempotencyKey: sub.key(n),
+		},
+	}
+	for _, it := range items {
+		inv.Items = append(inv.Items, InvoiceItem{
+			ProductID:   it.ProductID,
+			ProductName: it.ProductName,
+			Quantity:    it.Quantity * sub.Quantity,
+			UnitPrice:   it.UnitPrice,
+			TaxCategory: it.TaxCategory,
+		})
+	}
+	var credit int64
+	for _, a := range pending {
+		if a.Amount < 0 {
+			credit -= a.Amount
+			continue
+		}
+		inv.Items = append(inv.Items, InvoiceItem{
+			ProductID:   a.ProductID,
+			ProductName: a.Description,
+			Quantity:    1,
+			UnitPrice:   money.New(a.Amount, currency),
+			TaxCategory: a.TaxCategory,
+		})
+	}
+	for i := range inv.Items {
+		it := &inv.Items[i]
+		if it.UnitPrice.Amount > 0 && int64(it.Quantity) > math.MaxInt64/it.UnitPrice.Amount {
+			return nil, 0, ErrAmountOverflow
+		}
+		it.Discount = min(credit, int64(it.Quantity)*it.UnitPrice.Amount)
+		credit -= it.Discount
+	}
+	return inv, credit, nil
+}
@@ -0,0 +1,153 @@
+package billing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+)
+
+func TestSubscriptionPeriod(t *testing.T) {
+	sub := Subscription{Interval: 1, Anchor: time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)}
+	want := []string{"2024-01-31", "2024-02-29", "2024-03-31", "2024-04-30"}
+	for n, w := range want {
+		start, end := sub.Period(n)
+		if got := start.Format(time.DateOnly); got != w {
+			t.Errorf("period %d starts %s, want %s", n, got, w)
+		}
+		if next, _ := sub.Period(n + 1); !end.Equal(next) {
+			t.Errorf("period %d ends %s, want the start of the next one %s", n, end, next)
+		}
+	}
+	sub.Interval = 12
+	if start, _ := sub.Period(1); start.Format(time.DateOnly) != "2025-01-31" {
+		t.Errorf("want a yearly period, got %s", start)
+	}
+}
+
+func TestPlanValidate(t *testing.T) {
+	item := PlanItem{ProductID: 1, Quantity: 1}
+	tt := []struct {
+		name    string
+		plan    Plan
+		wantErr error
+	}{
+		{"ok", Plan{Name: "Basic", Interval: 1, Items: []PlanItem{item}}, nil},
+		{"no-name", Plan{Interval: 1, Items: []PlanItem{item}}, ErrPlanNameCantBeEmpty},
+		{"no-interval", Plan{Name: "Basic", Items: []PlanItem{item}}, ErrInvalidInterval},
+		{"over-a-year", Plan{Name: "Basic", Interval: 13, Items: []PlanItem{item}}, ErrInvalidInterval},
+		{"no-items", Plan{Name: "Basic", Interval: 1}, ErrPlanItemsCantBeEmpty},
+		{"no-quantity", Plan{Name: "Basic", Interval: 1, Items: []PlanItem{{ProductID: 1}}}, ErrInvalidQuantity},
+		{"duplicate", Plan{Name: "Basic", Interval: 1, Items: []PlanItem{item, item}}, ErrDuplicatePlanProduct},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.plan.validate(); !errors.Is(err, tc.wantErr) {
+				t.Errorf("want %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestProrateChange(t *testing.T) {
+	// Period 0 of April, 30 days, invoiced. The change is at its middle.
+	sub := Subscription{ID: 4, Interval: 1, Quantity: 2, Next: 1, Anchor: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)}
+	mid := time.Date(2024, 4, 16, 0, 0, 0, 0, time.UTC)
+	basic := []PlanItem{{ProductID: 1, Quantity: 1, ProductName: "Basic", UnitPrice: money.New(3000, "EUR")}}
+	pro := []PlanItem{
+		{ProductID: 2, Quantity: 1, ProductName: "Pro", UnitPrice: money.New(6000, "EUR")},
+		{ProductID: 3, Quantity: 2, ProductName: "Storage", UnitPrice: money.New(100, "EUR")},
+	}
+
+	t.Run("upgrade", func(t *testing.T) {
+		got, err := prorateChange(sub, basic, pro, 2, 2, mid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[int64]int64{1: -3000, 2: 6000, 3: 200}
+		if len(got) != len(want) {
+			t.Fatalf("want %d adjustments, got %+v", len(want), got)
+		}
+		for _, a := range got {
+			if a.Amount != want[a.ProductID] || a.SubscriptionID != 4 {
+				t.Errorf("product %d: want %d, got %+v", a.ProductID, want[a.ProductID], a)
+			}
+		}
+		if got[0].Description != "Basic, prorated 2024-04-16 to 2024-05-01" {
+			t.Errorf("unexpected description %q", got[0].Description)
+		}
+	})
+	t.Run("quantity-nets-by-product", func(t *testing.T) {
+		got, err := prorateChange(sub, basic, basic, 2, 1, mid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].Amount != -1500 {
+			t.Fatalf("want a credit of 1500, got %+v", got)
+		}
+	})
+	t.Run("same", func(t *testing.T) {
+		got, err := prorateChange(sub, basic, basic, 2, 2, mid)
+		if err != nil || len(got) != 0 {
+			t.Fatalf("want nothing to prorate, got %+v, %v", got, err)
+		}
+	})
+	t.Run("other-currency", func(t *testing.T) {
+		usd := []PlanItem{{ProductID: 4, Quantity: 1, ProductName: "Basic US", UnitPrice: money.New(3300, "USD")}}
+		if _, err := prorateChange(sub, basic, usd, 2, 2, mid); !errors.Is(err, ErrPlanCurrencyMismatch) {
+			t.Errorf("want error %v, got %v", ErrPlanCurrencyMismatch, err)
+		}
+	})
+	for name, tc := range map[string]struct {
+		sub Subscription
+		at  time.Time
+	}{
+		"not-invoiced-yet": {Subscription{Interval: 1, Anchor: sub.Anchor}, mid},
+		"next-period-due":  {sub, time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)},
+		"before-period":    {sub, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := prorateChange(tc.sub, basic, pro, 2, 2, tc.at)
+			if err != nil || len(got) != 0 {
+				t.Fatalf("want nothing to prorate, got %+v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestRenewal(t *testing.T) {
+	sub := Subscription{ID: 4, ClientID: 7, Quantity: 2, Jurisdiction: "MX"}
+	items := []PlanItem{{ProductID: 2, Quantity: 1, ProductName: "Pro", UnitPrice: money.New(6000, "EUR"), TaxCategory: "standard"}}
+
+	t.Run("charges", func(t *testing.T) {
+		pending := []Adjustment{{ProductID: 3, Description: "Storage, prorated", Amount: 200, TaxCategory: "standard"}}
+		inv, carry, err := renewal(sub, 3, items, pending)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := inv.Header
+		if h.ClientID != 7 || h.Jurisdiction != "MX" || h.IdempotencyKey != "subscription:4:3" || carry != 0 {
+			t.Fatalf("unexpected header %+v, carry %d", h, carry)
+		}
+		if len(inv.Items) != 2 || inv.Items[0].Quantity != 2 || inv.Items[1].UnitPrice != money.New(200, "EUR") || inv.Items[1].ProductName != "Storage, prorated" {
+			t.Fatalf("unexpected items %+v", inv.Items)
+		}
+	})
+	t.Run("credits-as-discounts", func(t *testing.T) {
+		pending := []Adjustment{{ProductID: 1, Amount: -4000}, {Amount: -9000}}
+		inv, carry, err := renewal(sub, 3, items, pending)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(inv.Items) != 1 || inv.Items[0].Discount != 12000 || carry != 1000 {
+			t.Fatalf("want the line discounted in full and 1000 carried, got %+v, %d", inv.Items, carry)
+		}
+		if err := generateInvoice(inv, TaxRules{Rates: []TaxRate{{Category: "standard", Rate: 1600}}}); err != nil {
+			t.Fatal(err)
+		}
+		if inv.Header.Total != 0 {
+			t.Errorf("want nothing to pay, got %+v", inv.Header.Totals)
+		}
+	})
+}
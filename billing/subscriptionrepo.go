@@ -0,0 +1,443 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// CreatePlan creates a subscription plan with its products.
+func (r *Repo) CreatePlan(ctx context.Context, p *Plan) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+	q := r.q.WithTx(tx)
+
+	row, err := q.SubscriptionPlanCreate(ctx, dbgen.SubscriptionPlanCreateParams{
+		Name:           p.Name,
+		IntervalMonths: int32(p.Interval),
+	})
+	if err != nil {
+		return err
+	}
+	for _, it := range p.Items {
+		err := q.SubscriptionPlanItemCreate(ctx, dbgen.SubscriptionPlanItemCreateParams{
+			PlanID:    row.ID,
+			ProductID: it.ProductID,
+			Quantity:  int32(it.Quantity),
+		})
+		if err != nil {
+			return planErr(err)
+		}
+	}
+	items, err := q.SubscriptionPlanItemByPlan(ctx, row.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := planCurrency(toDomainPlanItems(items)); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	p.ID = row.ID
+	p.CreatedAt = row.CreatedAt
+	p.Items = toDomainPlanItems(items)
+	return nil
+}
+
+// planErr maps the errors of the database breaking a constraint of the
+// plan items to the errors of the domain.
+func planErr(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case uniqueViolation:
+		return ErrDuplicatePlanProduct
+	case foreignKeyViolation:
+		return ErrPlanProductNotFound
+	}
+	return err
+}
+
+// Plan returns a subscription plan with its products.
+func (r *Repo) Plan(ctx context.Context, id int64) (*Plan, error) {
+	return r.plan(ctx, r.q, id)
+}
+
+func (r *Repo) plan(ctx context.Context, q *dbgen.Queries, id int64) (*Plan, error) {
+	row, err := q.SubscriptionPlanByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPlanNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	items, err := q.SubscriptionPlanItemByPlan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	p := toDomainPlan(row)
+	p.Items = toDomainPlanItems(items)
+	return &p, nil
+}
+
+// Plans returns all the subscription plans with their products.
+func (r *Repo) Plans(ctx context.Context) ([]Plan, error) {
+	rows, err := r.q.SubscriptionPlanAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plans := make([]Plan, 0, len(rows))
+	for _, row := range rows {
+		items, err := r.q.SubscriptionPlanItemByPlan(ctx, row.ID)
+		if err != nil {
+			return nil, err
+		}
+		p := toDomainPlan(row)
+		p.Items = toDomainPlanItems(items)
+		plans = append(plans, p)
+	}
+	return plans, nil
+}
+
+// CreateSubscription creates an active subscription.
+func (r *Repo) CreateSubscription(ctx context.Context, sub *Subscription) error {
+	row, err := r.q.SubscriptionCreate(ctx, dbgen.SubscriptionCreateParams{
+		ClientID:       sub.ClientID,
+		PlanID:         sub.PlanID,
+		Quantity:       int32(sub.Quantity),
+		IntervalMonths: int32(sub.Interval),
+		Jurisdiction:   sub.Jurisdiction,
+		Anchor:         sub.Anchor,
+		NextBillAt:     sub.NextBillAt,
+	})
+	if err != nil {
+		return err
+	}
+	sub.ID = row.ID
+	sub.Status = SubscriptionStatus(row.Status)
+	sub.CreatedAt = row.CreatedAt
+	return nil
+}
+
+// Subscription returns a subscription by its ID.
+func (r *Repo) Subscription(ctx context.Context, id int64) (*Subscription, error) {
+	row, err := r.q.SubscriptionByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	sub := toDomainSubscription(row)
+	return &sub, nil
+}
+
+// Subscriptions returns the subscriptions of a client.
+func (r *Repo) Subscriptions(ctx context.Context, clientID int64) ([]Subscription, error) {
+	rows, err := r.q.SubscriptionByClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	return toDomainSubscriptions(rows), nil
+}
+
+// DueSubscriptions returns up to limit active subscriptions whose next
+// period starts at now or before, the longest due first.
+func (r *Repo) DueSubscriptions(ctx context.Context, now time.Time, limit int) ([]Subscription, error) {
+	rows, err := r.q.SubscriptionDue(ctx, dbgen.SubscriptionDueParams{
+		NextBillAt: now,
+		Limit:      int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toDomainSubscriptions(rows), nil
+}
+
+// PendingAdjustments returns the adjustments of a subscription not
+// invoiced yet.
+func (r *Repo) PendingAdjustments(ctx context.Context, subscriptionID int64) ([]Adjustment, error) {
+	return r.pendingAdjustments(ctx, r.q, subscriptionID)
+}
+
+func (r *Repo) pendingAdjustments(ctx context.Context, q *dbgen.Queries, subscriptionID int64) ([]Adjustment, error) {
+	rows, err := q.SubscriptionAdjustmentPending(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	adjustments := make([]Adjustment, 0, len(rows))
+	for _, row := range rows {
+		adjustments = append(adjustments, Adjustment{
+			ID:             row.ID,
+			SubscriptionID: row.SubscriptionID,
+			ProductID:      row.ProductID.Int64,
+			Description:    row.Description,
+			Amount:         row.Amount,
+			TaxCategory:    row.TaxCategory,
+			InvoiceID:      row.InvoiceHeaderID.Int64,
+			CreatedAt:      row.CreatedAt,
+		})
+	}
+	return adjustments, nil
+}
+
+// ChangeSubscription changes the plan and quantity of an active
+// subscription at a time, and records the adjustments that prorate the
+// change over the rest of the period invoiced. The subscription is locked
+// meanwhile, it isn't renewed at the same time.
+func (r *Repo) ChangeSubscription(ctx context.Context, id, planID int64, quantity int, at time.Time) (*Subscription, []Adjustment, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+	q := r.q.WithTx(tx)
+
+	row, err := q.SubscriptionByIDForUpdate(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	sub := toDomainSubscription(row)
+	if sub.Status == SubscriptionCanceled {
+		return nil, nil, ErrSubscriptionCanceled
+	}
+	old, err := r.plan(ctx, q, sub.PlanID)
+	if err != nil {
+		return nil, nil, err
+	}
+	plan, err := r.plan(ctx, q, planID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if plan.Interval != sub.Interval {
+		return nil, nil, ErrPlanIntervalDiffers
+	}
+	adjustments, err := prorateChange(sub, old.Items, plan.Items, sub.Quantity, quantity, at)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range adjustments {
+		if err := createAdjustment(ctx, q, &adjustments[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+	err = q.SubscriptionChange(ctx, dbgen.SubscriptionChangeParams{
+		PlanID:   planID,
+		Quantity: int32(quantity),
+		ID:       id,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+	sub.PlanID, sub.Quantity = planID, quantity
+	return &sub, adjustments, nil
+}
+
+// CancelSubscription cancels an active subscription, its periods aren't
+// invoiced anymore.
+func (r *Repo) CancelSubscription(ctx context.Context, id int64, at time.Time) (*Subscription, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+	q := r.q.WithTx(tx)
+
+	row, err := q.SubscriptionByIDForUpdate(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	sub := toDomainSubscription(row)
+	if sub.Status == SubscriptionCanceled {
+		return nil, ErrSubscriptionCanceled
+	}
+	err = q.SubscriptionCancel(ctx, dbgen.SubscriptionCancelParams{
+		CanceledAt: sql.NullTime{Time: at, Valid: true},
+		ID:         id,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	sub.Status, sub.CanceledAt = SubscriptionCanceled, &at
+	return &sub, nil
+}
+
+// billFunc generates the invoice of the next period of sub, with its items
+// and the adjustments pending. It returns the id of the invoice and the
+// credit left to carry to the next period.
+type billFunc func(sub Subscription, items []PlanItem, pending []Adjustment) (invoiceID, carry int64, err error)
+
+// RenewSubscription invoices the next period of a subscription with bill,
+// if it's active and due at now, and advances it to the following one.
+// The subscription is locked meanwhile, it's skipped if another
+// transaction, maybe of another replica, has it locked: renewed is false
+// then, as when it isn't due. If the transaction fails after bill, the
+// invoice is kept and bill must return it when called again.
+func (r *Repo) RenewSubscription(ctx context.Context, id int64, now time.Time, bill billFunc) (renewed bool, err error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+	q := r.q.WithTx(tx)
+
+	row, err := q.SubscriptionDueForUpdate(ctx, dbgen.SubscriptionDueForUpdateParams{
+		ID:         id,
+		NextBillAt: now,
+	})
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	sub := toDomainSubscription(row)
+	plan, err := r.plan(ctx, q, sub.PlanID)
+	if err != nil {
+		return false, err
+	}
+	pending, err := r.pendingAdjustments(ctx, q, sub.ID)
+	if err != nil {
+		return false, err
+	}
+	invoiceID, carry, err := bill(sub, plan.Items, pending)
+	if err != nil {
+		return false, err
+	}
+	if len(pending) > 0 {
+		err := q.SubscriptionAdjustmentInvoiced(ctx, dbgen.SubscriptionAdjustmentInvoicedParams{
+			InvoiceHeaderID: pgtype.Int8{Int64: invoiceID, Valid: true},
+			SubscriptionID:  sub.ID,
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+	if carry > 0 {
+		err := createAdjustment(ctx, q, &Adjustment{
+			SubscriptionID: sub.ID,
+			Description:    "Credit carried over",
+			Amount:         -carry,
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+	next, _ := sub.Period(sub.Next + 1)
+	err = q.SubscriptionAdvance(ctx, dbgen.SubscriptionAdvanceParams{
+		NextPeriod: int32(sub.Next + 1),
+		NextBillAt: next,
+		ID:         sub.ID,
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+func createAdjustment(ctx context.Context, q *dbgen.Queries, a *Adjustment) error {
+	row, err := q.SubscriptionAdjustmentCreate(ctx, dbgen.SubscriptionAdjustmentCreateParams{
+		SubscriptionID: a.SubscriptionID,
+		ProductID:      pgtype.Int8{Int64: a.ProductID, Valid: a.ProductID != 0},
+		Description:    a.Description,
+		Amount:         a.Amount,
+	})
+	if err != nil {
+		return err
+	}
+	a.ID = row.ID
+	a.CreatedAt = row.CreatedAt
+	return nil
+}
+
+// DeleteAllSubscriptions deletes all the subscriptions and plans.
+// This is used for testing purposes to reset the state of the subscription
+// tables.
+func (r *Repo) DeleteAllSubscriptions(ctx context.Context) error {
+	err := r.q.SubscriptionDeleteAll(ctx)
+	if err != nil {
+		return fmt.Errorf("can't truncate table: %v", err)
+	}
+	return nil
+}
+
+// toDomainPlan converts a dbgen.SubscriptionPlan row to a Plan, without
+// its items.
+func toDomainPlan(row dbgen.SubscriptionPlan) Plan {
+	return Plan{
+		ID:        row.ID,
+		Name:      row.Name,
+		Interval:  int(row.IntervalMonths),
+		CreatedAt: row.CreatedAt,
+	}
+}
+
+// toDomainPlanItems converts the items of a plan, with their products, to
+// PlanItems.
+func toDomainPlanItems(rows []dbgen.SubscriptionPlanItemByPlanRow) []PlanItem {
+	items := make([]PlanItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, PlanItem{
+			ProductID:   row.ProductID,
+			Quantity:    int(row.Quantity),
+			ProductName: row.ProductName,
+			UnitPrice:   money.New(row.UnitPrice, money.Currency(row.Currency)),
+			TaxCategory: row.TaxCategory,
+		})
+	}
+	return items
+}
+
+// toDomainSubscription converts a dbgen.Subscription row to a Subscription.
+func toDomainSubscription(row dbgen.Subscription) Subscription {
+	return Subscription{
+		ID:           row.ID,
+		ClientID:     row.ClientID,
+		PlanID:       row.PlanID,
+		Quantity:     int(row.Quantity),
+		Interval:     int(row.IntervalMonths),
+		Status:       SubscriptionStatus(row.Status),
+		Jurisdiction: row.Jurisdiction,
+		Anchor:       row.Anchor,
+		Next:         int(row.NextPeriod),
+		NextBillAt:   row.NextBillAt,
+		CreatedAt:    row.CreatedAt,
+		CanceledAt:   timePtr(row.CanceledAt),
+	}
+}
+
+// toDomainSubscriptions converts dbgen.Subscription rows to Subscriptions.
+func toDomainSubscriptions(rows []dbgen.Subscription) []Subscription {
+	subs := make([]Subscription, 0, len(rows))
+	for _, row := range rows {
+		subs = append(subs, toDomainSubscription(row))
+	}
+	return subs
+}
@@ -0,0 +1,268 @@
+package billing
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+var (
+	ErrTaxCategoryNotFound        = errors.New("tax category not found")
+	ErrTaxCategoryExists          = errors.New("tax category already exists")
+	ErrTaxCategoryCodeCantBeEmpty = errors.New("tax category code can't be empty")
+	ErrTaxNameCantBeEmpty         = errors.New("tax name can't be empty")
+	ErrJurisdictionCantBeEmpty    = errors.New("tax jurisdiction can't be empty")
+	ErrTaxRateNotFound            = errors.New("no tax rate for the category in the jurisdiction at the date")
+	ErrTaxRateOverlaps            = errors.New("the validity of the tax rate overlaps another one of the category in the jurisdiction")
+	ErrInvalidValidity            = errors.New("a validity period must end after it starts")
+	ErrExemptionReasonCantBeEmpty = errors.New("tax exemption reason can't be empty")
+	ErrInvalidTaxRounding         = errors.New("tax rounding must be line or invoice")
+)
+
+// TaxRounding where the tax of an invoice is rounded.
+type TaxRounding string
+
+// Tax roundings.
+const (
+	// RoundPerLine rounds the tax of each line, the tax of the invoice is
+	// their sum.
+	RoundPerLine TaxRounding = "line"
+
+	// RoundPerInvoice rounds the tax of each rate of the invoice once, on
+	// the sum of its lines, and spreads the rounding over the lines.
+	RoundPerInvoice TaxRounding = "invoice"
+)
+
+// ParseTaxRounding returns the TaxRounding named s.
+func ParseTaxRounding(s string) (TaxRounding, error) {
+	r := TaxRounding(s)
+	if r != RoundPerLine && r != RoundPerInvoice {
+		return "", ErrInvalidTaxRounding
+	}
+	return r, nil
+}
+
+// TaxCategory groups the products taxed alike, e.g. standard, reduced or
+// zero rated. The products without category aren't taxed.
+type TaxCategory struct {
+	Code      string
+	Name      string
+	CreatedAt time.Time
+}
+
+// TaxRate of a category in a jurisdiction (e.g. a country or a region) for
+// a period. The periods of a category in a jurisdiction don't overlap.
+type TaxRate struct {
+	ID           int64
+	Category     string
+	Jurisdiction string
+	Name         string // e.g. VAT 16%
+	Rate         int    // basis points, 1600 = 16%
+	ValidFrom    time.Time
+	ValidTo      *time.Time // open if nil, excluded
+	CreatedAt    time.Time
+}
+
+// validate checks the fields of a new rate.
+func (r TaxRate) validate() error {
+	switch {
+	case r.Category == "":
+		return ErrTaxCategoryCodeCantBeEmpty
+	case r.Jurisdiction == "":
+		return ErrJurisdictionCantBeEmpty
+	case r.Name == "":
+		return ErrTaxNameCantBeEmpty
+	case r.Rate < 0 || r.Rate > MaxTaxRate:
+		return ErrInvalidTaxRate
+	}
+	return validatePeriod(r.ValidFrom, r.ValidTo)
+}
+
+// TaxExemption exempts a client of the taxes of a category, or of all of
+// them, in a jurisdiction for a period.
+type TaxExemption struct {
+	ID           int64
+	ClientID     int64
+	Category     string // all if empty
+	Jurisdiction string
+	Reason       string // e.g. the exemption certificate
+	ValidFrom    time.Time
+	ValidTo      *time.Time // open if nil, excluded
+	CreatedAt    time.Time
+}
+
+// validate checks the fields of a new exemption.
+func (e TaxExemption) validate() error {
+	switch {
+	case e.Jurisdiction == "":
+		return ErrJurisdictionCantBeEmpty
+	case e.Reason == "":
+		return ErrExemptionReasonCantBeEmpty
+	}
+	return validatePeriod(e.ValidFrom, e.ValidTo)
+}
+
+// validatePeriod checks a validity period.
+func validatePeriod(from time.Time, to *time.Time) error {
+	if from.IsZero() || (to != nil && !to.After(from)) {
+		return ErrInvalidValidity
+	}
+	return nil
+}
+
+// validAt reports whether t is in the period from, to.
+func validAt(from time.Time, to *time.Time, t time.Time) bool {
+	return !t.Before(from) && (to == nil || t.Before(*to))
+}
+
+// TaxRules the rates of a jurisdiction and the exemptions of a client in
+// it, to tax an invoice.
+type TaxRules struct {
+	Rates      []TaxRate
+	Exemptions []TaxExemption
+}
+
+// apply sets the tax rate of the items of inv, by their category, at the
+// date of the invoice. The items without category aren't taxed.
+func (tr TaxRules) apply(inv *Invoice) error {
+	at := inv.Header.CreatedAt
+	for i := range inv.Items {
+		it := &inv.Items[i]
+		it.TaxRate, it.TaxName, it.TaxExempt = 0, "", false
+		if it.TaxCategory == "" {
+			continue
+		}
+		exempt := slices.ContainsFunc(tr.Exemptions, func(e TaxExemption) bool {
+			return (e.Category == "" || e.Category == it.TaxCategory) && validAt(e.ValidFrom, e.ValidTo, at)
+		})
+		if exempt {
+			it.TaxExempt = true
+			continue
+		}
+		i := slices.IndexFunc(tr.Rates, func(r TaxRate) bool {
+			return r.Category == it.TaxCategory && validAt(r.ValidFrom, r.ValidTo, at)
+		})
+		if i < 0 {
+			return fmt.Errorf("%w: %s in %q", ErrTaxRateNotFound, it.TaxCategory, inv.Header.Jurisdiction)
+		}
+		it.TaxRate = tr.Rates[i].Rate
+		it.TaxName = tr.Rates[i].Name
+	}
+	return nil
+}
+
+// TaxLine of the tax breakdown of an invoice, its lines of the same
+// category and rate.
+type TaxLine struct {
+	Category string
+	Name     string
+	Rate     int
+	Exempt   bool
+	Base     int64 // taxable amount, tax excluded
+	Tax      int64
+}
+
+// taxGroup lines of an invoice taxed alike, to round their tax once.
+type taxGroup struct {
+	line  TaxLine
+	den   int64
+	sum   int64   // of nums
+	items []int   // index of the items
+	nums  []int64 // tax of each item times den
+}
+
+// computeTaxes returns the tax of each item of inv, rounded as the header
+// says, and the tax breakdown. amounts are what the tax applies to, tax
+// included if the prices include it.
+func computeTaxes(inv *Invoice, amounts []int64) ([]int64, []TaxLine, error) {
+	h := inv.Header
+	taxes := make([]int64, len(inv.Items))
+	var groups []*taxGroup
+	for i, it := range inv.Items {
+		num, den, err := taxFraction(amounts[i], it.TaxRate, h.PricesIncludeTax)
+		if err != nil {
+			return nil, nil, err
+		}
+		if taxes[i], err = roundHalfUp(num, den); err != nil {
+			return nil, nil, err
+		}
+		line := TaxLine{Category: it.TaxCategory, Name: it.TaxName, Rate: it.TaxRate, Exempt: it.TaxExempt}
+		j := slices.IndexFunc(groups, func(g *taxGroup) bool { return g.line == line })
+		if j < 0 {
+			groups = append(groups, &taxGroup{line: line, den: den})
+			j = len(groups) - 1
+		}
+		g := groups[j]
+		if g.sum, err = addAmount(g.sum, num); err != nil {
+			return nil, nil, err
+		}
+		g.items = append(g.items, i)
+		g.nums = append(g.nums, num)
+	}
+	lines := make([]TaxLine, 0, len(groups))
+	for _, g := range groups {
+		if h.TaxRounding == RoundPerInvoice {
+			if err := g.spread(taxes); err != nil {
+				return nil, nil, err
+			}
+		}
+		line := g.line
+		for _, i := range g.items {
+			base := amounts[i]
+			if h.PricesIncludeTax {
+				base -= taxes[i]
+			}
+			line.Base += base
+			line.Tax += taxes[i]
+		}
+		lines = append(lines, line)
+	}
+	return taxes, lines, nil
+}
+
+// spread rounds the tax of the group once and spreads it over its items,
+// rounding down each one and adding the cents left to the items with the
+// largest remainders.
+func (g *taxGroup) spread(taxes []int64) error {
+	total, err := roundHalfUp(g.sum, g.den)
+	if err != nil {
+		return err
+	}
+	order := make([]int, len(g.items))
+	for k := range g.items {
+		taxes[g.items[k]] = g.nums[k] / g.den
+		total -= taxes[g.items[k]]
+		order[k] = k
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(g.nums[b]%g.den, g.nums[a]%g.den)
+	})
+	for _, k := range order[:total] {
+		taxes[g.items[k]]++
+	}
+	return nil
+}
+
+// taxFraction returns the tax of amount at rate as num/den. If the amount
+// includes the tax, the tax is the part of it at rate over 100% + rate.
+func taxFraction(amount int64, rate int, inclusive bool) (num, den int64, err error) {
+	if amount > (math.MaxInt64-2*MaxTaxRate)/MaxTaxRate {
+		return 0, 0, ErrAmountOverflow
+	}
+	den = MaxTaxRate
+	if inclusive {
+		den += int64(rate)
+	}
+	return amount * int64(rate), den, nil
+}
+
+// roundHalfUp returns num/den rounded half up.
+func roundHalfUp(num, den int64) (int64, error) {
+	if num > math.MaxInt64-den/2 {
+		return 0, ErrAmountOverflow
+	}
+	return (num + den/2) / den, nil
+}
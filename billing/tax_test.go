@@ -0,0 +1,205 @@
+package billing
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/money"
+)
+
+func TestInvoiceComputeTaxes(t *testing.T) {
+	// Three lines of 5 at 10%, 0.5 of tax each.
+	cents := ItemList{
+		{Quantity: 1, UnitPrice: money.New(5, "EUR"), TaxCategory: "standard", TaxName: "VAT 10%", TaxRate: 1000},
+		{Quantity: 1, UnitPrice: money.New(5, "EUR"), TaxCategory: "standard", TaxName: "VAT 10%", TaxRate: 1000},
+		{Quantity: 1, UnitPrice: money.New(5, "EUR"), TaxCategory: "standard", TaxName: "VAT 10%", TaxRate: 1000},
+	}
+	tt := []struct {
+		name      string
+		header    InvoiceHeader
+		items     ItemList
+		wantTaxes []int64
+		want      Totals
+		wantLines []TaxLine
+	}{
+		{
+			name:      "rounded-per-line",
+			header:    InvoiceHeader{TaxRounding: RoundPerLine},
+			items:     cents,
+			wantTaxes: []int64{1, 1, 1},
+			want:      Totals{Subtotal: 15, Tax: 3, Total: 18},
+			wantLines: []TaxLine{{Category: "standard", Name: "VAT 10%", Rate: 1000, Base: 15, Tax: 3}},
+		},
+		{
+			name:      "rounded-per-invoice",
+			header:    InvoiceHeader{TaxRounding: RoundPerInvoice},
+			items:     cents,
+			wantTaxes: []int64{1, 1, 0},
+			want:      Totals{Subtotal: 15, Tax: 2, Total: 17},
+			wantLines: []TaxLine{{Category: "standard", Name: "VAT 10%", Rate: 1000, Base: 15, Tax: 2}},
+		},
+		{
+			name:   "rounded-per-invoice-largest-remainder",
+			header: InvoiceHeader{TaxRounding: RoundPerInvoice},
+			items: ItemList{
+				{Quantity: 1, UnitPrice: money.New(3, "EUR"), TaxRate: 1000}, // 0.3
+				{Quantity: 1, UnitPrice: money.New(7, "EUR"), TaxRate: 1000}, // 0.7
+				{Quantity: 1, UnitPrice: money.New(4, "EUR"), TaxRate: 1000}, // 0.4
+			},
+			wantTaxes: []int64{0, 1, 0},
+			want:      Totals{Subtotal: 14, Tax: 1, Total: 15},
+			wantLines: []TaxLine{{Rate: 1000, Base: 14, Tax: 1}},
+		},
+		{
+			name:   "prices-include-tax",
+			header: InvoiceHeader{PricesIncludeTax: true, TaxRounding: RoundPerLine},
+			items: ItemList{
+				{Quantity: 1, UnitPrice: money.New(1160, "EUR"), TaxCategory: "standard", TaxName: "IVA 16%", TaxRate: 1600},
+				{Quantity: 2, UnitPrice: money.New(600, "EUR"), TaxCategory: "standard", TaxName: "IVA 16%", TaxRate: 1600, Totals: Totals{Discount: 40}},
+			},
+			wantTaxes: []int64{160, 160},
+			want:      Totals{Subtotal: 2040, Discount: 40, Tax: 320, Total: 2320},
+			wantLines: []TaxLine{{Category: "standard", Name: "IVA 16%", Rate: 1600, Base: 2000, Tax: 320}},
+		},
+		{
+			name:   "breakdown-by-category-and-exemption",
+			header: InvoiceHeader{TaxRounding: RoundPerLine},
+			items: ItemList{
+				{Quantity: 1, UnitPrice: money.New(1000, "EUR"), TaxCategory: "standard", TaxName: "IVA 16%", TaxRate: 1600},
+				{Quantity: 1, UnitPrice: money.New(500, "EUR"), TaxCategory: "reduced", TaxName: "IVA 8%", TaxRate: 800},
+				{Quantity: 1, UnitPrice: money.New(300, "EUR"), TaxCategory: "standard", TaxExempt: true},
+				{Quantity: 1, UnitPrice: money.New(200, "EUR")},
+				{Quantity: 1, UnitPrice: money.New(2000, "EUR"), TaxCategory: "standard", TaxName: "IVA 16%", TaxRate: 1600},
+			},
+			wantTaxes: []int64{160, 40, 0, 0, 320},
+			want:      Totals{Subtotal: 4000, Tax: 520, Total: 4520},
+			wantLines: []TaxLine{
+				{Category: "standard", Name: "IVA 16%", Rate: 1600, Base: 3000, Tax: 480},
+				{Category: "reduced", Name: "IVA 8%", Rate: 800, Base: 500, Tax: 40},
+				{Category: "standard", Exempt: true, Base: 300},
+				{Base: 200},
+			},
+		},
+	}
+	for _, tc := range tt {
+		h := tc.header
+		inv := &Invoice{Header: &h, Items: slices.Clone(tc.items)}
+		if err := inv.computeTotals(); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		for i, it := range inv.Items {
+			if it.Tax != tc.wantTaxes[i] || it.Total != it.Subtotal-it.Discount+it.Tax {
+				t.Errorf("%s: item %d: want tax %d, got %+v", tc.name, i, tc.wantTaxes[i], it.Totals)
+			}
+		}
+		if inv.Header.Totals != tc.want {
+			t.Errorf("%s: want %+v, got %+v", tc.name, tc.want, inv.Header.Totals)
+		}
+		if !slices.Equal(inv.Taxes, tc.wantLines) {
+			t.Errorf("%s: want breakdown %+v, got %+v", tc.name, tc.wantLines, inv.Taxes)
+		}
+	}
+}
+
+func TestTaxRulesApply(t *testing.T) {
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	jul := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	rules := TaxRules{
+		Rates: []TaxRate{
+			{Category: "standard", Name: "VAT 15%", Rate: 1500, ValidFrom: jan, ValidTo: &jul},
+			{Category: "standard", Name: "VAT 16%", Rate: 1600, ValidFrom: jul},
+			{Category: "reduced", Name: "VAT 8%", Rate: 800, ValidFrom: jan},
+		},
+	}
+	exempt := rules
+	exempt.Exemptions = []TaxExemption{{Category: "reduced", ValidFrom: jan, ValidTo: &jul}}
+	all := rules
+	all.Exemptions = []TaxExemption{{ValidFrom: jan}}
+
+	tt := []struct {
+		name     string
+		rules    TaxRules
+		at       time.Time
+		category string
+		want     InvoiceItem
+		wantErr  error
+	}{
+		{name: "untaxed", rules: rules, at: jan},
+		{name: "before-change", rules: rules, at: jul.Add(-time.Second), category: "standard", want: InvoiceItem{TaxName: "VAT 15%", TaxRate: 1500}},
+		{name: "after-change", rules: rules, at: jul, category: "standard", want: InvoiceItem{TaxName: "VAT 16%", TaxRate: 1600}},
+		{name: "before-any-rate", rules: rules, at: jan.Add(-time.Second), category: "standard", wantErr: ErrTaxRateNotFound},
+		{name: "unknown-category", rules: rules, at: jan, category: "luxury", wantErr: ErrTaxRateNotFound},
+		{name: "exempt-of-category", rules: exempt, at: jan, category: "reduced", want: InvoiceItem{TaxExempt: true}},
+		{name: "exemption-of-other-category", rules: exempt, at: jan, category: "standard", want: InvoiceItem{TaxName: "VAT 15%", TaxRate: 1500}},
+		{name: "exemption-expired", rules: exempt, at: jul, category: "reduced", want: InvoiceItem{TaxName: "VAT 8%", TaxRate: 800}},
+		{name: "exempt-of-all", rules: all, at: jul, category: "standard", want: InvoiceItem{TaxExempt: true}},
+	}
+	for _, tc := range tt {
+		inv := &Invoice{
+			Header: &InvoiceHeader{CreatedAt: tc.at},
+			Items:  ItemList{{TaxCategory: tc.category, TaxRate: 9999}},
+		}
+		err := tc.rules.apply(inv)
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: want error %v, got %v", tc.name, tc.wantErr, err)
+			continue
+		}
+		it := inv.Items[0]
+		if err == nil && (it.TaxName != tc.want.TaxName || it.TaxRate != tc.want.TaxRate || it.TaxExempt != tc.want.TaxExempt) {
+			t.Errorf("%s: want %+v, got %+v", tc.name, tc.want, it)
+		}
+	}
+}
+
+func TestCreditNoteOfTaxIncludedInvoice(t *testing.T) {
+	inv := &Invoice{
+		Header: &InvoiceHeader{PricesIncludeTax: true},
+		Items:  ItemList{{ID: 1, Quantity: 3, UnitPrice: money.New(1160, "EUR"), TaxRate: 1600, Totals: Totals{Discount: 10}}},
+	}
+	if err := inv.computeTotals(); err != nil {
+		t.Fatal(err)
+	}
+	item := inv.Items[0]
+	credited := map[int64]int{}
+	var sum Totals
+	for range item.Quantity {
+		cn := &CreditNote{Items: CreditNoteItems{{InvoiceItemID: 1, Quantity: 1}}}
+		if err := buildCreditNote(cn, inv.Items, credited, item.Total-sum.Total); err != nil {
+			t.Fatal(err)
+		}
+		if l := cn.Items[0]; l.Total != l.Subtotal-l.Discount+l.Tax {
+			t.Fatalf("unexpected line %+v", l)
+		}
+		sum, _ = sum.add(cn.Totals)
+		credited[1]++
+	}
+	if sum != item.Totals {
+		t.Errorf("want the credited lines to add up to %+v, got %+v", item.Totals, sum)
+	}
+}
+
+func TestTaxRateValidate(t *testing.T) {
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	valid := TaxRate{Category: "standard", Jurisdiction: "MX", Name: "IVA 16%", Rate: 1600, ValidFrom: jan}
+	tt := []struct {
+		name    string
+		change  func(*TaxRate)
+		wantErr error
+	}{
+		{name: "valid", change: func(*TaxRate) {}},
+		{name: "no-category", change: func(r *TaxRate) { r.Category = "" }, wantErr: ErrTaxCategoryCodeCantBeEmpty},
+		{name: "no-jurisdiction", change: func(r *TaxRate) { r.Jurisdiction = "" }, wantErr: ErrJurisdictionCantBeEmpty},
+		{name: "rate-over-100", change: func(r *TaxRate) { r.Rate = MaxTaxRate + 1 }, wantErr: ErrInvalidTaxRate},
+		{name: "no-start", change: func(r *TaxRate) { r.ValidFrom = time.Time{} }, wantErr: ErrInvalidValidity},
+		{name: "ends-at-start", change: func(r *TaxRate) { r.ValidTo = &jan }, wantErr: ErrInvalidValidity},
+	}
+	for _, tc := range tt {
+		r := valid
+		tc.change(&r)
+		if err := r.validate(); !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: want error %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Error codes of the database for the constraints of the tax tables.
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+	exclusionViolation  = "23P01"
+)
+
+// taxErr maps the errors of the database breaking a constraint of the tax
+// tables to the errors of the domain.
+func taxErr(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case uniqueViolation:
+		return ErrTaxCategoryExists
+	case foreignKeyViolation:
+		return ErrTaxCategoryNotFound
+	case exclusionViolation:
+		return ErrTaxRateOverlaps
+	}
+	return err
+}
+
+// CreateTaxCategory creates a tax category.
+func (r *Repo) CreateTaxCategory(ctx context.Context, c *TaxCategory) error {
+	createdAt, err := r.q.TaxCategoryCreate(ctx, dbgen.TaxCategoryCreateParams{
+		Code: c.Code,
+		Name: c.Name,
+	})
+	if err != nil {
+		return taxErr(err)
+	}
+	c.CreatedAt = createdAt
+	return nil
+}
+
+// TaxCategories returns all the tax categories.
+func (r *Repo) TaxCategories(ctx context.Context) ([]TaxCategory, error) {
+	rows, err := r.q.TaxCategoryAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	categories := make([]TaxCategory, 0, len(rows))
+	for _, row := range rows {
+		categories = append(categories, TaxCategory{Code: row.Code, Name: row.Name, CreatedAt: row.CreatedAt})
+	}
+	return categories, nil
+}
+
+// CreateTaxRate creates a tax rate, the database rejects it if its period
+// overlaps another one of the category in the jurisdiction.
+func (r *Repo) CreateTaxRate(ctx context.Context, t *TaxRate) error {
+	row, err := r.q.TaxRateCreate(ctx, dbgen.TaxRateCreateParams{
+		Category:     t.Category,
+		Jurisdiction: t.Jurisdiction,
+		Name:         t.Name,
+		Rate:         int32(t.Rate),
+		ValidFrom:    t.ValidFrom,
+		ValidTo:      nullTime(t.ValidTo),
+	})
+	if err != nil {
+		return taxErr(err)
+	}
+	t.ID = row.ID
+	t.CreatedAt = row.CreatedAt
+	return nil
+}
+
+// TaxRates returns all the tax rates, of all the periods.
+func (r *Repo) TaxRates(ctx context.Context) ([]TaxRate, error) {
+	rows, err := r.q.TaxRateAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toDomainTaxRates(rows), nil
+}
+
+// CreateTaxExemption creates a tax exemption of a client.
+func (r *Repo) CreateTaxExemption(ctx context.Context, e *TaxExemption) error {
+	row, err := r.q.TaxExemptionCreate(ctx, dbgen.TaxExemptionCreateParams{
+		ClientID:     e.ClientID,
+		Category:     pgtype.Text{String: e.Category, Valid: e.Category != ""},
+		Jurisdiction: e.Jurisdiction,
+		Reason:       e.Reason,
+		ValidFrom:    e.ValidFrom,
+		ValidTo:      nullTime(e.ValidTo),
+	})
+	if err != nil {
+		return taxErr(err)
+	}
+	e.ID = row.ID
+	e.CreatedAt = row.CreatedAt
+	return nil
+}
+
+// TaxExemptions returns the tax exemptions of a client.
+func (r *Repo) TaxExemptions(ctx context.Context, clientID int64) ([]TaxExemption, error) {
+	rows, err := r.q.TaxExemptionByClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	return toDomainTaxExemptions(rows), nil
+}
+
+// TaxRules returns the rates of a jurisdiction and the exemptions of the
+// client in it, of all the periods.
+func (r *Repo) TaxRules(ctx context.Context, clientID int64, jurisdiction string) (TaxRules, error) {
+	rates, err := r.q.TaxRateByJurisdiction(ctx, jurisdiction)
+	if err != nil {
+		return TaxRules{}, fmt.Errorf("tax rates: %w", err)
+	}
+	exemptions, err := r.q.TaxExemptionByClientJurisdiction(ctx, dbgen.TaxExemptionByClientJurisdictionParams{
+		ClientID:     clientID,
+		Jurisdiction: jurisdiction,
+	})
+	if err != nil {
+		return TaxRules{}, fmt.Errorf("tax exemptions: %w", err)
+	}
+	return TaxRules{
+		Rates:      toDomainTaxRates(rates),
+		Exemptions: toDomainTaxExemptions(exemptions),
+	}, nil
+}
+
+// DeleteAllTaxes deletes all the tax rates and exemptions, the categories
+// are kept.
+// This is used for testing purposes to reset the state of the tax tables.
+func (r *Repo) DeleteAllTaxes(ctx context.Context) error {
+	err := r.q.TaxDeleteAll(ctx)
+	if err != nil {
+		return fmt.Errorf("can't truncate table: %v", err)
+	}
+	return nil
+}
+
+// nullTime converts an optional time to a nullable column.
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+// timePtr converts a nullable column to an optional time.
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// toDomainTaxRates converts dbgen.TaxRate rows to TaxRates.
+func toDomainTaxRates(rows []dbgen.TaxRate) []TaxRate {
+	rates := make([]TaxRate, 0, len(rows))
+	for _, row := range rows {
+		rates = append(rates, TaxRate{
+			ID:           row.ID,
+			Category:     row.Category,
+			Jurisdiction: row.Jurisdiction,
+			Name:         row.Name,
+			Rate:         int(row.Rate),
+			ValidFrom:    row.ValidFrom,
+			ValidTo:      timePtr(row.ValidTo),
+			CreatedAt:    row.CreatedAt,
+		})
+	}
+	return rates
+}
+
+// toDomainTaxExemptions converts dbgen.TaxExemption rows to TaxExemptions.
+func toDomainTaxExemptions(rows []dbgen.TaxExemption) []TaxExemption {
+	exemptions := make([]TaxExemption, 0, len(rows))
+	for _, row := range rows {
+		exemptions = append(exemptions, TaxExemption{
+			ID:           row.ID,
+			ClientID:     row.ClientID,
+			Category:     row.Category.String,
+			Jurisdiction: row.Jurisdiction,
+			Reason:       row.Reason,
+			ValidFrom:    row.ValidFrom,
+			ValidTo:      timePtr(row.ValidTo),
+			CreatedAt:    row.CreatedAt,
+		})
+	}
+	return exemptions
+}
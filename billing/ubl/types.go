@@ -0,0 +1,171 @@
+package ubl
+
+import "encoding/xml"
+
+// The types of the UBL 2.1 documents, only the elements used by Peppol BIS
+// Billing 3.0, in the order of the schemas. The cbc and cac prefixes are
+// declared on the root element.
+
+// Namespaces of UBL 2.1.
+const (
+	nsInvoice    = "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"
+	nsCreditNote = "urn:oasis:names:specification:ubl:schema:xsd:CreditNote-2"
+	nsCAC        = "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2"
+	nsCBC        = "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2"
+)
+
+type invoice struct {
+	XMLName xml.Name `xml:"Invoice"`
+	xmlns
+
+	CustomizationID      string        `xml:"cbc:CustomizationID"`
+	ProfileID            string        `xml:"cbc:ProfileID"`
+	ID                   string        `xml:"cbc:ID"`
+	IssueDate            string        `xml:"cbc:IssueDate"`
+	InvoiceTypeCode      string        `xml:"cbc:InvoiceTypeCode"`
+	Note                 string        `xml:"cbc:Note,omitempty"`
+	DocumentCurrencyCode string        `xml:"cbc:DocumentCurrencyCode"`
+	BuyerReference       string        `xml:"cbc:BuyerReference"`
+	Supplier             party         `xml:"cac:AccountingSupplierParty>cac:Party"`
+	Customer             party         `xml:"cac:AccountingCustomerParty>cac:Party"`
+	TaxTotal             taxTotal      `xml:"cac:TaxTotal"`
+	LegalMonetaryTotal   monetaryTotal `xml:"cac:LegalMonetaryTotal"`
+	Lines                []invoiceLine `xml:"cac:InvoiceLine"`
+}
+
+type creditNote struct {
+	XMLName xml.Name `xml:"CreditNote"`
+	xmlns
+
+	CustomizationID      string           `xml:"cbc:CustomizationID"`
+	ProfileID            string           `xml:"cbc:ProfileID"`
+	ID                   string           `xml:"cbc:ID"`
+	IssueDate            string           `xml:"cbc:IssueDate"`
+	CreditNoteTypeCode   string           `xml:"cbc:CreditNoteTypeCode"`
+	Note                 string           `xml:"cbc:Note,omitempty"`
+	DocumentCurrencyCode string           `xml:"cbc:DocumentCurrencyCode"`
+	BuyerReference       string           `xml:"cbc:BuyerReference"`
+	BillingReference     documentRef      `xml:"cac:BillingReference>cac:InvoiceDocumentReference"`
+	Supplier             party            `xml:"cac:AccountingSupplierParty>cac:Party"`
+	Customer             party            `xml:"cac:AccountingCustomerParty>cac:Party"`
+	TaxTotal             taxTotal         `xml:"cac:TaxTotal"`
+	LegalMonetaryTotal   monetaryTotal    `xml:"cac:LegalMonetaryTotal"`
+	Lines                []creditNoteLine `xml:"cac:CreditNoteLine"`
+}
+
+// xmlns the namespaces declared on the root element.
+type xmlns struct {
+	Default string `xml:"xmlns,attr"`
+	CAC     string `xml:"xmlns:cac,attr"`
+	CBC     string `xml:"xmlns:cbc,attr"`
+}
+
+type documentRef struct {
+	ID        string `xml:"cbc:ID"`
+	IssueDate string `xml:"cbc:IssueDate,omitempty"`
+}
+
+type party struct {
+	EndpointID  endpoint  `xml:"cbc:EndpointID"`
+	Name        string    `xml:"cac:PartyName>cbc:Name,omitempty"`
+	Address     address   `xml:"cac:PostalAddress"`
+	TaxScheme   *partyTax `xml:"cac:PartyTaxScheme,omitempty"`
+	LegalEntity string    `xml:"cac:PartyLegalEntity>cbc:RegistrationName"`
+	Contact     *contact  `xml:"cac:Contact,omitempty"`
+}
+
+type address struct {
+	StreetName           string `xml:"cbc:StreetName,omitempty"`
+	AdditionalStreetName string `xml:"cbc:AdditionalStreetName,omitempty"`
+	Country              string `xml:"cac:Country>cbc:IdentificationCode"`
+}
+
+type contact struct {
+	ElectronicMail string `xml:"cbc:ElectronicMail"`
+}
+
+type endpoint struct {
+	SchemeID string `xml:"schemeID,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type partyTax struct {
+	CompanyID string `xml:"cbc:CompanyID"`
+	TaxScheme string `xml:"cac:TaxScheme>cbc:ID"`
+}
+
+type amount struct {
+	Currency string `xml:"currencyID,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type quantity struct {
+	UnitCode string `xml:"unitCode,attr"`
+	Value    int    `xml:",chardata"`
+}
+
+type taxTotal struct {
+	TaxAmount amount        `xml:"cbc:TaxAmount"`
+	Subtotals []taxSubtotal `xml:"cac:TaxSubtotal"`
+}
+
+type taxSubtotal struct {
+	TaxableAmount amount      `xml:"cbc:TaxableAmount"`
+	TaxAmount     amount      `xml:"cbc:TaxAmount"`
+	Category      taxCategory `xml:"cac:TaxCategory"`
+}
+
+type taxCategory struct {
+	ID                 string `xml:"cbc:ID"`
+	Percent            string `xml:"cbc:Percent"`
+	TaxExemptionReason string `xml:"cbc:TaxExemptionReason,omitempty"`
+	TaxScheme          string `xml:"cac:TaxScheme>cbc:ID"`
+}
+
+type monetaryTotal struct {
+	LineExtensionAmount amount `xml:"cbc:LineExtensionAmount"`
+	TaxExclusiveAmount  amount `xml:"cbc:TaxExclusiveAmount"`
+	TaxInclusiveAmount  amount `xml:"cbc:TaxInclusiveAmount"`
+	PayableAmount       amount `xml:"cbc:PayableAmount"`
+}
+
+type invoiceLine struct {
+	ID                  string           `xml:"cbc:ID"`
+	InvoicedQuantity    quantity         `xml:"cbc:InvoicedQuantity"`
+	LineExtensionAmount amount           `xml:"cbc:LineExtensionAmount"`
+	AllowanceCharge     *allowanceCharge `xml:"cac:AllowanceCharge,omitempty"`
+	Item                item             `xml:"cac:Item"`
+	Price               price            `xml:"cac:Price"`
+}
+
+type creditNoteLine struct {
+	ID                  string           `xml:"cbc:ID"`
+	CreditedQuantity    quantity         `xml:"cbc:CreditedQuantity"`
+	LineExtensionAmount amount           `xml:"cbc:LineExtensionAmount"`
+	AllowanceCharge     *allowanceCharge `xml:"cac:AllowanceCharge,omitempty"`
+	Item                item             `xml:"cac:Item"`
+	Price               price            `xml:"cac:Price"`
+}
+
+type allowanceCharge struct {
+	ChargeIndicator       bool   `xml:"cbc:ChargeIndicator"`
+	AllowanceChargeReason string `xml:"cbc:AllowanceChargeReason"`
+	Amount                amount `xml:"cbc:Amount"`
+}
+
+type item struct {
+	Name                      string          `xml:"cbc:Name"`
+	SellersItemIdentification string          `xml:"cac:SellersItemIdentification>cbc:ID,omitempty"`
+	ClassifiedTaxCategory     itemTaxCategory `xml:"cac:ClassifiedTaxCategory"`
+}
+
+type itemTaxCategory struct {
+	ID        string `xml:"cbc:ID"`
+	Percent   string `xml:"cbc:Percent"`
+	TaxScheme string `xml:"cac:TaxScheme>cbc:ID"`
+}
+
+type price struct {
+	PriceAmount  amount    `xml:"cbc:PriceAmount"`
+	BaseQuantity *quantity `xml:"cbc:BaseQuantity,omitempty"`
+}
@@ -0,0 +1,383 @@
+// Package ubl encodes invoices and credit notes as UBL 2.1 XML documents
+// conforming to Peppol BIS Billing 3.0, the e-invoices exchanged between
+// businesses over the Peppol network.
+package ubl
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/money"
+)
+
+var (
+	// ErrNotIssued a draft invoice isn't a legal document, it can't be sent.
+	ErrNotIssued = errors.New("only issued invoices can be exported")
+
+	// ErrNoBuyerAddress Peppol routes the documents to an electronic
+	// address of the buyer.
+	ErrNoBuyerAddress = errors.New("the client has no electronic address")
+
+	// ErrSellerNotSet the name, country and endpoint of the seller are
+	// required.
+	ErrSellerNotSet = errors.New("the seller of the e-invoices isn't configured")
+)
+
+// Identifiers of Peppol BIS Billing 3.0.
+const (
+	customizationID = "urn:cen.eu:en16931:2017#compliant#urn:fdc:peppol.eu:2017:poacc:billing:3.0"
+	profileID       = "urn:fdc:peppol.eu:2017:poacc:billing:01:1.0"
+)
+
+// Codes of the UN/CEFACT and UN/EDIFACT code lists used by Peppol.
+const (
+	commercialInvoice = "380" // UNCL1001
+	creditNoteCode    = "381" // UNCL1001
+	unitCode          = "C62" // UN/ECE Rec 20, one unit
+	emailScheme       = "EM"  // EAS, electronic mail
+	vat               = "VAT" // tax scheme
+)
+
+// Tax categories of UNCL5305.
+const (
+	standardRated = "S"
+	zeroRated     = "Z"
+	exempt        = "E"
+)
+
+// Seller the supplier of the invoices.
+type Seller struct {
+	Name    string
+	VATID   string // with the country prefix, e.g. ES12345678Z, omitted if empty
+	Country string // ISO 3166-1 alpha-2
+
+	// Endpoint is the Peppol electronic address, scheme (EAS) and
+	// identifier, e.g. 0088 and 5790000435975.
+	EndpointScheme string
+	EndpointID     string
+}
+
+// Buyer the client of an invoice.
+type Buyer struct {
+	Name    string
+	Email   string // the electronic address if the buyer has no other
+	Address string // its first line is the street, the rest follows it
+}
+
+// Encoder encodes the documents of a seller in their currency.
+type Encoder struct {
+	seller   Seller
+	currency string
+}
+
+// NewEncoder returns an Encoder of the documents of seller, with amounts
+// in currency (ISO 4217) if a document has none.
+func NewEncoder(seller Seller, currency string) *Encoder {
+	return &Encoder{seller: seller, currency: currency}
+}
+
+// in returns the encoder of a document in currency c, the one of e if
+// empty.
+func (e *Encoder) in(c money.Currency) *Encoder {
+	if c == "" {
+		return e
+	}
+	doc := *e
+	doc.currency = string(c)
+	return &doc
+}
+
+// Invoice encodes an issued invoice, header, items and tax breakdown.
+func (e *Encoder) Invoice(inv *billing.Invoice, b Buyer) ([]byte, error) {
+	h := inv.Header
+	if h.Status == billing.StatusDraft {
+		return nil, ErrNotIssued
+	}
+	e = e.in(h.Currency)
+	if err := e.check(b); err != nil {
+		return nil, err
+	}
+	lines := make([]invoiceLine, 0, len(inv.Items))
+	for i, it := range inv.Items {
+		l := e.line(i, it.ProductID, it.ProductName, it.Quantity, it.UnitPrice.Amount, it.Totals, category(it.TaxRate, it.TaxExempt))
+		lines = append(lines, invoiceLine{
+			ID:                  l.ID,
+			InvoicedQuantity:    l.quantity,
+			LineExtensionAmount: l.LineExtensionAmount,
+			AllowanceCharge:     l.AllowanceCharge,
+			Item:                l.Item,
+			Price:               l.Price,
+		})
+	}
+	doc := invoice{
+		xmlns:                xmlns{Default: nsInvoice, CAC: nsCAC, CBC: nsCBC},
+		CustomizationID:      customizationID,
+		ProfileID:            profileID,
+		ID:                   h.Number,
+		IssueDate:            date(h.CreatedAt),
+		InvoiceTypeCode:      commercialInvoice,
+		Note:                 note(h),
+		DocumentCurrencyCode: e.currency,
+		BuyerReference:       buyerReference(h.ClientID),
+		Supplier:             e.supplier(),
+		Customer:             e.customer(b, h.Jurisdiction),
+		TaxTotal:             e.taxTotal(h.Tax, taxLines(inv.Items)),
+		LegalMonetaryTotal:   e.monetaryTotal(h.Totals),
+		Lines:                lines,
+	}
+	return encode(doc)
+}
+
+// CreditNote encodes a credit note of the invoice inv.
+func (e *Encoder) CreditNote(cn *billing.CreditNote, inv *billing.Invoice, b Buyer) ([]byte, error) {
+	if err := e.check(b); err != nil {
+		return nil, err
+	}
+	e = e.in(cmp.Or(cn.Currency, inv.Header.Currency))
+	h := inv.Header
+	exempt := make(map[int64]bool, len(inv.Items))
+	for _, it := range inv.Items {
+		exempt[it.ID] = it.TaxExempt
+	}
+	lines := make([]creditNoteLine, 0, len(cn.Items))
+	credited := make([]taxed, 0, len(cn.Items))
+	for i, it := range cn.Items {
+		cat := category(it.TaxRate, exempt[it.InvoiceItemID])
+		l := e.line(i, it.ProductID, it.ProductName, it.Quantity, it.UnitPrice.Amount, it.Totals, cat)
+		lines = append(lines, creditNoteLine{
+			ID:                  l.ID,
+			CreditedQuantity:    l.quantity,
+			LineExtensionAmount: l.LineExtensionAmount,
+			AllowanceCharge:     l.AllowanceCharge,
+			Item:                l.Item,
+			Price:               l.Price,
+		})
+		credited = append(credited, taxed{cat: cat, totals: it.Totals})
+	}
+	doc := creditNote{
+		xmlns:                xmlns{Default: nsCreditNote, CAC: nsCAC, CBC: nsCBC},
+		CustomizationID:      customizationID,
+		ProfileID:            profileID,
+		ID:                   cn.Number,
+		IssueDate:            date(cn.CreatedAt),
+		CreditNoteTypeCode:   creditNoteCode,
+		Note:                 cn.Reason,
+		DocumentCurrencyCode: e.currency,
+		BuyerReference:       buyerReference(cn.ClientID),
+		BillingReference:     documentRef{ID: h.Number, IssueDate: date(h.CreatedAt)},
+		Supplier:             e.supplier(),
+		Customer:             e.customer(b, h.Jurisdiction),
+		TaxTotal:             e.taxTotal(cn.Tax, credited),
+		LegalMonetaryTotal:   e.monetaryTotal(cn.Totals),
+		Lines:                lines,
+	}
+	return encode(doc)
+}
+
+// check checks that the parties of a document with b have the elements
+// Peppol requires.
+func (e *Encoder) check(b Buyer) error {
+	if e.seller.Name == "" || e.seller.Country == "" || e.seller.EndpointID == "" {
+		return ErrSellerNotSet
+	}
+	if b.Email == "" {
+		return ErrNoBuyerAddress
+	}
+	return nil
+}
+
+// encode marshals a document with the XML declaration.
+func encode(doc any) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	enc := xml.NewEncoder(&b)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("ubl: %w", err)
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// line the elements shared by the lines of invoices and credit notes.
+type line struct {
+	ID                  string
+	quantity            quantity
+	LineExtensionAmount amount
+	AllowanceCharge     *allowanceCharge
+	Item                item
+	Price               price
+}
+
+// line returns the line i of a document. Its net amount is the subtotal
+// less the discount, an allowance of the line. If the subtotal isn't the
+// quantity by the unit price, because the price included the tax, the
+// price is given as the subtotal for the whole quantity so that the net
+// amount still adds up.
+func (e *Encoder) line(i int, productID int64, name string, q int, unitPrice int64, t billing.Totals, cat taxCategory) line {
+	l := line{
+		ID:                  strconv.Itoa(i + 1),
+		quantity:            quantity{UnitCode: unitCode, Value: q},
+		LineExtensionAmount: e.amount(t.Subtotal - t.Discount),
+		Item: item{
+			Name:                      name,
+			SellersItemIdentification: strconv.FormatInt(productID, 10),
+			ClassifiedTaxCategory:     itemTaxCategory{ID: cat.ID, Percent: cat.Percent, TaxScheme: vat},
+		},
+		Price: price{PriceAmount: e.amount(unitPrice)},
+	}
+	if unitPrice*int64(q) != t.Subtotal {
+		l.Price = price{PriceAmount: e.amount(t.Subtotal), BaseQuantity: &quantity{UnitCode: unitCode, Value: q}}
+	}
+	if t.Discount > 0 {
+		l.AllowanceCharge = &allowanceCharge{AllowanceChargeReason: "Discount", Amount: e.amount(t.Discount)}
+	}
+	return l
+}
+
+// taxed the totals of a line and its tax category.
+type taxed struct {
+	cat    taxCategory
+	totals billing.Totals
+}
+
+// taxLines returns the items of an invoice with their tax category.
+func taxLines(items billing.ItemList) []taxed {
+	lines := make([]taxed, 0, len(items))
+	for _, it := range items {
+		lines = append(lines, taxed{cat: category(it.TaxRate, it.TaxExempt), totals: it.Totals})
+	}
+	return lines
+}
+
+// taxTotal returns the tax of a document and its breakdown, a subtotal per
+// category and rate as Peppol requires, in order of appearance.
+func (e *Encoder) taxTotal(tax int64, lines []taxed) taxTotal {
+	var cats []taxCategory
+	base := map[taxCategory]int64{}
+	taxes := map[taxCategory]int64{}
+	for _, l := range lines {
+		if _, ok := base[l.cat]; !ok {
+			cats = append(cats, l.cat)
+		}
+		base[l.cat] += l.totals.Subtotal - l.totals.Discount
+		taxes[l.cat] += l.totals.Tax
+	}
+	t := taxTotal{TaxAmount: e.amount(tax)}
+	for _, c := range cats {
+		t.Subtotals = append(t.Subtotals, taxSubtotal{
+			TaxableAmount: e.amount(base[c]),
+			TaxAmount:     e.amount(taxes[c]),
+			Category:      c,
+		})
+	}
+	return t
+}
+
+// monetaryTotal returns the totals of a document, without allowances or
+// charges at its level nor prepaid amounts.
+func (e *Encoder) monetaryTotal(t billing.Totals) monetaryTotal {
+	net := e.amount(t.Subtotal - t.Discount)
+	return monetaryTotal{
+		LineExtensionAmount: net,
+		TaxExclusiveAmount:  net,
+		TaxInclusiveAmount:  e.amount(t.Total),
+		PayableAmount:       e.amount(t.Total),
+	}
+}
+
+// supplier returns the party of the seller.
+func (e *Encoder) supplier() party {
+	p := party{
+		EndpointID:  endpoint{SchemeID: e.seller.EndpointScheme, Value: e.seller.EndpointID},
+		Name:        e.seller.Name,
+		Address:     address{Country: e.seller.Country},
+		LegalEntity: e.seller.Name,
+	}
+	if e.seller.VATID != "" {
+		p.TaxScheme = &partyTax{CompanyID: e.seller.VATID, TaxScheme: vat}
+	}
+	return p
+}
+
+// customer returns the party of the buyer, in the country of the
+// jurisdiction of the invoice (e.g. US of US-CA), else of the seller.
+func (e *Encoder) customer(b Buyer, jurisdiction string) party {
+	country, _, _ := strings.Cut(jurisdiction, "-")
+	if len(country) != 2 {
+		country = e.seller.Country
+	}
+	return party{
+		EndpointID:  endpoint{SchemeID: emailScheme, Value: b.Email},
+		Name:        b.Name,
+		Address:     postalAddress(b.Address, strings.ToUpper(country)),
+		LegalEntity: b.Name,
+		Contact:     &contact{ElectronicMail: b.Email},
+	}
+}
+
+// postalAddress returns the address of a party in country, its first line
+// as the street name and the others joined as the additional one.
+func postalAddress(s, country string) address {
+	var lines []string
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	a := address{Country: country}
+	if len(lines) > 0 {
+		a.StreetName = lines[0]
+		a.AdditionalStreetName = strings.Join(lines[1:], ", ")
+	}
+	return a
+}
+
+// category returns the tax category of a line taxed at rate, in basis
+// points, or exempt. Untaxed lines are zero rated.
+func category(rate int, isExempt bool) taxCategory {
+	switch {
+	case isExempt:
+		return taxCategory{ID: exempt, Percent: "0", TaxExemptionReason: "Exempt", TaxScheme: vat}
+	case rate == 0:
+		return taxCategory{ID: zeroRated, Percent: "0", TaxScheme: vat}
+	}
+	return taxCategory{ID: standardRated, Percent: percent(rate), TaxScheme: vat}
+}
+
+// amount returns an amount in minor units with the decimals of the
+// currency.
+func (e *Encoder) amount(v int64) amount {
+	return amount{Currency: e.currency, Value: money.New(v, money.Currency(e.currency)).Decimal()}
+}
+
+// percent returns a rate in basis points as a percentage.
+func percent(bp int) string {
+	return strconv.FormatFloat(float64(bp)/100, 'f', -1, 64)
+}
+
+// date returns the date of t in UTC.
+func date(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
+
+// buyerReference identifies the buyer to route the invoice on its side,
+// Peppol requires it or an order reference.
+func buyerReference(clientID int64) string {
+	return strconv.FormatInt(clientID, 10)
+}
+
+// note tells whether the prices of the invoice included the tax.
+func note(h *billing.InvoiceHeader) string {
+	if h.PricesIncludeTax {
+		return "Prices include tax"
+	}
+	return ""
+}
@@ -0,0 +1,519 @@
+package ubl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/money"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+var seller = Seller{
+	Name:           "Genesis SL",
+	VATID:          "ES12345678Z",
+	Country:        "ES",
+	EndpointScheme: "9920",
+	EndpointID:     "ES12345678Z",
+}
+
+var buyer = Buyer{Name: "Ana Pérez", Email: "ana@example.com", Address: "Calle Mayor 1\n28013 Madrid"}
+
+// testInvoice of the golden files, a line with a discount, one at a reduced
+// rate and one exempt.
+func testInvoice() *billing.Invoice {
+	return &billing.Invoice{
+		Header: &billing.InvoiceHeader{
+			ID:           42,
+			Number:       "INV-2024-000042",
+			ClientID:     7,
+			Status:       billing.StatusIssued,
+			Totals:       billing.Totals{Subtotal: 1280000, Discount: 5000, Tax: 195200, Total: 1470200},
+			Jurisdiction: "ES",
+			TaxRounding:  billing.RoundPerLine,
+			CreatedAt:    time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC),
+		},
+		Items: billing.ItemList{
+			{ID: 1, ProductID: 3, ProductName: "Laptop <14\">", Quantity: 1, UnitPrice: money.New(1200000, "EUR"), TaxCategory: "standard", TaxRate: 1600,
+				Totals: billing.Totals{Subtotal: 1200000, Discount: 5000, Tax: 191200, Total: 1386200}},
+			{ID: 2, ProductID: 5, ProductName: "Café molido", Quantity: 2, UnitPrice: money.New(25000, "EUR"), TaxCategory: "reduced", TaxRate: 800,
+				Totals: billing.Totals{Subtotal: 50000, Tax: 4000, Total: 54000}},
+			{ID: 3, ProductID: 8, ProductName: "Support", Quantity: 3, UnitPrice: money.New(10000, "EUR"), TaxCategory: "standard", TaxExempt: true,
+				Totals: billing.Totals{Subtotal: 30000, Total: 30000}},
+		},
+	}
+}
+
+// testCreditNote of the golden files, of a coffee and the support of
+// testInvoice.
+func testCreditNote() *billing.CreditNote {
+	return &billing.CreditNote{
+		ID:        9,
+		Number:    "CN-2024-000009",
+		InvoiceID: 42,
+		ClientID:  7,
+		Reason:    "Returned goods",
+		Totals:    billing.Totals{Subtotal: 55000, Tax: 2000, Total: 57000},
+		Items: billing.CreditNoteItems{
+			{InvoiceItemID: 2, ProductID: 5, ProductName: "Café molido", Quantity: 1, UnitPrice: money.New(25000, "EUR"), TaxRate: 800,
+				Totals: billing.Totals{Subtotal: 25000, Tax: 2000, Total: 27000}},
+			{InvoiceItemID: 3, ProductID: 8, ProductName: "Support", Quantity: 3, UnitPrice: money.New(10000, "EUR"),
+				Totals: billing.Totals{Subtotal: 30000, Total: 30000}},
+		},
+		CreatedAt: time.Date(2024, 3, 20, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestInvoiceGolden(t *testing.T) {
+	b, err := NewEncoder(seller, "EUR").Invoice(testInvoice(), buyer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "invoice.xml", b)
+	root := validate(t, b, "invoice.schema")
+	checkRules(t, root, "cac:InvoiceLine", "cbc:InvoicedQuantity")
+}
+
+func TestCreditNoteGolden(t *testing.T) {
+	b, err := NewEncoder(seller, "EUR").CreditNote(testCreditNote(), testInvoice(), buyer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "creditnote.xml", b)
+	root := validate(t, b, "creditnote.schema")
+	checkRules(t, root, "cac:CreditNoteLine", "cbc:CreditedQuantity")
+	if got := root.text("cac:BillingReference/cac:InvoiceDocumentReference/cbc:ID"); got != "INV-2024-000042" {
+		t.Errorf("want the invoice referenced, got %q", got)
+	}
+	// The support was exempt on the invoice, so is its credit.
+	if got := root.all("cac:TaxTotal/cac:TaxSubtotal")[1].text("cac:TaxCategory/cbc:ID"); got != exempt {
+		t.Errorf("want the exempt category, got %q", got)
+	}
+}
+
+func TestPricesIncludeTax(t *testing.T) {
+	inv := &billing.Invoice{
+		Header: &billing.InvoiceHeader{
+			Number:           "INV-2024-000043",
+			ClientID:         7,
+			Status:           billing.StatusIssued,
+			Totals:           billing.Totals{Subtotal: 3000, Tax: 480, Total: 3480},
+			PricesIncludeTax: true,
+			CreatedAt:        time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+		},
+		Items: billing.ItemList{
+			{ProductID: 3, ProductName: "Pen", Quantity: 3, UnitPrice: money.New(1160, "EUR"), TaxRate: 1600,
+				Totals: billing.Totals{Subtotal: 3000, Tax: 480, Total: 3480}},
+		},
+	}
+	b, err := NewEncoder(seller, "EUR").Invoice(inv, Buyer{Name: "Client 7", Email: "client7@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := validate(t, b, "invoice.schema")
+	checkRules(t, root, "cac:InvoiceLine", "cbc:InvoicedQuantity")
+	line := root.all("cac:InvoiceLine")[0]
+	if got := line.text("cac:Price/cbc:PriceAmount"); got != "30.00" {
+		t.Errorf("want the net price of the line, got %q", got)
+	}
+	// Without a jurisdiction the buyer is in the country of the seller.
+	if got := root.text("cac:AccountingCustomerParty/cac:Party/cac:PostalAddress/cac:Country/cbc:IdentificationCode"); got != "ES" {
+		t.Errorf("want the country of the seller, got %q", got)
+	}
+}
+
+func TestDraftNotExported(t *testing.T) {
+	inv := testInvoice()
+	inv.Header.Status = billing.StatusDraft
+	_, err := NewEncoder(seller, "EUR").Invoice(inv, buyer)
+	if !errors.Is(err, ErrNotIssued) {
+		t.Fatalf("want %v, got %v", ErrNotIssued, err)
+	}
+}
+
+func TestPartiesRequired(t *testing.T) {
+	_, err := NewEncoder(seller, "EUR").Invoice(testInvoice(), Buyer{Name: "Client 7"})
+	if !errors.Is(err, ErrNoBuyerAddress) {
+		t.Errorf("want %v, got %v", ErrNoBuyerAddress, err)
+	}
+	_, err = NewEncoder(Seller{Name: "Genesis SL"}, "EUR").CreditNote(testCreditNote(), testInvoice(), buyer)
+	if !errors.Is(err, ErrSellerNotSet) {
+		t.Errorf("want %v, got %v", ErrSellerNotSet, err)
+	}
+}
+
+func TestAmount(t *testing.T) {
+	e := NewEncoder(seller, "USD")
+	tests := map[int64]string{0: "0.00", 5: "0.05", 123456: "1234.56", -2050: "-20.50"}
+	for v, want := range tests {
+		if got := e.amount(v); got.Value != want || got.Currency != "USD" {
+			t.Errorf("amount(%d) = %+v, want %s USD", v, got, want)
+		}
+	}
+	if got := NewEncoder(seller, "EUR").in("JPY").amount(1234); got.Value != "1234" || got.Currency != "JPY" {
+		t.Errorf("amount(1234) = %+v, want 1234 JPY", got)
+	}
+}
+
+// The validator must reject what the schemas don't allow, or the tests
+// above prove nothing.
+func TestValidatorRejects(t *testing.T) {
+	b, err := NewEncoder(seller, "EUR").Invoice(testInvoice(), buyer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := loadSchema(t, "invoice.schema")
+	tests := map[string]struct{ old, new string }{
+		"out of order":    {"<cbc:ProfileID>", "<cbc:Note>x</cbc:Note>\n  <cbc:ProfileID>"},
+		"missing":         {"<cbc:InvoiceTypeCode>380</cbc:InvoiceTypeCode>", ""},
+		"unknown element": {"<cbc:BuyerReference>", "<cbc:Foo>x</cbc:Foo><cbc:BuyerReference>"},
+		"no attribute":    {`<cbc:PayableAmount currencyID="EUR">`, "<cbc:PayableAmount>"},
+		"empty":           {"<cbc:ID>INV-2024-000042</cbc:ID>", "<cbc:ID></cbc:ID>"},
+		"empty aggregate": {"<cac:LegalMonetaryTotal>", "<cac:Delivery></cac:Delivery><cac:LegalMonetaryTotal>"},
+		"wrong namespace": {"xmlns:cbc=\"" + nsCBC, "xmlns:cbc=\"urn:x"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bad := bytes.Replace(b, []byte(tt.old), []byte(tt.new), 1)
+			if bytes.Equal(bad, b) {
+				t.Fatalf("%q not found", tt.old)
+			}
+			root, err := parse(bad)
+			if err == nil {
+				err = errors.Join(s.check(root, "")...)
+			}
+			if err == nil {
+				t.Fatal("want the document rejected")
+			}
+		})
+	}
+}
+
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs, run the tests with -update if the change is intended:\n%s", name, got)
+	}
+}
+
+// validate checks a document against the common schema and that of its
+// root, and returns it.
+func validate(t *testing.T, b []byte, schema string) *node {
+	t.Helper()
+	root, err := parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range loadSchema(t, schema).check(root, "") {
+		t.Error(err)
+	}
+	return root
+}
+
+// checkRules checks the calculation rules of Peppol BIS Billing 3.0 on a
+// document without allowances or charges at its level.
+func checkRules(t *testing.T, root *node, lineName, qtyName string) {
+	t.Helper()
+	currency := root.text("cbc:DocumentCurrencyCode")
+	root.walk(func(n *node) {
+		if c, ok := n.attrs["currencyID"]; ok && c != currency {
+			t.Errorf("%s in %s, want %s", n.name, c, currency)
+		}
+	})
+	var lines int64
+	taxable := map[string]int64{}
+	for _, l := range root.all(lineName) {
+		net := cents(t, l.text("cbc:LineExtensionAmount"))
+		lines += net
+		base := int64(1)
+		if q := l.text("cac:Price/cbc:BaseQuantity"); q != "" {
+			base = int64(atoi(t, q))
+		}
+		gross := int64(atoi(t, l.text(qtyName))) * cents(t, l.text("cac:Price/cbc:PriceAmount")) / base
+		for _, a := range l.all("cac:AllowanceCharge") {
+			gross -= cents(t, a.text("cbc:Amount"))
+		}
+		if gross != net {
+			t.Errorf("line %s: quantity by price less allowances is %d, want %d", l.text("cbc:ID"), gross, net)
+		}
+		cat := l.text("cac:Item/cac:ClassifiedTaxCategory/cbc:ID") + " " + l.text("cac:Item/cac:ClassifiedTaxCategory/cbc:Percent")
+		taxable[cat] += net
+	}
+	var tax int64
+	for _, s := range root.all("cac:TaxTotal/cac:TaxSubtotal") {
+		id := s.text("cac:TaxCategory/cbc:ID")
+		cat := id + " " + s.text("cac:TaxCategory/cbc:Percent")
+		if got := cents(t, s.text("cbc:TaxableAmount")); got != taxable[cat] {
+			t.Errorf("taxable amount of %s is %d, want %d", cat, got, taxable[cat])
+		}
+		delete(taxable, cat)
+		amount := cents(t, s.text("cbc:TaxAmount"))
+		tax += amount
+		if (id == exempt || id == zeroRated) && amount != 0 {
+			t.Errorf("tax of %s is %d, want 0", cat, amount)
+		}
+		if reason := s.text("cac:TaxCategory/cbc:TaxExemptionReason"); (id == exempt) != (reason != "") {
+			t.Errorf("category %s with exemption reason %q", cat, reason)
+		}
+	}
+	for cat := range taxable {
+		t.Errorf("category %s of the lines not in the breakdown", cat)
+	}
+	total := func(name string) int64 { return cents(t, root.text("cac:LegalMonetaryTotal/"+name)) }
+	if got := cents(t, root.text("cac:TaxTotal/cbc:TaxAmount")); got != tax {
+		t.Errorf("tax amount %d, want the sum of the breakdown %d", got, tax)
+	}
+	if got := total("cbc:LineExtensionAmount"); got != lines {
+		t.Errorf("line extension amount %d, want the sum of the lines %d", got, lines)
+	}
+	if total("cbc:TaxExclusiveAmount") != lines {
+		t.Error("tax exclusive amount isn't the sum of the lines")
+	}
+	if total("cbc:TaxInclusiveAmount") != lines+tax {
+		t.Error("tax inclusive amount isn't the tax exclusive amount plus the tax")
+	}
+	if total("cbc:PayableAmount") != total("cbc:TaxInclusiveAmount") {
+		t.Error("payable amount isn't the tax inclusive amount")
+	}
+}
+
+func cents(t *testing.T, s string) int64 {
+	t.Helper()
+	units, dec, ok := strings.Cut(s, ".")
+	if !ok || len(dec) != 2 {
+		t.Fatalf("amount %q, want two decimals", s)
+	}
+	return int64(atoi(t, units))*100 + int64(atoi(t, dec))
+}
+
+func atoi(t *testing.T, s string) int {
+	t.Helper()
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// node an element of a document, named with the prefix of its namespace.
+type node struct {
+	name     string
+	attrs    map[string]string
+	chars    string
+	children []*node
+}
+
+// all returns the descendants at a path of element names.
+func (n *node) all(path string) []*node {
+	nodes := []*node{n}
+	for _, name := range strings.Split(path, "/") {
+		var next []*node
+		for _, p := range nodes {
+			for _, c := range p.children {
+				if c.name == name {
+					next = append(next, c)
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// text returns the text of the first descendant at path, empty if none.
+func (n *node) text(path string) string {
+	if nodes := n.all(path); len(nodes) > 0 {
+		return nodes[0].chars
+	}
+	return ""
+}
+
+func (n *node) walk(f func(*node)) {
+	f(n)
+	for _, c := range n.children {
+		c.walk(f)
+	}
+}
+
+// parse returns the root of a document whose elements are all in the UBL
+// namespaces.
+func parse(b []byte) (*node, error) {
+	prefixes := map[string]string{nsCAC: "cac:", nsCBC: "cbc:"}
+	roots := map[string]string{"Invoice": nsInvoice, "CreditNote": nsCreditNote}
+	d := xml.NewDecoder(bytes.NewReader(b))
+	var stack []*node
+	var root *node
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return root, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			n := &node{attrs: map[string]string{}}
+			if len(stack) == 0 {
+				if roots[tok.Name.Local] != tok.Name.Space {
+					return nil, fmt.Errorf("root %s in namespace %q", tok.Name.Local, tok.Name.Space)
+				}
+				n.name, root = tok.Name.Local, n
+			} else {
+				prefix, ok := prefixes[tok.Name.Space]
+				if !ok {
+					return nil, fmt.Errorf("%s in namespace %q", tok.Name.Local, tok.Name.Space)
+				}
+				n.name = prefix + tok.Name.Local
+				p := stack[len(stack)-1]
+				p.children = append(p.children, n)
+			}
+			for _, a := range tok.Attr {
+				if a.Name.Space != "xmlns" && a.Name.Local != "xmlns" {
+					n.attrs[a.Name.Local] = a.Value
+				}
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].chars += string(tok)
+			}
+		}
+	}
+}
+
+// schema the child elements of the aggregates, in order, and the required
+// attributes of the basic components, as the files of testdata describe
+// them.
+type schema struct {
+	children map[string][]child
+	attrs    map[string][]string
+}
+
+type child struct {
+	name     string
+	min, max int // max -1 is unbounded
+}
+
+// loadSchema loads the common schema and that of a root.
+func loadSchema(t *testing.T, name string) schema {
+	t.Helper()
+	s := schema{children: map[string][]child{}, attrs: map[string][]string{}}
+	for _, name := range []string{"common.schema", name} {
+		f, err := os.Open(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		var parent string
+		sc := bufio.NewScanner(f)
+		for line := 1; sc.Scan(); line++ {
+			text := sc.Text()
+			fields := strings.Fields(text)
+			switch {
+			case len(fields) == 0 || strings.HasPrefix(text, "#"):
+			case strings.HasPrefix(text, "\t") && len(fields) == 2 && parent != "":
+				lo, hi, _ := strings.Cut(fields[1], "..")
+				c := child{name: fields[0], min: atoi(t, lo), max: -1}
+				if hi != "n" {
+					c.max = atoi(t, hi)
+				}
+				s.children[parent] = append(s.children[parent], c)
+			case !strings.HasPrefix(text, "\t"):
+				parent = fields[0]
+				for _, a := range fields[1:] {
+					s.attrs[parent] = append(s.attrs[parent], strings.TrimPrefix(a, "@"))
+				}
+			default:
+				t.Fatalf("%s:%d: invalid line %q", name, line, text)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// check returns the errors of n and its descendants: the children of an
+// aggregate must be those of its schema in order and within their
+// cardinality, and a basic component has text and its required attributes.
+// Peppol doesn't allow empty elements.
+func (s schema) check(n *node, path string) []error {
+	path += "/" + n.name
+	children, ok := s.children[n.name]
+	if !ok {
+		var errs []error
+		if len(n.children) > 0 || strings.TrimSpace(n.chars) == "" {
+			errs = append(errs, fmt.Errorf("%s: want a non-empty basic component", path))
+		}
+		for _, a := range s.attrs[n.name] {
+			if n.attrs[a] == "" {
+				errs = append(errs, fmt.Errorf("%s: missing attribute %s", path, a))
+			}
+		}
+		return errs
+	}
+	var errs []error
+	if strings.TrimSpace(n.chars) != "" {
+		errs = append(errs, fmt.Errorf("%s: text in an aggregate", path))
+	}
+	if len(n.children) == 0 {
+		errs = append(errs, fmt.Errorf("%s: empty aggregate", path))
+	}
+	counts := make([]int, len(children))
+	i := 0
+	for _, c := range n.children {
+		j := index(children, c.name)
+		switch {
+		case j < 0:
+			errs = append(errs, fmt.Errorf("%s: unexpected %s", path, c.name))
+			continue
+		case j < i:
+			errs = append(errs, fmt.Errorf("%s: %s out of order", path, c.name))
+		default:
+			i = j
+		}
+		counts[j]++
+		errs = append(errs, s.check(c, path)...)
+	}
+	for j, c := range children {
+		if counts[j] < c.min || (c.max >= 0 && counts[j] > c.max) {
+			errs = append(errs, fmt.Errorf("%s: %d %s, want %d..%d", path, counts[j], c.name, c.min, c.max))
+		}
+	}
+	return errs
+}
+
+func index(children []child, name string) int {
+	for i, c := range children {
+		if c.name == name {
+			return i
+		}
+	}
+	return -1
+}
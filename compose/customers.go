@@ -0,0 +1,34 @@
+package compose
+
+import (
+	"context"
+	"errors"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/store"
+)
+
+// storeCustomers reads the clients of the invoices from the customers of
+// the store.
+type storeCustomers struct {
+	store *store.Service
+}
+
+// Customer returns the customer id as billing reads it, the deleted ones
+// too.
+func (s storeCustomers) Customer(ctx context.Context, id int64) (*billing.Customer, error) {
+	c, err := s.store.FindCustomer(ctx, id)
+	if errors.Is(err, store.ErrCustomerNotFound) {
+		return nil, billing.ErrCustomerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &billing.Customer{
+		ID:      c.ID,
+		Name:    c.BilledName(),
+		Email:   c.Email,
+		Address: c.BillingAddress,
+		Deleted: c.DeletedAt != nil,
+	}, nil
+}
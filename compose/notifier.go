@@ -0,0 +1,38 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/mail"
+	"github.com/adrianolmedo/genesis/money"
+)
+
+// mailNotifier sends the payment reminders by email to the clients.
+type mailNotifier struct {
+	customers billing.Customers
+	mailer    mail.Mailer
+}
+
+// Remind emails the reminder to the client of the invoice.
+func (m mailNotifier) Remind(ctx context.Context, r billing.Reminder) error {
+	client, err := m.customers.Customer(ctx, r.ClientID)
+	if err != nil {
+		return fmt.Errorf("client %d: %w", r.ClientID, err)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hello %s,\n\n", client.Name)
+	fmt.Fprintf(&b, "The invoice %s was due on %s and %s is still unpaid.\n",
+		r.InvoiceNumber, r.DueAt.Format("2006-01-02"), money.New(r.AmountDue, r.Currency))
+	if r.FeeInvoiceNumber != "" {
+		fmt.Fprintf(&b, "A late fee of %s has been invoiced with the invoice %s.\n", money.New(r.LateFee, r.Currency), r.FeeInvoiceNumber)
+	}
+	b.WriteString("\nPlease pay it as soon as possible. If you already did, ignore this email.\n")
+	return m.mailer.Send(ctx, mail.Message{
+		To:      client.Email,
+		Subject: "Payment reminder: invoice " + r.InvoiceNumber,
+		Body:    b.String(),
+	})
+}
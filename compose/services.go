@@ -1,7 +1,23 @@
 package compose
 
 import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adrianolmedo/genesis"
 	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/billing/pdf"
+	"github.com/adrianolmedo/genesis/billing/report"
+	"github.com/adrianolmedo/genesis/billing/ubl"
+	"github.com/adrianolmedo/genesis/ledger"
+	"github.com/adrianolmedo/genesis/logger"
+	"github.com/adrianolmedo/genesis/mail"
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/password"
 	storage "github.com/adrianolmedo/genesis/pgsql/sqlc"
 	"github.com/adrianolmedo/genesis/store"
 	"github.com/adrianolmedo/genesis/user"
@@ -12,13 +28,156 @@ type Services struct {
 	User    *user.Service
 	Store   *store.Service
 	Billing *billing.Service
+
+	// InvoicePDF renders the invoices as PDF.
+	InvoicePDF *pdf.Renderer
+
+	// UBL encodes the invoices and credit notes as Peppol UBL documents.
+	UBL *ubl.Encoder
+
+	// Notifier sends the reminders of the overdue invoices by email.
+	Notifier billing.Notifier
+
+	// Report computes the receivables aging and revenue reports.
+	Report *report.Service
+
+	// Ledger reads the journal the billing events are posted to.
+	Ledger *ledger.Service
 }
 
 // NewServices returns a new Services instance with initialized services.
-func NewServices(s *storage.Storage) *Services {
+func NewServices(s *storage.Storage, cfg genesis.Config) (*Services, error) {
+	hasher, err := password.New(cfg.PasswordHasher)
+	if err != nil {
+		return nil, fmt.Errorf("password hasher: %w", err)
+	}
+	mailer, err := newMailer(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: %w", err)
+	}
+	key := []byte(cfg.TokenSecret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		logger.Warn("token secret not set, the tokens sent by email stop working on restart")
+	}
+	billingOpts, err := billingOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := invoiceTemplate(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invoice template: %w", err)
+	}
+	seller, err := sellerOf(cfg)
+	if err != nil {
+		return nil, err
+	}
+	opts := user.Options{
+		RequireVerifiedEmail: cfg.RequireVerifiedEmail,
+		BaseURL:              strings.TrimSuffix(cfg.BaseURL, "/"),
+		TokenKey:             key,
+		Hasher:               hasher,
+		Mailer:               mailer,
+	}
+	shop := store.NewService(s.Product, s.Customer, hasher, billingOpts.Currency)
+	customers := storeCustomers{store: shop}
+	billingOpts.Customers = customers
 	return &Services{
-		User:    user.NewService(s.User),
-		Store:   store.NewService(s.Product, s.Customer),
-		Billing: billing.NewService(s.Invoice),
+		User: user.NewService(user.Repos{
+			Users:    s.User,
+			Sessions: s.Session,
+			Roles:    s.Role,
+			Attempts: s.Attempts,
+			MFA:      s.MFA,
+			Tokens:   s.Tokens,
+			APIKeys:  s.APIKeys,
+		}, opts),
+		Store:   shop,
+		Billing: billing.NewService(s.Invoice, billingOpts),
+
+		InvoicePDF: pdf.NewRenderer(tmpl, cfg.InvoicePDFCache),
+		UBL:        ubl.NewEncoder(seller, string(billingOpts.Currency)),
+		Notifier:   mailNotifier{customers: customers, mailer: mailer},
+		Report:     report.NewService(s.Report, billingOpts.Currency),
+		Ledger:     ledger.NewService(s.Ledger),
+	}, nil
+}
+
+// billingOptions returns the billing Options of cfg, the invoices and the
+// credit notes can't share a numbering series.
+func billingOptions(cfg genesis.Config) (billing.Options, error) {
+	invoices, err := billing.ParseNumberFormat(cfg.InvoiceNumberFormat)
+	if err != nil {
+		return billing.Options{}, fmt.Errorf("invoice numbers: %w", err)
+	}
+	creditNotes, err := billing.ParseNumberFormat(cfg.CreditNoteNumberFormat)
+	if err != nil {
+		return billing.Options{}, fmt.Errorf("credit note numbers: %w", err)
+	}
+	if invoices.String() == creditNotes.String() {
+		return billing.Options{}, errors.New("invoices and credit notes need different number formats")
+	}
+	rounding, err := billing.ParseTaxRounding(cfg.TaxRounding)
+	if err != nil {
+		return billing.Options{}, err
+	}
+	currency, err := money.ParseCurrency(cfg.Currency)
+	if err != nil {
+		return billing.Options{}, fmt.Errorf("currency %q: %w", cfg.Currency, err)
+	}
+	return billing.Options{
+		InvoiceNumbers:    invoices,
+		CreditNoteNumbers: creditNotes,
+		Jurisdiction:      cfg.TaxJurisdiction,
+		PricesIncludeTax:  cfg.PricesIncludeTax,
+		TaxRounding:       rounding,
+		PaymentTermsDays:  cfg.PaymentTermsDays,
+		Currency:          currency,
+		DraftRenewals:     cfg.SubscriptionDraftInvoices,
+	}, nil
+}
+
+// invoiceTemplate returns the template of the invoice PDFs of cfg, the
+// default one if there is no file.
+func invoiceTemplate(cfg genesis.Config) (*pdf.Template, error) {
+	if cfg.InvoicePDFTemplate == "" {
+		return pdf.DefaultTemplate(), nil
+	}
+	text, err := os.ReadFile(cfg.InvoicePDFTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return pdf.ParseTemplate(filepath.Base(cfg.InvoicePDFTemplate), string(text))
+}
+
+// sellerOf returns the seller of the UBL invoices of cfg.
+func sellerOf(cfg genesis.Config) (ubl.Seller, error) {
+	s := ubl.Seller{
+		Name:    cfg.SellerName,
+		VATID:   cfg.SellerVATID,
+		Country: strings.ToUpper(cfg.SellerCountry),
+	}
+	if cfg.SellerEndpoint != "" {
+		scheme, id, ok := strings.Cut(cfg.SellerEndpoint, ":")
+		if !ok || scheme == "" || id == "" {
+			return ubl.Seller{}, fmt.Errorf("seller endpoint %q must be scheme:id", cfg.SellerEndpoint)
+		}
+		s.EndpointScheme, s.EndpointID = scheme, id
+	}
+	return s, nil
+}
+
+// newMailer returns the Mailer of cfg: SMTP if there is a server, else the
+// mail file or stdout.
+func newMailer(cfg genesis.Config) (mail.Mailer, error) {
+	switch {
+	case cfg.SMTPAddr != "":
+		return mail.NewSMTP(cfg.SMTPAddr, cfg.MailFrom, cfg.SMTPUsername, cfg.SMTPPassword), nil
+	case cfg.MailFile != "":
+		return mail.NewFile(cfg.MailFile, cfg.MailFrom)
 	}
+	return mail.NewWriter(os.Stdout, cfg.MailFrom), nil
 }
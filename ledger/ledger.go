@@ -0,0 +1,167 @@
+// Package ledger is the double-entry general ledger the billing events are
+// posted to. An entry debits and credits the accounts of the chart by the
+// same amount, this is checked here and again by the database when the
+// transaction that posts it commits. The journal is append only.
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrUnbalanced      = errors.New("the debits of the journal entry don't equal its credits")
+	ErrInvalidLine     = errors.New("a journal line debits or credits a positive amount to an account")
+	ErrEntryExists     = errors.New("the event is already posted")
+	ErrAccountNotFound = errors.New("account not found in the chart of accounts")
+	ErrJournalAppend   = errors.New("the journal is append only")
+)
+
+// Codes of the chart of accounts, created by the migrations.
+const (
+	Cash               = "1000"
+	AccountsReceivable = "1100"
+	TaxPayable         = "2100"
+	CustomerCredit     = "2200" // received and not applied to an invoice
+	Revenue            = "4000"
+	ExchangeDifference = "4900" // gains less losses of exchange and rounding
+)
+
+// AccountType the side an account increases on, debit for assets and
+// expenses, credit for the others.
+type AccountType string
+
+// Types of the accounts.
+const (
+	TypeAsset     AccountType = "asset"
+	TypeLiability AccountType = "liability"
+	TypeEquity    AccountType = "equity"
+	TypeRevenue   AccountType = "revenue"
+	TypeExpense   AccountType = "expense"
+)
+
+// DebitNormal reports whether the balance of an account of type t is its
+// debits less its credits.
+func (t AccountType) DebitNormal() bool {
+	return t == TypeAsset || t == TypeExpense
+}
+
+// Account of the chart of accounts.
+type Account struct {
+	Code string
+	Name string
+	Type AccountType
+}
+
+// Line debits or credits an account, one of them is 0.
+type Line struct {
+	ID      int64
+	Account string // code
+	Debit   int64
+	Credit  int64
+}
+
+// Entry of the journal, the lines of a billing event.
+type Entry struct {
+	ID       int64
+	Source   string // the kind of event, e.g. invoice
+	SourceID int64  // of the document of the event
+	Memo     string
+	PostedAt time.Time
+	Lines    []Line
+
+	CreatedAt time.Time
+}
+
+// Debit adds a line debiting amount to account, a credit if it's negative
+// and none if it's 0.
+func (e *Entry) Debit(account string, amount int64) {
+	switch {
+	case amount > 0:
+		e.Lines = append(e.Lines, Line{Account: account, Debit: amount})
+	case amount < 0:
+		e.Lines = append(e.Lines, Line{Account: account, Credit: -amount})
+	}
+}
+
+// Credit adds a line crediting amount to account, a debit if it's negative
+// and none if it's 0.
+func (e *Entry) Credit(account string, amount int64) {
+	e.Debit(account, -amount)
+}
+
+// Reverse returns the entry that cancels e, its lines on the other side.
+func (e *Entry) Reverse(source, memo string, at time.Time) *Entry {
+	r := &Entry{Source: source, SourceID: e.SourceID, Memo: memo, PostedAt: at}
+	for _, l := range e.Lines {
+		r.Lines = append(r.Lines, Line{Account: l.Account, Debit: l.Credit, Credit: l.Debit})
+	}
+	return r
+}
+
+// Empty reports whether e moves no amount, nothing is posted.
+func (e *Entry) Empty() bool {
+	return len(e.Lines) == 0
+}
+
+// Validate checks that e can be posted: every line is on one side and the
+// debits equal the credits.
+func (e *Entry) Validate() error {
+	if e.Source == "" || e.SourceID <= 0 {
+		return errors.New("the journal entry has no source")
+	}
+	if len(e.Lines) < 2 {
+		return fmt.Errorf("%w: %d lines", ErrUnbalanced, len(e.Lines))
+	}
+	var debits, credits int64
+	for _, l := range e.Lines {
+		if l.Account == "" || l.Debit < 0 || l.Credit < 0 || (l.Debit == 0) == (l.Credit == 0) {
+			return fmt.Errorf("%w: %s %d/%d", ErrInvalidLine, l.Account, l.Debit, l.Credit)
+		}
+		debits += l.Debit
+		credits += l.Credit
+	}
+	if debits != credits {
+		return fmt.Errorf("%w: debit %d, credit %d", ErrUnbalanced, debits, credits)
+	}
+	return nil
+}
+
+// Balance of an account in the trial balance.
+type Balance struct {
+	Account
+	Debit  int64 // posted
+	Credit int64 // posted
+}
+
+// Net returns the balance on the normal side of the account, negative if
+// it's on the other one.
+func (b Balance) Net() int64 {
+	if b.Type.DebitNormal() {
+		return b.Debit - b.Credit
+	}
+	return b.Credit - b.Debit
+}
+
+// TrialBalance the balances of the accounts at a time, its debits equal
+// its credits.
+type TrialBalance struct {
+	At       time.Time // zero for all the entries
+	Accounts []Balance
+	Debit    int64
+	Credit   int64
+}
+
+// newTrialBalance sums the balances, ErrUnbalanced if the ledger is.
+func newTrialBalance(at time.Time, balances []Balance) (*TrialBalance, error) {
+	tb := &TrialBalance{At: at, Accounts: balances}
+	for _, b := range balances {
+		tb.Debit += b.Debit
+		tb.Credit += b.Credit
+	}
+	if tb.Debit != tb.Credit {
+		return tb, fmt.Errorf("%w: debit %d, credit %d", ErrUnbalanced, tb.Debit, tb.Credit)
+	}
+	return tb, nil
+}
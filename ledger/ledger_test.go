@@ -0,0 +1,126 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEntryValidate(t *testing.T) {
+	tt := []struct {
+		name    string
+		lines   []Line
+		wantErr error
+	}{
+		{
+			name:  "balanced",
+			lines: []Line{{Account: AccountsReceivable, Debit: 1160}, {Account: Revenue, Credit: 1000}, {Account: TaxPayable, Credit: 160}},
+		},
+		{
+			name:    "unbalanced",
+			lines:   []Line{{Account: AccountsReceivable, Debit: 1160}, {Account: Revenue, Credit: 1000}},
+			wantErr: ErrUnbalanced,
+		},
+		{
+			name:    "one-line",
+			lines:   []Line{{Account: Cash, Debit: 0, Credit: 0}},
+			wantErr: ErrUnbalanced,
+		},
+		{
+			name:    "both-sides",
+			lines:   []Line{{Account: Cash, Debit: 100, Credit: 100}, {Account: Revenue, Credit: 0, Debit: 0}},
+			wantErr: ErrInvalidLine,
+		},
+		{
+			name:    "negative-amount",
+			lines:   []Line{{Account: Cash, Debit: -100}, {Account: Revenue, Credit: -100}},
+			wantErr: ErrInvalidLine,
+		},
+		{
+			name:    "no-account",
+			lines:   []Line{{Debit: 100}, {Account: Revenue, Credit: 100}},
+			wantErr: ErrInvalidLine,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &Entry{Source: "invoice", SourceID: 1, Lines: tc.lines}
+			if err := e.Validate(); !errors.Is(err, tc.wantErr) {
+				t.Errorf("want error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestEntryDebitCredit(t *testing.T) {
+	e := &Entry{Source: "payment", SourceID: 1}
+	e.Debit(Cash, 1500)
+	e.Credit(AccountsReceivable, 1000)
+	e.Credit(CustomerCredit, 500)
+	e.Credit(TaxPayable, 0) // no line
+	if len(e.Lines) != 3 {
+		t.Fatalf("want 3 lines, got %+v", e.Lines)
+	}
+	if err := e.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A negative amount is on the other side.
+	e = &Entry{Source: "credit_note", SourceID: 1}
+	e.Debit(AccountsReceivable, -300)
+	e.Credit(Revenue, -300)
+	want := []Line{{Account: AccountsReceivable, Credit: 300}, {Account: Revenue, Debit: 300}}
+	if len(e.Lines) != 2 || e.Lines[0] != want[0] || e.Lines[1] != want[1] {
+		t.Errorf("want lines %+v, got %+v", want, e.Lines)
+	}
+	if !(&Entry{}).Empty() {
+		t.Error("want an entry without lines empty")
+	}
+}
+
+func TestEntryReverse(t *testing.T) {
+	at := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	e := &Entry{Source: "invoice", SourceID: 7}
+	e.Debit(AccountsReceivable, 1160)
+	e.Credit(Revenue, 1000)
+	e.Credit(TaxPayable, 160)
+	r := e.Reverse("invoice_void", "Void", at)
+	if r.Source != "invoice_void" || r.SourceID != 7 || !r.PostedAt.Equal(at) {
+		t.Errorf("unexpected reverse entry %+v", r)
+	}
+	for i, l := range r.Lines {
+		if l.Debit != e.Lines[i].Credit || l.Credit != e.Lines[i].Debit || l.Account != e.Lines[i].Account {
+			t.Errorf("want line %d reversed, got %+v of %+v", i, l, e.Lines[i])
+		}
+	}
+	if err := r.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTrialBalance(t *testing.T) {
+	balances := []Balance{
+		{Account: Account{Code: Cash, Type: TypeAsset}, Debit: 1000},
+		{Account: Account{Code: AccountsReceivable, Type: TypeAsset}, Debit: 1160, Credit: 1000},
+		{Account: Account{Code: TaxPayable, Type: TypeLiability}, Credit: 160},
+		{Account: Account{Code: Revenue, Type: TypeRevenue}, Credit: 1000},
+	}
+	tb, err := newTrialBalance(time.Time{}, balances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tb.Debit != 2160 || tb.Credit != 2160 {
+		t.Errorf("want 2160 debit and credit, got %d and %d", tb.Debit, tb.Credit)
+	}
+	if got := balances[1].Net(); got != 160 {
+		t.Errorf("want receivable of 160, got %d", got)
+	}
+	if got := balances[3].Net(); got != 1000 {
+		t.Errorf("want revenue of 1000, got %d", got)
+	}
+
+	balances[0].Debit = 999
+	if _, err := newTrialBalance(time.Time{}, balances); !errors.Is(err, ErrUnbalanced) {
+		t.Errorf("want error %v, got %v", ErrUnbalanced, err)
+	}
+}
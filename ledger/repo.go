@@ -0,0 +1,162 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Codes of the errors raised by the database on the journal.
+const (
+	appendOnlyErrCode = "GN002"
+	unbalancedErrCode = "GN003"
+)
+
+// Repo reads the ledger.
+type Repo struct {
+	q *dbgen.Queries // methods generated by sqlc
+}
+
+// NewRepo creates a new ledger repository instance.
+func NewRepo(db dbgen.DBTX) *Repo {
+	return &Repo{
+		q: dbgen.New(db),
+	}
+}
+
+// Post validates and inserts e with the queries q of the transaction of its
+// event, the database checks it's balanced when it commits. An empty entry
+// isn't posted.
+func Post(ctx context.Context, q *dbgen.Queries, e *Entry) error {
+	if e.Empty() {
+		return nil
+	}
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	id, err := q.JournalEntryCreate(ctx, dbgen.JournalEntryCreateParams{
+		Source:    e.Source,
+		SourceID:  e.SourceID,
+		Memo:      e.Memo,
+		PostedAt:  e.PostedAt,
+		CreatedAt: e.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("journal entry: %w", Err(err))
+	}
+	e.ID = id
+	for i := range e.Lines {
+		l := &e.Lines[i]
+		l.ID, err = q.JournalLineCreate(ctx, dbgen.JournalLineCreateParams{
+			EntryID:     id,
+			AccountCode: l.Account,
+			Debit:       l.Debit,
+			Credit:      l.Credit,
+		})
+		if err != nil {
+			return fmt.Errorf("journal line: %w", Err(err))
+		}
+	}
+	return nil
+}
+
+// Err maps an error of the database on the journal, of a statement or of
+// the commit of a transaction that posted to it, to the errors of ledger.
+func Err(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch {
+	case pgErr.Code == unbalancedErrCode:
+		return fmt.Errorf("%w: %s", ErrUnbalanced, pgErr.Message)
+	case pgErr.Code == appendOnlyErrCode:
+		return fmt.Errorf("%w: %s", ErrJournalAppend, pgErr.Message)
+	case pgErr.ConstraintName == "journal_entry_source_uq":
+		return ErrEntryExists
+	case pgErr.ConstraintName == "journal_line_account_code_fk":
+		return ErrAccountNotFound
+	}
+	return err
+}
+
+// Accounts returns the chart of accounts, by code.
+func (r *Repo) Accounts(ctx context.Context) ([]Account, error) {
+	rows, err := r.q.LedgerAccountAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]Account, 0, len(rows))
+	for _, row := range rows {
+		accounts = append(accounts, Account{Code: row.Code, Name: row.Name, Type: AccountType(row.Type)})
+	}
+	return accounts, nil
+}
+
+// Entries returns the entries posted by the event of a document, with
+// their lines, oldest first.
+func (r *Repo) Entries(ctx context.Context, source string, sourceID int64) ([]Entry, error) {
+	rows, err := r.q.JournalEntryBySource(ctx, dbgen.JournalEntryBySourceParams{Source: source, SourceID: sourceID})
+	if err != nil {
+		return nil, err
+	}
+	lines, err := r.q.JournalLineBySource(ctx, dbgen.JournalLineBySourceParams{Source: source, SourceID: sourceID})
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(rows))
+	for _, row := range rows {
+		e := Entry{
+			ID:        row.ID,
+			Source:    row.Source,
+			SourceID:  row.SourceID,
+			Memo:      row.Memo,
+			PostedAt:  row.PostedAt,
+			CreatedAt: row.CreatedAt,
+		}
+		for _, l := range lines {
+			if l.EntryID == row.ID {
+				e.Lines = append(e.Lines, Line{ID: l.ID, Account: l.AccountCode, Debit: l.Debit, Credit: l.Credit})
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Balances returns the debits and credits of each account posted up to at,
+// all of them if it's zero.
+func (r *Repo) Balances(ctx context.Context, at time.Time) ([]Balance, error) {
+	rows, err := r.q.LedgerTrialBalance(ctx, sql.NullTime{Time: at, Valid: !at.IsZero()})
+	if err != nil {
+		return nil, err
+	}
+	balances := make([]Balance, 0, len(rows))
+	for _, row := range rows {
+		balances = append(balances, Balance{
+			Account: Account{Code: row.Code, Name: row.Name, Type: AccountType(row.Type)},
+			Debit:   row.Debit,
+			Credit:  row.Credit,
+		})
+	}
+	return balances, nil
+}
+
+// DeleteAll deletes all the journal entries and lines (permanently), the
+// chart of accounts is kept.
+func (r *Repo) DeleteAll(ctx context.Context) error {
+	err := r.q.JournalDeleteAll(ctx)
+	if err != nil {
+		return fmt.Errorf("can't truncate table: %v", err)
+	}
+	return nil
+}
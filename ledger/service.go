@@ -0,0 +1,37 @@
+package ledger
+
+import (
+	"context"
+	"time"
+)
+
+// Service reads the ledger, the billing events post to it.
+type Service struct {
+	repo *Repo
+}
+
+// NewService creates a new ledger service.
+func NewService(repo *Repo) *Service {
+	return &Service{repo: repo}
+}
+
+// Accounts returns the chart of accounts.
+func (s *Service) Accounts(ctx context.Context) ([]Account, error) {
+	return s.repo.Accounts(ctx)
+}
+
+// Entries returns the entries posted by the event source of a document.
+func (s *Service) Entries(ctx context.Context, source string, sourceID int64) ([]Entry, error) {
+	return s.repo.Entries(ctx, source, sourceID)
+}
+
+// TrialBalance returns the balances of the accounts at at, of all the
+// entries if it's zero. It returns the balances and ErrUnbalanced if the
+// debits don't equal the credits.
+func (s *Service) TrialBalance(ctx context.Context, at time.Time) (*TrialBalance, error) {
+	balances, err := s.repo.Balances(ctx, at)
+	if err != nil {
+		return nil, err
+	}
+	return newTrialBalance(at, balances)
+}
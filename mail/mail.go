@@ -0,0 +1,120 @@
+// Package mail sends the emails of the application through a Mailer: SMTP
+// in production, a writer (stdout or a file) for local development and
+// tests.
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidAddress the recipient isn't a valid email address.
+var ErrInvalidAddress = errors.New("invalid email address")
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends messages.
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// SMTP sends messages through an SMTP server, with PLAIN authentication if
+// a username is given.
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTP creates a Mailer for the SMTP server at addr (host:port) sending
+// from the address from.
+func NewSMTP(addr, from, username, password string) *SMTP {
+	s := &SMTP{addr: addr, from: from}
+	if username != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+// Send the message. The SMTP exchange can't be canceled, ctx is only
+// checked before it starts.
+func (s *SMTP) Send(ctx context.Context, m Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(m.To)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidAddress, m.To)
+	}
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{to.Address}, format(s.from, m))
+}
+
+// Writer writes the messages to w instead of sending them.
+type Writer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+// NewWriter creates a Mailer that writes the messages to w, e.g.: os.Stdout.
+func NewWriter(w io.Writer, from string) *Writer {
+	return &Writer{w: w, from: from}
+}
+
+// NewFile creates a Mailer that appends the messages to the file at path.
+func NewFile(path, from string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter(f, from), nil
+}
+
+// Send writes the message followed by a blank line.
+func (w *Writer) Send(ctx context.Context, m Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := mail.ParseAddress(m.To); err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidAddress, m.To)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.w.Write(append(format(w.from, m), "\r\n"...))
+	return err
+}
+
+// format returns the message in RFC 5322 format.
+func format(from string, m Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", sanitize(m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// sanitize removes line breaks of a header value, so it can't inject
+// headers.
+func sanitize(s string) string {
+	return strings.NewReplacer("\r", "", "\n", " ").Replace(s)
+}
@@ -0,0 +1,38 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewWriter(&buf, "noreply@genesis.local")
+	err := m.Send(context.Background(), Message{
+		To:      "example@gmail.com",
+		Subject: "Verify your email\r\nBcc: attacker@example.com",
+		Body:    "Hello\nworld",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"From: noreply@genesis.local\r\n",
+		"To: example@gmail.com\r\n",
+		"Subject: Verify your email Bcc: attacker@example.com\r\n",
+		"\r\n\r\nHello\r\nworld\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in the message:\n%s", want, got)
+		}
+	}
+
+	err = m.Send(context.Background(), Message{To: "not an address"})
+	if !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("want error %v, got %v", ErrInvalidAddress, err)
+	}
+}
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Passwords were stored in plain text. Hash them with bcrypt (supported by
+-- the password package), they are upgraded to argon2id on the next login.
+-- Only the values already hashed by it are kept, a plain text password may
+-- start with $ too.
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+UPDATE "user" SET password = crypt(password, gen_salt('bf', 12))
+WHERE password !~ '^\$(argon2id|2[aby])\$';
+
+UPDATE customer SET password = crypt(password, gen_salt('bf', 12))
+WHERE password <> '' AND password !~ '^\$(argon2id|2[aby])\$';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Hashes can't be reverted to plain text.
+SELECT 1;
+-- +goose StatementEnd
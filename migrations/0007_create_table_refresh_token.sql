@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS refresh_token (
+    id BIGSERIAL,
+    user_id BIGINT NOT NULL,
+    family_id UUID NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    rotated_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT refresh_token_id_pk PRIMARY KEY (id),
+
+    CONSTRAINT refresh_token_token_hash_uq UNIQUE (token_hash),
+
+    CONSTRAINT refresh_token_user_id_fk FOREIGN KEY (user_id)
+        REFERENCES "user" (id) ON UPDATE RESTRICT ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS refresh_token_family_id_idx ON refresh_token (family_id);
+CREATE INDEX IF NOT EXISTS refresh_token_user_id_idx ON refresh_token (user_id);
+
+CREATE TABLE IF NOT EXISTS revoked_token (
+    jti VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT revoked_token_jti_pk PRIMARY KEY (jti)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS revoked_token;
+DROP INDEX IF EXISTS refresh_token_user_id_idx;
+DROP INDEX IF EXISTS refresh_token_family_id_idx;
+ALTER TABLE refresh_token DROP CONSTRAINT IF EXISTS refresh_token_user_id_fk;
+ALTER TABLE refresh_token DROP CONSTRAINT IF EXISTS refresh_token_token_hash_uq;
+ALTER TABLE refresh_token DROP CONSTRAINT IF EXISTS refresh_token_id_pk;
+DROP TABLE IF EXISTS refresh_token;
+-- +goose StatementEnd
@@ -0,0 +1,71 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS role (
+    id BIGSERIAL,
+    name VARCHAR(50) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT role_id_pk PRIMARY KEY (id),
+    CONSTRAINT role_name_uq UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS permission (
+    id BIGSERIAL,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+
+    CONSTRAINT permission_id_pk PRIMARY KEY (id),
+    CONSTRAINT permission_name_uq UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS role_permission (
+    role_id BIGINT NOT NULL,
+    permission_id BIGINT NOT NULL,
+
+    CONSTRAINT role_permission_pk PRIMARY KEY (role_id, permission_id),
+
+    CONSTRAINT role_permission_role_id_fk FOREIGN KEY (role_id)
+        REFERENCES role (id) ON UPDATE RESTRICT ON DELETE CASCADE,
+
+    CONSTRAINT role_permission_permission_id_fk FOREIGN KEY (permission_id)
+        REFERENCES permission (id) ON UPDATE RESTRICT ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS user_role (
+    user_id BIGINT NOT NULL,
+    role_id BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT user_role_pk PRIMARY KEY (user_id, role_id),
+
+    CONSTRAINT user_role_user_id_fk FOREIGN KEY (user_id)
+        REFERENCES "user" (id) ON UPDATE RESTRICT ON DELETE CASCADE,
+
+    CONSTRAINT user_role_role_id_fk FOREIGN KEY (role_id)
+        REFERENCES role (id) ON UPDATE RESTRICT ON DELETE CASCADE
+);
+
+INSERT INTO permission (name, description) VALUES
+    ('users:read', 'List users'),
+    ('users:write', 'Update and delete users'),
+    ('products:write', 'Add, update and delete products'),
+    ('invoices:write', 'Generate invoices'),
+    ('roles:write', 'Assign and revoke roles')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role (name, description) VALUES ('admin', 'Full access')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id FROM role r CROSS JOIN permission p WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_role;
+DROP TABLE IF EXISTS role_permission;
+DROP TABLE IF EXISTS permission;
+DROP TABLE IF EXISTS role;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS login_attempt (
+    email VARCHAR(100) NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ,
+    blocked_until TIMESTAMPTZ,
+
+    CONSTRAINT login_attempt_email_pk PRIMARY KEY (email)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE login_attempt DROP CONSTRAINT IF EXISTS login_attempt_email_pk;
+DROP TABLE IF EXISTS login_attempt;
+-- +goose StatementEnd
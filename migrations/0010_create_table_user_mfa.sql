@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id BIGINT NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    enabled_at TIMESTAMPTZ,
+    last_counter BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT user_mfa_user_id_pk PRIMARY KEY (user_id),
+
+    CONSTRAINT user_mfa_user_id_fk FOREIGN KEY (user_id)
+        REFERENCES "user" (id) ON UPDATE RESTRICT ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_code (
+    id BIGSERIAL,
+    user_id BIGINT NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT mfa_recovery_code_id_pk PRIMARY KEY (id),
+
+    CONSTRAINT mfa_recovery_code_user_id_code_hash_uq UNIQUE (user_id, code_hash),
+
+    CONSTRAINT mfa_recovery_code_user_id_fk FOREIGN KEY (user_id)
+        REFERENCES "user" (id) ON UPDATE RESTRICT ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS mfa_challenge (
+    token_hash VARCHAR(64) NOT NULL,
+    user_id BIGINT NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT mfa_challenge_token_hash_pk PRIMARY KEY (token_hash),
+
+    CONSTRAINT mfa_challenge_user_id_fk FOREIGN KEY (user_id)
+        REFERENCES "user" (id) ON UPDATE RESTRICT ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE mfa_challenge DROP CONSTRAINT IF EXISTS mfa_challenge_user_id_fk;
+ALTER TABLE mfa_challenge DROP CONSTRAINT IF EXISTS mfa_challenge_token_hash_pk;
+DROP TABLE IF EXISTS mfa_challenge;
+ALTER TABLE mfa_recovery_code DROP CONSTRAINT IF EXISTS mfa_recovery_code_user_id_fk;
+ALTER TABLE mfa_recovery_code DROP CONSTRAINT IF EXISTS mfa_recovery_code_user_id_code_hash_uq;
+ALTER TABLE mfa_recovery_code DROP CONSTRAINT IF EXISTS mfa_recovery_code_id_pk;
+DROP TABLE IF EXISTS mfa_recovery_code;
+ALTER TABLE user_mfa DROP CONSTRAINT IF EXISTS user_mfa_user_id_fk;
+ALTER TABLE user_mfa DROP CONSTRAINT IF EXISTS user_mfa_user_id_pk;
+DROP TABLE IF EXISTS user_mfa;
+-- +goose StatementEnd
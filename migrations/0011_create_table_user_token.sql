@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "user" ADD COLUMN IF NOT EXISTS verified_at TIMESTAMPTZ;
+
+-- Accounts created before the verification are trusted.
+UPDATE "user" SET verified_at = created_at WHERE verified_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS user_token (
+    id BIGSERIAL,
+    user_id BIGINT NOT NULL,
+    purpose VARCHAR(20) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT user_token_id_pk PRIMARY KEY (id),
+
+    CONSTRAINT user_token_token_hash_uq UNIQUE (token_hash),
+
+    CONSTRAINT user_token_purpose_ck CHECK (purpose IN ('verify_email', 'reset_password')),
+
+    CONSTRAINT user_token_user_id_fk FOREIGN KEY (user_id)
+        REFERENCES "user" (id) ON UPDATE RESTRICT ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS user_token_user_id_idx ON user_token (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS user_token_user_id_idx;
+ALTER TABLE user_token DROP CONSTRAINT IF EXISTS user_token_user_id_fk;
+ALTER TABLE user_token DROP CONSTRAINT IF EXISTS user_token_purpose_ck;
+ALTER TABLE user_token DROP CONSTRAINT IF EXISTS user_token_token_hash_uq;
+ALTER TABLE user_token DROP CONSTRAINT IF EXISTS user_token_id_pk;
+DROP TABLE IF EXISTS user_token;
+ALTER TABLE "user" DROP COLUMN IF EXISTS verified_at;
+-- +goose StatementEnd
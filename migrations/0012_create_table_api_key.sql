@@ -0,0 +1,60 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS service_account (
+    id BIGSERIAL,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT service_account_id_pk PRIMARY KEY (id),
+    CONSTRAINT service_account_name_uq UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS api_key (
+    id BIGSERIAL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    user_id BIGINT,
+    service_account_id BIGINT,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT api_key_id_pk PRIMARY KEY (id),
+
+    CONSTRAINT api_key_prefix_uq UNIQUE (prefix),
+
+    -- A key is owned either by a user or by a service account.
+    CONSTRAINT api_key_owner_ck CHECK ((user_id IS NULL) <> (service_account_id IS NULL)),
+
+    CONSTRAINT api_key_user_id_fk FOREIGN KEY (user_id)
+        REFERENCES "user" (id) ON UPDATE RESTRICT ON DELETE CASCADE,
+
+    CONSTRAINT api_key_service_account_id_fk FOREIGN KEY (service_account_id)
+        REFERENCES service_account (id) ON UPDATE RESTRICT ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS api_key_user_id_idx ON api_key (user_id);
+CREATE INDEX IF NOT EXISTS api_key_service_account_id_idx ON api_key (service_account_id);
+
+INSERT INTO permission (name, description) VALUES
+    ('service_accounts:write', 'Manage service accounts and their API keys')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id FROM role r CROSS JOIN permission p
+WHERE r.name = 'admin' AND p.name = 'service_accounts:write'
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name = 'service_accounts:write';
+DROP INDEX IF EXISTS api_key_service_account_id_idx;
+DROP INDEX IF EXISTS api_key_user_id_idx;
+DROP TABLE IF EXISTS api_key;
+DROP TABLE IF EXISTS service_account;
+-- +goose StatementEnd
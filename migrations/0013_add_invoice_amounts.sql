@@ -0,0 +1,70 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Amounts in minor units of the currency (e.g. cents), tax rates in basis
+-- points (1600 = 16%).
+ALTER TABLE invoice_item
+    ADD COLUMN IF NOT EXISTS product_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS quantity INT NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS unit_price BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tax_rate INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS subtotal BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS discount BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tax BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS total BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE invoice_header
+    ADD COLUMN IF NOT EXISTS subtotal BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS discount BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS tax BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS total BIGINT NOT NULL DEFAULT 0;
+
+-- Items generated before are taken as one unit at the current price.
+UPDATE invoice_item i
+SET product_name = p.name, unit_price = p.price, subtotal = p.price, total = p.price
+FROM product p
+WHERE p.id = i.product_id;
+
+UPDATE invoice_header h
+SET subtotal = s.subtotal, discount = s.discount, tax = s.tax, total = s.total
+FROM (
+    SELECT invoice_header_id, SUM(subtotal) AS subtotal, SUM(discount) AS discount,
+        SUM(tax) AS tax, SUM(total) AS total
+    FROM invoice_item
+    GROUP BY invoice_header_id
+) s
+WHERE s.invoice_header_id = h.id;
+
+ALTER TABLE invoice_item
+    ADD CONSTRAINT invoice_item_quantity_ck CHECK (quantity > 0),
+    ADD CONSTRAINT invoice_item_unit_price_ck CHECK (unit_price >= 0),
+    ADD CONSTRAINT invoice_item_tax_rate_ck CHECK (tax_rate BETWEEN 0 AND 10000),
+    ADD CONSTRAINT invoice_item_discount_ck CHECK (discount BETWEEN 0 AND subtotal),
+    ADD CONSTRAINT invoice_item_total_ck CHECK (total = subtotal - discount + tax);
+
+ALTER TABLE invoice_header
+    ADD CONSTRAINT invoice_header_total_ck CHECK (total = subtotal - discount + tax);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE invoice_header DROP CONSTRAINT IF EXISTS invoice_header_total_ck;
+ALTER TABLE invoice_item DROP CONSTRAINT IF EXISTS invoice_item_total_ck;
+ALTER TABLE invoice_item DROP CONSTRAINT IF EXISTS invoice_item_discount_ck;
+ALTER TABLE invoice_item DROP CONSTRAINT IF EXISTS invoice_item_tax_rate_ck;
+ALTER TABLE invoice_item DROP CONSTRAINT IF EXISTS invoice_item_unit_price_ck;
+ALTER TABLE invoice_item DROP CONSTRAINT IF EXISTS invoice_item_quantity_ck;
+ALTER TABLE invoice_header
+    DROP COLUMN IF EXISTS total,
+    DROP COLUMN IF EXISTS tax,
+    DROP COLUMN IF EXISTS discount,
+    DROP COLUMN IF EXISTS subtotal;
+ALTER TABLE invoice_item
+    DROP COLUMN IF EXISTS total,
+    DROP COLUMN IF EXISTS tax,
+    DROP COLUMN IF EXISTS discount,
+    DROP COLUMN IF EXISTS subtotal,
+    DROP COLUMN IF EXISTS tax_rate,
+    DROP COLUMN IF EXISTS unit_price,
+    DROP COLUMN IF EXISTS quantity,
+    DROP COLUMN IF EXISTS product_name;
+-- +goose StatementEnd
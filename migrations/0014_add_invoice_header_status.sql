@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Invoices generated before the status are issued.
+ALTER TABLE invoice_header ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'issued';
+
+CREATE INDEX IF NOT EXISTS invoice_header_client_id_idx ON invoice_header (client_id);
+CREATE INDEX IF NOT EXISTS invoice_header_created_at_idx ON invoice_header (created_at);
+CREATE INDEX IF NOT EXISTS invoice_item_invoice_header_id_idx ON invoice_item (invoice_header_id);
+
+INSERT INTO permission (name, description) VALUES
+    ('invoices:read', 'List and read invoices')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id FROM role r CROSS JOIN permission p
+WHERE r.name = 'admin' AND p.name = 'invoices:read'
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name = 'invoices:read';
+DROP INDEX IF EXISTS invoice_item_invoice_header_id_idx;
+DROP INDEX IF EXISTS invoice_header_created_at_idx;
+DROP INDEX IF EXISTS invoice_header_client_id_idx;
+ALTER TABLE invoice_header DROP COLUMN IF EXISTS status;
+-- +goose StatementEnd
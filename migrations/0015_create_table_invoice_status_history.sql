@@ -0,0 +1,84 @@
+-- +goose Up
+-- +goose StatementBegin
+-- New invoices are drafts until issued.
+ALTER TABLE invoice_header ALTER COLUMN status SET DEFAULT 'draft';
+
+ALTER TABLE invoice_header ADD CONSTRAINT invoice_header_status_ck
+    CHECK (status IN ('draft', 'issued', 'partially_paid', 'paid', 'void'));
+
+CREATE TABLE IF NOT EXISTS invoice_status_history (
+    id BIGSERIAL,
+    invoice_header_id BIGINT NOT NULL,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    changed_by_user_id BIGINT,
+    changed_by_service_account_id BIGINT,
+    reason TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT invoice_status_history_id_pk PRIMARY KEY (id),
+
+    CONSTRAINT invoice_status_history_invoice_header_id_fk FOREIGN KEY (invoice_header_id)
+        REFERENCES invoice_header (id) ON UPDATE RESTRICT ON DELETE CASCADE,
+
+    CONSTRAINT invoice_status_history_changed_by_user_id_fk FOREIGN KEY (changed_by_user_id)
+        REFERENCES "user" (id) ON UPDATE RESTRICT ON DELETE SET NULL,
+
+    CONSTRAINT invoice_status_history_changed_by_service_account_id_fk FOREIGN KEY (changed_by_service_account_id)
+        REFERENCES service_account (id) ON UPDATE RESTRICT ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS invoice_status_history_invoice_header_id_idx
+    ON invoice_status_history (invoice_header_id);
+
+-- Only the status of an invoice changes once it's issued, its amounts and
+-- items are kept as they were sent to the client.
+CREATE OR REPLACE FUNCTION invoice_header_immutable() RETURNS trigger AS $$
+BEGIN
+    IF OLD.status = 'draft' THEN
+        RETURN COALESCE(NEW, OLD);
+    END IF;
+    IF TG_OP = 'DELETE' OR (NEW.uuid, NEW.client_id, NEW.subtotal, NEW.discount, NEW.tax, NEW.total, NEW.created_at)
+        IS DISTINCT FROM (OLD.uuid, OLD.client_id, OLD.subtotal, OLD.discount, OLD.tax, OLD.total, OLD.created_at) THEN
+        RAISE EXCEPTION 'invoice % is %, it can''t be modified', OLD.id, OLD.status
+            USING ERRCODE = 'GN001';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER invoice_header_immutable_trg
+    BEFORE UPDATE OR DELETE ON invoice_header
+    FOR EACH ROW EXECUTE FUNCTION invoice_header_immutable();
+
+CREATE OR REPLACE FUNCTION invoice_item_immutable() RETURNS trigger AS $$
+DECLARE
+    header_status VARCHAR(20);
+BEGIN
+    SELECT status INTO header_status FROM invoice_header
+    WHERE id = COALESCE(NEW.invoice_header_id, OLD.invoice_header_id);
+    IF header_status <> 'draft' THEN
+        RAISE EXCEPTION 'invoice % is %, its items can''t be modified',
+            COALESCE(NEW.invoice_header_id, OLD.invoice_header_id), header_status
+            USING ERRCODE = 'GN001';
+    END IF;
+    RETURN COALESCE(NEW, OLD);
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER invoice_item_immutable_trg
+    BEFORE INSERT OR UPDATE OR DELETE ON invoice_item
+    FOR EACH ROW EXECUTE FUNCTION invoice_item_immutable();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS invoice_item_immutable_trg ON invoice_item;
+DROP FUNCTION IF EXISTS invoice_item_immutable();
+DROP TRIGGER IF EXISTS invoice_header_immutable_trg ON invoice_header;
+DROP FUNCTION IF EXISTS invoice_header_immutable();
+DROP INDEX IF EXISTS invoice_status_history_invoice_header_id_idx;
+DROP TABLE IF EXISTS invoice_status_history;
+ALTER TABLE invoice_header DROP CONSTRAINT IF EXISTS invoice_header_status_ck;
+ALTER TABLE invoice_header ALTER COLUMN status SET DEFAULT 'issued';
+-- +goose StatementEnd
@@ -0,0 +1,72 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Gapless counter of each invoice number series, e.g. INV-2024-{seq:06}.
+CREATE TABLE IF NOT EXISTS invoice_sequence (
+    series VARCHAR(100) NOT NULL,
+    last_value BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT invoice_sequence_series_pk PRIMARY KEY (series),
+    CONSTRAINT invoice_sequence_last_value_ck CHECK (last_value > 0)
+);
+
+ALTER TABLE invoice_header ADD COLUMN IF NOT EXISTS number VARCHAR(100);
+
+-- Number the existing invoices with the default format, in order of
+-- creation within each year.
+UPDATE invoice_header h
+SET number = 'INV-' || to_char(n.created_at AT TIME ZONE 'UTC', 'YYYY') || '-' || lpad(n.seq::TEXT, 6, '0')
+FROM (
+    SELECT id, created_at, row_number() OVER (
+        PARTITION BY to_char(created_at AT TIME ZONE 'UTC', 'YYYY') ORDER BY id
+    ) AS seq
+    FROM invoice_header
+) n
+WHERE n.id = h.id;
+
+INSERT INTO invoice_sequence (series, last_value)
+SELECT 'INV-' || to_char(created_at AT TIME ZONE 'UTC', 'YYYY') || '-{seq:06}', count(*)
+FROM invoice_header
+GROUP BY 1;
+
+ALTER TABLE invoice_header ALTER COLUMN number SET NOT NULL;
+ALTER TABLE invoice_header ADD CONSTRAINT invoice_header_number_uq UNIQUE (number);
+ALTER TABLE invoice_header ADD CONSTRAINT invoice_header_number_ck CHECK (number <> '');
+
+-- The number of an issued invoice doesn't change either.
+CREATE OR REPLACE FUNCTION invoice_header_immutable() RETURNS trigger AS $$
+BEGIN
+    IF OLD.status = 'draft' THEN
+        RETURN COALESCE(NEW, OLD);
+    END IF;
+    IF TG_OP = 'DELETE' OR (NEW.uuid, NEW.number, NEW.client_id, NEW.subtotal, NEW.discount, NEW.tax, NEW.total, NEW.created_at)
+        IS DISTINCT FROM (OLD.uuid, OLD.number, OLD.client_id, OLD.subtotal, OLD.discount, OLD.tax, OLD.total, OLD.created_at) THEN
+        RAISE EXCEPTION 'invoice % is %, it can''t be modified', OLD.id, OLD.status
+            USING ERRCODE = 'GN001';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION invoice_header_immutable() RETURNS trigger AS $$
+BEGIN
+    IF OLD.status = 'draft' THEN
+        RETURN COALESCE(NEW, OLD);
+    END IF;
+    IF TG_OP = 'DELETE' OR (NEW.uuid, NEW.client_id, NEW.subtotal, NEW.discount, NEW.tax, NEW.total, NEW.created_at)
+        IS DISTINCT FROM (OLD.uuid, OLD.client_id, OLD.subtotal, OLD.discount, OLD.tax, OLD.total, OLD.created_at) THEN
+        RAISE EXCEPTION 'invoice % is %, it can''t be modified', OLD.id, OLD.status
+            USING ERRCODE = 'GN001';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE invoice_header DROP CONSTRAINT IF EXISTS invoice_header_number_ck;
+ALTER TABLE invoice_header DROP CONSTRAINT IF EXISTS invoice_header_number_uq;
+ALTER TABLE invoice_header DROP COLUMN IF EXISTS number;
+DROP TABLE IF EXISTS invoice_sequence;
+-- +goose StatementEnd
@@ -0,0 +1,55 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS payment (
+    id BIGSERIAL,
+    client_id BIGINT NOT NULL,
+    amount BIGINT NOT NULL,
+    method VARCHAR(20) NOT NULL,
+    reference VARCHAR(100) NOT NULL DEFAULT '',
+    received_at TIMESTAMPTZ NOT NULL,
+    created_by_user_id BIGINT,
+    created_by_service_account_id BIGINT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT payment_id_pk PRIMARY KEY (id),
+    CONSTRAINT payment_amount_ck CHECK (amount > 0),
+    CONSTRAINT payment_method_ck CHECK (method IN ('cash', 'transfer', 'card', 'check', 'other')),
+
+    CONSTRAINT payment_created_by_user_id_fk FOREIGN KEY (created_by_user_id)
+        REFERENCES "user" (id) ON UPDATE RESTRICT ON DELETE SET NULL,
+
+    CONSTRAINT payment_created_by_service_account_id_fk FOREIGN KEY (created_by_service_account_id)
+        REFERENCES service_account (id) ON UPDATE RESTRICT ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS payment_client_id_idx ON payment (client_id);
+
+-- The part of a payment applied to an invoice, what isn't allocated is
+-- credit of the client.
+CREATE TABLE IF NOT EXISTS payment_allocation (
+    id BIGSERIAL,
+    payment_id BIGINT NOT NULL,
+    invoice_header_id BIGINT NOT NULL,
+    amount BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT payment_allocation_id_pk PRIMARY KEY (id),
+    CONSTRAINT payment_allocation_amount_ck CHECK (amount > 0),
+    CONSTRAINT payment_allocation_payment_invoice_uq UNIQUE (payment_id, invoice_header_id),
+
+    CONSTRAINT payment_allocation_payment_id_fk FOREIGN KEY (payment_id)
+        REFERENCES payment (id) ON UPDATE RESTRICT ON DELETE CASCADE,
+
+    CONSTRAINT payment_allocation_invoice_header_id_fk FOREIGN KEY (invoice_header_id)
+        REFERENCES invoice_header (id) ON UPDATE RESTRICT ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS payment_allocation_invoice_header_id_idx
+    ON payment_allocation (invoice_header_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS payment_allocation;
+DROP TABLE IF EXISTS payment;
+-- +goose StatementEnd
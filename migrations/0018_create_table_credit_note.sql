@@ -0,0 +1,72 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS credit_note (
+    id BIGSERIAL,
+    uuid UUID NOT NULL,
+    number VARCHAR(100) NOT NULL,
+    invoice_header_id BIGINT NOT NULL,
+    client_id BIGINT NOT NULL,
+    reason TEXT NOT NULL,
+    subtotal BIGINT NOT NULL,
+    discount BIGINT NOT NULL,
+    tax BIGINT NOT NULL,
+    total BIGINT NOT NULL,
+    created_by_user_id BIGINT,
+    created_by_service_account_id BIGINT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT credit_note_id_pk PRIMARY KEY (id),
+    CONSTRAINT credit_note_uuid_uq UNIQUE (uuid),
+    CONSTRAINT credit_note_number_uq UNIQUE (number),
+    CONSTRAINT credit_note_number_ck CHECK (number <> ''),
+    CONSTRAINT credit_note_amounts_ck CHECK (subtotal >= 0 AND discount >= 0 AND tax >= 0 AND total >= 0),
+    CONSTRAINT credit_note_total_ck CHECK (total = subtotal - discount + tax),
+
+    CONSTRAINT credit_note_invoice_header_id_fk FOREIGN KEY (invoice_header_id)
+        REFERENCES invoice_header (id) ON UPDATE RESTRICT ON DELETE RESTRICT,
+
+    CONSTRAINT credit_note_created_by_user_id_fk FOREIGN KEY (created_by_user_id)
+        REFERENCES "user" (id) ON UPDATE RESTRICT ON DELETE SET NULL,
+
+    CONSTRAINT credit_note_created_by_service_account_id_fk FOREIGN KEY (created_by_service_account_id)
+        REFERENCES service_account (id) ON UPDATE RESTRICT ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS credit_note_invoice_header_id_idx ON credit_note (invoice_header_id);
+CREATE INDEX IF NOT EXISTS credit_note_client_id_idx ON credit_note (client_id);
+
+CREATE TABLE IF NOT EXISTS credit_note_item (
+    id BIGSERIAL,
+    credit_note_id BIGINT NOT NULL,
+    invoice_item_id BIGINT NOT NULL,
+    product_id BIGINT NOT NULL,
+    product_name TEXT NOT NULL,
+    quantity INT NOT NULL,
+    unit_price BIGINT NOT NULL,
+    tax_rate INT NOT NULL,
+    subtotal BIGINT NOT NULL,
+    discount BIGINT NOT NULL,
+    tax BIGINT NOT NULL,
+    total BIGINT NOT NULL,
+
+    CONSTRAINT credit_note_item_id_pk PRIMARY KEY (id),
+    CONSTRAINT credit_note_item_quantity_ck CHECK (quantity > 0),
+    CONSTRAINT credit_note_item_amounts_ck CHECK (subtotal >= 0 AND discount >= 0 AND tax >= 0 AND total >= 0),
+    CONSTRAINT credit_note_item_total_ck CHECK (total = subtotal - discount + tax),
+
+    CONSTRAINT credit_note_item_credit_note_id_fk FOREIGN KEY (credit_note_id)
+        REFERENCES credit_note (id) ON UPDATE RESTRICT ON DELETE CASCADE,
+
+    CONSTRAINT credit_note_item_invoice_item_id_fk FOREIGN KEY (invoice_item_id)
+        REFERENCES invoice_item (id) ON UPDATE RESTRICT ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS credit_note_item_credit_note_id_idx ON credit_note_item (credit_note_id);
+CREATE INDEX IF NOT EXISTS credit_note_item_invoice_item_id_idx ON credit_note_item (invoice_item_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS credit_note_item;
+DROP TABLE IF EXISTS credit_note;
+-- +goose StatementEnd
@@ -0,0 +1,160 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The periods of the rates of a category in a jurisdiction can't overlap.
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE TABLE IF NOT EXISTS tax_category (
+    code VARCHAR(30) NOT NULL,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT tax_category_code_pk PRIMARY KEY (code),
+    CONSTRAINT tax_category_code_ck CHECK (code <> '')
+);
+
+CREATE TABLE IF NOT EXISTS tax_rate (
+    id BIGSERIAL,
+    category VARCHAR(30) NOT NULL,
+    jurisdiction VARCHAR(20) NOT NULL,
+    name TEXT NOT NULL,
+    rate INT NOT NULL,
+    valid_from TIMESTAMPTZ NOT NULL,
+    valid_to TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT tax_rate_id_pk PRIMARY KEY (id),
+    CONSTRAINT tax_rate_rate_ck CHECK (rate BETWEEN 0 AND 10000),
+    CONSTRAINT tax_rate_jurisdiction_ck CHECK (jurisdiction <> ''),
+    CONSTRAINT tax_rate_validity_ck CHECK (valid_to IS NULL OR valid_to > valid_from),
+    CONSTRAINT tax_rate_validity_ex EXCLUDE USING gist (
+        category WITH =, jurisdiction WITH =, tstzrange(valid_from, valid_to) WITH &&
+    ),
+
+    CONSTRAINT tax_rate_category_fk FOREIGN KEY (category)
+        REFERENCES tax_category (code) ON UPDATE RESTRICT ON DELETE RESTRICT
+);
+
+-- An exemption without category exempts of all of them.
+CREATE TABLE IF NOT EXISTS tax_exemption (
+    id BIGSERIAL,
+    client_id BIGINT NOT NULL,
+    category VARCHAR(30),
+    jurisdiction VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL,
+    valid_from TIMESTAMPTZ NOT NULL,
+    valid_to TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT tax_exemption_id_pk PRIMARY KEY (id),
+    CONSTRAINT tax_exemption_reason_ck CHECK (reason <> ''),
+    CONSTRAINT tax_exemption_validity_ck CHECK (valid_to IS NULL OR valid_to > valid_from),
+
+    CONSTRAINT tax_exemption_category_fk FOREIGN KEY (category)
+        REFERENCES tax_category (code) ON UPDATE RESTRICT ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS tax_exemption_client_id_idx ON tax_exemption (client_id);
+
+-- Products without category aren't taxed.
+ALTER TABLE product ADD COLUMN IF NOT EXISTS tax_category VARCHAR(30);
+ALTER TABLE product ADD CONSTRAINT product_tax_category_fk FOREIGN KEY (tax_category)
+    REFERENCES tax_category (code) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+ALTER TABLE invoice_header ADD COLUMN IF NOT EXISTS jurisdiction VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE invoice_header ADD COLUMN IF NOT EXISTS prices_include_tax BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE invoice_header ADD COLUMN IF NOT EXISTS tax_rounding VARCHAR(10) NOT NULL DEFAULT 'line';
+ALTER TABLE invoice_header ADD CONSTRAINT invoice_header_tax_rounding_ck CHECK (tax_rounding IN ('line', 'invoice'));
+
+ALTER TABLE invoice_item ADD COLUMN IF NOT EXISTS tax_category VARCHAR(30) NOT NULL DEFAULT '';
+ALTER TABLE invoice_item ADD COLUMN IF NOT EXISTS tax_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE invoice_item ADD COLUMN IF NOT EXISTS tax_exempt BOOLEAN NOT NULL DEFAULT false;
+
+-- Tax breakdown of an invoice, a row per category and rate.
+CREATE TABLE IF NOT EXISTS invoice_tax (
+    id BIGSERIAL,
+    invoice_header_id BIGINT NOT NULL,
+    category VARCHAR(30) NOT NULL,
+    name TEXT NOT NULL,
+    rate INT NOT NULL,
+    exempt BOOLEAN NOT NULL,
+    base BIGINT NOT NULL,
+    tax BIGINT NOT NULL,
+
+    CONSTRAINT invoice_tax_id_pk PRIMARY KEY (id),
+    CONSTRAINT invoice_tax_uq UNIQUE (invoice_header_id, category, name, rate, exempt),
+    CONSTRAINT invoice_tax_rate_ck CHECK (rate BETWEEN 0 AND 10000),
+    CONSTRAINT invoice_tax_amounts_ck CHECK (base >= 0 AND tax >= 0),
+
+    CONSTRAINT invoice_tax_invoice_header_id_fk FOREIGN KEY (invoice_header_id)
+        REFERENCES invoice_header (id) ON UPDATE RESTRICT ON DELETE CASCADE
+);
+
+-- The breakdown of an issued invoice can't change, as its items.
+CREATE TRIGGER invoice_tax_immutable_trg
+    BEFORE INSERT OR UPDATE OR DELETE ON invoice_tax
+    FOR EACH ROW EXECUTE FUNCTION invoice_item_immutable();
+
+-- Nor the tax settings of an issued invoice.
+CREATE OR REPLACE FUNCTION invoice_header_immutable() RETURNS trigger AS $$
+BEGIN
+    IF OLD.status = 'draft' THEN
+        RETURN COALESCE(NEW, OLD);
+    END IF;
+    IF TG_OP = 'DELETE' OR (NEW.uuid, NEW.number, NEW.client_id, NEW.subtotal, NEW.discount, NEW.tax, NEW.total, NEW.created_at,
+            NEW.jurisdiction, NEW.prices_include_tax, NEW.tax_rounding)
+        IS DISTINCT FROM (OLD.uuid, OLD.number, OLD.client_id, OLD.subtotal, OLD.discount, OLD.tax, OLD.total, OLD.created_at,
+            OLD.jurisdiction, OLD.prices_include_tax, OLD.tax_rounding) THEN
+        RAISE EXCEPTION 'invoice % is %, it can''t be modified', OLD.id, OLD.status
+            USING ERRCODE = 'GN001';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+INSERT INTO tax_category (code, name) VALUES
+    ('standard', 'Standard rate')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO permission (name, description) VALUES
+    ('taxes:write', 'Manage tax categories, rates and exemptions')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id FROM role r CROSS JOIN permission p
+WHERE r.name = 'admin' AND p.name = 'taxes:write'
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name = 'taxes:write';
+
+CREATE OR REPLACE FUNCTION invoice_header_immutable() RETURNS trigger AS $$
+BEGIN
+    IF OLD.status = 'draft' THEN
+        RETURN COALESCE(NEW, OLD);
+    END IF;
+    IF TG_OP = 'DELETE' OR (NEW.uuid, NEW.number, NEW.client_id, NEW.subtotal, NEW.discount, NEW.tax, NEW.total, NEW.created_at)
+        IS DISTINCT FROM (OLD.uuid, OLD.number, OLD.client_id, OLD.subtotal, OLD.discount, OLD.tax, OLD.total, OLD.created_at) THEN
+        RAISE EXCEPTION 'invoice % is %, it can''t be modified', OLD.id, OLD.status
+            USING ERRCODE = 'GN001';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS invoice_tax_immutable_trg ON invoice_tax;
+DROP TABLE IF EXISTS invoice_tax;
+ALTER TABLE invoice_item DROP COLUMN IF EXISTS tax_exempt;
+ALTER TABLE invoice_item DROP COLUMN IF EXISTS tax_name;
+ALTER TABLE invoice_item DROP COLUMN IF EXISTS tax_category;
+ALTER TABLE invoice_header DROP CONSTRAINT IF EXISTS invoice_header_tax_rounding_ck;
+ALTER TABLE invoice_header DROP COLUMN IF EXISTS tax_rounding;
+ALTER TABLE invoice_header DROP COLUMN IF EXISTS prices_include_tax;
+ALTER TABLE invoice_header DROP COLUMN IF EXISTS jurisdiction;
+ALTER TABLE product DROP CONSTRAINT IF EXISTS product_tax_category_fk;
+ALTER TABLE product DROP COLUMN IF EXISTS tax_category;
+DROP TABLE IF EXISTS tax_exemption;
+DROP TABLE IF EXISTS tax_rate;
+DROP TABLE IF EXISTS tax_category;
+-- +goose StatementEnd
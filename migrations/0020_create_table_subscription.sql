@@ -0,0 +1,111 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- An invoice generated with a key can't be generated again, the recurring
+-- invoices are generated once per period of a subscription.
+ALTER TABLE invoice_header ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(100);
+ALTER TABLE invoice_header
+    ADD CONSTRAINT invoice_header_idempotency_key_uq UNIQUE (idempotency_key);
+
+CREATE TABLE IF NOT EXISTS subscription_plan (
+    id BIGSERIAL,
+    name VARCHAR(100) NOT NULL,
+    interval_months INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT subscription_plan_id_pk PRIMARY KEY (id),
+    CONSTRAINT subscription_plan_interval_months_ck CHECK (interval_months BETWEEN 1 AND 12)
+);
+
+CREATE TABLE IF NOT EXISTS subscription_plan_item (
+    plan_id BIGINT NOT NULL,
+    product_id BIGINT NOT NULL,
+    quantity INT NOT NULL,
+
+    CONSTRAINT subscription_plan_item_pk PRIMARY KEY (plan_id, product_id),
+    CONSTRAINT subscription_plan_item_quantity_ck CHECK (quantity > 0),
+
+    CONSTRAINT subscription_plan_item_plan_id_fk FOREIGN KEY (plan_id)
+        REFERENCES subscription_plan (id) ON UPDATE RESTRICT ON DELETE CASCADE,
+
+    CONSTRAINT subscription_plan_item_product_id_fk FOREIGN KEY (product_id)
+        REFERENCES product (id) ON UPDATE RESTRICT ON DELETE RESTRICT
+);
+
+-- Period n of a subscription starts n * interval_months after the anchor,
+-- next_period is the first one not invoiced and next_bill_at its start.
+CREATE TABLE IF NOT EXISTS subscription (
+    id BIGSERIAL,
+    client_id BIGINT NOT NULL,
+    plan_id BIGINT NOT NULL,
+    quantity INT NOT NULL DEFAULT 1,
+    interval_months INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    jurisdiction VARCHAR(20) NOT NULL DEFAULT '',
+    anchor TIMESTAMPTZ NOT NULL,
+    next_period INT NOT NULL DEFAULT 0,
+    next_bill_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    canceled_at TIMESTAMPTZ,
+
+    CONSTRAINT subscription_id_pk PRIMARY KEY (id),
+    CONSTRAINT subscription_quantity_ck CHECK (quantity > 0),
+    CONSTRAINT subscription_status_ck CHECK (status IN ('active', 'canceled')),
+
+    CONSTRAINT subscription_plan_id_fk FOREIGN KEY (plan_id)
+        REFERENCES subscription_plan (id) ON UPDATE RESTRICT ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS subscription_client_id_idx ON subscription (client_id);
+CREATE INDEX IF NOT EXISTS subscription_due_idx ON subscription (next_bill_at)
+    WHERE status = 'active';
+
+-- Prorated charges and credits invoiced with the next period, pending while
+-- invoice_header_id is null.
+CREATE TABLE IF NOT EXISTS subscription_adjustment (
+    id BIGSERIAL,
+    subscription_id BIGINT NOT NULL,
+    product_id BIGINT,
+    description VARCHAR(200) NOT NULL,
+    amount BIGINT NOT NULL,
+    invoice_header_id BIGINT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT subscription_adjustment_id_pk PRIMARY KEY (id),
+    CONSTRAINT subscription_adjustment_amount_ck CHECK (amount < 0 OR product_id IS NOT NULL),
+
+    CONSTRAINT subscription_adjustment_subscription_id_fk FOREIGN KEY (subscription_id)
+        REFERENCES subscription (id) ON UPDATE RESTRICT ON DELETE CASCADE,
+
+    CONSTRAINT subscription_adjustment_product_id_fk FOREIGN KEY (product_id)
+        REFERENCES product (id) ON UPDATE RESTRICT ON DELETE RESTRICT,
+
+    CONSTRAINT subscription_adjustment_invoice_header_id_fk FOREIGN KEY (invoice_header_id)
+        REFERENCES invoice_header (id) ON UPDATE RESTRICT ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS subscription_adjustment_pending_idx
+    ON subscription_adjustment (subscription_id) WHERE invoice_header_id IS NULL;
+
+INSERT INTO permission (name, description) VALUES
+    ('subscriptions:write', 'Manage subscription plans and subscriptions')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id FROM role r CROSS JOIN permission p
+WHERE r.name = 'admin' AND p.name = 'subscriptions:write'
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name = 'subscriptions:write';
+
+DROP TABLE IF EXISTS subscription_adjustment;
+DROP TABLE IF EXISTS subscription;
+DROP TABLE IF EXISTS subscription_plan_item;
+DROP TABLE IF EXISTS subscription_plan;
+
+ALTER TABLE invoice_header DROP CONSTRAINT IF EXISTS invoice_header_idempotency_key_uq;
+ALTER TABLE invoice_header DROP COLUMN IF EXISTS idempotency_key;
+-- +goose StatementEnd
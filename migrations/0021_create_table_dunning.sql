@@ -0,0 +1,95 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- An invoice is due the days of the payment terms of its client after its
+-- date, invoices older than this column aren't dunned.
+ALTER TABLE invoice_header ADD COLUMN IF NOT EXISTS due_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS invoice_header_due_at_idx ON invoice_header (due_at)
+    WHERE status IN ('issued', 'partially_paid');
+
+-- Payment terms of a client, the default ones if it has none.
+CREATE TABLE IF NOT EXISTS payment_terms (
+    client_id BIGINT NOT NULL,
+    net_days INT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT payment_terms_pk PRIMARY KEY (client_id),
+    CONSTRAINT payment_terms_net_days_ck CHECK (net_days BETWEEN 0 AND 365)
+);
+
+-- Steps of the dunning policy, a reminder is sent the days after the due
+-- date of each one, with a late fee invoiced with the product given.
+CREATE TABLE IF NOT EXISTS dunning_step (
+    id BIGSERIAL,
+    days_after_due INT NOT NULL,
+    late_fee BIGINT NOT NULL DEFAULT 0,
+    late_fee_rate INT NOT NULL DEFAULT 0,
+    fee_product_id BIGINT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT dunning_step_id_pk PRIMARY KEY (id),
+    CONSTRAINT dunning_step_days_after_due_uq UNIQUE (days_after_due),
+    CONSTRAINT dunning_step_days_after_due_ck CHECK (days_after_due >= 0),
+    CONSTRAINT dunning_step_late_fee_ck CHECK (late_fee >= 0),
+    CONSTRAINT dunning_step_late_fee_rate_ck CHECK (late_fee_rate BETWEEN 0 AND 10000),
+    CONSTRAINT dunning_step_fee_product_ck CHECK ((late_fee = 0 AND late_fee_rate = 0) OR fee_product_id IS NOT NULL),
+
+    CONSTRAINT dunning_step_fee_product_id_fk FOREIGN KEY (fee_product_id)
+        REFERENCES product (id) ON UPDATE RESTRICT ON DELETE RESTRICT
+);
+
+-- Reminders of the overdue invoices, queued while pending and kept as the
+-- audit of what was sent. The step is copied, it can change or be deleted.
+CREATE TABLE IF NOT EXISTS dunning_reminder (
+    id BIGSERIAL,
+    invoice_header_id BIGINT NOT NULL,
+    days_after_due INT NOT NULL,
+    amount_due BIGINT NOT NULL,
+    late_fee BIGINT NOT NULL DEFAULT 0,
+    fee_product_id BIGINT,
+    fee_invoice_id BIGINT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at TIMESTAMPTZ,
+
+    CONSTRAINT dunning_reminder_id_pk PRIMARY KEY (id),
+    CONSTRAINT dunning_reminder_step_uq UNIQUE (invoice_header_id, days_after_due),
+    CONSTRAINT dunning_reminder_status_ck CHECK (status IN ('pending', 'sent', 'failed', 'canceled')),
+    CONSTRAINT dunning_reminder_late_fee_ck CHECK (late_fee = 0 OR fee_product_id IS NOT NULL),
+
+    CONSTRAINT dunning_reminder_invoice_header_id_fk FOREIGN KEY (invoice_header_id)
+        REFERENCES invoice_header (id) ON UPDATE RESTRICT ON DELETE RESTRICT,
+
+    CONSTRAINT dunning_reminder_fee_product_id_fk FOREIGN KEY (fee_product_id)
+        REFERENCES product (id) ON UPDATE RESTRICT ON DELETE RESTRICT,
+
+    CONSTRAINT dunning_reminder_fee_invoice_id_fk FOREIGN KEY (fee_invoice_id)
+        REFERENCES invoice_header (id) ON UPDATE RESTRICT ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS dunning_reminder_pending_idx ON dunning_reminder (id)
+    WHERE status = 'pending';
+
+INSERT INTO permission (name, description) VALUES
+    ('dunning:write', 'Manage the dunning policy and the payment terms of the clients')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id FROM role r CROSS JOIN permission p
+WHERE r.name = 'admin' AND p.name = 'dunning:write'
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permission WHERE name = 'dunning:write';
+
+DROP TABLE IF EXISTS dunning_reminder;
+DROP TABLE IF EXISTS dunning_step;
+DROP TABLE IF EXISTS payment_terms;
+
+DROP INDEX IF EXISTS invoice_header_due_at_idx;
+ALTER TABLE invoice_header DROP COLUMN IF EXISTS due_at;
+-- +goose StatementEnd
@@ -0,0 +1,81 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- The name and address the customer is billed to, its full name if the
+-- billing name is empty.
+ALTER TABLE customer ADD COLUMN IF NOT EXISTS billing_name VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE customer ADD COLUMN IF NOT EXISTS billing_address VARCHAR(300) NOT NULL DEFAULT '';
+
+-- The customer as billed when the invoice was generated, it doesn't change
+-- if the customer does.
+ALTER TABLE invoice_header ADD COLUMN IF NOT EXISTS bill_to_name VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE invoice_header ADD COLUMN IF NOT EXISTS bill_to_email VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE invoice_header ADD COLUMN IF NOT EXISTS bill_to_address VARCHAR(300) NOT NULL DEFAULT '';
+
+-- The invoices were generated for users. The clients that aren't customers
+-- are created with the same id, from the user if there is one, else as a
+-- deleted customer named by its id.
+INSERT INTO customer (id, uuid, first_name, last_name, email, password, created_at, deleted_at)
+SELECT c.client_id, gen_random_uuid(), COALESCE(u.first_name, 'Client ' || c.client_id), COALESCE(u.last_name, ''),
+    COALESCE(u.email, ''), '', COALESCE(u.created_at, now()), CASE WHEN u.id IS NULL THEN now() END
+FROM (SELECT DISTINCT client_id FROM invoice_header) c
+LEFT JOIN "user" u ON u.id = c.client_id
+WHERE NOT EXISTS (SELECT 1 FROM customer WHERE id = c.client_id);
+
+SELECT setval(pg_get_serial_sequence('customer', 'id'), COALESCE(MAX(id), 1), MAX(id) IS NOT NULL) FROM customer;
+
+UPDATE invoice_header h SET
+    bill_to_name = COALESCE(NULLIF(c.billing_name, ''), TRIM(c.first_name || ' ' || c.last_name)),
+    bill_to_email = c.email,
+    bill_to_address = c.billing_address
+FROM customer c
+WHERE c.id = h.client_id;
+
+-- A customer is soft deleted, one with invoices can't be removed.
+ALTER TABLE invoice_header ADD CONSTRAINT invoice_header_client_id_fk FOREIGN KEY (client_id)
+    REFERENCES customer (id) ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+-- Nor the customer an issued invoice is billed to.
+CREATE OR REPLACE FUNCTION invoice_header_immutable() RETURNS trigger AS $$
+BEGIN
+    IF OLD.status = 'draft' THEN
+        RETURN COALESCE(NEW, OLD);
+    END IF;
+    IF TG_OP = 'DELETE' OR (NEW.uuid, NEW.number, NEW.client_id, NEW.subtotal, NEW.discount, NEW.tax, NEW.total, NEW.created_at,
+            NEW.jurisdiction, NEW.prices_include_tax, NEW.tax_rounding, NEW.bill_to_name, NEW.bill_to_email, NEW.bill_to_address)
+        IS DISTINCT FROM (OLD.uuid, OLD.number, OLD.client_id, OLD.subtotal, OLD.discount, OLD.tax, OLD.total, OLD.created_at,
+            OLD.jurisdiction, OLD.prices_include_tax, OLD.tax_rounding, OLD.bill_to_name, OLD.bill_to_email, OLD.bill_to_address) THEN
+        RAISE EXCEPTION 'invoice % is %, it can''t be modified', OLD.id, OLD.status
+            USING ERRCODE = 'GN001';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION invoice_header_immutable() RETURNS trigger AS $$
+BEGIN
+    IF OLD.status = 'draft' THEN
+        RETURN COALESCE(NEW, OLD);
+    END IF;
+    IF TG_OP = 'DELETE' OR (NEW.uuid, NEW.number, NEW.client_id, NEW.subtotal, NEW.discount, NEW.tax, NEW.total, NEW.created_at,
+            NEW.jurisdiction, NEW.prices_include_tax, NEW.tax_rounding)
+        IS DISTINCT FROM (OLD.uuid, OLD.number, OLD.client_id, OLD.subtotal, OLD.discount, OLD.tax, OLD.total, OLD.created_at,
+            OLD.jurisdiction, OLD.prices_include_tax, OLD.tax_rounding) THEN
+        RAISE EXCEPTION 'invoice % is %, it can''t be modified', OLD.id, OLD.status
+            USING ERRCODE = 'GN001';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- The customers created from the clients are kept.
+ALTER TABLE invoice_header DROP CONSTRAINT IF EXISTS invoice_header_client_id_fk;
+ALTER TABLE invoice_header DROP COLUMN IF EXISTS bill_to_address;
+ALTER TABLE invoice_header DROP COLUMN IF EXISTS bill_to_email;
+ALTER TABLE invoice_header DROP COLUMN IF EXISTS bill_to_name;
+ALTER TABLE customer DROP COLUMN IF EXISTS billing_address;
+ALTER TABLE customer DROP COLUMN IF EXISTS billing_name;
+-- +goose StatementEnd
@@ -0,0 +1,168 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Chart of accounts of the general ledger.
+CREATE TABLE IF NOT EXISTS ledger_account (
+    code VARCHAR(10) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+
+    CONSTRAINT ledger_account_code_pk PRIMARY KEY (code),
+    CONSTRAINT ledger_account_type_ck CHECK (type IN ('asset', 'liability', 'equity', 'revenue', 'expense'))
+);
+
+INSERT INTO ledger_account (code, name, type) VALUES
+    ('1000', 'Cash', 'asset'),
+    ('1100', 'Accounts receivable', 'asset'),
+    ('2100', 'Tax payable', 'liability'),
+    ('2200', 'Customer credit', 'liability'),
+    ('4000', 'Revenue', 'revenue'),
+    ('4900', 'Exchange and rounding differences', 'revenue')
+ON CONFLICT (code) DO NOTHING;
+
+-- A journal entry posted by a billing event, once per event.
+CREATE TABLE IF NOT EXISTS journal_entry (
+    id BIGSERIAL,
+    source VARCHAR(20) NOT NULL,
+    source_id BIGINT NOT NULL,
+    memo VARCHAR(200) NOT NULL DEFAULT '',
+    posted_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT journal_entry_id_pk PRIMARY KEY (id),
+    CONSTRAINT journal_entry_source_uq UNIQUE (source, source_id)
+);
+
+CREATE INDEX IF NOT EXISTS journal_entry_posted_at_idx ON journal_entry (posted_at);
+
+-- A line debits or credits an account, never both.
+CREATE TABLE IF NOT EXISTS journal_line (
+    id BIGSERIAL,
+    entry_id BIGINT NOT NULL,
+    account_code VARCHAR(10) NOT NULL,
+    debit BIGINT NOT NULL DEFAULT 0,
+    credit BIGINT NOT NULL DEFAULT 0,
+
+    CONSTRAINT journal_line_id_pk PRIMARY KEY (id),
+    CONSTRAINT journal_line_side_ck CHECK (debit >= 0 AND credit >= 0 AND (debit = 0) <> (credit = 0)),
+
+    CONSTRAINT journal_line_entry_id_fk FOREIGN KEY (entry_id)
+        REFERENCES journal_entry (id) ON UPDATE RESTRICT ON DELETE RESTRICT,
+
+    CONSTRAINT journal_line_account_code_fk FOREIGN KEY (account_code)
+        REFERENCES ledger_account (code) ON UPDATE RESTRICT ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS journal_line_entry_id_idx ON journal_line (entry_id);
+CREATE INDEX IF NOT EXISTS journal_line_account_code_idx ON journal_line (account_code);
+
+-- The debits of an entry equal its credits. Checked when the transaction
+-- commits, once all the lines are inserted.
+CREATE OR REPLACE FUNCTION journal_entry_balanced() RETURNS trigger AS $$
+DECLARE
+    entry BIGINT;
+    lines INT;
+    debits BIGINT;
+    credits BIGINT;
+BEGIN
+    IF TG_TABLE_NAME = 'journal_entry' THEN
+        entry := NEW.id;
+    ELSE
+        entry := NEW.entry_id;
+    END IF;
+    SELECT COUNT(*), COALESCE(SUM(debit), 0), COALESCE(SUM(credit), 0) INTO lines, debits, credits
+    FROM journal_line WHERE entry_id = entry;
+    IF lines < 2 OR debits <> credits THEN
+        RAISE EXCEPTION 'journal entry % is unbalanced, % lines debit % and credit %', entry, lines, debits, credits
+            USING ERRCODE = 'GN003';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER journal_entry_balanced_trg
+    AFTER INSERT ON journal_entry
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION journal_entry_balanced();
+
+CREATE CONSTRAINT TRIGGER journal_line_balanced_trg
+    AFTER INSERT ON journal_line
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION journal_entry_balanced();
+
+-- The journal is append only, an error is corrected by another entry.
+CREATE OR REPLACE FUNCTION journal_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION '% is append only, it can''t be modified', TG_TABLE_NAME
+        USING ERRCODE = 'GN002';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER journal_entry_immutable_trg
+    BEFORE UPDATE OR DELETE ON journal_entry
+    FOR EACH ROW EXECUTE FUNCTION journal_immutable();
+
+CREATE TRIGGER journal_line_immutable_trg
+    BEFORE UPDATE OR DELETE ON journal_line
+    FOR EACH ROW EXECUTE FUNCTION journal_immutable();
+
+-- The billing events before the ledger are posted as they would have been:
+-- the invoices when issued and voided, the payments and the credit notes.
+-- An invoice credited and then voided reverses on its void only what its
+-- credit notes didn't, voiding a credited invoice is refused now.
+INSERT INTO journal_entry (source, source_id, memo, posted_at)
+SELECT 'invoice', h.id, 'Invoice ' || h.number, COALESCE(
+    (SELECT MIN(s.changed_at) FROM invoice_status_history s WHERE s.invoice_header_id = h.id AND s.to_status = 'issued'),
+    h.created_at)
+FROM invoice_header h
+WHERE h.status <> 'draft' AND h.total <> 0
+UNION ALL
+SELECT 'invoice_void', h.id, 'Void invoice ' || h.number, COALESCE(
+    (SELECT MIN(s.changed_at) FROM invoice_status_history s WHERE s.invoice_header_id = h.id AND s.to_status = 'void'),
+    h.created_at)
+FROM invoice_header h
+WHERE h.status = 'void'
+    AND h.total <> (SELECT COALESCE(SUM(c.total), 0) FROM credit_note c WHERE c.invoice_header_id = h.id)
+UNION ALL
+SELECT 'payment', p.id, 'Payment ' || p.id, p.created_at FROM payment p WHERE p.amount <> 0
+UNION ALL
+SELECT 'credit_note', c.id, 'Credit note ' || c.number, c.created_at FROM credit_note c WHERE c.total <> 0;
+
+WITH credited AS (
+    SELECT h.id, COALESCE(SUM(c.total), 0) AS total, COALESCE(SUM(c.tax), 0) AS tax
+    FROM invoice_header h
+    LEFT JOIN credit_note c ON c.invoice_header_id = h.id
+    GROUP BY h.id
+)
+INSERT INTO journal_line (entry_id, account_code, debit, credit)
+SELECT e.id, l.account_code, GREATEST(l.amount, 0), GREATEST(-l.amount, 0)
+FROM journal_entry e
+JOIN (
+    SELECT 'invoice' AS source, h.id AS source_id, '1100' AS account_code, h.total AS amount FROM invoice_header h
+    UNION ALL SELECT 'invoice', h.id, '4000', -(h.total - h.tax) FROM invoice_header h
+    UNION ALL SELECT 'invoice', h.id, '2100', -h.tax FROM invoice_header h
+    UNION ALL SELECT 'invoice_void', h.id, '1100', -(h.total - cr.total) FROM invoice_header h, credited cr WHERE cr.id = h.id
+    UNION ALL SELECT 'invoice_void', h.id, '4000', (h.total - h.tax) - (cr.total - cr.tax) FROM invoice_header h, credited cr WHERE cr.id = h.id
+    UNION ALL SELECT 'invoice_void', h.id, '2100', h.tax - cr.tax FROM invoice_header h, credited cr WHERE cr.id = h.id
+    UNION ALL SELECT 'payment', p.id, '1000', p.amount FROM payment p
+    UNION ALL SELECT 'payment', p.id, '1100', -COALESCE((SELECT SUM(a.amount) FROM payment_allocation a WHERE a.payment_id = p.id), 0) FROM payment p
+    UNION ALL SELECT 'payment', p.id, '2200', -(p.amount - COALESCE((SELECT SUM(a.amount) FROM payment_allocation a WHERE a.payment_id = p.id), 0)) FROM payment p
+    UNION ALL SELECT 'credit_note', c.id, '4000', c.total - c.tax FROM credit_note c
+    UNION ALL SELECT 'credit_note', c.id, '2100', c.tax FROM credit_note c
+    UNION ALL SELECT 'credit_note', c.id, '1100', -c.total FROM credit_note c
+) l ON l.source = e.source AND l.source_id = e.source_id
+WHERE l.amount <> 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS journal_line_immutable_trg ON journal_line;
+DROP TRIGGER IF EXISTS journal_entry_immutable_trg ON journal_entry;
+DROP FUNCTION IF EXISTS journal_immutable();
+DROP TRIGGER IF EXISTS journal_line_balanced_trg ON journal_line;
+DROP TRIGGER IF EXISTS journal_entry_balanced_trg ON journal_entry;
+DROP FUNCTION IF EXISTS journal_entry_balanced();
+DROP TABLE IF EXISTS journal_line;
+DROP TABLE IF EXISTS journal_entry;
+DROP TABLE IF EXISTS ledger_account;
+-- +goose StatementEnd
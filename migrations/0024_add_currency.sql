@@ -0,0 +1,114 @@
+-- +goose Up
+-- +goose ENVSUB ON
+-- +goose StatementBegin
+-- The amounts are in minor units of their currency (ISO 4217). Those
+-- before this migration are of the currency the application runs with,
+-- CURRENCY when migrating.
+ALTER TABLE product ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT '${CURRENCY:-EUR}';
+ALTER TABLE product ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE product ADD CONSTRAINT product_currency_ck CHECK (currency ~ '^[A-Z]{3}$');
+
+-- An invoice is in the currency of the prices of its products. Its base
+-- currency is the one of the books when it was generated, and its
+-- exchange rate to it is captured when it's issued.
+ALTER TABLE invoice_header ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT '${CURRENCY:-EUR}';
+ALTER TABLE invoice_header ADD COLUMN IF NOT EXISTS base_currency CHAR(3) NOT NULL DEFAULT '${CURRENCY:-EUR}';
+ALTER TABLE invoice_header ADD COLUMN IF NOT EXISTS exchange_rate BIGINT;
+ALTER TABLE invoice_header ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE invoice_header ALTER COLUMN base_currency DROP DEFAULT;
+
+-- The invoices issued before were in the base currency.
+UPDATE invoice_header SET exchange_rate = 100000000 WHERE status <> 'draft';
+
+ALTER TABLE invoice_header ADD CONSTRAINT invoice_header_currency_ck
+    CHECK (currency ~ '^[A-Z]{3}$' AND base_currency ~ '^[A-Z]{3}$');
+ALTER TABLE invoice_header ADD CONSTRAINT invoice_header_exchange_rate_ck
+    CHECK ((exchange_rate IS NOT NULL AND exchange_rate > 0) OR (exchange_rate IS NULL AND status IN ('draft', 'void')));
+
+-- The payments and credit notes are in the currency of their invoices.
+ALTER TABLE payment ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT '${CURRENCY:-EUR}';
+ALTER TABLE payment ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE credit_note ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT '${CURRENCY:-EUR}';
+ALTER TABLE credit_note ALTER COLUMN currency DROP DEFAULT;
+-- +goose StatementEnd
+-- +goose ENVSUB OFF
+
+-- +goose StatementBegin
+-- Rates of exchange managed locally, a unit of from_currency is worth rate
+-- / 10^8 units of to_currency since valid_from, until the next rate of the
+-- pair. A pair is also read inverted.
+CREATE TABLE IF NOT EXISTS exchange_rate (
+    id BIGSERIAL,
+    from_currency CHAR(3) NOT NULL,
+    to_currency CHAR(3) NOT NULL,
+    rate BIGINT NOT NULL,
+    valid_from TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CONSTRAINT exchange_rate_id_pk PRIMARY KEY (id),
+    CONSTRAINT exchange_rate_pair_uq UNIQUE (from_currency, to_currency, valid_from),
+    CONSTRAINT exchange_rate_pair_ck CHECK (from_currency ~ '^[A-Z]{3}$' AND to_currency ~ '^[A-Z]{3}$'
+        AND from_currency <> to_currency),
+    CONSTRAINT exchange_rate_rate_ck CHECK (rate > 0)
+);
+
+INSERT INTO permission (name, description) VALUES
+    ('exchange_rates:write', 'Manage the exchange rates of the currencies')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permission (role_id, permission_id)
+SELECT r.id, p.id FROM role r CROSS JOIN permission p
+WHERE r.name = 'admin' AND p.name = 'exchange_rates:write'
+ON CONFLICT DO NOTHING;
+
+-- Nor the currencies and exchange rate of an issued invoice.
+CREATE OR REPLACE FUNCTION invoice_header_immutable() RETURNS trigger AS $$
+BEGIN
+    IF OLD.status = 'draft' THEN
+        RETURN COALESCE(NEW, OLD);
+    END IF;
+    IF TG_OP = 'DELETE' OR (NEW.uuid, NEW.number, NEW.client_id, NEW.subtotal, NEW.discount, NEW.tax, NEW.total, NEW.created_at,
+            NEW.jurisdiction, NEW.prices_include_tax, NEW.tax_rounding, NEW.bill_to_name, NEW.bill_to_email, NEW.bill_to_address,
+            NEW.currency, NEW.base_currency, NEW.exchange_rate)
+        IS DISTINCT FROM (OLD.uuid, OLD.number, OLD.client_id, OLD.subtotal, OLD.discount, OLD.tax, OLD.total, OLD.created_at,
+            OLD.jurisdiction, OLD.prices_include_tax, OLD.tax_rounding, OLD.bill_to_name, OLD.bill_to_email, OLD.bill_to_address,
+            OLD.currency, OLD.base_currency, OLD.exchange_rate) THEN
+        RAISE EXCEPTION 'invoice % is %, it can''t be modified', OLD.id, OLD.status
+            USING ERRCODE = 'GN001';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION invoice_header_immutable() RETURNS trigger AS $$
+BEGIN
+    IF OLD.status = 'draft' THEN
+        RETURN COALESCE(NEW, OLD);
+    END IF;
+    IF TG_OP = 'DELETE' OR (NEW.uuid, NEW.number, NEW.client_id, NEW.subtotal, NEW.discount, NEW.tax, NEW.total, NEW.created_at,
+            NEW.jurisdiction, NEW.prices_include_tax, NEW.tax_rounding, NEW.bill_to_name, NEW.bill_to_email, NEW.bill_to_address)
+        IS DISTINCT FROM (OLD.uuid, OLD.number, OLD.client_id, OLD.subtotal, OLD.discount, OLD.tax, OLD.total, OLD.created_at,
+            OLD.jurisdiction, OLD.prices_include_tax, OLD.tax_rounding, OLD.bill_to_name, OLD.bill_to_email, OLD.bill_to_address) THEN
+        RAISE EXCEPTION 'invoice % is %, it can''t be modified', OLD.id, OLD.status
+            USING ERRCODE = 'GN001';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DELETE FROM permission WHERE name = 'exchange_rates:write';
+DROP TABLE IF EXISTS exchange_rate;
+
+ALTER TABLE credit_note DROP COLUMN IF EXISTS currency;
+ALTER TABLE payment DROP COLUMN IF EXISTS currency;
+ALTER TABLE invoice_header DROP CONSTRAINT IF EXISTS invoice_header_exchange_rate_ck;
+ALTER TABLE invoice_header DROP CONSTRAINT IF EXISTS invoice_header_currency_ck;
+ALTER TABLE invoice_header DROP COLUMN IF EXISTS exchange_rate;
+ALTER TABLE invoice_header DROP COLUMN IF EXISTS base_currency;
+ALTER TABLE invoice_header DROP COLUMN IF EXISTS currency;
+ALTER TABLE product DROP CONSTRAINT IF EXISTS product_currency_ck;
+ALTER TABLE product DROP COLUMN IF EXISTS currency;
+-- +goose StatementEnd
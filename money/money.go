@@ -0,0 +1,135 @@
+// Package money represents amounts of money as integers of the minor
+// units of their currency (e.g. cents), so they are exact, and converts
+// them between currencies at exchange rates. The amounts are rounded half
+// away from zero to the digits of the minor units of their currency.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrCurrencyMismatch = errors.New("the amounts are in different currencies")
+	ErrInvalidAmount    = errors.New("invalid amount, expected a decimal number like 1234.56")
+	ErrOverflow         = errors.New("amount too large")
+)
+
+// Money an amount in minor units of a currency, 123456 EUR is 1234.56 EUR.
+type Money struct {
+	Amount   int64
+	Currency Currency
+}
+
+// New returns amount minor units of currency c.
+func New(amount int64, c Currency) Money {
+	return Money{Amount: amount, Currency: c}
+}
+
+// Parse returns the decimal amount s, e.g. "1234.56", of currency c,
+// rounded to its minor units.
+func Parse(s string, c Currency) (Money, error) {
+	if !c.Valid() {
+		return Money{}, ErrUnknownCurrency
+	}
+	amount, err := parseDecimal(s, c.Exponent())
+	if err != nil {
+		return Money{}, err
+	}
+	return New(amount, c), nil
+}
+
+// Add returns the sum of m and o, of the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return New(sum, m.Currency), nil
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Decimal returns the amount in major units with the digits of its minor
+// units, 123456 EUR is "1234.56" and 1234 JPY is "1234".
+func (m Money) Decimal() string {
+	return formatDecimal(m.Amount, m.Currency.Exponent())
+}
+
+// String returns the amount and its currency, e.g. "1234.56 EUR".
+func (m Money) String() string {
+	return m.Decimal() + " " + string(m.Currency)
+}
+
+// formatDecimal formats v with exp decimals.
+func formatDecimal(v int64, exp int) string {
+	sign := ""
+	u := uint64(v)
+	if v < 0 {
+		sign, u = "-", -u
+	}
+	s := strconv.FormatUint(u, 10)
+	if exp == 0 {
+		return sign + s
+	}
+	if len(s) <= exp {
+		s = strings.Repeat("0", exp-len(s)+1) + s
+	}
+	return sign + s[:len(s)-exp] + "." + s[len(s)-exp:]
+}
+
+// parseDecimal parses the decimal number s, e.g. "-1234.567", as an
+// integer of exp decimals, rounded half away from zero.
+func parseDecimal(s string, exp int) (int64, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	} else {
+		s = strings.TrimPrefix(s, "+")
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if (whole == "" && frac == "") || strings.Trim(whole+frac, "0123456789") != "" {
+		return 0, ErrInvalidAmount
+	}
+	n, ok := new(big.Int).SetString(whole+frac, 10)
+	if !ok {
+		return 0, ErrInvalidAmount
+	}
+	if neg {
+		n.Neg(n)
+	}
+	num, den := n, big.NewInt(1)
+	if d := exp - len(frac); d >= 0 {
+		num.Mul(num, pow10(d))
+	} else {
+		den = pow10(-d)
+	}
+	return roundDiv(num, den)
+}
+
+// roundDiv returns num / den rounded half away from zero, den > 0.
+func roundDiv(num, den *big.Int) (int64, error) {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Abs(r).Cmp(new(big.Int).Sub(den, new(big.Int).Abs(r))) >= 0 {
+		q.Add(q, big.NewInt(int64(num.Sign())))
+	}
+	if !q.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return q.Int64(), nil
+}
+
+// pow10 returns 10^n.
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,166 @@
+package money
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseCurrency(t *testing.T) {
+	tt := []struct {
+		code    string
+		want    Currency
+		wantExp int
+		wantErr error
+	}{
+		{code: "EUR", want: "EUR", wantExp: 2},
+		{code: "jpy", want: "JPY", wantExp: 0},
+		{code: " KWD ", want: "KWD", wantExp: 3},
+		{code: "XXX", wantErr: ErrUnknownCurrency},
+		{code: "", wantErr: ErrUnknownCurrency},
+	}
+	for _, tc := range tt {
+		t.Run(tc.code, func(t *testing.T) {
+			got, err := ParseCurrency(tc.code)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want error %v, got %v", tc.wantErr, err)
+			}
+			if got != tc.want || (err == nil && got.Exponent() != tc.wantExp) {
+				t.Errorf("want %s with exponent %d, got %s with %d", tc.want, tc.wantExp, got, got.Exponent())
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tt := []struct {
+		name     string
+		s        string
+		currency Currency
+		want     int64
+		wantErr  error
+	}{
+		{name: "cents", s: "1234.56", currency: "EUR", want: 123456},
+		{name: "whole", s: "12", currency: "EUR", want: 1200},
+		{name: "half-up", s: "0.125", currency: "EUR", want: 13},
+		{name: "half-away-from-zero", s: "-0.125", currency: "EUR", want: -13},
+		{name: "down", s: "0.1249", currency: "EUR", want: 12},
+		{name: "no-minor-units", s: "1234.5", currency: "JPY", want: 1235},
+		{name: "three-decimals", s: "1.2345", currency: "KWD", want: 1235},
+		{name: "leading-dot", s: ".5", currency: "USD", want: 50},
+		{name: "empty", s: "", currency: "EUR", wantErr: ErrInvalidAmount},
+		{name: "letters", s: "12a", currency: "EUR", wantErr: ErrInvalidAmount},
+		{name: "two-signs", s: "-+1", currency: "EUR", wantErr: ErrInvalidAmount},
+		{name: "unknown-currency", s: "1", currency: "XXX", wantErr: ErrUnknownCurrency},
+		{name: "overflow", s: "99999999999999999999", currency: "EUR", wantErr: ErrOverflow},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse(tc.s, tc.currency)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want error %v, got %v", tc.wantErr, err)
+			}
+			if err == nil && (got.Amount != tc.want || got.Currency != tc.currency) {
+				t.Errorf("want %d %s, got %v", tc.want, tc.currency, got)
+			}
+		})
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tt := []struct {
+		m    Money
+		want string
+	}{
+		{New(123456, "EUR"), "1234.56 EUR"},
+		{New(-5, "EUR"), "-0.05 EUR"},
+		{New(0, "USD"), "0.00 USD"},
+		{New(1234, "JPY"), "1234 JPY"},
+		{New(1234, "BHD"), "1.234 BHD"},
+	}
+	for _, tc := range tt {
+		if got := tc.m.String(); got != tc.want {
+			t.Errorf("want %s, got %s", tc.want, got)
+		}
+	}
+}
+
+func TestMoneyAdd(t *testing.T) {
+	sum, err := New(150, "EUR").Add(New(-50, "EUR"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != New(100, "EUR") {
+		t.Errorf("want 1.00 EUR, got %v", sum)
+	}
+	if _, err := New(1, "EUR").Add(New(1, "USD")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("want error %v, got %v", ErrCurrencyMismatch, err)
+	}
+	if _, err := New(1<<62, "EUR").Add(New(1<<62, "EUR")); !errors.Is(err, ErrOverflow) {
+		t.Errorf("want error %v, got %v", ErrOverflow, err)
+	}
+}
+
+func TestRateConvert(t *testing.T) {
+	tt := []struct {
+		name    string
+		rate    string
+		from    Currency
+		to      Currency
+		amount  int64
+		want    int64
+		wantErr error
+	}{
+		{name: "cents", rate: "1.0825", from: "EUR", to: "USD", amount: 10000, want: 10825},
+		{name: "half-up", rate: "1.0825", from: "EUR", to: "USD", amount: 2, want: 2},
+		{name: "half-away-from-zero", rate: "0.5", from: "EUR", to: "USD", amount: -1, want: -1},
+		{name: "to-no-minor-units", rate: "161.235", from: "EUR", to: "JPY", amount: 1999, want: 3223},
+		{name: "from-no-minor-units", rate: "0.0062", from: "JPY", to: "EUR", amount: 1000, want: 620},
+		{name: "to-three-decimals", rate: "0.30745", from: "USD", to: "KWD", amount: 100, want: 307},
+		{name: "identity", rate: "1", from: "EUR", to: "EUR", amount: 12345, want: 12345},
+		{name: "zero-rate", rate: "0", from: "EUR", to: "USD", wantErr: ErrInvalidRate},
+		{name: "negative-rate", rate: "-1.5", from: "EUR", to: "USD", wantErr: ErrInvalidRate},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := ParseRate(tc.from, tc.to, tc.rate)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want error %v, got %v", tc.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			got, err := r.Convert(New(tc.amount, tc.from))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != New(tc.want, tc.to) {
+				t.Errorf("want %v, got %v", New(tc.want, tc.to), got)
+			}
+		})
+	}
+
+	r := Rate{From: "EUR", To: "USD", Value: 108250000}
+	if _, err := r.Convert(New(1, "USD")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("want error %v, got %v", ErrCurrencyMismatch, err)
+	}
+	if _, err := (Rate{From: "EUR", To: "USD"}).Convert(New(1, "EUR")); !errors.Is(err, ErrInvalidRate) {
+		t.Errorf("want error %v, got %v", ErrInvalidRate, err)
+	}
+}
+
+func TestRateInvert(t *testing.T) {
+	r, err := ParseRate("EUR", "USD", "1.25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := r.Invert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.From != "USD" || inv.To != "EUR" || inv.Decimal() != "0.80000000" {
+		t.Errorf("want USD to EUR at 0.80000000, got %+v", inv)
+	}
+	if got := Identity("EUR").Decimal(); got != "1.00000000" {
+		t.Errorf("want identity 1.00000000, got %s", got)
+	}
+}
@@ -0,0 +1,93 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrInvalidRate an exchange rate must be greater than zero.
+var ErrInvalidRate = errors.New("exchange rate must be greater than zero")
+
+// RateScale is the precision of the exchange rates, 8 decimals: a Value
+// of 108250000 is 1.0825.
+const RateScale = 100_000_000
+
+// RateDecimals are the decimals of the exchange rates.
+const RateDecimals = 8
+
+// Rate of exchange of a currency for another one, a unit of From is worth
+// Value / RateScale units of To.
+type Rate struct {
+	From  Currency
+	To    Currency
+	Value int64
+}
+
+// Identity returns the rate of c to itself, 1.
+func Identity(c Currency) Rate {
+	return Rate{From: c, To: c, Value: RateScale}
+}
+
+// ParseRate returns the rate from, to of the decimal s, e.g. "1.0825",
+// rounded to 8 decimals.
+func ParseRate(from, to Currency, s string) (Rate, error) {
+	if !from.Valid() || !to.Valid() {
+		return Rate{}, ErrUnknownCurrency
+	}
+	v, err := parseDecimal(s, RateDecimals)
+	if err != nil {
+		return Rate{}, err
+	}
+	if v <= 0 {
+		return Rate{}, ErrInvalidRate
+	}
+	return Rate{From: from, To: to, Value: v}, nil
+}
+
+// IsZero reports whether the rate is unset.
+func (r Rate) IsZero() bool {
+	return r.Value == 0
+}
+
+// Invert returns the rate from To to From, rounded to 8 decimals.
+func (r Rate) Invert() (Rate, error) {
+	if r.Value <= 0 {
+		return Rate{}, ErrInvalidRate
+	}
+	v, err := roundDiv(big.NewInt(RateScale*RateScale), big.NewInt(r.Value))
+	if err != nil {
+		return Rate{}, err
+	}
+	if v == 0 {
+		return Rate{}, ErrInvalidRate
+	}
+	return Rate{From: r.To, To: r.From, Value: v}, nil
+}
+
+// Convert returns m, in From, in To at the rate, rounded half away from
+// zero to the minor units of To.
+func (r Rate) Convert(m Money) (Money, error) {
+	if m.Currency != r.From {
+		return Money{}, fmt.Errorf("%w: %s at a rate of %s", ErrCurrencyMismatch, m.Currency, r.From)
+	}
+	if r.Value <= 0 {
+		return Money{}, ErrInvalidRate
+	}
+	if r.From == r.To && r.Value == RateScale {
+		return m, nil
+	}
+	num := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(r.Value))
+	num.Mul(num, pow10(r.To.Exponent()))
+	den := new(big.Int).Mul(big.NewInt(RateScale), pow10(r.From.Exponent()))
+	v, err := roundDiv(num, den)
+	if err != nil {
+		return Money{}, err
+	}
+	return New(v, r.To), nil
+}
+
+// Decimal returns the rate with 8 decimals, e.g. "1.08250000".
+func (r Rate) Decimal() string {
+	return formatDecimal(r.Value, RateDecimals)
+}
@@ -0,0 +1,65 @@
+package password
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultBcryptCost is the cost used for new bcrypt hashes.
+const DefaultBcryptCost = 12
+
+// Bcrypt hashes passwords with bcrypt, its encoding ($2a$12$...) already
+// carries the cost.
+type Bcrypt struct {
+	cost int
+}
+
+// NewBcrypt creates a bcrypt Algorithm with the given cost.
+func NewBcrypt(cost int) *Bcrypt {
+	return &Bcrypt{cost: cost}
+}
+
+// Name of the algorithm.
+func (b *Bcrypt) Name() string { return "bcrypt" }
+
+// Hash plain with a random salt.
+func (b *Bcrypt) Hash(plain string) (string, error) {
+	h, err := bcrypt.GenerateFromPassword([]byte(plain), b.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(h), nil
+}
+
+// Verify plain against encoded.
+func (b *Bcrypt) Verify(encoded, plain string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(plain))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatch
+	}
+	if err != nil {
+		return ErrMalformedHash
+	}
+	return nil
+}
+
+// Identifies reports whether encoded is a bcrypt hash.
+func (b *Bcrypt) Identifies(encoded string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(encoded, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsRehash reports whether encoded was produced with a lower cost.
+func (b *Bcrypt) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return true
+	}
+	return cost < b.cost
+}
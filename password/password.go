@@ -0,0 +1,97 @@
+// Package password hashes and verifies credentials. Hashes are encoded in a
+// self-describing format (algorithm and parameters travel with the hash), so
+// the cost can be raised later and old hashes upgraded on the next login.
+package password
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrMismatch the plain password doesn't match the hash.
+	ErrMismatch = errors.New("password does not match")
+
+	// ErrUnknownAlgorithm no registered algorithm recognizes the hash.
+	ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+
+	// ErrMalformedHash the hash can't be decoded.
+	ErrMalformedHash = errors.New("malformed password hash")
+)
+
+// Algorithm is a password hashing scheme.
+type Algorithm interface {
+	// Name of the algorithm, e.g.: "argon2id".
+	Name() string
+
+	// Hash returns the encoded hash of plain, including its parameters.
+	Hash(plain string) (string, error)
+
+	// Verify returns ErrMismatch if plain doesn't match the encoded hash.
+	Verify(encoded, plain string) error
+
+	// Identifies reports whether encoded was produced by this algorithm.
+	Identifies(encoded string) bool
+
+	// NeedsRehash reports whether encoded was produced with parameters
+	// different from the current ones of the algorithm.
+	NeedsRehash(encoded string) bool
+}
+
+// Hasher hashes new passwords with a preferred algorithm and verifies
+// passwords hashed by any of the accepted ones.
+type Hasher struct {
+	preferred Algorithm
+	accepted  []Algorithm
+}
+
+// NewHasher creates a Hasher that hashes with preferred and also verifies
+// hashes produced by the accepted algorithms.
+func NewHasher(preferred Algorithm, accepted ...Algorithm) *Hasher {
+	return &Hasher{
+		preferred: preferred,
+		accepted:  append([]Algorithm{preferred}, accepted...),
+	}
+}
+
+// New returns a Hasher whose preferred algorithm is name ("argon2id" or
+// "bcrypt") with default parameters. Hashes of the other algorithm are still
+// verified and upgraded on the next successful login.
+func New(name string) (*Hasher, error) {
+	argon := NewArgon2id(DefaultArgon2idParams)
+	bc := NewBcrypt(DefaultBcryptCost)
+	switch name {
+	case "", argon.Name():
+		return NewHasher(argon, bc), nil
+	case bc.Name():
+		return NewHasher(bc, argon), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, name)
+}
+
+// Default returns a Hasher that prefers argon2id and accepts bcrypt.
+func Default() *Hasher {
+	h, _ := New("")
+	return h
+}
+
+// Hash plain with the preferred algorithm.
+func (h *Hasher) Hash(plain string) (string, error) {
+	return h.preferred.Hash(plain)
+}
+
+// Verify checks plain against the encoded hash. When it matches, rehash
+// reports whether the hash should be replaced by a new one from Hash because
+// it was produced with an old algorithm or weaker parameters.
+func (h *Hasher) Verify(encoded, plain string) (rehash bool, err error) {
+	for _, a := range h.accepted {
+		if !a.Identifies(encoded) {
+			continue
+		}
+		if err := a.Verify(encoded, plain); err != nil {
+			return false, err
+		}
+		return a != h.preferred || a.NeedsRehash(encoded), nil
+	}
+	return false, ErrUnknownAlgorithm
+}
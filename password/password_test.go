@@ -0,0 +1,115 @@
+package password_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/adrianolmedo/genesis/password"
+)
+
+// weak parameters to keep the tests fast.
+var weakArgon2id = password.Argon2idParams{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestHasherVerify(t *testing.T) {
+	t.Parallel()
+	h := password.NewHasher(password.NewArgon2id(weakArgon2id), password.NewBcrypt(4))
+	encoded, err := h.Hash("1234567b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt := []struct {
+		name       string
+		plain      string
+		wantErr    error
+		wantRehash bool
+	}{
+		{name: "match", plain: "1234567b", wantErr: nil, wantRehash: false},
+		{name: "mismatch", plain: "1234567c", wantErr: password.ErrMismatch, wantRehash: false},
+		{name: "empty", plain: "", wantErr: password.ErrMismatch, wantRehash: false},
+	}
+	for _, tc := range tt {
+		rehash, err := h.Verify(encoded, tc.plain)
+		if !errors.Is(err, tc.wantErr) {
+			t.Fatalf("%s: want error %v, got %v", tc.name, tc.wantErr, err)
+		}
+		if rehash != tc.wantRehash {
+			t.Errorf("%s: want rehash %t, got %t", tc.name, tc.wantRehash, rehash)
+		}
+	}
+}
+
+func TestHasherRehash(t *testing.T) {
+	t.Parallel()
+	old := password.NewHasher(password.NewArgon2id(weakArgon2id))
+	legacy := password.NewHasher(password.NewBcrypt(4))
+	stronger := weakArgon2id
+	stronger.Iterations = 2
+	current := password.NewHasher(password.NewArgon2id(stronger), password.NewBcrypt(4))
+
+	tt := []struct {
+		name   string
+		hasher *password.Hasher
+	}{
+		{name: "weaker-argon2id-params", hasher: old},
+		{name: "bcrypt-to-argon2id", hasher: legacy},
+	}
+	for _, tc := range tt {
+		encoded, err := tc.hasher.Hash("1234567b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rehash, err := current.Verify(encoded, "1234567b")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !rehash {
+			t.Errorf("%s: expected rehash", tc.name)
+		}
+	}
+}
+
+func TestHasherUnknownHash(t *testing.T) {
+	t.Parallel()
+	h := password.NewHasher(password.NewArgon2id(weakArgon2id))
+	tt := []struct {
+		name    string
+		encoded string
+		wantErr error
+	}{
+		{name: "plain-text", encoded: "1234567b", wantErr: password.ErrUnknownAlgorithm},
+		{name: "truncated", encoded: "$argon2id$v=19$m=1024", wantErr: password.ErrMalformedHash},
+		{name: "bad-salt", encoded: "$argon2id$v=19$m=1024,t=1,p=1$!!$AAAA", wantErr: password.ErrMalformedHash},
+	}
+	for _, tc := range tt {
+		_, err := h.Verify(tc.encoded, "1234567b")
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: want error %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	tt := []struct {
+		name        string
+		algorithm   string
+		errExpected bool
+	}{
+		{name: "default", algorithm: "", errExpected: false},
+		{name: "argon2id", algorithm: "argon2id", errExpected: false},
+		{name: "bcrypt", algorithm: "bcrypt", errExpected: false},
+		{name: "unknown", algorithm: "md5", errExpected: true},
+	}
+	for _, tc := range tt {
+		_, err := password.New(tc.algorithm)
+		if (err != nil) != tc.errExpected {
+			t.Fatalf("%s: unexpected error value %v", tc.name, err)
+		}
+	}
+}
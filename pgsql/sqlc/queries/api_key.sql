@@ -0,0 +1,34 @@
+-- name: ServiceAccountCreate :one
+INSERT INTO "service_account" (name, description, created_at) VALUES ($1, $2, $3) RETURNING id;
+
+-- name: ServiceAccountByID :one
+SELECT * FROM "service_account" WHERE id = $1;
+
+-- name: ServiceAccountAll :many
+SELECT * FROM "service_account" ORDER BY id;
+
+-- name: APIKeyCreate :one
+INSERT INTO "api_key"
+(prefix, key_hash, name, user_id, service_account_id, scopes, expires_at, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id;
+
+-- name: APIKeyByPrefix :one
+SELECT * FROM "api_key" WHERE prefix = $1;
+
+-- name: APIKeyByID :one
+SELECT * FROM "api_key" WHERE id = $1;
+
+-- name: APIKeyByUser :many
+SELECT * FROM "api_key" WHERE user_id = $1 ORDER BY id;
+
+-- name: APIKeyByServiceAccount :many
+SELECT * FROM "api_key" WHERE service_account_id = $1 ORDER BY id;
+
+-- name: APIKeyRevoke :execrows
+UPDATE "api_key" SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL;
+
+-- name: APIKeyTouch :exec
+UPDATE "api_key" SET last_used_at = $1 WHERE id = $2;
+
+-- name: APIKeyDeleteAll :exec
+TRUNCATE TABLE "api_key", "service_account" RESTART IDENTITY;
@@ -0,0 +1,56 @@
+-- name: CreditNoteCreate :one
+INSERT INTO "credit_note" (
+    uuid,
+    number,
+    invoice_header_id,
+    client_id,
+    reason,
+    currency,
+    subtotal,
+    discount,
+    tax,
+    total,
+    created_by_user_id,
+    created_by_service_account_id,
+    created_at
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id;
+
+-- name: CreditNoteItemCreate :one
+INSERT INTO "credit_note_item" (
+    credit_note_id,
+    invoice_item_id,
+    product_id,
+    product_name,
+    quantity,
+    unit_price,
+    tax_rate,
+    subtotal,
+    discount,
+    tax,
+    total
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id;
+
+-- name: CreditNoteByID :one
+SELECT * FROM "credit_note" WHERE id = $1;
+
+-- name: CreditNoteByInvoice :many
+SELECT * FROM "credit_note" WHERE invoice_header_id = $1 ORDER BY id;
+
+-- name: CreditNoteItemByCreditNote :many
+SELECT * FROM "credit_note_item" WHERE credit_note_id = $1 ORDER BY id;
+
+-- name: CreditNoteCreditedToInvoice :one
+SELECT COALESCE(SUM(total), 0)::BIGINT FROM "credit_note" WHERE invoice_header_id = $1;
+
+-- name: CreditNoteTotalsByInvoice :many
+SELECT total FROM "credit_note" WHERE invoice_header_id = $1 ORDER BY id;
+
+-- name: CreditNoteQuantityByItem :many
+SELECT i.invoice_item_id, SUM(i.quantity)::INT AS quantity
+FROM "credit_note_item" i
+JOIN "credit_note" c ON c.id = i.credit_note_id
+WHERE c.invoice_header_id = $1
+GROUP BY i.invoice_item_id;
+
+-- name: CreditNoteDeleteAll :exec
+TRUNCATE TABLE "credit_note", "credit_note_item" RESTART IDENTITY;
@@ -5,8 +5,13 @@ INSERT INTO "customer" (
     last_name,
     email,
     password,
+    billing_name,
+    billing_address,
     created_at
-) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id;
+
+-- name: CustomerByID :one
+SELECT * FROM "customer" WHERE id = $1;
 
 -- name: CustomerListAsc :many
 SELECT * FROM "customer" WHERE deleted_at IS NULL ORDER BY @sort::text ASC LIMIT $1 OFFSET $2;
@@ -15,4 +20,7 @@ SELECT * FROM "customer" WHERE deleted_at IS NULL ORDER BY @sort::text ASC LIMIT
 SELECT COUNT (*) FROM "customer" WHERE deleted_at IS NULL;
 
 -- name: CustomerDelete :one
-UPDATE "customer" SET deleted_at = $1 WHERE id = $2 RETURNING id;
\ No newline at end of file
+UPDATE "customer" SET deleted_at = $1 WHERE id = $2 RETURNING id;
+
+-- name: CustomerDeleteAll :exec
+TRUNCATE TABLE "customer" RESTART IDENTITY CASCADE;
@@ -0,0 +1,68 @@
+-- name: PaymentTermsUpsert :one
+INSERT INTO "payment_terms" (client_id, net_days, updated_at) VALUES ($1, $2, $3)
+ON CONFLICT (client_id) DO UPDATE SET net_days = EXCLUDED.net_days, updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: PaymentTermsByClient :one
+SELECT * FROM "payment_terms" WHERE client_id = $1;
+
+-- name: DunningStepCreate :one
+INSERT INTO "dunning_step" (days_after_due, late_fee, late_fee_rate, fee_product_id)
+VALUES ($1, $2, $3, $4) RETURNING id, created_at;
+
+-- name: DunningStepAll :many
+SELECT * FROM "dunning_step" ORDER BY days_after_due;
+
+-- name: DunningStepDelete :execrows
+DELETE FROM "dunning_step" WHERE id = $1;
+
+-- name: DunningOverdue :many
+-- The last step reached by each invoice overdue at now, if neither it nor
+-- a later one was reminded. The steps skipped aren't reminded.
+SELECT DISTINCT ON (h.id) h.id, h.client_id, h.idempotency_key, h.total, h.currency, h.base_currency, h.exchange_rate,
+    (SELECT COALESCE(SUM(c.total), 0) FROM "credit_note" c WHERE c.invoice_header_id = h.id)::BIGINT AS credited,
+    (SELECT COALESCE(SUM(a.amount), 0) FROM "payment_allocation" a WHERE a.invoice_header_id = h.id)::BIGINT AS paid,
+    s.days_after_due, s.late_fee, s.late_fee_rate, s.fee_product_id
+FROM "invoice_header" h
+JOIN "dunning_step" s ON h.due_at + make_interval(days => s.days_after_due) <= @now
+WHERE h.status IN ('issued', 'partially_paid')
+    AND NOT EXISTS (
+        SELECT 1 FROM "dunning_reminder" r
+        WHERE r.invoice_header_id = h.id AND r.days_after_due >= s.days_after_due
+    )
+ORDER BY h.id, s.days_after_due DESC
+LIMIT @batch;
+
+-- name: DunningReminderCreate :one
+-- No row if the step of the invoice was queued already.
+INSERT INTO "dunning_reminder" (invoice_header_id, days_after_due, amount_due, late_fee, fee_product_id, created_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (invoice_header_id, days_after_due) DO NOTHING
+RETURNING id;
+
+-- name: DunningReminderPending :many
+SELECT id FROM "dunning_reminder" WHERE status = 'pending' ORDER BY id LIMIT $1;
+
+-- name: DunningReminderForUpdate :one
+-- Skipped while another transaction, maybe of another replica, has it.
+SELECT r.*, h.number, h.client_id, h.status AS invoice_status, h.jurisdiction, h.due_at, h.currency,
+    COALESCE(p.name, '')::text AS fee_product_name,
+    COALESCE(p.tax_category, '')::text AS fee_tax_category,
+    COALESCE(f.number, '')::text AS fee_invoice_number
+FROM "dunning_reminder" r
+JOIN "invoice_header" h ON h.id = r.invoice_header_id
+LEFT JOIN "product" p ON p.id = r.fee_product_id
+LEFT JOIN "invoice_header" f ON f.id = r.fee_invoice_id
+WHERE r.id = $1 AND r.status = 'pending'
+FOR UPDATE OF r SKIP LOCKED;
+
+-- name: DunningReminderUpdate :exec
+UPDATE "dunning_reminder"
+SET status = $2, attempts = $3, last_error = $4, fee_invoice_id = $5, sent_at = $6
+WHERE id = $1;
+
+-- name: DunningReminderByInvoice :many
+SELECT * FROM "dunning_reminder" WHERE invoice_header_id = $1 ORDER BY days_after_due;
+
+-- name: DunningDeleteAll :exec
+TRUNCATE TABLE "dunning_reminder", "dunning_step", "payment_terms" RESTART IDENTITY;
@@ -0,0 +1,19 @@
+-- name: ExchangeRateCreate :one
+INSERT INTO "exchange_rate" (from_currency, to_currency, rate, valid_from)
+VALUES ($1, $2, $3, $4) RETURNING id, created_at;
+
+-- name: ExchangeRateAll :many
+SELECT * FROM "exchange_rate" ORDER BY from_currency, to_currency, valid_from DESC;
+
+-- name: ExchangeRateAt :one
+-- The last rate of the pair, or of its inverse, valid at @at. The pair as
+-- given first if both start at once.
+SELECT * FROM "exchange_rate"
+WHERE ((from_currency = @from_currency AND to_currency = @to_currency)
+        OR (from_currency = @to_currency AND to_currency = @from_currency))
+    AND valid_from <= @at
+ORDER BY valid_from DESC, (from_currency = @from_currency) DESC
+LIMIT 1;
+
+-- name: ExchangeRateDeleteAll :exec
+TRUNCATE TABLE "exchange_rate" RESTART IDENTITY;
@@ -1,11 +1,83 @@
 -- name: InvoiceHeaderCreate :one
-INSERT INTO "invoice_header" (uuid, client_id) VALUES ($1, $2) RETURNING id, created_at;
+INSERT INTO "invoice_header"
+(uuid, number, client_id, status, subtotal, discount, tax, total, jurisdiction, prices_include_tax, tax_rounding, idempotency_key, due_at,
+    bill_to_name, bill_to_email, bill_to_address, currency, base_currency, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19) RETURNING id, created_at;
+
+-- name: InvoiceHeaderByID :one
+SELECT * FROM "invoice_header" WHERE id = $1;
+
+-- name: InvoiceHeaderIDByIdempotencyKey :one
+SELECT id FROM "invoice_header" WHERE idempotency_key = $1;
+
+-- name: InvoiceHeaderList :many
+SELECT * FROM "invoice_header"
+WHERE (sqlc.narg('client_id')::bigint IS NULL OR client_id = sqlc.narg('client_id'))
+    AND (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+    AND (sqlc.narg('created_from')::timestamptz IS NULL OR created_at >= sqlc.narg('created_from'))
+    AND (sqlc.narg('created_to')::timestamptz IS NULL OR created_at < sqlc.narg('created_to'))
+ORDER BY
+    CASE WHEN @sort::text = 'id' AND @direction::text = 'ASC' THEN id END ASC,
+    CASE WHEN @sort::text = 'id' AND @direction::text = 'DESC' THEN id END DESC,
+    CASE WHEN @sort::text = 'client_id' AND @direction::text = 'ASC' THEN client_id END ASC,
+    CASE WHEN @sort::text = 'client_id' AND @direction::text = 'DESC' THEN client_id END DESC,
+    CASE WHEN @sort::text = 'created_at' AND @direction::text = 'ASC' THEN created_at END ASC,
+    CASE WHEN @sort::text = 'created_at' AND @direction::text = 'DESC' THEN created_at END DESC,
+    CASE WHEN @sort::text = 'total' AND @direction::text = 'ASC' THEN total END ASC,
+    CASE WHEN @sort::text = 'total' AND @direction::text = 'DESC' THEN total END DESC,
+    id ASC
+LIMIT @page_limit OFFSET @page_offset;
+
+-- name: InvoiceHeaderListCount :one
+SELECT COUNT(*) FROM "invoice_header"
+WHERE (sqlc.narg('client_id')::bigint IS NULL OR client_id = sqlc.narg('client_id'))
+    AND (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+    AND (sqlc.narg('created_from')::timestamptz IS NULL OR created_at >= sqlc.narg('created_from'))
+    AND (sqlc.narg('created_to')::timestamptz IS NULL OR created_at < sqlc.narg('created_to'));
 
 -- name: InvoiceHeaderDeleteAll :exec
-TRUNCATE TABLE "invoice_header" RESTART IDENTITY CASCADE;
+TRUNCATE TABLE "invoice_header", "invoice_sequence" RESTART IDENTITY CASCADE;
 
 -- name: InvoiceItemCreate :one
-INSERT INTO "invoice_item" (invoice_header_id, product_id) VALUES ($1, $2) RETURNING id, created_at;
+INSERT INTO "invoice_item"
+(invoice_header_id, product_id, product_name, quantity, unit_price, tax_category, tax_name, tax_rate, tax_exempt, subtotal, discount, tax, total)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id, created_at;
+
+-- name: InvoiceItemByHeader :many
+SELECT * FROM "invoice_item" WHERE invoice_header_id = $1 ORDER BY id;
 
 -- name: InvoiceItemDeleteAll :exec
-TRUNCATE TABLE "invoice_item" RESTART IDENTITY;
\ No newline at end of file
+TRUNCATE TABLE "invoice_item" RESTART IDENTITY;
+
+-- name: InvoiceHeaderByIDForUpdate :one
+SELECT * FROM "invoice_header" WHERE id = $1 FOR UPDATE;
+
+-- name: InvoiceHeaderSetStatus :one
+UPDATE "invoice_header" SET status = $1, updated_at = $2 WHERE id = $3 RETURNING *;
+
+-- name: InvoiceHeaderSetExchangeRate :exec
+UPDATE "invoice_header" SET exchange_rate = $1 WHERE id = $2 AND status = 'draft';
+
+-- name: InvoiceStatusHistoryCreate :exec
+INSERT INTO "invoice_status_history"
+(invoice_header_id, from_status, to_status, changed_by_user_id, changed_by_service_account_id, reason, changed_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: InvoiceStatusHistoryByInvoice :many
+SELECT * FROM "invoice_status_history" WHERE invoice_header_id = $1 ORDER BY id;
+
+-- name: InvoiceSequenceNext :one
+-- The row of the series stays locked until the transaction ends, the
+-- invoices of a series are numbered one after the other and a rollback
+-- gives the number back.
+INSERT INTO "invoice_sequence" (series, last_value) VALUES ($1, 1)
+ON CONFLICT (series) DO UPDATE
+SET last_value = "invoice_sequence".last_value + 1, updated_at = now()
+RETURNING last_value;
+
+-- name: InvoiceTaxCreate :exec
+INSERT INTO "invoice_tax" (invoice_header_id, category, name, rate, exempt, base, tax)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: InvoiceTaxByHeader :many
+SELECT * FROM "invoice_tax" WHERE invoice_header_id = $1 ORDER BY id;
@@ -0,0 +1,36 @@
+-- name: LedgerAccountAll :many
+SELECT * FROM "ledger_account" ORDER BY code;
+
+-- name: JournalEntryCreate :one
+INSERT INTO "journal_entry" (source, source_id, memo, posted_at, created_at)
+VALUES ($1, $2, $3, $4, $5) RETURNING id;
+
+-- name: JournalLineCreate :one
+INSERT INTO "journal_line" (entry_id, account_code, debit, credit)
+VALUES ($1, $2, $3, $4) RETURNING id;
+
+-- name: JournalEntryBySource :many
+SELECT * FROM "journal_entry" WHERE source = $1 AND source_id = $2 ORDER BY id;
+
+-- name: JournalLineBySource :many
+SELECT l.* FROM "journal_line" l
+JOIN "journal_entry" e ON e.id = l.entry_id
+WHERE e.source = $1 AND e.source_id = $2
+ORDER BY l.entry_id, l.id;
+
+-- name: LedgerTrialBalance :many
+-- The debits and credits of each account posted up to @posted_to, all of
+-- them if it's null. The accounts without lines are included.
+SELECT a.code, a.name, a.type,
+    COALESCE(SUM(l.debit), 0)::BIGINT AS debit,
+    COALESCE(SUM(l.credit), 0)::BIGINT AS credit
+FROM "ledger_account" a
+LEFT JOIN ("journal_line" l
+    JOIN "journal_entry" e ON e.id = l.entry_id
+        AND (sqlc.narg('posted_to')::timestamptz IS NULL OR e.posted_at <= sqlc.narg('posted_to')))
+    ON l.account_code = a.code
+GROUP BY a.code
+ORDER BY a.code;
+
+-- name: JournalDeleteAll :exec
+TRUNCATE TABLE "journal_line", "journal_entry" RESTART IDENTITY;
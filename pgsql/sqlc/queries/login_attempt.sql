@@ -0,0 +1,17 @@
+-- name: LoginAttemptByEmail :one
+SELECT * FROM "login_attempt" WHERE email = $1;
+
+-- name: LoginAttemptInit :exec
+INSERT INTO "login_attempt" (email) VALUES ($1) ON CONFLICT (email) DO NOTHING;
+
+-- name: LoginAttemptByEmailForUpdate :one
+SELECT * FROM "login_attempt" WHERE email = $1 FOR UPDATE;
+
+-- name: LoginAttemptUpdate :exec
+UPDATE "login_attempt" SET failures = $1, last_failure_at = $2, blocked_until = $3 WHERE email = $4;
+
+-- name: LoginAttemptDelete :exec
+DELETE FROM "login_attempt" WHERE email = $1;
+
+-- name: LoginAttemptDeleteAll :exec
+TRUNCATE TABLE "login_attempt";
@@ -0,0 +1,48 @@
+-- name: MFAEnrol :exec
+INSERT INTO "user_mfa" (user_id, secret, created_at) VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, last_counter = 0, created_at = EXCLUDED.created_at
+WHERE "user_mfa".enabled_at IS NULL;
+
+-- name: MFAByUser :one
+SELECT * FROM "user_mfa" WHERE user_id = $1;
+
+-- name: MFAByUserForUpdate :one
+SELECT * FROM "user_mfa" WHERE user_id = $1 FOR UPDATE;
+
+-- name: MFAEnable :exec
+UPDATE "user_mfa" SET enabled_at = $1, last_counter = $2 WHERE user_id = $3;
+
+-- name: MFAUseCounter :exec
+UPDATE "user_mfa" SET last_counter = $1 WHERE user_id = $2;
+
+-- name: MFADelete :exec
+DELETE FROM "user_mfa" WHERE user_id = $1;
+
+-- name: MFADeleteAll :exec
+TRUNCATE TABLE "user_mfa", "mfa_recovery_code", "mfa_challenge" RESTART IDENTITY;
+
+-- name: RecoveryCodeCreate :exec
+INSERT INTO "mfa_recovery_code" (user_id, code_hash, created_at) VALUES ($1, $2, $3);
+
+-- name: RecoveryCodeUse :one
+UPDATE "mfa_recovery_code" SET used_at = $1
+WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL RETURNING id;
+
+-- name: RecoveryCodeDeleteByUser :exec
+DELETE FROM "mfa_recovery_code" WHERE user_id = $1;
+
+-- name: MFAChallengeCreate :exec
+INSERT INTO "mfa_challenge" (token_hash, user_id, expires_at, created_at) VALUES ($1, $2, $3, $4);
+
+-- name: MFAChallengeByHashForUpdate :one
+SELECT * FROM "mfa_challenge" WHERE token_hash = $1 FOR UPDATE;
+
+-- name: MFAChallengeFail :exec
+UPDATE "mfa_challenge" SET failures = failures + 1 WHERE token_hash = $1;
+
+-- name: MFAChallengeDelete :exec
+DELETE FROM "mfa_challenge" WHERE token_hash = $1;
+
+-- name: MFAChallengeDeleteExpired :exec
+DELETE FROM "mfa_challenge" WHERE expires_at < $1;
@@ -0,0 +1,42 @@
+-- name: PaymentCreate :one
+INSERT INTO "payment" (
+    client_id,
+    amount,
+    currency,
+    method,
+    reference,
+    received_at,
+    created_by_user_id,
+    created_by_service_account_id,
+    created_at
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id;
+
+-- name: PaymentAllocationCreate :exec
+INSERT INTO "payment_allocation" (payment_id, invoice_header_id, amount, created_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: PaymentAllocatedToInvoice :one
+SELECT COALESCE(SUM(amount), 0)::BIGINT FROM "payment_allocation" WHERE invoice_header_id = $1;
+
+-- name: PaymentAllocationAmountsByInvoice :many
+SELECT amount FROM "payment_allocation" WHERE invoice_header_id = $1 ORDER BY id;
+
+-- name: PaymentClientBalance :one
+-- The amounts of a currency, those of the others don't add up to them.
+WITH invoices AS (
+    SELECT h.total,
+        (SELECT COALESCE(SUM(c.total), 0) FROM "credit_note" c WHERE c.invoice_header_id = h.id) AS credited,
+        (SELECT COALESCE(SUM(a.amount), 0) FROM "payment_allocation" a WHERE a.invoice_header_id = h.id) AS allocated
+    FROM "invoice_header" h
+    WHERE h.client_id = $1 AND h.currency = $2 AND h.status IN ('issued', 'partially_paid', 'paid')
+)
+SELECT
+    COALESCE(SUM(total), 0)::BIGINT AS invoiced,
+    COALESCE(SUM(credited), 0)::BIGINT AS credited,
+    COALESCE(SUM(allocated), 0)::BIGINT AS allocated,
+    COALESCE(SUM(GREATEST(credited + allocated - total, 0)), 0)::BIGINT AS overcredited,
+    (SELECT COALESCE(SUM(p.amount), 0) FROM "payment" p WHERE p.client_id = $1 AND p.currency = $2)::BIGINT AS received
+FROM invoices;
+
+-- name: PaymentDeleteAll :exec
+TRUNCATE TABLE "payment", "payment_allocation" RESTART IDENTITY;
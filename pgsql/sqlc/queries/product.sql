@@ -1,7 +1,7 @@
 -- name: ProductCreate :one
 INSERT INTO "product"
-(uuid, name, observations, price, created_at)
-VALUES ($1, $2, $3, $4, $5) RETURNING id;
+(uuid, name, observations, price, currency, tax_category, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;
 
 -- name: ProductByID :one
 SELECT * FROM "product" WHERE id = $1 AND deleted_at IS NULL;
@@ -12,8 +12,10 @@ SET
     name = $1,
     observations = $2,
     price = $3,
-    updated_at = $4
-WHERE id = $5
+    currency = $4,
+    tax_category = $5,
+    updated_at = $6
+WHERE id = $7
 RETURNING id;
 
 -- name: ProductAll :many
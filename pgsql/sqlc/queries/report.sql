@@ -0,0 +1,90 @@
+-- name: ReportAging :many
+-- What each client owed at @at in @currency, by the days its invoices were
+-- overdue. The invoices without a due date were due when they were created.
+WITH owed AS (
+    SELECT h.client_id, h.bill_to_name, h.created_at,
+        h.total
+            - (SELECT COALESCE(SUM(c.total), 0) FROM "credit_note" c WHERE c.invoice_header_id = h.id AND c.created_at <= @at)
+            - (SELECT COALESCE(SUM(a.amount), 0) FROM "payment_allocation" a WHERE a.invoice_header_id = h.id AND a.created_at <= @at)
+            AS due,
+        floor(extract(epoch FROM @at::timestamptz - COALESCE(h.due_at, h.created_at)) / 86400) AS days
+    FROM "invoice_header" h
+    WHERE h.status IN ('issued', 'partially_paid', 'paid')
+        AND h.currency = @currency
+        AND h.created_at <= @at
+        AND (sqlc.narg('client_id')::bigint IS NULL OR h.client_id = sqlc.narg('client_id'))
+)
+SELECT client_id,
+    (array_agg(bill_to_name ORDER BY created_at DESC))[1]::text AS name,
+    COUNT(*)::BIGINT AS invoices,
+    COALESCE(SUM(due) FILTER (WHERE days <= 0), 0)::BIGINT AS not_due,
+    COALESCE(SUM(due) FILTER (WHERE days BETWEEN 1 AND 30), 0)::BIGINT AS overdue_1_30,
+    COALESCE(SUM(due) FILTER (WHERE days BETWEEN 31 AND 60), 0)::BIGINT AS overdue_31_60,
+    COALESCE(SUM(due) FILTER (WHERE days BETWEEN 61 AND 90), 0)::BIGINT AS overdue_61_90,
+    COALESCE(SUM(due) FILTER (WHERE days > 90), 0)::BIGINT AS overdue_90,
+    SUM(due)::BIGINT AS total
+FROM owed
+WHERE due > 0
+GROUP BY client_id
+ORDER BY total DESC, client_id;
+
+-- name: ReportRevenueByProduct :many
+-- The items invoiced less those credited in @currency, by product, each one
+-- on the date of its document. Drafts and void invoices aren't revenue.
+WITH lines AS (
+    SELECT i.product_id, i.product_name, h.created_at, i.quantity::BIGINT AS quantity, i.total - i.tax AS net, i.tax, i.total
+    FROM "invoice_item" i
+    JOIN "invoice_header" h ON h.id = i.invoice_header_id
+    WHERE h.status IN ('issued', 'partially_paid', 'paid')
+        AND h.currency = @currency
+        AND (sqlc.narg('created_from')::timestamptz IS NULL OR h.created_at >= sqlc.narg('created_from'))
+        AND (sqlc.narg('created_to')::timestamptz IS NULL OR h.created_at < sqlc.narg('created_to'))
+        AND (sqlc.narg('client_id')::bigint IS NULL OR h.client_id = sqlc.narg('client_id'))
+    UNION ALL
+    SELECT ci.product_id, ci.product_name, c.created_at, -ci.quantity::BIGINT, -(ci.total - ci.tax), -ci.tax, -ci.total
+    FROM "credit_note_item" ci
+    JOIN "credit_note" c ON c.id = ci.credit_note_id
+    WHERE c.currency = @currency
+        AND (sqlc.narg('created_from')::timestamptz IS NULL OR c.created_at >= sqlc.narg('created_from'))
+        AND (sqlc.narg('created_to')::timestamptz IS NULL OR c.created_at < sqlc.narg('created_to'))
+        AND (sqlc.narg('client_id')::bigint IS NULL OR c.client_id = sqlc.narg('client_id'))
+)
+SELECT product_id,
+    (array_agg(product_name ORDER BY created_at DESC))[1]::text AS product_name,
+    SUM(quantity)::BIGINT AS quantity,
+    SUM(net)::BIGINT AS net,
+    SUM(tax)::BIGINT AS tax,
+    SUM(total)::BIGINT AS total
+FROM lines
+GROUP BY product_id
+ORDER BY net DESC, product_id;
+
+-- name: ReportRevenueByMonth :many
+-- The invoices less the credit notes in @currency by month of their date, in
+-- UTC.
+WITH docs AS (
+    SELECT h.created_at, 1 AS invoices, 0 AS credit_notes, h.total - h.tax AS invoiced, 0::BIGINT AS credited, h.tax, h.total
+    FROM "invoice_header" h
+    WHERE h.status IN ('issued', 'partially_paid', 'paid')
+        AND h.currency = @currency
+        AND (sqlc.narg('created_from')::timestamptz IS NULL OR h.created_at >= sqlc.narg('created_from'))
+        AND (sqlc.narg('created_to')::timestamptz IS NULL OR h.created_at < sqlc.narg('created_to'))
+        AND (sqlc.narg('client_id')::bigint IS NULL OR h.client_id = sqlc.narg('client_id'))
+    UNION ALL
+    SELECT c.created_at, 0, 1, 0, c.total - c.tax, -c.tax, -c.total
+    FROM "credit_note" c
+    WHERE c.currency = @currency
+        AND (sqlc.narg('created_from')::timestamptz IS NULL OR c.created_at >= sqlc.narg('created_from'))
+        AND (sqlc.narg('created_to')::timestamptz IS NULL OR c.created_at < sqlc.narg('created_to'))
+        AND (sqlc.narg('client_id')::bigint IS NULL OR c.client_id = sqlc.narg('client_id'))
+)
+SELECT to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM')::text AS month,
+    SUM(invoices)::BIGINT AS invoices,
+    SUM(credit_notes)::BIGINT AS credit_notes,
+    SUM(invoiced)::BIGINT AS invoiced,
+    SUM(credited)::BIGINT AS credited,
+    SUM(tax)::BIGINT AS tax,
+    SUM(total)::BIGINT AS total
+FROM docs
+GROUP BY month
+ORDER BY month;
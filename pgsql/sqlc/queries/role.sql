@@ -0,0 +1,31 @@
+-- name: RoleAll :many
+SELECT * FROM "role" ORDER BY name;
+
+-- name: RoleByName :one
+SELECT * FROM "role" WHERE name = $1;
+
+-- name: RolePermissionAll :many
+SELECT r.name AS role_name, p.name AS permission_name
+FROM "role_permission" rp
+JOIN "role" r ON r.id = rp.role_id
+JOIN "permission" p ON p.id = rp.permission_id
+ORDER BY r.name, p.name;
+
+-- name: UserRoleAssign :exec
+INSERT INTO "user_role" (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;
+
+-- name: UserRoleRevoke :one
+DELETE FROM "user_role" WHERE user_id = $1 AND role_id = $2 RETURNING user_id;
+
+-- name: UserRoleNames :many
+SELECT r.name FROM "role" r
+JOIN "user_role" ur ON ur.role_id = r.id
+WHERE ur.user_id = $1
+ORDER BY r.name;
+
+-- name: UserPermissionNames :many
+SELECT DISTINCT p.name FROM "permission" p
+JOIN "role_permission" rp ON rp.permission_id = p.id
+JOIN "user_role" ur ON ur.role_id = rp.role_id
+WHERE ur.user_id = $1
+ORDER BY p.name;
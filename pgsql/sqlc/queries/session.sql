@@ -0,0 +1,31 @@
+-- name: RefreshTokenCreate :one
+INSERT INTO "refresh_token"
+(user_id, family_id, token_hash, expires_at, created_at)
+VALUES ($1, $2, $3, $4, $5) RETURNING id;
+
+-- name: RefreshTokenByHashForUpdate :one
+SELECT * FROM "refresh_token" WHERE token_hash = $1 FOR UPDATE;
+
+-- name: RefreshTokenByHash :one
+SELECT * FROM "refresh_token" WHERE token_hash = $1;
+
+-- name: RefreshTokenRotate :exec
+UPDATE "refresh_token" SET rotated_at = $1 WHERE id = $2;
+
+-- name: RefreshTokenRevokeFamily :exec
+UPDATE "refresh_token" SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL;
+
+-- name: RefreshTokenRevokeByUser :exec
+UPDATE "refresh_token" SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL;
+
+-- name: RefreshTokenDeleteAll :exec
+TRUNCATE TABLE "refresh_token" RESTART IDENTITY;
+
+-- name: RevokedTokenCreate :exec
+INSERT INTO "revoked_token" (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING;
+
+-- name: RevokedTokenExists :one
+SELECT EXISTS (SELECT 1 FROM "revoked_token" WHERE jti = $1);
+
+-- name: RevokedTokenDeleteExpired :exec
+DELETE FROM "revoked_token" WHERE expires_at < $1;
@@ -0,0 +1,65 @@
+-- name: SubscriptionPlanCreate :one
+INSERT INTO "subscription_plan" (name, interval_months) VALUES ($1, $2) RETURNING id, created_at;
+
+-- name: SubscriptionPlanItemCreate :exec
+INSERT INTO "subscription_plan_item" (plan_id, product_id, quantity) VALUES ($1, $2, $3);
+
+-- name: SubscriptionPlanByID :one
+SELECT * FROM "subscription_plan" WHERE id = $1;
+
+-- name: SubscriptionPlanAll :many
+SELECT * FROM "subscription_plan" ORDER BY id;
+
+-- name: SubscriptionPlanItemByPlan :many
+-- The products are read at their current name, price and tax category.
+SELECT i.product_id, i.quantity, p.name AS product_name, p.price AS unit_price, p.currency,
+    COALESCE(p.tax_category, '')::text AS tax_category
+FROM "subscription_plan_item" i JOIN "product" p ON p.id = i.product_id
+WHERE i.plan_id = $1 ORDER BY i.product_id;
+
+-- name: SubscriptionCreate :one
+INSERT INTO "subscription" (client_id, plan_id, quantity, interval_months, jurisdiction, anchor, next_bill_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, status, created_at;
+
+-- name: SubscriptionByID :one
+SELECT * FROM "subscription" WHERE id = $1;
+
+-- name: SubscriptionByIDForUpdate :one
+SELECT * FROM "subscription" WHERE id = $1 FOR UPDATE;
+
+-- name: SubscriptionByClient :many
+SELECT * FROM "subscription" WHERE client_id = $1 ORDER BY id;
+
+-- name: SubscriptionDue :many
+SELECT * FROM "subscription" WHERE status = 'active' AND next_bill_at <= $1
+ORDER BY next_bill_at, id LIMIT $2;
+
+-- name: SubscriptionDueForUpdate :one
+-- Skipped while another transaction, maybe of another replica, has it.
+SELECT * FROM "subscription" WHERE id = $1 AND status = 'active' AND next_bill_at <= $2
+FOR UPDATE SKIP LOCKED;
+
+-- name: SubscriptionAdvance :exec
+UPDATE "subscription" SET next_period = $1, next_bill_at = $2 WHERE id = $3;
+
+-- name: SubscriptionChange :exec
+UPDATE "subscription" SET plan_id = $1, quantity = $2 WHERE id = $3;
+
+-- name: SubscriptionCancel :exec
+UPDATE "subscription" SET status = 'canceled', canceled_at = $1 WHERE id = $2;
+
+-- name: SubscriptionAdjustmentCreate :one
+INSERT INTO "subscription_adjustment" (subscription_id, product_id, description, amount)
+VALUES ($1, $2, $3, $4) RETURNING id, created_at;
+
+-- name: SubscriptionAdjustmentPending :many
+SELECT a.*, COALESCE(p.tax_category, '')::text AS tax_category
+FROM "subscription_adjustment" a LEFT JOIN "product" p ON p.id = a.product_id
+WHERE a.subscription_id = $1 AND a.invoice_header_id IS NULL ORDER BY a.id;
+
+-- name: SubscriptionAdjustmentInvoiced :exec
+UPDATE "subscription_adjustment" SET invoice_header_id = $1
+WHERE subscription_id = $2 AND invoice_header_id IS NULL;
+
+-- name: SubscriptionDeleteAll :exec
+TRUNCATE TABLE "subscription_adjustment", "subscription", "subscription_plan_item", "subscription_plan" RESTART IDENTITY;
@@ -0,0 +1,29 @@
+-- name: TaxCategoryCreate :one
+INSERT INTO "tax_category" (code, name) VALUES ($1, $2) RETURNING created_at;
+
+-- name: TaxCategoryAll :many
+SELECT * FROM "tax_category" ORDER BY code;
+
+-- name: TaxRateCreate :one
+INSERT INTO "tax_rate" (category, jurisdiction, name, rate, valid_from, valid_to)
+VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at;
+
+-- name: TaxRateAll :many
+SELECT * FROM "tax_rate" ORDER BY category, jurisdiction, valid_from;
+
+-- name: TaxRateByJurisdiction :many
+SELECT * FROM "tax_rate" WHERE jurisdiction = $1 ORDER BY category, valid_from;
+
+-- name: TaxExemptionCreate :one
+INSERT INTO "tax_exemption" (client_id, category, jurisdiction, reason, valid_from, valid_to)
+VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at;
+
+-- name: TaxExemptionByClient :many
+SELECT * FROM "tax_exemption" WHERE client_id = $1 ORDER BY id;
+
+-- name: TaxExemptionByClientJurisdiction :many
+SELECT * FROM "tax_exemption" WHERE client_id = $1 AND jurisdiction = $2 ORDER BY id;
+
+-- name: TaxDeleteAll :exec
+-- The categories are kept, the products refer to them.
+TRUNCATE TABLE "tax_exemption", "tax_rate" RESTART IDENTITY;
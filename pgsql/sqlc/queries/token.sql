@@ -0,0 +1,16 @@
+-- name: UserTokenCreate :one
+INSERT INTO "user_token"
+(user_id, purpose, token_hash, expires_at, created_at)
+VALUES ($1, $2, $3, $4, $5) RETURNING id;
+
+-- name: UserTokenByHashForUpdate :one
+SELECT * FROM "user_token" WHERE token_hash = $1 FOR UPDATE;
+
+-- name: UserTokenUseAll :exec
+UPDATE "user_token" SET used_at = $1 WHERE user_id = $2 AND purpose = $3 AND used_at IS NULL;
+
+-- name: UserTokenDeleteExpired :exec
+DELETE FROM "user_token" WHERE expires_at < $1;
+
+-- name: UserTokenDeleteAll :exec
+TRUNCATE TABLE "user_token" RESTART IDENTITY;
@@ -7,6 +7,8 @@ import (
 
 	"github.com/adrianolmedo/genesis"
 	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/billing/report"
+	"github.com/adrianolmedo/genesis/ledger"
 	"github.com/adrianolmedo/genesis/store"
 	"github.com/adrianolmedo/genesis/user"
 
@@ -17,9 +19,17 @@ import (
 type Storage struct {
 	db       *pgxpool.Pool
 	User     *user.Repo
+	Session  *user.SessionRepo
+	Role     *user.RoleRepo
+	Attempts *user.LoginAttemptRepo
+	MFA      *user.MFARepo
+	Tokens   *user.TokenRepo
+	APIKeys  *user.APIKeyRepo
 	Product  *store.ProductRepo
 	Customer *store.CustomerRepo
 	Invoice  *billing.Repo
+	Report   *report.Repo
+	Ledger   *ledger.Repo
 }
 
 // NewStorage creates a new Storage instance with all repositories.
@@ -27,9 +37,17 @@ func NewStorage(ctx context.Context, db *pgxpool.Pool, cfg genesis.Config) (*Sto
 	return &Storage{
 		db:       db,
 		User:     user.NewRepo(db),
+		Session:  user.NewSessionRepo(db),
+		Role:     user.NewRoleRepo(db),
+		Attempts: user.NewLoginAttemptRepo(db),
+		MFA:      user.NewMFARepo(db),
+		Tokens:   user.NewTokenRepo(db),
+		APIKeys:  user.NewAPIKeyRepo(db),
 		Product:  store.NewProductRepo(db),
 		Customer: store.NewCustomerRepo(db),
 		Invoice:  billing.NewRepo(db),
+		Report:   report.NewRepo(db),
+		Ledger:   ledger.NewRepo(db),
 	}, nil
 }
 
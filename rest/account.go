@@ -0,0 +1,139 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/adrianolmedo/genesis/compose"
+	"github.com/adrianolmedo/genesis/logger"
+	"github.com/adrianolmedo/genesis/user"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// verifyUser godoc
+//
+//	@Summary		Verify email
+//	@Description	Verify the email of a user with the token of the link sent to it
+//	@Tags			users
+//	@Produce		json
+//	@Param			token	query		string	true	"Verification token"
+//	@Failure		400		{object}	errorResp
+//	@Failure		500		{object}	errorResp
+//	@Success		200		{object}	resp
+//	@Router			/users/verify [get]
+func verifyUser(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		err := svcs.User.VerifyEmail(ctx, c.Query("token"))
+		if err != nil {
+			return tokenError(c, err)
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Email verified",
+		})
+	}
+}
+
+// forgotPassword godoc
+//
+//	@Summary		Forgot password
+//	@Description	Send a token to reset the password to the email, if it's registered
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400					{object}	errorResp
+//	@Failure		500					{object}	errorResp
+//	@Success		200					{object}	resp
+//	@Param			forgotPasswordReq	body		forgotPasswordReq	true	"application/json"
+//	@Router			/password/forgot [post]
+func forgotPassword(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		req := forgotPasswordReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		err := svcs.User.ForgotPassword(ctx, req.Email)
+		if err != nil {
+			// Delivery problems aren't reported to the client, they would
+			// tell that the email is registered.
+			logger.Error("forgot password", "err", err.Error())
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "If the email is registered you will receive a token to reset the password",
+		})
+	}
+}
+
+// forgotPasswordReq email of the account to reset the password.
+type forgotPasswordReq struct {
+	Email string `json:"email" example:"example@gmail.com"`
+}
+
+// resetPassword godoc
+//
+//	@Summary		Reset password
+//	@Description	Replace the password with the token sent by email, every session is closed
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400					{object}	errorResp
+//	@Failure		500					{object}	errorResp
+//	@Success		200					{object}	resp
+//	@Param			resetPasswordReq	body		resetPasswordReq	true	"application/json"
+//	@Router			/password/reset [post]
+func resetPassword(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		req := resetPasswordReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		err := svcs.User.ResetPassword(ctx, req.Token, req.Password)
+		if errors.Is(err, user.ErrPasswordCantBeEmpty) {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			return tokenError(c, err)
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Password changed",
+			Details: "Sign in with the new password",
+		})
+	}
+}
+
+// resetPasswordReq token sent by email and the new password.
+type resetPasswordReq struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// tokenError responds the errors of the tokens sent by email.
+func tokenError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, user.ErrTokenInvalid) || errors.Is(err, user.ErrTokenExpired) ||
+		errors.Is(err, user.ErrNotFound) {
+		return errorJSON(c, http.StatusBadRequest, detailsResp{
+			Code:    "003",
+			Message: err.Error(),
+			Details: "Request a new token",
+		})
+	}
+	logger.Error("account token", "err", err.Error())
+	return errorJSON(c, http.StatusInternalServerError, detailsResp{
+		Code:    "003",
+		Message: "The token could not be used",
+	})
+}
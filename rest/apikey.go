@@ -0,0 +1,327 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/adrianolmedo/genesis/compose"
+	"github.com/adrianolmedo/genesis/logger"
+	"github.com/adrianolmedo/genesis/user"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// createAPIKey godoc
+//
+//	@Summary		Create API key
+//	@Description	Create an API key for the authenticated user, or for a service account, the key is shown once
+//	@Tags			apikeys
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400				{object}	errorResp
+//	@Failure		401				{object}	errorResp
+//	@Failure		403				{object}	errorResp
+//	@Failure		404				{object}	errorResp
+//	@Failure		500				{object}	errorResp
+//	@Success		201				{object}	resp{data=createdAPIKeyResp}
+//	@Param			createAPIKeyReq	body		createAPIKeyReq	true	"application/json"
+//	@Router			/apikeys [post]
+func createAPIKey(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		req := createAPIKeyReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		k := &user.APIKey{
+			Name:             req.Name,
+			Scopes:           req.Scopes,
+			ExpiresAt:        req.ExpiresAt,
+			ServiceAccountID: req.ServiceAccountID,
+		}
+		plain, err := svcs.User.CreateAPIKey(ctx, principalFrom(c), k)
+		if err != nil {
+			return apiKeyError(c, err)
+		}
+		return respJSON(c, http.StatusCreated, detailsResp{
+			Message: "API key created",
+			Details: "Keep the key in a safe place, it won't be shown again",
+			Data: createdAPIKeyResp{
+				Key:        plain,
+				apiKeyResp: toAPIKeyResp(*k),
+			},
+		})
+	}
+}
+
+// createAPIKeyReq name, scopes and optional expiry of a new API key.
+type createAPIKeyReq struct {
+	Name             string     `json:"name" example:"ci"`
+	Scopes           []string   `json:"scopes" example:"products:write"`
+	ExpiresAt        *time.Time `json:"expiresAt,omitempty" example:"2030-01-01T00:00:00Z"`
+	ServiceAccountID int64      `json:"serviceAccountId,omitempty"` // owner of the key instead of the user
+}
+
+// createdAPIKeyResp API key with the plain key, only returned on creation.
+type createdAPIKeyResp struct {
+	Key string `json:"key" example:"gen_k3m9x2aq_..."`
+	apiKeyResp
+}
+
+// apiKeyResp subset of APIKey fields, the key itself is never returned.
+type apiKeyResp struct {
+	ID               int64      `json:"id"`
+	Prefix           string     `json:"prefix" example:"k3m9x2aq"`
+	Name             string     `json:"name" example:"ci"`
+	UserID           int64      `json:"userId,omitempty"`
+	ServiceAccountID int64      `json:"serviceAccountId,omitempty"`
+	Scopes           []string   `json:"scopes" example:"products:write"`
+	ExpiresAt        *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt       *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt        *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt        time.Time  `json:"createdAt"`
+}
+
+// toAPIKeyResp converts an APIKey to its response.
+func toAPIKeyResp(k user.APIKey) apiKeyResp {
+	return apiKeyResp{
+		ID:               k.ID,
+		Prefix:           k.Prefix,
+		Name:             k.Name,
+		UserID:           k.UserID,
+		ServiceAccountID: k.ServiceAccountID,
+		Scopes:           k.Scopes,
+		ExpiresAt:        k.ExpiresAt,
+		LastUsedAt:       k.LastUsedAt,
+		RevokedAt:        k.RevokedAt,
+		CreatedAt:        k.CreatedAt,
+	}
+}
+
+// toAPIKeysResp converts API keys to their responses.
+func toAPIKeysResp(keys []user.APIKey) []apiKeyResp {
+	list := make([]apiKeyResp, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, toAPIKeyResp(k))
+	}
+	return list
+}
+
+// listAPIKeys godoc
+//
+//	@Summary		List API keys
+//	@Description	Get the API keys of the authenticated user or service account
+//	@Tags			apikeys
+//	@Produce		json
+//	@Failure		401	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=[]apiKeyResp}
+//	@Router			/apikeys [get]
+func listAPIKeys(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		keys, err := svcs.User.APIKeys(ctx, principalFrom(c))
+		if err != nil {
+			return apiKeyError(c, err)
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    toAPIKeysResp(keys),
+		})
+	}
+}
+
+// revokeAPIKey godoc
+//
+//	@Summary		Revoke API key
+//	@Description	Revoke an API key, it can't be used anymore
+//	@Tags			apikeys
+//	@Produce		json
+//	@Param			id	path		int	true	"API key id"
+//	@Failure		400	{object}	errorResp
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		404	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp
+//	@Router			/apikeys/{id} [delete]
+func revokeAPIKey(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID API key",
+			})
+		}
+		err = svcs.User.RevokeAPIKey(ctx, principalFrom(c), int64(id))
+		if err != nil {
+			return apiKeyError(c, err)
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "API key revoked",
+		})
+	}
+}
+
+// createServiceAccount godoc
+//
+//	@Summary		Create service account
+//	@Description	Register a service account, to be granted API keys
+//	@Tags			apikeys
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400						{object}	errorResp
+//	@Failure		401						{object}	errorResp
+//	@Failure		403						{object}	errorResp
+//	@Failure		500						{object}	errorResp
+//	@Success		201						{object}	resp{data=serviceAccountResp}
+//	@Param			createServiceAccountReq	body		createServiceAccountReq	true	"application/json"
+//	@Router			/service-accounts [post]
+func createServiceAccount(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		req := createServiceAccountReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		sa := &user.ServiceAccount{
+			Name:        req.Name,
+			Description: req.Description,
+		}
+		if err := svcs.User.CreateServiceAccount(ctx, sa); err != nil {
+			return apiKeyError(c, err)
+		}
+		return respJSON(c, http.StatusCreated, detailsResp{
+			Message: "Service account created",
+			Data:    toServiceAccountResp(*sa),
+		})
+	}
+}
+
+// createServiceAccountReq name and description of a new service account.
+type createServiceAccountReq struct {
+	Name        string `json:"name" example:"billing-worker"`
+	Description string `json:"description" example:"Generates the monthly invoices"`
+}
+
+// serviceAccountResp subset of ServiceAccount fields.
+type serviceAccountResp struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name" example:"billing-worker"`
+	Description string    `json:"description" example:"Generates the monthly invoices"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// toServiceAccountResp converts a ServiceAccount to its response.
+func toServiceAccountResp(sa user.ServiceAccount) serviceAccountResp {
+	return serviceAccountResp{
+		ID:          sa.ID,
+		Name:        sa.Name,
+		Description: sa.Description,
+		CreatedAt:   sa.CreatedAt,
+	}
+}
+
+// listServiceAccounts godoc
+//
+//	@Summary		List service accounts
+//	@Description	Get the service accounts
+//	@Tags			apikeys
+//	@Produce		json
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=[]serviceAccountResp}
+//	@Router			/service-accounts [get]
+func listServiceAccounts(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		sas, err := svcs.User.ServiceAccounts(ctx)
+		if err != nil {
+			return apiKeyError(c, err)
+		}
+		list := make([]serviceAccountResp, 0, len(sas))
+		for _, sa := range sas {
+			list = append(list, toServiceAccountResp(sa))
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    list,
+		})
+	}
+}
+
+// listServiceAccountAPIKeys godoc
+//
+//	@Summary		List service account API keys
+//	@Description	Get the API keys of a service account
+//	@Tags			apikeys
+//	@Produce		json
+//	@Param			id	path		int	true	"Service account id"
+//	@Failure		400	{object}	errorResp
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		404	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=[]apiKeyResp}
+//	@Router			/service-accounts/{id}/apikeys [get]
+func listServiceAccountAPIKeys(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID service account",
+			})
+		}
+		keys, err := svcs.User.ServiceAccountAPIKeys(ctx, int64(id))
+		if err != nil {
+			return apiKeyError(c, err)
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    toAPIKeysResp(keys),
+		})
+	}
+}
+
+// apiKeyError responds the errors of the API keys management.
+func apiKeyError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, user.ErrAPIKeyNameCantBeEmpty),
+		errors.Is(err, user.ErrAPIKeyExpiryInPast),
+		errors.Is(err, user.ErrServiceAccountNameCantBeEmpty):
+		return errorJSON(c, http.StatusBadRequest, detailsResp{
+			Code:    "002",
+			Message: err.Error(),
+		})
+	case errors.Is(err, user.ErrScopeNotGranted), errors.Is(err, user.ErrForbidden):
+		return errorJSON(c, http.StatusForbidden, detailsResp{
+			Code:    "001",
+			Message: err.Error(),
+		})
+	case errors.Is(err, user.ErrAPIKeyNotFound), errors.Is(err, user.ErrServiceAccountNotFound):
+		return errorJSON(c, http.StatusNotFound, detailsResp{
+			Code:    "003",
+			Message: err.Error(),
+		})
+	}
+	logger.Error("api key", "err", err.Error())
+	return errorJSON(c, http.StatusInternalServerError, detailsResp{
+		Code:    "003",
+		Message: "The API keys could not be updated",
+	})
+}
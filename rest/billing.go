@@ -1,15 +1,22 @@
 package rest
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/billing/pdf"
+	"github.com/adrianolmedo/genesis/billing/ubl"
 	"github.com/adrianolmedo/genesis/compose"
 	"github.com/adrianolmedo/genesis/logger"
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/pgsql"
 	"github.com/adrianolmedo/genesis/store"
-	"github.com/adrianolmedo/genesis/user"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -23,8 +30,9 @@ import (
 //	@Produce		json
 //	@Failure		400					{object}	errorResp
 //	@Failure		404					{object}	errorResp
+//	@Failure		422					{object}	errorResp
 //	@Failure		500					{object}	errorResp
-//	@Success		201					{object}	resp{data=generateInvoiceReq}
+//	@Success		201					{object}	resp{data=invoiceResp}
 //	@Param			generateInvoiceReq	body		generateInvoiceReq	true	"application/json"
 //	@Router			/invoices [post]
 func generateInvoice(svcs *compose.Services) fiber.Handler {
@@ -38,32 +46,28 @@ func generateInvoice(svcs *compose.Services) fiber.Handler {
 				Message: "The JSON structure is not correct",
 			})
 		}
-		clientID := req.Header.ClientID
-		_, err = svcs.User.Find(ctx, clientID)
-		if errors.Is(err, user.ErrNotFound) {
-			logger.Error("generating invoice", fmt.Sprintf("user ID %d not found to generate invoice", clientID))
-			return errorJSON(c, http.StatusNotFound, detailsResp{
-				Code:    "002",
-				Message: err.Error(),
-			})
-		}
-		if err != nil {
-			logger.Error("generating invoice", err.Error())
-			return errorJSON(c, http.StatusBadRequest, detailsResp{
-				Code:    "002",
-				Message: err.Error(),
-			})
-		}
-
-		// assmble is like a mapper to convert invoiceItemReq to billing.InvoiceItem.
-		assemble := func(i invoiceItemReq) billing.InvoiceItem {
+		// assemble is like a mapper to convert invoiceItemReq to
+		// billing.InvoiceItem, the name, price and tax category of the
+		// product are copied to the item.
+		assemble := func(i invoiceItemReq, p *store.Product) billing.InvoiceItem {
+			quantity := i.Quantity
+			if quantity == 0 {
+				quantity = 1
+			}
 			return billing.InvoiceItem{
-				ProductID: i.ProductID,
+				ProductID:   i.ProductID,
+				ProductName: p.Name,
+				Quantity:    quantity,
+				UnitPrice:   p.Price,
+				TaxCategory: p.TaxCategory,
+				Totals: billing.Totals{
+					Discount: i.Discount,
+				},
 			}
 		}
 		items := make(billing.ItemList, 0, len(req.Items))
 		for _, item := range req.Items {
-			_, err := svcs.Store.Find(ctx, item.ProductID)
+			product, err := svcs.Store.Find(ctx, item.ProductID)
 			if errors.Is(err, store.ErrProductNotFound) {
 				logger.Debug("generating invoice", fmt.Sprintf("product ID %d not found to add the invoice", item.ProductID))
 				return errorJSON(c, http.StatusNotFound, detailsResp{
@@ -78,15 +82,46 @@ func generateInvoice(svcs *compose.Services) fiber.Handler {
 					Message: err.Error(),
 				})
 			}
-			items = append(items, assemble(item))
+			items = append(items, assemble(item, product))
 		}
 		invoice := &billing.Invoice{
 			Header: &billing.InvoiceHeader{
-				ClientID: clientID,
+				ClientID:     req.Header.ClientID,
+				Jurisdiction: req.Header.Jurisdiction,
+				Currency:     money.Currency(req.Header.Currency),
 			},
 			Items: items,
 		}
 		err = svcs.Billing.Generate(ctx, invoice)
+		if errors.Is(err, billing.ErrClientIDCantBeEmpty) {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		if errors.Is(err, billing.ErrCustomerNotFound) {
+			logger.Debug("generating invoice", fmt.Sprintf("customer ID %d not found to generate invoice", req.Header.ClientID))
+			return errorJSON(c, http.StatusNotFound, detailsResp{
+				Code:    "002",
+				Message: fmt.Sprintf("%s with id %d", billing.ErrCustomerNotFound, req.Header.ClientID),
+			})
+		}
+		if errors.Is(err, billing.ErrCustomerDeleted) || errors.Is(err, billing.ErrTaxRateNotFound) ||
+			errors.Is(err, billing.ErrCurrencyMismatch) {
+			return errorJSON(c, http.StatusUnprocessableEntity, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		if errors.Is(err, billing.ErrItemListCantBeEmpty) || errors.Is(err, billing.ErrInvalidQuantity) ||
+			errors.Is(err, billing.ErrInvalidUnitPrice) || errors.Is(err, billing.ErrInvalidDiscount) ||
+			errors.Is(err, billing.ErrInvalidTaxRate) || errors.Is(err, billing.ErrAmountOverflow) ||
+			errors.Is(err, money.ErrUnknownCurrency) {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
 		if err != nil {
 			logger.Error("generating invoice", err.Error())
 			return errorJSON(c, http.StatusInternalServerError, detailsResp{
@@ -97,10 +132,7 @@ func generateInvoice(svcs *compose.Services) fiber.Handler {
 		logger.Info("generating invoice", fmt.Sprintf("invoice ID %d generated", invoice.Header.ID))
 		return respJSON(c, http.StatusCreated, detailsResp{
 			Message: "Invoice generated",
-			Data: generateInvoiceReq{
-				Header: req.Header,
-				Items:  req.Items,
-			},
+			Data:    toInvoiceResp(invoice),
 		})
 	}
 }
@@ -112,10 +144,1081 @@ type generateInvoiceReq struct {
 }
 
 // invoiceItemReq represents a Command to generate invoice item as product.
+// The amounts are in minor units of the currency (e.g. cents), the tax is
+// given by the tax category of the product.
 type invoiceItemReq struct {
 	ProductID int64 `json:"productId"`
+	Quantity  int   `json:"quantity,omitempty" example:"2"`   // 1 if omitted
+	Discount  int64 `json:"discount,omitempty" example:"500"` // amount off the line
 }
 
 type invoiceHeaderReq struct {
-	ClientID int64 `json:"clientId"`
+	ClientID     int64  `json:"clientId"`
+	Jurisdiction string `json:"jurisdiction,omitempty" example:"MX"` // the default one if omitted
+	Currency     string `json:"currency,omitempty" example:"MXN"`    // of the products if omitted
+}
+
+// invoiceResp invoice with its totals and items, the amounts are in minor
+// units of its currency (e.g. cents). The exchange rate to the base
+// currency is captured when it's issued.
+type invoiceResp struct {
+	ID       int64             `json:"id"`
+	UUID     string            `json:"uuid"`
+	Number   string            `json:"number" example:"INV-2024-000001"`
+	ClientID int64             `json:"clientId"`
+	Subtotal int64             `json:"subtotal" example:"2300"`
+	Discount int64             `json:"discount" example:"500"`
+	Tax      int64             `json:"tax" example:"240"`
+	Total    int64             `json:"total" example:"2040"`
+	Status   string            `json:"status" example:"issued"`
+	BillTo   billToResp        `json:"billTo"`
+	Items    []invoiceItemResp `json:"items,omitempty"` // omitted in lists
+	Taxes    []taxLineResp     `json:"taxes,omitempty"` // omitted in lists
+
+	Jurisdiction     string     `json:"jurisdiction" example:"MX"`
+	PricesIncludeTax bool       `json:"pricesIncludeTax"`
+	TaxRounding      string     `json:"taxRounding" example:"line"`
+	Currency         string     `json:"currency" example:"MXN"`
+	BaseCurrency     string     `json:"baseCurrency" example:"EUR"`
+	ExchangeRate     string     `json:"exchangeRate,omitempty" example:"0.05412000"` // a unit of currency in the base one
+	DueAt            *time.Time `json:"dueAt,omitempty"`                             // by the payment terms of the client
+	CreatedAt        time.Time  `json:"createdAt"`
+}
+
+// billToResp the customer an invoice is billed to, as it was on
+// generation.
+type billToResp struct {
+	Name    string `json:"name" example:"Acme S.A. de C.V."`
+	Email   string `json:"email" example:"billing@acme.mx"`
+	Address string `json:"address,omitempty" example:"Av. Reforma 1, 06600 CDMX"`
+}
+
+// invoiceItemResp item of an invoice with the product as it was on
+// generation.
+type invoiceItemResp struct {
+	ID          int64  `json:"id"`
+	ProductID   int64  `json:"productId"`
+	ProductName string `json:"productName" example:"Coca-Cola"`
+	Quantity    int    `json:"quantity" example:"2"`
+	UnitPrice   int64  `json:"unitPrice" example:"1000"`
+	TaxCategory string `json:"taxCategory,omitempty" example:"standard"`
+	TaxName     string `json:"taxName,omitempty" example:"IVA 16%"`
+	TaxRate     int    `json:"taxRate" example:"1600"`
+	TaxExempt   bool   `json:"taxExempt,omitempty"`
+	Subtotal    int64  `json:"subtotal" example:"2000"`
+	Discount    int64  `json:"discount" example:"500"`
+	Tax         int64  `json:"tax" example:"240"`
+	Total       int64  `json:"total" example:"1740"`
+}
+
+// taxLineResp line of the tax breakdown of an invoice, its items of a
+// category and rate.
+type taxLineResp struct {
+	Category string `json:"category" example:"standard"`
+	Name     string `json:"name" example:"IVA 16%"`
+	Rate     int    `json:"rate" example:"1600"`
+	Exempt   bool   `json:"exempt"`
+	Base     int64  `json:"base" example:"1500"`
+	Tax      int64  `json:"tax" example:"240"`
+}
+
+// toInvoiceResp converts a billing.Invoice to its response.
+func toInvoiceResp(inv *billing.Invoice) invoiceResp {
+	var items []invoiceItemResp
+	for _, it := range inv.Items {
+		items = append(items, invoiceItemResp{
+			ID:          it.ID,
+			ProductID:   it.ProductID,
+			ProductName: it.ProductName,
+			Quantity:    it.Quantity,
+			UnitPrice:   it.UnitPrice.Amount,
+			TaxCategory: it.TaxCategory,
+			TaxName:     it.TaxName,
+			TaxRate:     it.TaxRate,
+			TaxExempt:   it.TaxExempt,
+			Subtotal:    it.Subtotal,
+			Discount:    it.Discount,
+			Tax:         it.Tax,
+			Total:       it.Total,
+		})
+	}
+	var taxes []taxLineResp
+	for _, t := range inv.Taxes {
+		taxes = append(taxes, taxLineResp{
+			Category: t.Category,
+			Name:     t.Name,
+			Rate:     t.Rate,
+			Exempt:   t.Exempt,
+			Base:     t.Base,
+			Tax:      t.Tax,
+		})
+	}
+	h := inv.Header
+	var dueAt *time.Time
+	if !h.DueAt.IsZero() {
+		dueAt = &h.DueAt
+	}
+	var rate string
+	if !h.ExchangeRate.IsZero() {
+		rate = h.ExchangeRate.Decimal()
+	}
+	return invoiceResp{
+		ID:               h.ID,
+		UUID:             h.UUID,
+		Number:           h.Number,
+		ClientID:         h.ClientID,
+		Status:           string(h.Status),
+		BillTo:           billToResp{Name: h.BillTo.Name, Email: h.BillTo.Email, Address: h.BillTo.Address},
+		Subtotal:         h.Subtotal,
+		Discount:         h.Discount,
+		Tax:              h.Tax,
+		Total:            h.Total,
+		Items:            items,
+		Taxes:            taxes,
+		Jurisdiction:     h.Jurisdiction,
+		PricesIncludeTax: h.PricesIncludeTax,
+		TaxRounding:      string(h.TaxRounding),
+		Currency:         string(h.Currency),
+		BaseCurrency:     string(h.BaseCurrency),
+		ExchangeRate:     rate,
+		DueAt:            dueAt,
+		CreatedAt:        h.CreatedAt,
+	}
+}
+
+// findInvoice godoc
+//
+//	@Summary		Find invoice
+//	@Description	Get an invoice with its items, or with format=ubl as a UBL 2.1 Peppol BIS Billing 3.0 document
+//	@Tags			billing
+//	@Produce		json
+//	@Produce		xml
+//	@Param			id		path		int		true	"Invoice id"
+//	@Param			format	query		string	false	"ubl for the UBL document"
+//	@Failure		400		{object}	errorResp
+//	@Failure		401		{object}	errorResp
+//	@Failure		403		{object}	errorResp
+//	@Failure		404		{object}	errorResp
+//	@Failure		409		{object}	errorResp
+//	@Failure		422		{object}	errorResp
+//	@Failure		500		{object}	errorResp
+//	@Success		200		{object}	resp{data=invoiceResp}
+//	@Router			/invoices/{id} [get]
+func findInvoice(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID invoice",
+			})
+		}
+		invoice, err := svcs.Billing.Find(ctx, int64(id))
+		if errors.Is(err, billing.ErrInvoiceHeaderNotFound) {
+			return errorJSON(c, http.StatusNotFound, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			logger.Error("find invoice", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The invoice could not be read",
+			})
+		}
+		if c.Query("format") == "ubl" {
+			return sendUBL(c, invoice.Header.BillTo, invoice.Header.Number, func(b ubl.Buyer) ([]byte, error) {
+				return svcs.UBL.Invoice(invoice, b)
+			})
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    toInvoiceResp(invoice),
+		})
+	}
+}
+
+// sendUBL sends the UBL document billed to encoded by encode, as an
+// attachment named number.xml.
+func sendUBL(c *fiber.Ctx, to billing.BillTo, number string, encode func(ubl.Buyer) ([]byte, error)) error {
+	doc, err := encode(ubl.Buyer{Name: to.Name, Email: to.Email, Address: to.Address})
+	if errors.Is(err, ubl.ErrNotIssued) {
+		return errorJSON(c, http.StatusConflict, detailsResp{
+			Code:    "003",
+			Message: err.Error(),
+		})
+	}
+	if errors.Is(err, ubl.ErrNoBuyerAddress) {
+		return errorJSON(c, http.StatusUnprocessableEntity, detailsResp{
+			Code:    "003",
+			Message: err.Error(),
+		})
+	}
+	if err != nil {
+		logger.Error("ubl", "err", err.Error())
+		return errorJSON(c, http.StatusInternalServerError, detailsResp{
+			Code:    "003",
+			Message: "The document could not be encoded",
+		})
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationXMLCharsetUTF8)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", number+".xml"))
+	return c.Status(http.StatusOK).Send(doc)
+}
+
+// invoicePDF godoc
+//
+//	@Summary		Invoice PDF
+//	@Description	Get an invoice as a PDF document, with its customer, items, totals and tax breakdown. The file is cached until the invoice changes, an If-None-Match with its ETag answers 304
+//	@Tags			billing
+//	@Produce		application/pdf
+//	@Param			id	path		int	true	"Invoice id"
+//	@Failure		400	{object}	errorResp
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		404	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{file}		binary
+//	@Router			/invoices/{id}/pdf [get]
+func invoicePDF(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID invoice",
+			})
+		}
+		invoice, err := svcs.Billing.Find(ctx, int64(id))
+		if errors.Is(err, billing.ErrInvoiceHeaderNotFound) {
+			return errorJSON(c, http.StatusNotFound, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			logger.Error("invoice pdf", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The invoice could not be read",
+			})
+		}
+		to := invoice.Header.BillTo
+		f, err := svcs.InvoicePDF.Render(pdf.NewDocument(invoice, pdf.Customer{Name: to.Name, Email: to.Email, Address: to.Address}))
+		if err != nil {
+			logger.Error("invoice pdf", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The invoice could not be rendered",
+			})
+		}
+		c.Set(fiber.HeaderETag, f.ETag)
+		c.Set(fiber.HeaderCacheControl, "private, no-cache")
+		if c.Get(fiber.HeaderIfNoneMatch) == f.ETag {
+			return c.SendStatus(http.StatusNotModified)
+		}
+		c.Set(fiber.HeaderContentType, "application/pdf")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("inline; filename=%q", invoice.Header.Number+".pdf"))
+		return c.Status(http.StatusOK).Send(f.Body)
+	}
+}
+
+// listInvoices godoc
+//
+//	@Summary	List invoices
+//	@Tags		billing
+//	@Produce	json
+//	@Failure	400			{object}	errorResp
+//	@Failure	401			{object}	errorResp
+//	@Failure	403			{object}	errorResp
+//	@Failure	500			{object}	errorResp
+//	@Success	200			{object}	filterResp{links=pgsql.FilterLinks,meta=pgsql.FilterResult,data=[]invoiceResp}
+//	@Param		limit		query		int		false	"Limit of pages"										example(2)
+//	@Param		page		query		int		false	"Current page"											example(1)
+//	@Param		sort		query		string	false	"Sort by id, client_id, created_at or total"			example(created_at)
+//	@Param		direction	query		string	false	"Order by ascendent o descendent"						example(desc)
+//	@Param		clientId	query		int		false	"Invoices of the client"								example(1)
+//	@Param		status		query		string	false	"Invoices with the status"								example(issued)
+//	@Param		from		query		string	false	"Created on or after, date or RFC 3339 time"			example(2024-01-01)
+//	@Param		to			query		string	false	"Created on or before, date (included) or RFC 3339 time"	example(2024-01-31)
+//	@Router		/invoices [get]
+func listInvoices(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		filter, err := pgsql.NewFilter(
+			c.QueryInt("limit"),
+			c.QueryInt("page"),
+			c.Query("sort", "created_at"),
+			c.Query("direction"),
+		)
+		if err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		by, err := invoiceListFilter(c)
+		if err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		invoices, total, err := svcs.Billing.List(ctx, filter, by)
+		if errors.Is(err, billing.ErrInvalidSort) || errors.Is(err, billing.ErrInvalidStatus) ||
+			errors.Is(err, billing.ErrInvalidDateRange) {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			logger.Error("list invoices", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The invoices could not be listed",
+			})
+		}
+		if invoices.IsEmpty() {
+			return respJSON(c, http.StatusOK, detailsResp{
+				Code:    "005",
+				Message: "There are not invoices",
+			})
+		}
+		fr := filter.Paginate(total)
+		list := make([]invoiceResp, 0, len(invoices))
+		for i := range invoices {
+			list = append(list, toInvoiceResp(&invoices[i]))
+		}
+		return c.Status(http.StatusOK).JSON(filterResp{
+			Links: filter.Links(c.Path(), fr.TotalPages),
+			Meta:  fr,
+			Data:  list,
+		})
+	}
+}
+
+// invoiceListFilter reads the filter of the invoices from the query.
+func invoiceListFilter(c *fiber.Ctx) (billing.ListFilter, error) {
+	by := billing.ListFilter{
+		Status: billing.Status(c.Query("status")),
+	}
+	var err error
+	if by.ClientID, err = queryClientID(c); err != nil {
+		return by, err
+	}
+	by.From, by.To, err = queryDateRange(c)
+	return by, err
+}
+
+// queryClientID parses the clientId query, 0 if it's missing.
+func queryClientID(c *fiber.Ctx) (int64, error) {
+	v := c.Query("clientId")
+	if v == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("positive number expected for clientId")
+	}
+	return id, nil
+}
+
+// queryCurrency parses the currency query, in any case, empty if it's
+// missing.
+func queryCurrency(c *fiber.Ctx) (money.Currency, error) {
+	v := c.Query("currency")
+	if v == "" {
+		return "", nil
+	}
+	currency, err := money.ParseCurrency(v)
+	if err != nil {
+		return "", fmt.Errorf("currency: %w, expected an ISO 4217 code like EUR", err)
+	}
+	return currency, nil
+}
+
+// queryDateRange parses the from and to queries, zero if they're missing.
+// A date without time given as to includes the whole day.
+func queryDateRange(c *fiber.Ctx) (from, to time.Time, err error) {
+	if v := c.Query("from"); v != "" {
+		if from, _, err = parseDate(v); err != nil {
+			return from, to, fmt.Errorf("from: %v", err)
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		t, day, err := parseDate(v)
+		if err != nil {
+			return from, to, fmt.Errorf("to: %v", err)
+		}
+		if day {
+			t = t.AddDate(0, 0, 1)
+		}
+		to = t
+	}
+	return from, to, nil
+}
+
+// queryAt parses the at query, zero if it's missing. A date without time
+// is the end of the day.
+func queryAt(c *fiber.Ctx) (time.Time, error) {
+	v := c.Query("at")
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, day, err := parseDate(v)
+	if err != nil {
+		return t, fmt.Errorf("at: %v", err)
+	}
+	if day {
+		t = t.AddDate(0, 0, 1).Add(-time.Microsecond)
+	}
+	return t, nil
+}
+
+// parseDate parses a date (2006-01-02, in UTC) or an RFC 3339 time, day
+// reports whether it was a date.
+func parseDate(s string) (t time.Time, day bool, err error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, false, errors.New("date (2006-01-02) or RFC 3339 time expected")
+	}
+	return t, false, nil
+}
+
+// changeInvoiceStatus godoc
+//
+//	@Summary		Change invoice status
+//	@Description	Issue a draft invoice or void an issued one. An invoice changes to partially paid or paid only by its payments and credit notes. The change is recorded in the history of the invoice
+//	@Tags			billing
+//	@Accept			json
+//	@Produce		json
+//	@Param			id					path		int					true	"Invoice id"
+//	@Param			invoiceStatusReq	body		invoiceStatusReq	false	"application/json"
+//	@Failure		400					{object}	errorResp
+//	@Failure		401					{object}	errorResp
+//	@Failure		403					{object}	errorResp
+//	@Failure		404					{object}	errorResp
+//	@Failure		409					{object}	errorResp
+//	@Failure		422					{object}	errorResp
+//	@Failure		500					{object}	errorResp
+//	@Success		200					{object}	resp{data=invoiceResp}
+//	@Router			/invoices/{id}/issue [post]
+//	@Router			/invoices/{id}/void [post]
+func changeInvoiceStatus(change func(ctx context.Context, by billing.Actor, id int64, reason string) (*billing.InvoiceHeader, error)) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID invoice",
+			})
+		}
+		req := invoiceStatusReq{}
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return errorJSON(c, http.StatusBadRequest, detailsResp{
+					Code:    "002",
+					Message: "The JSON structure is not correct",
+					Details: "Check the JSON syntax in the structure",
+				})
+			}
+		}
+		p := principalFrom(c)
+		by := billing.Actor{UserID: p.UserID, ServiceAccountID: p.ServiceAccountID}
+		header, err := change(ctx, by, int64(id), req.Reason)
+		if errors.Is(err, billing.ErrInvoiceHeaderNotFound) {
+			return errorJSON(c, http.StatusNotFound, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		if errors.Is(err, billing.ErrInvalidTransition) || errors.Is(err, billing.ErrInvoiceImmutable) ||
+			errors.Is(err, billing.ErrInvoiceCredited) {
+			return errorJSON(c, http.StatusConflict, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		if errors.Is(err, billing.ErrExchangeRateNotFound) {
+			return errorJSON(c, http.StatusUnprocessableEntity, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			logger.Error("change invoice status", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The invoice status could not be changed",
+			})
+		}
+		logger.Info("change invoice status", "invoice", header.ID, "status", header.Status)
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Invoice " + strings.ReplaceAll(string(header.Status), "_", " "),
+			Data:    toInvoiceResp(&billing.Invoice{Header: header}),
+		})
+	}
+}
+
+// invoiceStatusReq optional reason of a status change, kept in the history.
+type invoiceStatusReq struct {
+	Reason string `json:"reason,omitempty" example:"Duplicated"`
+}
+
+// invoiceHistory godoc
+//
+//	@Summary		Invoice status history
+//	@Description	Get the status changes of an invoice, who made them and when, oldest first
+//	@Tags			billing
+//	@Produce		json
+//	@Param			id	path		int	true	"Invoice id"
+//	@Failure		400	{object}	errorResp
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		404	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=[]statusChangeResp}
+//	@Router			/invoices/{id}/history [get]
+func invoiceHistory(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID invoice",
+			})
+		}
+		changes, err := svcs.Billing.History(ctx, int64(id))
+		if errors.Is(err, billing.ErrInvoiceHeaderNotFound) {
+			return errorJSON(c, http.StatusNotFound, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			logger.Error("invoice history", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The invoice history could not be read",
+			})
+		}
+		list := make([]statusChangeResp, 0, len(changes))
+		for _, ch := range changes {
+			list = append(list, statusChangeResp{
+				From:                      string(ch.From),
+				To:                        string(ch.To),
+				ChangedByUserID:           ch.By.UserID,
+				ChangedByServiceAccountID: ch.By.ServiceAccountID,
+				Reason:                    ch.Reason,
+				ChangedAt:                 ch.ChangedAt,
+			})
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    list,
+		})
+	}
+}
+
+// statusChangeResp a change in the status history of an invoice.
+type statusChangeResp struct {
+	From                      string    `json:"from" example:"draft"`
+	To                        string    `json:"to" example:"issued"`
+	ChangedByUserID           int64     `json:"changedByUserId,omitempty"`
+	ChangedByServiceAccountID int64     `json:"changedByServiceAccountId,omitempty"`
+	Reason                    string    `json:"reason,omitempty"`
+	ChangedAt                 time.Time `json:"changedAt"`
+}
+
+// payInvoice godoc
+//
+//	@Summary		Pay invoice
+//	@Description	Record a payment of the client of the invoice. Without allocations it's applied to the invoice, else to the invoices given, of the same client. What isn't allocated is credit of the client. The invoices paid change to partially paid or paid
+//	@Tags			billing
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int			true	"Invoice id"
+//	@Param			paymentReq	body		paymentReq	true	"application/json"
+//	@Failure		400			{object}	errorResp
+//	@Failure		401			{object}	errorResp
+//	@Failure		403			{object}	errorResp
+//	@Failure		404			{object}	errorResp
+//	@Failure		409			{object}	errorResp
+//	@Failure		500			{object}	errorResp
+//	@Success		201			{object}	resp{data=paymentResp}
+//	@Router			/invoices/{id}/payments [post]
+func payInvoice(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID invoice",
+			})
+		}
+		req := paymentReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		p := &billing.Payment{
+			Amount:     req.Amount,
+			Method:     billing.PaymentMethod(req.Method),
+			Reference:  req.Reference,
+			ReceivedAt: time.Now(),
+		}
+		if req.ReceivedAt != nil {
+			p.ReceivedAt = *req.ReceivedAt
+		}
+		for _, a := range req.Allocations {
+			p.Allocations = append(p.Allocations, billing.Allocation{
+				InvoiceID: a.InvoiceID,
+				Amount:    a.Amount,
+			})
+		}
+		principal := principalFrom(c)
+		by := billing.Actor{UserID: principal.UserID, ServiceAccountID: principal.ServiceAccountID}
+		if err := svcs.Billing.Pay(ctx, by, int64(id), p); err != nil {
+			return paymentError(c, err)
+		}
+		logger.Info("pay invoice", "payment", p.ID, "invoice", id, "amount", p.Amount)
+		return respJSON(c, http.StatusCreated, detailsResp{
+			Message: "Payment recorded",
+			Data:    toPaymentResp(p),
+		})
+	}
+}
+
+// paymentReq a payment received, the amounts are in minor units of the
+// currency of the invoices it pays (e.g. cents).
+type paymentReq struct {
+	Amount      int64           `json:"amount" example:"5000"`
+	Method      string          `json:"method" example:"transfer"` // cash, transfer, card, check or other
+	Reference   string          `json:"reference,omitempty" example:"TRX-20240131-0042"`
+	ReceivedAt  *time.Time      `json:"receivedAt,omitempty"` // now if omitted
+	Allocations []allocationReq `json:"allocations,omitempty"`
+}
+
+// allocationReq amount of a payment applied to an invoice.
+type allocationReq struct {
+	InvoiceID int64 `json:"invoiceId"`
+	Amount    int64 `json:"amount" example:"2040"`
+}
+
+// paymentResp a payment with its allocations and the credit left.
+type paymentResp struct {
+	ID          int64           `json:"id"`
+	ClientID    int64           `json:"clientId"`
+	Amount      int64           `json:"amount" example:"5000"`
+	Currency    string          `json:"currency" example:"EUR"` // of its invoices
+	Method      string          `json:"method" example:"transfer"`
+	Reference   string          `json:"reference,omitempty" example:"TRX-20240131-0042"`
+	ReceivedAt  time.Time       `json:"receivedAt"`
+	Allocations []allocationReq `json:"allocations"`
+	Credit      int64           `json:"credit" example:"2960"` // not allocated
+	CreatedAt   time.Time       `json:"createdAt"`
+}
+
+// toPaymentResp converts a billing.Payment to its response.
+func toPaymentResp(p *billing.Payment) paymentResp {
+	allocs := make([]allocationReq, 0, len(p.Allocations))
+	for _, a := range p.Allocations {
+		allocs = append(allocs, allocationReq{InvoiceID: a.InvoiceID, Amount: a.Amount})
+	}
+	return paymentResp{
+		ID:          p.ID,
+		ClientID:    p.ClientID,
+		Amount:      p.Amount,
+		Currency:    string(p.Currency),
+		Method:      string(p.Method),
+		Reference:   p.Reference,
+		ReceivedAt:  p.ReceivedAt,
+		Allocations: allocs,
+		Credit:      p.Credit(),
+		CreatedAt:   p.CreatedAt,
+	}
+}
+
+// paymentError responds the errors of recording a payment.
+func paymentError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, billing.ErrPaymentAmount),
+		errors.Is(err, billing.ErrInvalidPaymentMethod),
+		errors.Is(err, billing.ErrReceivedAtCantBeEmpty),
+		errors.Is(err, billing.ErrAllocationAmount),
+		errors.Is(err, billing.ErrDuplicateAllocation),
+		errors.Is(err, billing.ErrAllocationExceedsPayment),
+		errors.Is(err, billing.ErrAllocationExceedsBalance),
+		errors.Is(err, billing.ErrPaymentClientMismatch),
+		errors.Is(err, billing.ErrPaymentCurrencyMismatch),
+		errors.Is(err, billing.ErrAmountOverflow):
+		return errorJSON(c, http.StatusBadRequest, detailsResp{
+			Code:    "002",
+			Message: err.Error(),
+		})
+	case errors.Is(err, billing.ErrInvoiceHeaderNotFound):
+		return errorJSON(c, http.StatusNotFound, detailsResp{
+			Code:    "003",
+			Message: err.Error(),
+		})
+	case errors.Is(err, billing.ErrInvoiceNotPayable):
+		return errorJSON(c, http.StatusConflict, detailsResp{
+			Code:    "003",
+			Message: err.Error(),
+		})
+	}
+	logger.Error("pay invoice", "err", err.Error())
+	return errorJSON(c, http.StatusInternalServerError, detailsResp{
+		Code:    "003",
+		Message: "The payment could not be recorded",
+	})
+}
+
+// customerBalance godoc
+//
+//	@Summary		Customer balance
+//	@Description	Get what a customer has been invoiced, credited, has paid and still owes, and the credit it holds from overpayments, of the invoices in a currency. The amounts are in minor units of it
+//	@Tags			billing
+//	@Produce		json
+//	@Param			id			path		int		true	"Customer id, the client of the invoices"
+//	@Param			currency	query		string	false	"ISO 4217 code, the base currency if omitted"
+//	@Failure		400	{object}	errorResp
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=balanceResp}
+//	@Router			/customers/{id}/balance [get]
+func customerBalance(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID customer",
+			})
+		}
+		currency, err := queryCurrency(c)
+		if err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		b, err := svcs.Billing.Balance(ctx, int64(id), currency)
+		if err != nil {
+			logger.Error("customer balance", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The balance could not be read",
+			})
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data: balanceResp{
+				CustomerID:  b.ClientID,
+				Currency:    string(b.Currency),
+				Invoiced:    b.Invoiced,
+				Credited:    b.Credited,
+				Paid:        b.Paid,
+				Outstanding: b.Outstanding,
+				Credit:      b.Credit,
+			},
+		})
+	}
+}
+
+// balanceResp balance of a customer, in minor units of the currency.
+type balanceResp struct {
+	CustomerID  int64  `json:"customerId"`
+	Currency    string `json:"currency" example:"EUR"`
+	Invoiced    int64  `json:"invoiced" example:"10000"`
+	Credited    int64  `json:"credited" example:"0"`
+	Paid        int64  `json:"paid" example:"7000"`
+	Outstanding int64  `json:"outstanding" example:"3000"`
+	Credit      int64  `json:"credit" example:"500"`
+}
+
+// creditInvoice godoc
+//
+//	@Summary		Credit invoice
+//	@Description	Create a credit note that reverses an issued invoice, in full if no items are given, else the quantities of its items given. It lowers what the customer owes, an invoice settled by it changes to paid
+//	@Tags			billing
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int				true	"Invoice id"
+//	@Param			creditNoteReq	body		creditNoteReq	true	"application/json"
+//	@Failure		400				{object}	errorResp
+//	@Failure		401				{object}	errorResp
+//	@Failure		403				{object}	errorResp
+//	@Failure		404				{object}	errorResp
+//	@Failure		409				{object}	errorResp
+//	@Failure		500				{object}	errorResp
+//	@Success		201				{object}	resp{data=creditNoteResp}
+//	@Router			/invoices/{id}/credit-notes [post]
+func creditInvoice(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID invoice",
+			})
+		}
+		req := creditNoteReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		cn := &billing.CreditNote{Reason: req.Reason}
+		for _, it := range req.Items {
+			cn.Items = append(cn.Items, billing.CreditNoteItem{
+				InvoiceItemID: it.InvoiceItemID,
+				Quantity:      it.Quantity,
+			})
+		}
+		p := principalFrom(c)
+		by := billing.Actor{UserID: p.UserID, ServiceAccountID: p.ServiceAccountID}
+		err = svcs.Billing.Credit(ctx, by, int64(id), cn)
+		switch {
+		case errors.Is(err, billing.ErrCreditReasonCantBeEmpty),
+			errors.Is(err, billing.ErrCreditItemNotFound),
+			errors.Is(err, billing.ErrCreditQuantity),
+			errors.Is(err, billing.ErrCreditExceedsInvoice),
+			errors.Is(err, billing.ErrAmountOverflow):
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		case errors.Is(err, billing.ErrInvoiceHeaderNotFound):
+			return errorJSON(c, http.StatusNotFound, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		case errors.Is(err, billing.ErrInvoiceNotCreditable), errors.Is(err, billing.ErrNothingToCredit):
+			return errorJSON(c, http.StatusConflict, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		case err != nil:
+			logger.Error("credit invoice", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The credit note could not be created",
+			})
+		}
+		logger.Info("credit invoice", "invoice", id, "creditNote", cn.Number)
+		return respJSON(c, http.StatusCreated, detailsResp{
+			Message: "Credit note created",
+			Data:    toCreditNoteResp(cn),
+		})
+	}
+}
+
+// creditNoteReq reason and lines of a credit note, all the invoice if
+// there are no items.
+type creditNoteReq struct {
+	Reason string              `json:"reason" example:"Damaged goods returned"`
+	Items  []creditNoteItemReq `json:"items,omitempty"`
+}
+
+// creditNoteItemReq quantity of an invoice item to credit.
+type creditNoteItemReq struct {
+	InvoiceItemID int64 `json:"invoiceItemId"`
+	Quantity      int   `json:"quantity" example:"1"`
+}
+
+// creditNoteResp credit note with its totals and items, the amounts are in
+// minor units of the currency of its invoice (e.g. cents).
+type creditNoteResp struct {
+	ID        int64                `json:"id"`
+	UUID      string               `json:"uuid"`
+	Number    string               `json:"number" example:"CN-2024-000001"`
+	InvoiceID int64                `json:"invoiceId"`
+	ClientID  int64                `json:"clientId"`
+	Reason    string               `json:"reason" example:"Damaged goods returned"`
+	Currency  string               `json:"currency" example:"MXN"`
+	Subtotal  int64                `json:"subtotal" example:"1000"`
+	Discount  int64                `json:"discount" example:"250"`
+	Tax       int64                `json:"tax" example:"120"`
+	Total     int64                `json:"total" example:"870"`
+	Items     []creditNoteItemResp `json:"items,omitempty"` // omitted in lists
+	CreatedAt time.Time            `json:"createdAt"`
+}
+
+// creditNoteItemResp line of a credit note.
+type creditNoteItemResp struct {
+	ID            int64  `json:"id"`
+	InvoiceItemID int64  `json:"invoiceItemId"`
+	ProductID     int64  `json:"productId"`
+	ProductName   string `json:"productName" example:"Coca-Cola"`
+	Quantity      int    `json:"quantity" example:"1"`
+	UnitPrice     int64  `json:"unitPrice" example:"1000"`
+	TaxRate       int    `json:"taxRate" example:"1600"`
+	Subtotal      int64  `json:"subtotal" example:"1000"`
+	Discount      int64  `json:"discount" example:"250"`
+	Tax           int64  `json:"tax" example:"120"`
+	Total         int64  `json:"total" example:"870"`
+}
+
+// toCreditNoteResp converts a billing.CreditNote to its response.
+func toCreditNoteResp(cn *billing.CreditNote) creditNoteResp {
+	var items []creditNoteItemResp
+	for _, it := range cn.Items {
+		items = append(items, creditNoteItemResp{
+			ID:            it.ID,
+			InvoiceItemID: it.InvoiceItemID,
+			ProductID:     it.ProductID,
+			ProductName:   it.ProductName,
+			Quantity:      it.Quantity,
+			UnitPrice:     it.UnitPrice.Amount,
+			TaxRate:       it.TaxRate,
+			Subtotal:      it.Subtotal,
+			Discount:      it.Discount,
+			Tax:           it.Tax,
+			Total:         it.Total,
+		})
+	}
+	return creditNoteResp{
+		ID:        cn.ID,
+		UUID:      cn.UUID,
+		Number:    cn.Number,
+		InvoiceID: cn.InvoiceID,
+		ClientID:  cn.ClientID,
+		Reason:    cn.Reason,
+		Currency:  string(cn.Currency),
+		Subtotal:  cn.Subtotal,
+		Discount:  cn.Discount,
+		Tax:       cn.Tax,
+		Total:     cn.Total,
+		Items:     items,
+		CreatedAt: cn.CreatedAt,
+	}
+}
+
+// listInvoiceCreditNotes godoc
+//
+//	@Summary		List invoice credit notes
+//	@Description	Get the credit notes of an invoice, oldest first
+//	@Tags			billing
+//	@Produce		json
+//	@Param			id	path		int	true	"Invoice id"
+//	@Failure		400	{object}	errorResp
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		404	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=[]creditNoteResp}
+//	@Router			/invoices/{id}/credit-notes [get]
+func listInvoiceCreditNotes(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID invoice",
+			})
+		}
+		cns, err := svcs.Billing.CreditNotes(ctx, int64(id))
+		if errors.Is(err, billing.ErrInvoiceHeaderNotFound) {
+			return errorJSON(c, http.StatusNotFound, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			logger.Error("list credit notes", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The credit notes could not be listed",
+			})
+		}
+		list := make([]creditNoteResp, 0, len(cns))
+		for i := range cns {
+			list = append(list, toCreditNoteResp(&cns[i]))
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    list,
+		})
+	}
+}
+
+// findCreditNote godoc
+//
+//	@Summary		Find credit note
+//	@Description	Get a credit note with its items, or with format=ubl as a UBL 2.1 Peppol BIS Billing 3.0 document
+//	@Tags			billing
+//	@Produce		json
+//	@Produce		xml
+//	@Param			id		path		int		true	"Credit note id"
+//	@Param			format	query		string	false	"ubl for the UBL document"
+//	@Failure		400		{object}	errorResp
+//	@Failure		401		{object}	errorResp
+//	@Failure		403		{object}	errorResp
+//	@Failure		404		{object}	errorResp
+//	@Failure		422		{object}	errorResp
+//	@Failure		500		{object}	errorResp
+//	@Success		200		{object}	resp{data=creditNoteResp}
+//	@Router			/credit-notes/{id} [get]
+func findCreditNote(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID credit note",
+			})
+		}
+		cn, err := svcs.Billing.FindCreditNote(ctx, int64(id))
+		if errors.Is(err, billing.ErrCreditNoteNotFound) {
+			return errorJSON(c, http.StatusNotFound, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			logger.Error("find credit note", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The credit note could not be read",
+			})
+		}
+		if c.Query("format") == "ubl" {
+			invoice, err := svcs.Billing.Find(ctx, cn.InvoiceID)
+			if err != nil {
+				logger.Error("find credit note", "err", err.Error())
+				return errorJSON(c, http.StatusInternalServerError, detailsResp{
+					Code:    "003",
+					Message: "The invoice of the credit note could not be read",
+				})
+			}
+			return sendUBL(c, invoice.Header.BillTo, cn.Number, func(b ubl.Buyer) ([]byte, error) {
+				return svcs.UBL.CreditNote(cn, invoice, b)
+			})
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    toCreditNoteResp(cn),
+		})
+	}
 }
@@ -0,0 +1,362 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/compose"
+	"github.com/adrianolmedo/genesis/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// setPaymentTerms godoc
+//
+//	@Summary		Set payment terms
+//	@Description	Set the days after their date the invoices of a customer are due, of the invoices generated from now
+//	@Tags			dunning
+//	@Accept			json
+//	@Produce		json
+//	@Param			id					path		int					true	"Customer id, the client of the invoices"
+//	@Param			paymentTermsReq		body		paymentTermsReq		true	"application/json"
+//	@Failure		400					{object}	errorResp
+//	@Failure		401					{object}	errorResp
+//	@Failure		403					{object}	errorResp
+//	@Failure		500					{object}	errorResp
+//	@Success		200					{object}	resp{data=paymentTermsResp}
+//	@Router			/customers/{id}/payment-terms [put]
+func setPaymentTerms(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID customer",
+			})
+		}
+		req := paymentTermsReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		t := &billing.PaymentTerms{ClientID: int64(id), NetDays: req.NetDays}
+		err = svcs.Billing.SetPaymentTerms(c.UserContext(), t)
+		switch {
+		case errors.Is(err, billing.ErrClientIDCantBeEmpty), errors.Is(err, billing.ErrInvalidNetDays):
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		case err != nil:
+			logger.Error("set payment terms", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The payment terms could not be set",
+			})
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Payment terms set",
+			Data:    paymentTermsResp{CustomerID: t.ClientID, NetDays: t.NetDays},
+		})
+	}
+}
+
+// paymentTermsReq fields to set the payment terms of a customer.
+type paymentTermsReq struct {
+	NetDays int `json:"netDays" example:"30"` // 0 to 365
+}
+
+// paymentTermsResp payment terms of a customer.
+type paymentTermsResp struct {
+	CustomerID int64 `json:"customerId"`
+	NetDays    int   `json:"netDays" example:"30"`
+}
+
+// findPaymentTerms godoc
+//
+//	@Summary		Payment terms
+//	@Description	Get the payment terms of a customer, the default ones if it has none
+//	@Tags			dunning
+//	@Produce		json
+//	@Param			id	path		int	true	"Customer id, the client of the invoices"
+//	@Failure		400	{object}	errorResp
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=paymentTermsResp}
+//	@Router			/customers/{id}/payment-terms [get]
+func findPaymentTerms(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID customer",
+			})
+		}
+		t, err := svcs.Billing.PaymentTerms(c.UserContext(), int64(id))
+		if err != nil {
+			logger.Error("find payment terms", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The payment terms could not be read",
+			})
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    paymentTermsResp{CustomerID: t.ClientID, NetDays: t.NetDays},
+		})
+	}
+}
+
+// createDunningStep godoc
+//
+//	@Summary		Create dunning step
+//	@Description	Add a step to the dunning policy: a reminder of the invoices unpaid the days given after their due date, with an optional late fee of a fixed amount plus a rate of the amount due, invoiced with the product given
+//	@Tags			dunning
+//	@Accept			json
+//	@Produce		json
+//	@Param			dunningStepReq	body		dunningStepReq	true	"application/json"
+//	@Failure		400				{object}	errorResp
+//	@Failure		401				{object}	errorResp
+//	@Failure		403				{object}	errorResp
+//	@Failure		409				{object}	errorResp
+//	@Failure		422				{object}	errorResp
+//	@Failure		500				{object}	errorResp
+//	@Success		201				{object}	resp{data=dunningStepResp}
+//	@Router			/dunning-steps [post]
+func createDunningStep(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req := dunningStepReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		s := &billing.DunningStep{
+			DaysAfterDue: req.DaysAfterDue,
+			LateFee:      req.LateFee,
+			LateFeeRate:  req.LateFeeRate,
+			FeeProductID: req.FeeProductID,
+		}
+		err := svcs.Billing.CreateDunningStep(c.UserContext(), s)
+		switch {
+		case errors.Is(err, billing.ErrInvalidDaysAfterDue),
+			errors.Is(err, billing.ErrInvalidLateFee),
+			errors.Is(err, billing.ErrInvalidLateFeeRate),
+			errors.Is(err, billing.ErrFeeProductCantBeEmpty):
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		case errors.Is(err, billing.ErrFeeProductNotFound):
+			return errorJSON(c, http.StatusUnprocessableEntity, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		case errors.Is(err, billing.ErrDunningStepExists):
+			return errorJSON(c, http.StatusConflict, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		case err != nil:
+			logger.Error("create dunning step", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The dunning step could not be created",
+			})
+		}
+		return respJSON(c, http.StatusCreated, detailsResp{
+			Message: "Dunning step created",
+			Data:    toDunningStepResp(*s),
+		})
+	}
+}
+
+// dunningStepReq fields to create a step of the dunning policy, the
+// amounts are in minor units of the currency.
+type dunningStepReq struct {
+	DaysAfterDue int   `json:"daysAfterDue" example:"14"`
+	LateFee      int64 `json:"lateFee,omitempty" example:"500"`
+	LateFeeRate  int   `json:"lateFeeRate,omitempty" example:"150"` // basis points of the amount due
+	FeeProductID int64 `json:"feeProductId,omitempty"`              // required with a late fee
+}
+
+// dunningStepResp a step of the dunning policy.
+type dunningStepResp struct {
+	ID           int64     `json:"id"`
+	DaysAfterDue int       `json:"daysAfterDue" example:"14"`
+	LateFee      int64     `json:"lateFee" example:"500"`
+	LateFeeRate  int       `json:"lateFeeRate" example:"150"`
+	FeeProductID int64     `json:"feeProductId,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// toDunningStepResp converts a billing.DunningStep to its response.
+func toDunningStepResp(s billing.DunningStep) dunningStepResp {
+	return dunningStepResp{
+		ID:           s.ID,
+		DaysAfterDue: s.DaysAfterDue,
+		LateFee:      s.LateFee,
+		LateFeeRate:  s.LateFeeRate,
+		FeeProductID: s.FeeProductID,
+		CreatedAt:    s.CreatedAt,
+	}
+}
+
+// listDunningSteps godoc
+//
+//	@Summary		List dunning steps
+//	@Description	Get the steps of the dunning policy, by days after due
+//	@Tags			dunning
+//	@Produce		json
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=[]dunningStepResp}
+//	@Router			/dunning-steps [get]
+func listDunningSteps(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		steps, err := svcs.Billing.DunningSteps(c.UserContext())
+		if err != nil {
+			logger.Error("list dunning steps", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The dunning steps could not be read",
+			})
+		}
+		list := make([]dunningStepResp, 0, len(steps))
+		for _, s := range steps {
+			list = append(list, toDunningStepResp(s))
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    list,
+		})
+	}
+}
+
+// deleteDunningStep godoc
+//
+//	@Summary		Delete dunning step
+//	@Description	Remove a step from the dunning policy, the reminders already queued of it are sent
+//	@Tags			dunning
+//	@Produce		json
+//	@Param			id	path		int	true	"Dunning step id"
+//	@Failure		400	{object}	errorResp
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		404	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp
+//	@Router			/dunning-steps/{id} [delete]
+func deleteDunningStep(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID dunning step",
+			})
+		}
+		err = svcs.Billing.DeleteDunningStep(c.UserContext(), int64(id))
+		if errors.Is(err, billing.ErrDunningStepNotFound) {
+			return errorJSON(c, http.StatusNotFound, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			logger.Error("delete dunning step", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The dunning step could not be deleted",
+			})
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Dunning step deleted",
+		})
+	}
+}
+
+// listInvoiceReminders godoc
+//
+//	@Summary		List invoice reminders
+//	@Description	Get the reminders of an overdue invoice, sent or to send, with the amount due and the late fee of each one
+//	@Tags			dunning
+//	@Produce		json
+//	@Param			id	path		int	true	"Invoice id"
+//	@Failure		400	{object}	errorResp
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		404	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=[]reminderResp}
+//	@Router			/invoices/{id}/reminders [get]
+func listInvoiceReminders(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID invoice",
+			})
+		}
+		reminders, err := svcs.Billing.Reminders(c.UserContext(), int64(id))
+		if errors.Is(err, billing.ErrInvoiceHeaderNotFound) {
+			return errorJSON(c, http.StatusNotFound, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			logger.Error("list invoice reminders", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The reminders could not be read",
+			})
+		}
+		list := make([]reminderResp, 0, len(reminders))
+		for _, r := range reminders {
+			list = append(list, reminderResp{
+				ID:           r.ID,
+				DaysAfterDue: r.DaysAfterDue,
+				AmountDue:    r.AmountDue,
+				LateFee:      r.LateFee,
+				FeeInvoiceID: r.FeeInvoiceID,
+				Status:       string(r.Status),
+				Attempts:     r.Attempts,
+				LastError:    r.LastError,
+				CreatedAt:    r.CreatedAt,
+				SentAt:       r.SentAt,
+			})
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    list,
+		})
+	}
+}
+
+// reminderResp a reminder of an invoice, the amounts are in minor units of
+// the currency and those when it was queued.
+type reminderResp struct {
+	ID           int64      `json:"id"`
+	DaysAfterDue int        `json:"daysAfterDue" example:"14"`
+	AmountDue    int64      `json:"amountDue" example:"3000"`
+	LateFee      int64      `json:"lateFee" example:"545"`
+	FeeInvoiceID int64      `json:"feeInvoiceId,omitempty"`
+	Status       string     `json:"status" example:"sent"`
+	Attempts     int        `json:"attempts" example:"1"`
+	LastError    string     `json:"lastError,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	SentAt       *time.Time `json:"sentAt,omitempty"`
+}
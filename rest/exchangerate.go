@@ -0,0 +1,140 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/compose"
+	"github.com/adrianolmedo/genesis/logger"
+	"github.com/adrianolmedo/genesis/money"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// createExchangeRate godoc
+//
+//	@Summary		Create exchange rate
+//	@Description	Create the rate of a currency to another one from a date, until the next rate of the pair. The invoices are converted to the base currency at the rate valid when they're issued, a pair is also read inverted
+//	@Tags			billing
+//	@Accept			json
+//	@Produce		json
+//	@Param			exchangeRateReq	body		exchangeRateReq	true	"application/json"
+//	@Failure		400				{object}	errorResp
+//	@Failure		401				{object}	errorResp
+//	@Failure		403				{object}	errorResp
+//	@Failure		409				{object}	errorResp
+//	@Failure		500				{object}	errorResp
+//	@Success		201				{object}	resp{data=exchangeRateResp}
+//	@Router			/exchange-rates [post]
+func createExchangeRate(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		req := exchangeRateReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		from := money.Currency(strings.ToUpper(req.From))
+		to := money.Currency(strings.ToUpper(req.To))
+		rate, err := money.ParseRate(from, to, req.Rate)
+		if err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		e := &billing.ExchangeRate{Rate: rate, ValidFrom: req.ValidFrom}
+		err = svcs.Billing.CreateExchangeRate(ctx, e)
+		switch {
+		case errors.Is(err, billing.ErrSameCurrency), errors.Is(err, billing.ErrValidFromCantBeEmpty):
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		case errors.Is(err, billing.ErrExchangeRateExists):
+			return errorJSON(c, http.StatusConflict, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		case err != nil:
+			logger.Error("create exchange rate", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The exchange rate could not be created",
+			})
+		}
+		return respJSON(c, http.StatusCreated, detailsResp{
+			Message: "Exchange rate created",
+			Data:    toExchangeRateResp(*e),
+		})
+	}
+}
+
+// exchangeRateReq fields to create an exchange rate, a unit of From is
+// worth Rate units of To.
+type exchangeRateReq struct {
+	From      string    `json:"from" example:"EUR"`
+	To        string    `json:"to" example:"USD"`
+	Rate      string    `json:"rate" example:"1.0825"` // up to 8 decimals
+	ValidFrom time.Time `json:"validFrom"`
+}
+
+// exchangeRateResp an exchange rate.
+type exchangeRateResp struct {
+	ID        int64     `json:"id"`
+	From      string    `json:"from" example:"EUR"`
+	To        string    `json:"to" example:"USD"`
+	Rate      string    `json:"rate" example:"1.08250000"`
+	ValidFrom time.Time `json:"validFrom"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// toExchangeRateResp converts a billing.ExchangeRate to its response.
+func toExchangeRateResp(e billing.ExchangeRate) exchangeRateResp {
+	return exchangeRateResp{
+		ID:        e.ID,
+		From:      string(e.Rate.From),
+		To:        string(e.Rate.To),
+		Rate:      e.Rate.Decimal(),
+		ValidFrom: e.ValidFrom,
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+// listExchangeRates godoc
+//
+//	@Summary		List exchange rates
+//	@Description	Get the exchange rates of all the currencies, the latest of each pair first
+//	@Tags			billing
+//	@Produce		json
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=[]exchangeRateResp}
+//	@Router			/exchange-rates [get]
+func listExchangeRates(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rates, err := svcs.Billing.ExchangeRates(c.UserContext())
+		if err != nil {
+			logger.Error("list exchange rates", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The exchange rates could not be read",
+			})
+		}
+		list := make([]exchangeRateResp, 0, len(rates))
+		for _, e := range rates {
+			list = append(list, toExchangeRateResp(e))
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    list,
+		})
+	}
+}
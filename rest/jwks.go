@@ -0,0 +1,25 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/adrianolmedo/genesis/rest/jwt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// jwks godoc
+//
+//	@Summary		JSON Web Key Set
+//	@Description	Get the public keys that verify the access tokens, identified by the kid header of the token
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	jwt.JWKSet
+//	@Router			/.well-known/jwks.json [get]
+func jwks() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Keys are rotated, clients must refresh the set on an unknown kid.
+		c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+		return c.Status(http.StatusOK).JSON(jwt.JWKS())
+	}
+}
@@ -1,103 +1,121 @@
 package jwt
 
 import (
-	"crypto/rsa"
+	"context"
 	"errors"
 	"os"
-	"sync"
+	"slices"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var (
-	privateKey *rsa.PrivateKey
-	publicKey  *rsa.PublicKey
-	once       sync.Once
-)
+// AccessTokenTTL is the lifetime of an access token, sessions are extended
+// with refresh tokens.
+const AccessTokenTTL = 15 * time.Minute
+
+// ring is the key ring in use, set with Use.
+var ring atomic.Pointer[KeyRing]
+
+func init() {
+	ring.Store(NewKeyRing())
+}
 
-// Claims based on user email as token value.
+// Claims based on user ID and email as token value, with the roles and
+// permissions of the user at the time the token was issued.
 type Claims struct {
-	Email string `json:"email"`
+	UserID      int64    `json:"uid"`
+	Email       string   `json:"email"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// Generate signed token from user's email.
-func Generate(userEmail string) (string, error) {
-	claims := Claims{
-		Email: userEmail,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 2)),
-			Issuer:    "genesis",
-		},
-	}
-	t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	signedToken, err := t.SignedString(privateKey)
-	if err != nil {
-		return "", err
-	}
-	return signedToken, nil
+// HasPermission reports whether the token grants permission.
+func (c Claims) HasPermission(permission string) bool {
+	return slices.Contains(c.Permissions, permission)
 }
 
-// Verify signed token.
-func Verify(token string) (Claims, error) {
-	t, err := jwt.ParseWithClaims(token, &Claims{}, verify)
-	if err != nil {
-		return Claims{}, err
-	}
-	if !t.Valid {
-		return Claims{}, errors.New("invalid token")
-	}
-	claims, ok := t.Claims.(*Claims)
-	if !ok {
-		return Claims{}, errors.New("the claims could not be obtained")
-	}
-	return *claims, nil
+// claimsKey is the context key of the verified Claims.
+type claimsKey struct{}
+
+// NewContext returns a copy of ctx carrying the verified claims.
+func NewContext(ctx context.Context, c Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, c)
 }
 
-func verify(t *jwt.Token) (any, error) {
-	return publicKey, nil
+// FromContext returns the verified claims carried by ctx.
+func FromContext(ctx context.Context) (Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(Claims)
+	return c, ok
 }
 
-// LoadFiles read RSA files. Ensures that it can only be executed once.
-func LoadFiles(privateFile, publicFile string) error {
-	var err error
+// Use sets the key ring of Generate, Verify and JWKS.
+func Use(r *KeyRing) {
+	ring.Store(r)
+}
 
-	once.Do(func() {
-		err = loadFiles(privateFile, publicFile)
-	})
-	return err
+// Ring returns the key ring in use.
+func Ring() *KeyRing {
+	return ring.Load()
 }
 
-func loadFiles(private, public string) error {
+// Generate signed token from the user's claims with the key ring in use.
+func Generate(claims Claims) (string, error) {
+	return Ring().Generate(claims)
+}
+
+// Verify signed token with the key ring in use.
+func Verify(token string) (Claims, error) {
+	return Ring().Verify(token)
+}
+
+// JWKS returns the public keys of the key ring in use.
+func JWKS() JWKSet {
+	return Ring().JWKS()
+}
+
+// LoadFiles read RSA files and adds the key to the key ring in use.
+func LoadFiles(privateFile, publicFile string) error {
 	// With ioutil.ReadFile(s) only reads the content
 	// and you don't worry to close the resource with defer.
-	privateBytes, err := os.ReadFile(private)
+	privateBytes, err := os.ReadFile(privateFile)
 	if err != nil {
 		return err
 	}
-	publicBytes, err := os.ReadFile(public)
+	publicBytes, err := os.ReadFile(publicFile)
 	if err != nil {
 		return err
 	}
-	return parseRSA(privateBytes, publicBytes)
+	info, err := os.Stat(privateFile)
+	if err != nil {
+		return err
+	}
+	return parseRSA(privateBytes, publicBytes, info.ModTime())
 }
 
-// ParseRSA parse RSA by its keys.
+// ParseRSA parse RSA by its keys and adds it to the key ring in use.
 func ParseRSA(private, public string) error {
-	return parseRSA([]byte(private), []byte(public))
+	return parseRSA([]byte(private), []byte(public), time.Now())
 }
 
-func parseRSA(private, public []byte) error {
-	var err error
-
-	privateKey, err = jwt.ParseRSAPrivateKeyFromPEM(private)
+func parseRSA(private, public []byte, createdAt time.Time) error {
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(private)
 	if err != nil {
 		return err
 	}
-	publicKey, err = jwt.ParseRSAPublicKeyFromPEM(public)
+	publicKey, err := jwt.ParseRSAPublicKeyFromPEM(public)
+	if err != nil {
+		return err
+	}
+	if !privateKey.PublicKey.Equal(publicKey) {
+		return errors.New("the RSA public key doesn't match the private key")
+	}
+	k, err := NewKey(privateKey, createdAt)
 	if err != nil {
 		return err
 	}
+	Ring().Add(k)
 	return nil
 }
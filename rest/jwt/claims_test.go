@@ -36,10 +36,23 @@ DIhdpkWF5HTL9xPMKy4aUHcCorx8QC2sdDlGq6QyoMEiXA==
 )
 
 func TestClaims(t *testing.T) {
-	input := "example@gmail.com"
+	input := jwt.Claims{
+		Email:       "example@gmail.com",
+		Roles:       []string{"admin"},
+		Permissions: []string{"products:write"},
+	}
 	loadKeys(t)
 	token := genToken(t, input)
-	verifyClaims(t, token)
+	claims := verifyClaims(t, token)
+	if claims.Email != input.Email {
+		t.Errorf("want email %q, got %q", input.Email, claims.Email)
+	}
+	if !claims.HasPermission("products:write") {
+		t.Error("expected products:write permission")
+	}
+	if claims.HasPermission("users:write") {
+		t.Error("unexpected users:write permission")
+	}
 }
 
 // loadKeys read mocked credentials.
@@ -51,20 +64,24 @@ func loadKeys(t *testing.T) {
 	}
 }
 
-// genToken generate token from an email input.
-func genToken(t *testing.T, email string) (token string) {
+// genToken generate token from claims input.
+func genToken(t *testing.T, claims jwt.Claims) (token string) {
 	t.Helper()
-	token, err := jwt.Generate(email)
+	token, err := jwt.Generate(claims)
 	if err != nil {
 		t.Fatal(err)
 	}
 	return
 }
 
-func verifyClaims(t *testing.T, token string) {
+func verifyClaims(t *testing.T, token string) jwt.Claims {
 	t.Helper()
-	_, err := jwt.Verify(token)
+	claims, err := jwt.Verify(token)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if claims.ID == "" {
+		t.Error("expected token ID (jti)")
+	}
+	return claims
 }
@@ -0,0 +1,127 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// JWK is the public part of a key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC and OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json so other services
+// can verify the tokens.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the ring, newest first.
+func (r *KeyRing) JWKS() JWKSet {
+	keys := r.Keys()
+	set := JWKSet{Keys: make([]JWK, 0, len(keys))}
+	for i := len(keys) - 1; i >= 0; i-- {
+		jwk, err := newJWK(keys[i].Public)
+		if err != nil {
+			continue // the ring only holds supported keys
+		}
+		jwk.Kid = keys[i].ID
+		jwk.Use = "sig"
+		jwk.Alg = keys[i].Algorithm
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// newJWK returns the key parameters of public.
+func newJWK(public crypto.PublicKey) (JWK, error) {
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   b64(pub.N.Bytes()),
+			E:   b64(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		k, err := pub.ECDH()
+		if err != nil {
+			return JWK{}, fmt.Errorf("%w: %v", ErrUnsupportedKey, err)
+		}
+		// Uncompressed point: 0x04 || X || Y.
+		point := k.Bytes()[1:]
+		size := len(point) / 2
+		return JWK{
+			Kty: "EC",
+			Crv: pub.Curve.Params().Name,
+			X:   b64(point[:size]),
+			Y:   b64(point[size:]),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   b64(pub),
+		}, nil
+	}
+	return JWK{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, public)
+}
+
+// thumbprint returns the RFC 7638 thumbprint of public, used as key ID.
+func thumbprint(public crypto.PublicKey) (string, error) {
+	jwk, err := newJWK(public)
+	if err != nil {
+		return "", err
+	}
+	// The required members in lexicographic order, without whitespace.
+	var members any
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return b64(sum[:]), nil
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
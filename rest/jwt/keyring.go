@@ -0,0 +1,284 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/adrianolmedo/genesis/logger"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms supported by the key ring.
+const (
+	RS256 = "RS256"
+	ES256 = "ES256"
+	EdDSA = "EdDSA" // Ed25519
+)
+
+var (
+	ErrNoSigningKey         = errors.New("there is no signing key")
+	ErrUnknownKey           = errors.New("unknown key id")
+	ErrUnsupportedKey       = errors.New("unsupported key type")
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrRotationLocked       = errors.New("the keys directory is locked by another rotation")
+)
+
+// Key is a signing key of the ring. Keys loaded only to verify tokens have
+// a nil Private.
+type Key struct {
+	// ID is the kid header of the tokens signed with the key, the RFC 7638
+	// thumbprint of the public key.
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	Public    crypto.PublicKey
+	CreatedAt time.Time
+}
+
+// NewKey returns the Key of the private key, its algorithm is given by the
+// key type: RS256 for RSA, ES256 for ECDSA P-256 and EdDSA for Ed25519.
+func NewKey(private crypto.Signer, createdAt time.Time) (Key, error) {
+	k, err := NewPublicKey(private.Public(), createdAt)
+	if err != nil {
+		return Key{}, err
+	}
+	k.Private = private
+	return k, nil
+}
+
+// NewPublicKey returns a Key that can only verify tokens.
+func NewPublicKey(public crypto.PublicKey, createdAt time.Time) (Key, error) {
+	alg, err := algorithmOf(public)
+	if err != nil {
+		return Key{}, err
+	}
+	kid, err := thumbprint(public)
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{
+		ID:        kid,
+		Algorithm: alg,
+		Public:    public,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+func algorithmOf(public crypto.PublicKey) (string, error) {
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		return RS256, nil
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return "", fmt.Errorf("%w: ECDSA curve %s", ErrUnsupportedKey, pub.Curve.Params().Name)
+		}
+		return ES256, nil
+	case ed25519.PublicKey:
+		return EdDSA, nil
+	}
+	return "", fmt.Errorf("%w: %T", ErrUnsupportedKey, public)
+}
+
+func signingMethod(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case RS256:
+		return jwt.SigningMethodRS256, nil
+	case ES256:
+		return jwt.SigningMethodES256, nil
+	case EdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, alg)
+}
+
+// KeyRing holds the keys that sign and verify tokens. The newest key with a
+// private part signs new tokens, the older ones keep verifying the tokens
+// they signed until they are removed.
+type KeyRing struct {
+	mu         sync.RWMutex
+	keys       []Key // sorted by CreatedAt, oldest first
+	validation Validation
+
+	reloadMu       sync.Mutex
+	reload         func() error
+	reloadInterval time.Duration
+	reloadedAt     time.Time
+}
+
+// NewKeyRing creates a new KeyRing with keys and the DefaultValidation.
+func NewKeyRing(keys ...Key) *KeyRing {
+	r := &KeyRing{validation: DefaultValidation}
+	for _, k := range keys {
+		r.Add(k)
+	}
+	return r
+}
+
+// Add a key to the ring, replacing the one with the same ID.
+func (r *KeyRing) Add(k Key) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = slices.DeleteFunc(r.keys, func(old Key) bool {
+		return old.ID == k.ID
+	})
+	r.keys = append(r.keys, k)
+	slices.SortStableFunc(r.keys, func(a, b Key) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+}
+
+// SetValidation replaces the validation of the registered claims of the
+// tokens generated and verified.
+func (r *KeyRing) SetValidation(v Validation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.validation = v
+}
+
+// SetReload sets the reload of the keys of the ring, done when a token has
+// an unknown kid at most once per interval, e.g. to load a key rotated by
+// another process before the next rotation.
+func (r *KeyRing) SetReload(reload func() error, interval time.Duration) {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+	r.reload = reload
+	r.reloadInterval = interval
+}
+
+// reloadMissed reloads the keys after a kid miss, it reports whether they
+// were reloaded.
+func (r *KeyRing) reloadMissed() bool {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+	if r.reload == nil || time.Since(r.reloadedAt) < r.reloadInterval {
+		return false
+	}
+	r.reloadedAt = time.Now()
+	if err := r.reload(); err != nil {
+		logger.Error("signing keys reload", "err", err.Error())
+		return false
+	}
+	return true
+}
+
+// Validation returns the validation of the registered claims.
+func (r *KeyRing) Validation() Validation {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.validation
+}
+
+// Remove the key with ID kid from the ring, tokens signed with it are no
+// longer valid.
+func (r *KeyRing) Remove(kid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = slices.DeleteFunc(r.keys, func(k Key) bool {
+		return k.ID == kid
+	})
+}
+
+// Keys returns a copy of the keys of the ring, oldest first.
+func (r *KeyRing) Keys() []Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.keys)
+}
+
+// Current returns the key that signs new tokens.
+func (r *KeyRing) Current() (Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i := len(r.keys) - 1; i >= 0; i-- {
+		if r.keys[i].Private != nil {
+			return r.keys[i], nil
+		}
+	}
+	return Key{}, ErrNoSigningKey
+}
+
+// Lookup returns the key with ID kid.
+func (r *KeyRing) Lookup(kid string) (Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i := slices.IndexFunc(r.keys, func(k Key) bool {
+		return k.ID == kid
+	})
+	if i < 0 {
+		return Key{}, false
+	}
+	return r.keys[i], true
+}
+
+// Generate signed token from the user's claims with the current key. The
+// registered claims are set here following the Validation of the ring,
+// each token has a unique ID (jti) so it can be revoked before it expires.
+func (r *KeyRing) Generate(claims Claims) (string, error) {
+	k, err := r.Current()
+	if err != nil {
+		return "", err
+	}
+	method, err := signingMethod(k.Algorithm)
+	if err != nil {
+		return "", err
+	}
+	claims.RegisteredClaims = r.Validation().registered(time.Now())
+	t := jwt.NewWithClaims(method, claims)
+	t.Header["kid"] = k.ID
+	return t.SignedString(k.Private)
+}
+
+// Verify signed token with the key of its kid header. Tokens without kid,
+// issued before the key ring, are verified with the current key. The
+// registered claims are checked following the Validation of the ring, a
+// token out of its lifetime returns ErrTokenExpired or ErrTokenNotValidYet.
+func (r *KeyRing) Verify(token string) (Claims, error) {
+	t, err := jwt.ParseWithClaims(token, &Claims{}, r.keyFunc, r.Validation().parserOptions()...)
+	if err != nil {
+		return Claims{}, err
+	}
+	if !t.Valid {
+		return Claims{}, errors.New("invalid token")
+	}
+	claims, ok := t.Claims.(*Claims)
+	if !ok {
+		return Claims{}, errors.New("the claims could not be obtained")
+	}
+	if claims.ExpiresAt == nil {
+		return Claims{}, errNoExpiration
+	}
+	return *claims, nil
+}
+
+func (r *KeyRing) keyFunc(t *jwt.Token) (any, error) {
+	var k Key
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		var err error
+		k, err = r.Current()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var ok bool
+		k, ok = r.Lookup(kid)
+		if !ok && r.reloadMissed() {
+			k, ok = r.Lookup(kid)
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+		}
+	}
+	if t.Method.Alg() != k.Algorithm {
+		return nil, fmt.Errorf("%w: %q for a %s key", ErrUnsupportedAlgorithm, t.Method.Alg(), k.Algorithm)
+	}
+	return k.Public, nil
+}
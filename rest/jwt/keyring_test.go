@@ -0,0 +1,255 @@
+package jwt_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/rest/jwt"
+)
+
+func TestKeyRing(t *testing.T) {
+	tt := []struct {
+		alg string
+		kty string
+	}{
+		{alg: jwt.RS256, kty: "RSA"},
+		{alg: jwt.ES256, kty: "EC"},
+		{alg: jwt.EdDSA, kty: "OKP"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.alg, func(t *testing.T) {
+			k, err := jwt.GenerateKey(tc.alg, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			ring := jwt.NewKeyRing(k)
+			token, err := ring.Generate(jwt.Claims{Email: "example@gmail.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := ring.Verify(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Email != "example@gmail.com" {
+				t.Errorf("want email %q, got %q", "example@gmail.com", claims.Email)
+			}
+			set := ring.JWKS()
+			if len(set.Keys) != 1 {
+				t.Fatalf("want 1 key in JWKS, got %d", len(set.Keys))
+			}
+			jwk := set.Keys[0]
+			if jwk.Kid != k.ID || jwk.Alg != tc.alg || jwk.Kty != tc.kty || jwk.Use != "sig" {
+				t.Errorf("unexpected JWK %+v", jwk)
+			}
+
+			// Another ring doesn't know the kid of the token.
+			other, err := jwt.GenerateKey(tc.alg, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = jwt.NewKeyRing(other).Verify(token)
+			if !errors.Is(err, jwt.ErrUnknownKey) {
+				t.Errorf("want error %v, got %v", jwt.ErrUnknownKey, err)
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	rt := jwt.Rotation{
+		Ring:      jwt.NewKeyRing(),
+		Dir:       dir,
+		Algorithm: jwt.EdDSA,
+		Interval:  24 * time.Hour,
+	}
+	rotated, err := rt.Rotate(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rotated {
+		t.Fatal("expected a key to be generated for an empty ring")
+	}
+	first, err := rt.Ring.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := rt.Ring.Generate(jwt.Claims{Email: "example@gmail.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err = rt.Rotate(now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated {
+		t.Fatal("unexpected rotation before the interval")
+	}
+
+	// The replaced key keeps verifying its tokens.
+	now = now.Add(rt.Interval)
+	rotated, err = rt.Rotate(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rotated {
+		t.Fatal("expected a rotation after the interval")
+	}
+	second, err := rt.Ring.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ID == first.ID {
+		t.Fatal("expected a new signing key")
+	}
+	if _, err := rt.Ring.Verify(token); err != nil {
+		t.Errorf("token of the replaced key: %v", err)
+	}
+
+	// The keys on disk are loaded back.
+	ring, err := jwt.LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(ring.Keys()); got != 2 {
+		t.Fatalf("want 2 keys loaded, got %d", got)
+	}
+	current, err := ring.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.ID != second.ID {
+		t.Errorf("want current key %q, got %q", second.ID, current.ID)
+	}
+
+	// Kept while its tokens are valid within the clock skew.
+	skew := rt.Ring.Validation().ClockSkew
+	_, err = rt.Rotate(now.Add(jwt.AccessTokenTTL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rt.Ring.Lookup(first.ID); !ok {
+		t.Error("expected the replaced key to be kept within the clock skew")
+	}
+
+	// Once its tokens expired the replaced key is removed.
+	_, err = rt.Rotate(now.Add(jwt.AccessTokenTTL + skew))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rt.Ring.Lookup(first.ID); ok {
+		t.Error("expected the replaced key to be removed from the ring")
+	}
+	if _, err := os.Stat(filepath.Join(dir, first.ID+".pem")); !os.IsNotExist(err) {
+		t.Errorf("expected the replaced key file to be removed, got %v", err)
+	}
+	if got := len(rt.Ring.JWKS().Keys); got != 1 {
+		t.Errorf("want 1 key in JWKS, got %d", got)
+	}
+}
+
+func TestRotationSharedDir(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	rotation := func() jwt.Rotation {
+		return jwt.Rotation{
+			Ring:      jwt.NewKeyRing(),
+			Dir:       dir,
+			Algorithm: jwt.EdDSA,
+			Interval:  24 * time.Hour,
+		}
+	}
+	a, b := rotation(), rotation()
+	if _, err := a.Rotate(now); err != nil {
+		t.Fatal(err)
+	}
+
+	// The key of another process is loaded instead of generating one.
+	rotated, err := b.Rotate(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated {
+		t.Error("unexpected key generated with the key of another process in the dir")
+	}
+	ka, _ := a.Ring.Current()
+	kb, err := b.Ring.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ka.ID != kb.ID {
+		t.Errorf("want the signing key %q, got %q", ka.ID, kb.ID)
+	}
+
+	// Only one of them generates the key of the next interval.
+	now = now.Add(a.Interval)
+	if rotated, err = a.Rotate(now); err != nil || !rotated {
+		t.Fatalf("want a rotation, got %v, %v", rotated, err)
+	}
+	if rotated, err = b.Rotate(now); err != nil || rotated {
+		t.Fatalf("want no rotation, got %v, %v", rotated, err)
+	}
+	if got := len(b.Ring.Keys()); got != 2 {
+		t.Errorf("want 2 keys, got %d", got)
+	}
+
+	// Locked by another process.
+	if err := os.WriteFile(filepath.Join(dir, ".rotation.lock"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.Rotate(now.Add(b.Interval))
+	if !errors.Is(err, jwt.ErrRotationLocked) {
+		t.Errorf("want error %v, got %v", jwt.ErrRotationLocked, err)
+	}
+}
+
+func TestReloadUnknownKid(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	a := jwt.Rotation{Ring: jwt.NewKeyRing(), Dir: dir, Algorithm: jwt.EdDSA, Interval: time.Hour}
+	if _, err := a.Rotate(now); err != nil {
+		t.Fatal(err)
+	}
+	var reloads int
+	a.Ring.SetReload(func() error {
+		reloads++
+		return a.Reload()
+	}, time.Minute)
+
+	// Another process rotates the key and signs with it.
+	b := jwt.Rotation{Ring: jwt.NewKeyRing(), Dir: dir, Algorithm: jwt.EdDSA, Interval: time.Hour}
+	if _, err := b.Rotate(now.Add(b.Interval)); err != nil {
+		t.Fatal(err)
+	}
+	token, err := b.Ring.Generate(jwt.Claims{Email: "example@gmail.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Ring.Verify(token); err != nil {
+		t.Errorf("token of the key of another process: %v", err)
+	}
+
+	// An unknown kid reloads once per interval.
+	other, err := jwt.GenerateKey(jwt.EdDSA, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err = jwt.NewKeyRing(other).Generate(jwt.Claims{Email: "example@gmail.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, err := a.Ring.Verify(token); !errors.Is(err, jwt.ErrUnknownKey) {
+			t.Errorf("want error %v, got %v", jwt.ErrUnknownKey, err)
+		}
+	}
+	if reloads != 1 {
+		t.Errorf("want 1 reload, got %d", reloads)
+	}
+}
@@ -0,0 +1,290 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/adrianolmedo/genesis/logger"
+)
+
+// rsaKeyBits is the size of the generated RSA keys.
+const rsaKeyBits = 2048
+
+// createdHeader is the PEM header holding the creation time of a key file.
+const createdHeader = "Created"
+
+// Lock of a keys directory taken to generate a key.
+const (
+	lockFile = ".rotation.lock"
+
+	// lockWait is how long Rotate waits for the lock held by another
+	// process before giving up.
+	lockWait = 5 * time.Second
+
+	// lockStale is the age of a lock left by a process that died while
+	// holding it, it's taken over.
+	lockStale = time.Minute
+)
+
+// ReloadInterval is how often at most a Rotation reloads Dir when a token
+// has an unknown kid, see KeyRing.SetReload.
+const ReloadInterval = 5 * time.Second
+
+// GenerateKey creates a new signing key for alg.
+func GenerateKey(alg string, now time.Time) (Key, error) {
+	var (
+		private crypto.Signer
+		err     error
+	)
+	switch alg {
+	case RS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case ES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return Key{}, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, alg)
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	return NewKey(private, now)
+}
+
+// SaveKey writes the private key k into dir as <kid>.pem (PKCS #8), only
+// readable by the owner.
+func SaveKey(dir string, k Key) error {
+	if k.Private == nil {
+		return ErrNoSigningKey
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return err
+	}
+	b := pem.EncodeToMemory(&pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: map[string]string{createdHeader: k.CreatedAt.UTC().Format(time.RFC3339)},
+		Bytes:   der,
+	})
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	// Write and rename so a half written key is never loaded.
+	tmp := filepath.Join(dir, "."+k.ID+".tmp")
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, keyFile(dir, k.ID))
+}
+
+// LoadDir reads the keys saved by SaveKey in dir, a missing dir gives an
+// empty ring.
+func LoadDir(dir string) (*KeyRing, error) {
+	r := NewKeyRing()
+	if err := loadDir(r, dir); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// loadDir adds the keys saved in dir to r.
+func loadDir(r *KeyRing, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".pem") {
+			continue
+		}
+		k, err := loadKey(filepath.Join(dir, e.Name()))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // pruned by another process meanwhile
+		}
+		if err != nil {
+			return fmt.Errorf("key %s: %w", e.Name(), err)
+		}
+		r.Add(k)
+	}
+	return nil
+}
+
+func loadKey(file string) (Key, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return Key{}, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return Key{}, errors.New("no PEM data found")
+	}
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return Key{}, err
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return Key{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, private)
+	}
+	created, err := time.Parse(time.RFC3339, block.Headers[createdHeader])
+	if err != nil {
+		info, err := os.Stat(file)
+		if err != nil {
+			return Key{}, err
+		}
+		created = info.ModTime()
+	}
+	return NewKey(signer, created)
+}
+
+func keyFile(dir, kid string) string {
+	return filepath.Join(dir, kid+".pem")
+}
+
+// Rotation replaces the signing key of Ring every Interval with a new key
+// of Algorithm saved in Dir. A replaced key keeps verifying tokens for
+// AccessTokenTTL, the lifetime of the last token it signed, plus the
+// ClockSkew of the ring, then it is removed from the ring, from Dir and
+// from the JWKS.
+//
+// Dir may be shared by several processes: the keys written by the others
+// are loaded on each rotation and a lock file in Dir lets only one of them
+// generate the new key of an interval.
+type Rotation struct {
+	Ring      *KeyRing
+	Dir       string
+	Algorithm string
+	Interval  time.Duration
+}
+
+// Rotate loads the keys of Dir, generates a new key if the ring has none
+// or the current one is older than Interval (never when Interval is zero),
+// and removes the keys no longer needed. It reports whether a new key was
+// generated, by this process.
+func (rt Rotation) Rotate(now time.Time) (bool, error) {
+	if err := loadDir(rt.Ring, rt.Dir); err != nil {
+		return false, err
+	}
+	if !rt.due(now) {
+		return false, rt.prune(now)
+	}
+	unlock, err := rt.lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	// Another process may have generated the key while this one waited.
+	if err := loadDir(rt.Ring, rt.Dir); err != nil {
+		return false, err
+	}
+	if !rt.due(now) {
+		return false, rt.prune(now)
+	}
+	k, err := GenerateKey(rt.Algorithm, now)
+	if err != nil {
+		return false, err
+	}
+	if err := SaveKey(rt.Dir, k); err != nil {
+		return false, err
+	}
+	rt.Ring.Add(k)
+	logger.Info("signing key rotated", "kid", k.ID, "alg", k.Algorithm)
+	return true, rt.prune(now)
+}
+
+// Reload loads the keys of Dir written by other processes into the ring.
+func (rt Rotation) Reload() error {
+	return loadDir(rt.Ring, rt.Dir)
+}
+
+// due reports whether the ring needs a new signing key at now.
+func (rt Rotation) due(now time.Time) bool {
+	k, err := rt.Ring.Current()
+	return errors.Is(err, ErrNoSigningKey) ||
+		(rt.Interval > 0 && now.Sub(k.CreatedAt) >= rt.Interval)
+}
+
+// lock creates the lock file of Dir, waiting up to lockWait for another
+// process to remove it. The returned func removes it.
+func (rt Rotation) lock() (func(), error) {
+	if err := os.MkdirAll(rt.Dir, 0o700); err != nil {
+		return nil, err
+	}
+	file := filepath.Join(rt.Dir, lockFile)
+	deadline := time.Now().Add(lockWait)
+	for {
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(file) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		info, err := os.Stat(file)
+		if err == nil && time.Since(info.ModTime()) > lockStale {
+			logger.Info("stale signing key lock removed", "file", file)
+			os.Remove(file)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrRotationLocked
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// prune removes the keys replaced for longer than AccessTokenTTL plus the
+// ClockSkew tolerated, when no token they signed can be valid.
+func (rt Rotation) prune(now time.Time) error {
+	keep := AccessTokenTTL + rt.Ring.Validation().ClockSkew
+	keys := rt.Ring.Keys()
+	for i := 0; i < len(keys)-1; i++ {
+		replacedAt := keys[i+1].CreatedAt
+		if now.Sub(replacedAt) < keep {
+			continue
+		}
+		rt.Ring.Remove(keys[i].ID)
+		err := os.Remove(keyFile(rt.Dir, keys[i].ID))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		logger.Info("signing key removed", "kid", keys[i].ID)
+	}
+	return nil
+}
+
+// Run rotates the keys until ctx is done, checking every minute, so the
+// keys generated by other processes are loaded within a minute.
+func (rt Rotation) Run(ctx context.Context) {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			if _, err := rt.Rotate(now); err != nil {
+				logger.Error("signing key rotation failed", "err", err.Error())
+			}
+		}
+	}
+}
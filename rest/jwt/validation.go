@@ -0,0 +1,71 @@
+package jwt
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/adrianolmedo/genesis"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Errors of Verify on tokens well signed but not valid now, errors.Is
+// matches them.
+var (
+	ErrTokenExpired     = jwt.ErrTokenExpired
+	ErrTokenNotValidYet = jwt.ErrTokenNotValidYet
+)
+
+// errNoExpiration a token without exp would be valid forever.
+var errNoExpiration = fmt.Errorf("%w: exp", jwt.ErrTokenRequiredClaimMissing)
+
+// Validation of the registered claims of the access tokens.
+type Validation struct {
+	// Issuer is set as iss of the tokens generated and required on the
+	// tokens verified.
+	Issuer string
+
+	// Audience is set as aud of the tokens generated and required on the
+	// tokens verified, it isn't checked if empty.
+	Audience string
+
+	// ClockSkew tolerated between the servers on exp, nbf and iat.
+	ClockSkew time.Duration
+}
+
+// DefaultValidation of a new KeyRing.
+var DefaultValidation = Validation{
+	Issuer:    "genesis",
+	ClockSkew: 30 * time.Second,
+}
+
+// registered returns the registered claims of a token issued at now.
+func (v Validation) registered(now time.Time) jwt.RegisteredClaims {
+	rc := jwt.RegisteredClaims{
+		ID:        genesis.NextUUID(),
+		Issuer:    v.Issuer,
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+	}
+	if v.Audience != "" {
+		rc.Audience = jwt.ClaimStrings{v.Audience}
+	}
+	return rc
+}
+
+// parserOptions returns the options to verify a token.
+func (v Validation) parserOptions() []jwt.ParserOption {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{RS256, ES256, EdDSA}),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(v.ClockSkew),
+	}
+	if v.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(v.Issuer))
+	}
+	if v.Audience != "" {
+		opts = append(opts, jwt.WithAudience(v.Audience))
+	}
+	return opts
+}
@@ -0,0 +1,135 @@
+package jwt_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/rest/jwt"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+func TestVerifyRegisteredClaims(t *testing.T) {
+	k, err := jwt.GenerateKey(jwt.EdDSA, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring := jwt.NewKeyRing(k)
+	ring.SetValidation(jwt.Validation{
+		Issuer:    "genesis",
+		Audience:  "api",
+		ClockSkew: 30 * time.Second,
+	})
+	now := time.Now()
+	valid := gojwt.RegisteredClaims{
+		Issuer:    "genesis",
+		Audience:  gojwt.ClaimStrings{"api"},
+		IssuedAt:  gojwt.NewNumericDate(now),
+		NotBefore: gojwt.NewNumericDate(now),
+		ExpiresAt: gojwt.NewNumericDate(now.Add(time.Minute)),
+	}
+	tt := []struct {
+		name    string
+		edit    func(rc *gojwt.RegisteredClaims)
+		wantErr error
+	}{
+		{name: "valid", edit: func(rc *gojwt.RegisteredClaims) {}},
+		{
+			name: "expired",
+			edit: func(rc *gojwt.RegisteredClaims) {
+				rc.ExpiresAt = gojwt.NewNumericDate(now.Add(-time.Minute))
+			},
+			wantErr: jwt.ErrTokenExpired,
+		},
+		{
+			name: "expired-within-skew",
+			edit: func(rc *gojwt.RegisteredClaims) {
+				rc.ExpiresAt = gojwt.NewNumericDate(now.Add(-10 * time.Second))
+			},
+		},
+		{
+			name: "not-valid-yet",
+			edit: func(rc *gojwt.RegisteredClaims) {
+				rc.NotBefore = gojwt.NewNumericDate(now.Add(time.Minute))
+			},
+			wantErr: jwt.ErrTokenNotValidYet,
+		},
+		{
+			name: "not-valid-yet-within-skew",
+			edit: func(rc *gojwt.RegisteredClaims) {
+				rc.NotBefore = gojwt.NewNumericDate(now.Add(10 * time.Second))
+			},
+		},
+		{
+			name:    "without-exp",
+			edit:    func(rc *gojwt.RegisteredClaims) { rc.ExpiresAt = nil },
+			wantErr: gojwt.ErrTokenRequiredClaimMissing,
+		},
+		{
+			name:    "other-issuer",
+			edit:    func(rc *gojwt.RegisteredClaims) { rc.Issuer = "other" },
+			wantErr: gojwt.ErrTokenInvalidIssuer,
+		},
+		{
+			name:    "other-audience",
+			edit:    func(rc *gojwt.RegisteredClaims) { rc.Audience = gojwt.ClaimStrings{"other"} },
+			wantErr: gojwt.ErrTokenInvalidAudience,
+		},
+		{
+			name:    "without-audience",
+			edit:    func(rc *gojwt.RegisteredClaims) { rc.Audience = nil },
+			wantErr: gojwt.ErrTokenRequiredClaimMissing,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rc := valid
+			tc.edit(&rc)
+			token := signToken(t, k, jwt.Claims{Email: "example@gmail.com", RegisteredClaims: rc})
+			_, err := ring.Verify(token)
+			if tc.wantErr == nil && err != nil {
+				t.Fatalf("want no error, got %v", err)
+			}
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("want error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGenerateRegisteredClaims(t *testing.T) {
+	k, err := jwt.GenerateKey(jwt.EdDSA, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring := jwt.NewKeyRing(k)
+	ring.SetValidation(jwt.Validation{Issuer: "genesis", Audience: "api"})
+	token, err := ring.Generate(jwt.Claims{Email: "example@gmail.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ring.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Issuer != "genesis" || len(claims.Audience) != 1 || claims.Audience[0] != "api" {
+		t.Errorf("want iss %q and aud %q, got %q and %q", "genesis", "api", claims.Issuer, claims.Audience)
+	}
+	if claims.NotBefore == nil || claims.ExpiresAt == nil {
+		t.Error("want nbf and exp")
+	}
+}
+
+// signToken signs claims with k as is, without the registered claims set by
+// Generate.
+func signToken(t *testing.T, k jwt.Key, claims jwt.Claims) string {
+	t.Helper()
+	token := gojwt.NewWithClaims(gojwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = k.ID
+	s, err := token.SignedString(k.Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
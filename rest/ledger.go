@@ -0,0 +1,211 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/adrianolmedo/genesis/compose"
+	"github.com/adrianolmedo/genesis/ledger"
+	"github.com/adrianolmedo/genesis/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// listLedgerAccounts godoc
+//
+//	@Summary		Chart of accounts
+//	@Description	Get the accounts of the general ledger the billing events are posted to, by code
+//	@Tags			ledger
+//	@Produce		json
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=[]ledgerAccountResp}
+//	@Router			/ledger/accounts [get]
+func listLedgerAccounts(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		accounts, err := svcs.Ledger.Accounts(c.UserContext())
+		if err != nil {
+			logger.Error("list ledger accounts", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The accounts could not be read",
+			})
+		}
+		list := make([]ledgerAccountResp, 0, len(accounts))
+		for _, a := range accounts {
+			list = append(list, toLedgerAccountResp(a))
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    list,
+		})
+	}
+}
+
+// listJournalEntries godoc
+//
+//	@Summary		Journal entries of a document
+//	@Description	Get the journal entries posted by an invoice (issued), its void (invoice_void), a payment or a credit note, with their lines
+//	@Tags			ledger
+//	@Produce		json
+//	@Param			source		query		string	true	"invoice, invoice_void, payment or credit_note"	example(invoice)
+//	@Param			sourceId	query		int		true	"Id of the document"							example(1)
+//	@Failure		400			{object}	errorResp
+//	@Failure		401			{object}	errorResp
+//	@Failure		403			{object}	errorResp
+//	@Failure		500			{object}	errorResp
+//	@Success		200			{object}	resp{data=[]journalEntryResp}
+//	@Router			/ledger/entries [get]
+func listJournalEntries(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		source := c.Query("source")
+		if source == "" {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "source expected",
+			})
+		}
+		id, err := strconv.ParseInt(c.Query("sourceId"), 10, 64)
+		if err != nil || id <= 0 {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "positive number expected for sourceId",
+			})
+		}
+		entries, err := svcs.Ledger.Entries(c.UserContext(), source, id)
+		if err != nil {
+			logger.Error("list journal entries", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The journal entries could not be read",
+			})
+		}
+		list := make([]journalEntryResp, 0, len(entries))
+		for _, e := range entries {
+			list = append(list, toJournalEntryResp(e))
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    list,
+		})
+	}
+}
+
+// trialBalance godoc
+//
+//	@Summary		Trial balance
+//	@Description	Get the debits, credits and balance of each account posted up to a time, all of them if omitted. The debits equal the credits, else it answers 500 with the balances. The amounts are in minor units
+//	@Tags			ledger
+//	@Produce		json
+//	@Param			at	query		string	false	"Posted up to, date (end of the day) or RFC 3339 time"	example(2024-12-31)
+//	@Failure		400	{object}	errorResp
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=trialBalanceResp}
+//	@Router			/ledger/trial-balance [get]
+func trialBalance(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		at, err := queryAt(c)
+		if err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		tb, err := svcs.Ledger.TrialBalance(c.UserContext(), at)
+		if errors.Is(err, ledger.ErrUnbalanced) {
+			logger.Error("trial balance", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The ledger is unbalanced",
+				Data:    toTrialBalanceResp(tb),
+			})
+		}
+		if err != nil {
+			logger.Error("trial balance", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The trial balance could not be computed",
+			})
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    toTrialBalanceResp(tb),
+		})
+	}
+}
+
+type ledgerAccountResp struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func toLedgerAccountResp(a ledger.Account) ledgerAccountResp {
+	return ledgerAccountResp{Code: a.Code, Name: a.Name, Type: string(a.Type)}
+}
+
+type journalLineResp struct {
+	Account string `json:"account"`
+	Debit   int64  `json:"debit,omitempty"`
+	Credit  int64  `json:"credit,omitempty"`
+}
+
+type journalEntryResp struct {
+	ID       int64             `json:"id"`
+	Source   string            `json:"source"`
+	SourceID int64             `json:"sourceId"`
+	Memo     string            `json:"memo"`
+	PostedAt time.Time         `json:"postedAt"`
+	Lines    []journalLineResp `json:"lines"`
+}
+
+func toJournalEntryResp(e ledger.Entry) journalEntryResp {
+	lines := make([]journalLineResp, 0, len(e.Lines))
+	for _, l := range e.Lines {
+		lines = append(lines, journalLineResp{Account: l.Account, Debit: l.Debit, Credit: l.Credit})
+	}
+	return journalEntryResp{
+		ID:       e.ID,
+		Source:   e.Source,
+		SourceID: e.SourceID,
+		Memo:     e.Memo,
+		PostedAt: e.PostedAt,
+		Lines:    lines,
+	}
+}
+
+type accountBalanceResp struct {
+	ledgerAccountResp
+	Debit   int64 `json:"debit"`
+	Credit  int64 `json:"credit"`
+	Balance int64 `json:"balance"` // on the normal side of the account
+}
+
+type trialBalanceResp struct {
+	At       *time.Time           `json:"at,omitempty"`
+	Accounts []accountBalanceResp `json:"accounts"`
+	Debit    int64                `json:"debit"`
+	Credit   int64                `json:"credit"`
+}
+
+func toTrialBalanceResp(tb *ledger.TrialBalance) trialBalanceResp {
+	accounts := make([]accountBalanceResp, 0, len(tb.Accounts))
+	for _, b := range tb.Accounts {
+		accounts = append(accounts, accountBalanceResp{
+			ledgerAccountResp: toLedgerAccountResp(b.Account),
+			Debit:             b.Debit,
+			Credit:            b.Credit,
+			Balance:           b.Net(),
+		})
+	}
+	resp := trialBalanceResp{Accounts: accounts, Debit: tb.Debit, Credit: tb.Credit}
+	if !tb.At.IsZero() {
+		resp.At = &tb.At
+	}
+	return resp
+}
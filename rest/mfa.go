@@ -0,0 +1,239 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/adrianolmedo/genesis/compose"
+	"github.com/adrianolmedo/genesis/logger"
+	"github.com/adrianolmedo/genesis/user"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// mfaChallengeResp challenge to answer with an MFA code to complete a login.
+type mfaChallengeResp struct {
+	ChallengeToken string `json:"challengeToken"`
+	ExpiresIn      int    `json:"expiresIn" example:"300"` // challenge lifetime in seconds
+}
+
+// loginMFA godoc
+//
+//	@Summary		Login MFA
+//	@Description	Second step of the login, exchange the challenge and a TOTP or recovery code for the tokens
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400				{object}	errorResp
+//	@Failure		401				{object}	errorResp
+//	@Failure		500				{object}	errorResp
+//	@Success		201				{object}	resp{data=dataTokenResp}
+//	@Param			loginMFAReq		body		loginMFAReq	true	"application/json"
+//	@Router			/login/mfa [post]
+func loginMFA(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		req := loginMFAReq{}
+		err := c.BodyParser(&req)
+		if err != nil || req.ChallengeToken == "" {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "A challengeToken and code are expected",
+			})
+		}
+		u, err := svcs.User.AnswerMFAChallenge(ctx, req.ChallengeToken, req.Code)
+		if errors.Is(err, user.ErrMFACodeCantBeEmpty) {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		if errors.Is(err, user.ErrMFAInvalidCode) {
+			return errorJSON(c, http.StatusUnauthorized, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		if errors.Is(err, user.ErrChallengeNotFound) || errors.Is(err, user.ErrChallengeExpired) {
+			return errorJSON(c, http.StatusUnauthorized, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+				Details: "Sign in again",
+			})
+		}
+		if err != nil {
+			logger.Error("login mfa", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The login could not be completed",
+			})
+		}
+		return respSession(c, svcs, u, "You are logged")
+	}
+}
+
+// loginMFAReq challenge of the login and the code to answer it.
+type loginMFAReq struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code" example:"123456"` // TOTP or recovery code
+}
+
+// enrolMFA godoc
+//
+//	@Summary		Enrol MFA
+//	@Description	Generate a TOTP secret for the authenticated user, to be activated with a first code
+//	@Tags			users
+//	@Produce		json
+//	@Failure		401	{object}	errorResp
+//	@Failure		409	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		201	{object}	resp{data=mfaEnrolmentResp}
+//	@Router			/mfa/totp [post]
+func enrolMFA(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		e, err := svcs.User.EnrolMFA(ctx, principalFrom(c).UserID)
+		if errors.Is(err, user.ErrMFAAlreadyEnabled) {
+			return errorJSON(c, http.StatusConflict, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			logger.Error("enrol mfa", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "MFA could not be enrolled",
+			})
+		}
+		return respJSON(c, http.StatusCreated, detailsResp{
+			Message: "MFA enrolled",
+			Details: "Add it to your authenticator app and activate it with a code",
+			Data: mfaEnrolmentResp{
+				Secret: e.Secret,
+				URI:    e.URI,
+			},
+		})
+	}
+}
+
+// mfaEnrolmentResp secret to add to an authenticator app.
+type mfaEnrolmentResp struct {
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	URI    string `json:"uri" example:"otpauth://totp/Genesis:example@gmail.com?secret=JBSWY3DPEHPK3PXP&issuer=Genesis"`
+}
+
+// activateMFA godoc
+//
+//	@Summary		Activate MFA
+//	@Description	Verify a first code of the enrolled secret and require MFA on login
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400			{object}	errorResp
+//	@Failure		401			{object}	errorResp
+//	@Failure		404			{object}	errorResp
+//	@Failure		409			{object}	errorResp
+//	@Failure		500			{object}	errorResp
+//	@Success		200			{object}	resp{data=recoveryCodesResp}
+//	@Param			mfaCodeReq	body		mfaCodeReq	true	"application/json"
+//	@Router			/mfa/totp/activate [post]
+func activateMFA(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		req := mfaCodeReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		codes, err := svcs.User.ActivateMFA(ctx, principalFrom(c).UserID, req.Code)
+		if err != nil {
+			return mfaError(c, err)
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "MFA activated",
+			Details: "Keep the recovery codes in a safe place, they won't be shown again",
+			Data:    recoveryCodesResp{RecoveryCodes: codes},
+		})
+	}
+}
+
+// recoveryCodesResp single-use codes to login without the authenticator app.
+type recoveryCodesResp struct {
+	RecoveryCodes []string `json:"recoveryCodes" example:"k3m9-x2aq-7hft-p4dw"`
+}
+
+// disableMFA godoc
+//
+//	@Summary		Disable MFA
+//	@Description	Stop requiring MFA on login, a current code is required
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400			{object}	errorResp
+//	@Failure		401			{object}	errorResp
+//	@Failure		404			{object}	errorResp
+//	@Failure		500			{object}	errorResp
+//	@Success		200			{object}	resp
+//	@Param			mfaCodeReq	body		mfaCodeReq	true	"application/json"
+//	@Router			/mfa/totp [delete]
+func disableMFA(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		req := mfaCodeReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		err := svcs.User.DisableMFA(ctx, principalFrom(c).UserID, req.Code)
+		if err != nil {
+			return mfaError(c, err)
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "MFA disabled",
+		})
+	}
+}
+
+// mfaCodeReq TOTP code of the authenticator app.
+type mfaCodeReq struct {
+	Code string `json:"code" example:"123456"`
+}
+
+// mfaError responds the errors of the MFA management.
+func mfaError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, user.ErrMFACodeCantBeEmpty):
+		return errorJSON(c, http.StatusBadRequest, detailsResp{
+			Code:    "002",
+			Message: err.Error(),
+		})
+	case errors.Is(err, user.ErrMFAInvalidCode):
+		return errorJSON(c, http.StatusUnauthorized, detailsResp{
+			Code:    "003",
+			Message: err.Error(),
+		})
+	case errors.Is(err, user.ErrMFANotEnrolled):
+		return errorJSON(c, http.StatusNotFound, detailsResp{
+			Code:    "003",
+			Message: err.Error(),
+		})
+	case errors.Is(err, user.ErrMFAAlreadyEnabled):
+		return errorJSON(c, http.StatusConflict, detailsResp{
+			Code:    "003",
+			Message: err.Error(),
+		})
+	}
+	logger.Error("mfa", "err", err.Error())
+	return errorJSON(c, http.StatusInternalServerError, detailsResp{
+		Code:    "003",
+		Message: "The MFA could not be updated",
+	})
+}
@@ -0,0 +1,339 @@
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing/report"
+	"github.com/adrianolmedo/genesis/compose"
+	"github.com/adrianolmedo/genesis/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// agingReport godoc
+//
+//	@Summary		Receivables aging
+//	@Description	Get what each customer owed at a time by the days its invoices were overdue: current, 1-30, 31-60, 61-90 and 90+. The amounts are in minor units of a currency
+//	@Tags			reports
+//	@Produce		json,text/csv
+//	@Param			at			query		string	false	"Owed at, date (end of the day) or RFC 3339 time, now if omitted"	example(2024-01-31)
+//	@Param			clientId	query		int		false	"Receivables of the client"										example(1)
+//	@Param			currency	query		string	false	"Of the invoices, the base currency if omitted"					example(EUR)
+//	@Param			format		query		string	false	"json or csv"													example(csv)
+//	@Failure		400			{object}	errorResp
+//	@Failure		401			{object}	errorResp
+//	@Failure		403			{object}	errorResp
+//	@Failure		500			{object}	errorResp
+//	@Success		200			{object}	resp{data=agingResp}
+//	@Router			/reports/aging [get]
+func agingReport(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		csv, err := reportFormat(c)
+		if err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		at, err := queryAt(c)
+		if err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		clientID, err := queryClientID(c)
+		if err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		currency, err := queryCurrency(c)
+		if err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		aging, err := svcs.Report.Aging(c.UserContext(), at, clientID, currency)
+		if err != nil {
+			logger.Error("aging report", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The aging report could not be computed",
+			})
+		}
+		if csv {
+			return sendCSV(c, report.Filename("aging", aging.At), aging.WriteCSV)
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    toAgingResp(aging),
+		})
+	}
+}
+
+// revenueByProduct godoc
+//
+//	@Summary		Revenue by product
+//	@Description	Get what each product was invoiced less what was credited in a date range. Drafts and void invoices aren't revenue. The amounts are in minor units of a currency
+//	@Tags			reports
+//	@Produce		json,text/csv
+//	@Param			from		query		string	false	"Documents on or after, date or RFC 3339 time"				example(2024-01-01)
+//	@Param			to			query		string	false	"Documents on or before, date (included) or RFC 3339 time"	example(2024-12-31)
+//	@Param			clientId	query		int		false	"Revenue of the client"										example(1)
+//	@Param			currency	query		string	false	"Of the documents, the base currency if omitted"			example(EUR)
+//	@Param			format		query		string	false	"json or csv"												example(csv)
+//	@Failure		400			{object}	errorResp
+//	@Failure		401			{object}	errorResp
+//	@Failure		403			{object}	errorResp
+//	@Failure		500			{object}	errorResp
+//	@Success		200			{object}	resp{data=productsReportResp}
+//	@Router			/reports/revenue/products [get]
+func revenueByProduct(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		csv, f, err := reportFilter(c)
+		if err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		rep, err := svcs.Report.RevenueByProduct(c.UserContext(), f)
+		if errors.Is(err, report.ErrInvalidDateRange) {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			logger.Error("revenue by product", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The revenue report could not be computed",
+			})
+		}
+		if csv {
+			return sendCSV(c, report.Filename("revenue-products", time.Now()), rep.WriteCSV)
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    toProductsReportResp(rep),
+		})
+	}
+}
+
+// revenueByMonth godoc
+//
+//	@Summary		Revenue by month
+//	@Description	Get what was invoiced and credited each month (UTC) in a date range. Drafts and void invoices aren't revenue. The amounts are in minor units of a currency
+//	@Tags			reports
+//	@Produce		json,text/csv
+//	@Param			from		query		string	false	"Documents on or after, date or RFC 3339 time"				example(2024-01-01)
+//	@Param			to			query		string	false	"Documents on or before, date (included) or RFC 3339 time"	example(2024-12-31)
+//	@Param			clientId	query		int		false	"Revenue of the client"										example(1)
+//	@Param			currency	query		string	false	"Of the documents, the base currency if omitted"			example(EUR)
+//	@Param			format		query		string	false	"json or csv"												example(csv)
+//	@Failure		400			{object}	errorResp
+//	@Failure		401			{object}	errorResp
+//	@Failure		403			{object}	errorResp
+//	@Failure		500			{object}	errorResp
+//	@Success		200			{object}	resp{data=monthsReportResp}
+//	@Router			/reports/revenue/months [get]
+func revenueByMonth(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		csv, f, err := reportFilter(c)
+		if err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		rep, err := svcs.Report.RevenueByMonth(c.UserContext(), f)
+		if errors.Is(err, report.ErrInvalidDateRange) {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			logger.Error("revenue by month", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The revenue report could not be computed",
+			})
+		}
+		if csv {
+			return sendCSV(c, report.Filename("revenue-months", time.Now()), rep.WriteCSV)
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    toMonthsReportResp(rep),
+		})
+	}
+}
+
+// reportFormat reports whether the format query asks for CSV.
+func reportFormat(c *fiber.Ctx) (csv bool, err error) {
+	switch c.Query("format", "json") {
+	case "json":
+		return false, nil
+	case "csv":
+		return true, nil
+	}
+	return false, errors.New("json or csv expected for format")
+}
+
+// reportFilter reads the format and the filter of a revenue report from
+// the query.
+func reportFilter(c *fiber.Ctx) (csv bool, f report.Filter, err error) {
+	if csv, err = reportFormat(c); err != nil {
+		return csv, f, err
+	}
+	if f.ClientID, err = queryClientID(c); err != nil {
+		return csv, f, err
+	}
+	if f.Currency, err = queryCurrency(c); err != nil {
+		return csv, f, err
+	}
+	f.From, f.To, err = queryDateRange(c)
+	return csv, f, err
+}
+
+// sendCSV responds the CSV written by write as a file named name.
+func sendCSV(c *fiber.Ctx, name string, write func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		logger.Error("report csv", "err", err.Error())
+		return errorJSON(c, http.StatusInternalServerError, detailsResp{
+			Code:    "003",
+			Message: "The report could not be written",
+		})
+	}
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name))
+	return c.Status(http.StatusOK).Send(buf.Bytes())
+}
+
+type bucketsResp struct {
+	Current    int64 `json:"current"`
+	Days1To30  int64 `json:"days1To30"`
+	Days31To60 int64 `json:"days31To60"`
+	Days61To90 int64 `json:"days61To90"`
+	Over90     int64 `json:"over90"`
+	Total      int64 `json:"total"`
+}
+
+type agingRowResp struct {
+	ClientID int64  `json:"clientId"`
+	Name     string `json:"name"`
+	Invoices int    `json:"invoices"`
+	bucketsResp
+}
+
+type agingResp struct {
+	At       time.Time      `json:"at"`
+	Currency string         `json:"currency"`
+	Rows     []agingRowResp `json:"rows"`
+	Total    bucketsResp    `json:"total"`
+}
+
+func toBucketsResp(b report.Buckets) bucketsResp {
+	return bucketsResp{
+		Current:    b.Current,
+		Days1To30:  b.Days1To30,
+		Days31To60: b.Days31To60,
+		Days61To90: b.Days61To90,
+		Over90:     b.Over90,
+		Total:      b.Total,
+	}
+}
+
+func toAgingResp(a *report.Aging) agingResp {
+	rows := make([]agingRowResp, 0, len(a.Rows))
+	for _, r := range a.Rows {
+		rows = append(rows, agingRowResp{
+			ClientID:    r.ClientID,
+			Name:        r.Name,
+			Invoices:    r.Invoices,
+			bucketsResp: toBucketsResp(r.Buckets),
+		})
+	}
+	return agingResp{At: a.At, Currency: string(a.Currency), Rows: rows, Total: toBucketsResp(a.Total)}
+}
+
+type amountsResp struct {
+	Net   int64 `json:"net"`
+	Tax   int64 `json:"tax"`
+	Total int64 `json:"total"`
+}
+
+type productRevenueResp struct {
+	ProductID   int64  `json:"productId"`
+	ProductName string `json:"productName"`
+	Quantity    int64  `json:"quantity"`
+	amountsResp
+}
+
+type productsReportResp struct {
+	Currency string               `json:"currency"`
+	Rows     []productRevenueResp `json:"rows"`
+	Total    amountsResp          `json:"total"`
+}
+
+type monthRevenueResp struct {
+	Month       string `json:"month,omitempty"`
+	Invoices    int    `json:"invoices"`
+	CreditNotes int    `json:"creditNotes"`
+	Invoiced    int64  `json:"invoiced"`
+	Credited    int64  `json:"credited"`
+	amountsResp
+}
+
+type monthsReportResp struct {
+	Currency string             `json:"currency"`
+	Rows     []monthRevenueResp `json:"rows"`
+	Total    monthRevenueResp   `json:"total"`
+}
+
+func toAmountsResp(a report.Amounts) amountsResp {
+	return amountsResp{Net: a.Net, Tax: a.Tax, Total: a.Total}
+}
+
+func toProductsReportResp(p *report.ProductsReport) productsReportResp {
+	rows := make([]productRevenueResp, 0, len(p.Rows))
+	for _, r := range p.Rows {
+		rows = append(rows, productRevenueResp{
+			ProductID:   r.ProductID,
+			ProductName: r.ProductName,
+			Quantity:    r.Quantity,
+			amountsResp: toAmountsResp(r.Amounts),
+		})
+	}
+	return productsReportResp{Currency: string(p.Filter.Currency), Rows: rows, Total: toAmountsResp(p.Total)}
+}
+
+func toMonthRevenueResp(m report.MonthRevenue) monthRevenueResp {
+	return monthRevenueResp{
+		Month:       m.Month,
+		Invoices:    m.Invoices,
+		CreditNotes: m.CreditNotes,
+		Invoiced:    m.Invoiced,
+		Credited:    m.Credited,
+		amountsResp: toAmountsResp(m.Amounts),
+	}
+}
+
+func toMonthsReportResp(m *report.MonthsReport) monthsReportResp {
+	rows := make([]monthRevenueResp, 0, len(m.Rows))
+	for _, r := range m.Rows {
+		rows = append(rows, toMonthRevenueResp(r))
+	}
+	return monthsReportResp{Currency: string(m.Filter.Currency), Rows: rows, Total: toMonthRevenueResp(m.Total)}
+}
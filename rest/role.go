@@ -0,0 +1,177 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/adrianolmedo/genesis/compose"
+	"github.com/adrianolmedo/genesis/logger"
+	"github.com/adrianolmedo/genesis/user"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// listRoles godoc
+//
+//	@Summary		List roles
+//	@Description	Get the roles with their permissions
+//	@Tags			roles
+//	@Produce		json
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=[]roleResp}
+//	@Router			/roles [get]
+func listRoles(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		roles, err := svcs.User.Roles(ctx)
+		if err != nil {
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		if roles.IsEmpty() {
+			return respJSON(c, http.StatusOK, detailsResp{
+				Code:    "005",
+				Message: "There are not roles",
+			})
+		}
+		list := make([]roleResp, 0, len(roles))
+		for _, r := range roles {
+			list = append(list, roleResp{
+				Name:        r.Name,
+				Description: r.Description,
+				Permissions: r.Permissions,
+			})
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    list,
+		})
+	}
+}
+
+// roleResp subset of Role fields.
+type roleResp struct {
+	Name        string   `json:"name" example:"admin"`
+	Description string   `json:"description" example:"Full access"`
+	Permissions []string `json:"permissions" example:"products:write"`
+}
+
+// assignRole godoc
+//
+//	@Summary		Assign role
+//	@Description	Assign a role to a user
+//	@Tags			roles
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int				true	"User id"
+//	@Param			assignRoleReq	body		assignRoleReq	true	"application/json"
+//	@Failure		400				{object}	errorResp
+//	@Failure		401				{object}	errorResp
+//	@Failure		403				{object}	errorResp
+//	@Failure		404				{object}	errorResp
+//	@Failure		500				{object}	errorResp
+//	@Success		200				{object}	resp
+//	@Router			/users/{id}/roles [post]
+func assignRole(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID user",
+			})
+		}
+		req := assignRoleReq{}
+		err = c.BodyParser(&req)
+		if err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		err = svcs.User.AssignRole(ctx, int64(id), req.Role)
+		if errors.Is(err, user.ErrRoleCantBeEmpty) {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		if errors.Is(err, user.ErrNotFound) || errors.Is(err, user.ErrRoleNotFound) {
+			return errorJSON(c, http.StatusNotFound, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		logger.Info("role", fmt.Sprintf("role %q assigned to user ID %d", req.Role, id))
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Role assigned",
+			Details: "It takes effect on the next login or token refresh",
+		})
+	}
+}
+
+// assignRoleReq role to assign to a user.
+type assignRoleReq struct {
+	Role string `json:"role" example:"admin"`
+}
+
+// revokeRole godoc
+//
+//	@Summary		Revoke role
+//	@Description	Revoke a role from a user
+//	@Tags			roles
+//	@Produce		json
+//	@Param			id		path		int		true	"User id"
+//	@Param			role	path		string	true	"Role name"
+//	@Failure		400		{object}	errorResp
+//	@Failure		401		{object}	errorResp
+//	@Failure		403		{object}	errorResp
+//	@Failure		404		{object}	errorResp
+//	@Failure		500		{object}	errorResp
+//	@Success		200		{object}	resp
+//	@Router			/users/{id}/roles/{role} [delete]
+func revokeRole(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID user",
+			})
+		}
+		role := c.Params("role")
+		err = svcs.User.RevokeRole(ctx, int64(id), role)
+		if errors.Is(err, user.ErrRoleNotFound) || errors.Is(err, user.ErrRoleNotAssigned) {
+			return errorJSON(c, http.StatusNotFound, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		logger.Info("role", fmt.Sprintf("role %q revoked from user ID %d", role, id))
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Role revoked",
+			Details: "It takes effect on the next login or token refresh",
+		})
+	}
+}
@@ -1,12 +1,17 @@
 package rest
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/adrianolmedo/genesis/compose"
 	_ "github.com/adrianolmedo/genesis/docs"
+	"github.com/adrianolmedo/genesis/logger"
 	"github.com/adrianolmedo/genesis/rest/jwt"
+	"github.com/adrianolmedo/genesis/user"
 
 	"github.com/gofiber/fiber/v2"
 	swagger "github.com/swaggo/fiber-swagger"
@@ -37,21 +42,83 @@ func Router(svcs *compose.Services) *fiber.App {
 	})
 	f.Get("/v1/test-ratelimit", testRatelimit())
 	f.Get("/v1/test-timeout", testTimeout())
+	f.Get("/.well-known/jwks.json", jwks())
+	auth := authWare(svcs)
 	f.Post("/v1/login", loginUser(svcs))
+	f.Post("/v1/login/mfa", loginMFA(svcs))
+	f.Post("/v1/token/refresh", refreshToken(svcs))
+	session := requireSession()
+	f.Post("/v1/logout", auth, session, logoutUser(svcs))
+	f.Post("/v1/logout/all", auth, session, logoutAll(svcs))
+	f.Post("/v1/mfa/totp", auth, session, enrolMFA(svcs))
+	f.Post("/v1/mfa/totp/activate", auth, session, activateMFA(svcs))
+	f.Delete("/v1/mfa/totp", auth, session, disableMFA(svcs))
+	f.Get("/v1/apikeys", auth, session, listAPIKeys(svcs))
+	f.Post("/v1/apikeys", auth, session, createAPIKey(svcs))
+	f.Delete("/v1/apikeys/:id", auth, session, revokeAPIKey(svcs))
+	f.Get("/v1/service-accounts", auth, requirePermission(user.PermServiceAccountsWrite), listServiceAccounts(svcs))
+	f.Post("/v1/service-accounts", auth, requirePermission(user.PermServiceAccountsWrite), createServiceAccount(svcs))
+	f.Get("/v1/service-accounts/:id/apikeys", auth, requirePermission(user.PermServiceAccountsWrite), listServiceAccountAPIKeys(svcs))
 	f.Post("/v1/users", signUpUser(svcs))
+	f.Get("/v1/users/verify", verifyUser(svcs))
+	f.Post("/v1/password/forgot", forgotPassword(svcs))
+	f.Post("/v1/password/reset", resetPassword(svcs))
 	f.Get("/v1/users/:id", findUser(svcs))
-	f.Get("/v1/users", authWare, listUsers(svcs))
-	f.Put("/v1/users/:id", authWare, updateUser(svcs))
-	f.Delete("/v1/users/:id", authWare, deleteUser(svcs))
-	f.Post("/v1/customers", createCustomer(svcs))
-	f.Get("/v1/customers", listCustomers(svcs))
-	f.Delete("v1/customers/:id", deleteCustomer(svcs))
+	f.Get("/v1/users", auth, requirePermission(user.PermUsersRead), listUsers(svcs))
+	f.Put("/v1/users/:id", auth, session, updateUser(svcs))
+	f.Delete("/v1/users/:id", auth, session, deleteUser(svcs))
+	f.Delete("/v1/users/:id/lockout", auth, requirePermission(user.PermUsersWrite), unlockUser(svcs))
+	f.Get("/v1/roles", auth, requirePermission(user.PermRolesWrite), listRoles(svcs))
+	f.Post("/v1/users/:id/roles", auth, requirePermission(user.PermRolesWrite), assignRole(svcs))
+	f.Delete("/v1/users/:id/roles/:role", auth, requirePermission(user.PermRolesWrite), revokeRole(svcs))
+	f.Post("/v1/customers", auth, requirePermission(user.PermCustomersWrite), createCustomer(svcs))
+	f.Get("/v1/customers", auth, requirePermission(user.PermCustomersRead), listCustomers(svcs))
+	f.Delete("/v1/customers/:id", auth, requirePermission(user.PermCustomersWrite), deleteCustomer(svcs))
+	f.Get("/v1/customers/:id/balance", auth, requirePermission(user.PermInvoicesRead), customerBalance(svcs))
+	f.Post("/v1/customers/:id/tax-exemptions", auth, requirePermission(user.PermTaxesWrite), createTaxExemption(svcs))
+	f.Get("/v1/customers/:id/tax-exemptions", auth, requirePermission(user.PermInvoicesRead), listTaxExemptions(svcs))
+	f.Get("/v1/customers/:id/subscriptions", auth, requirePermission(user.PermInvoicesRead), listCustomerSubscriptions(svcs))
+	f.Put("/v1/customers/:id/payment-terms", auth, requirePermission(user.PermDunningWrite), setPaymentTerms(svcs))
+	f.Get("/v1/customers/:id/payment-terms", auth, requirePermission(user.PermInvoicesRead), findPaymentTerms(svcs))
 	f.Get("/v1/products", listProducts(svcs))
 	f.Get("/v1/products/:id", findProduct(svcs))
-	f.Post("/v1/products", authWare, addProduct(svcs))
-	f.Put("/v1/products/:id", authWare, updateProduct(svcs))
-	f.Delete("/v1/products/:id", authWare, deleteProduct(svcs))
-	f.Post("/v1/invoices", authWare, generateInvoice(svcs))
+	f.Post("/v1/products", auth, requirePermission(user.PermProductsWrite), addProduct(svcs))
+	f.Put("/v1/products/:id", auth, requirePermission(user.PermProductsWrite), updateProduct(svcs))
+	f.Delete("/v1/products/:id", auth, requirePermission(user.PermProductsWrite), deleteProduct(svcs))
+	f.Post("/v1/invoices", auth, requirePermission(user.PermInvoicesWrite), generateInvoice(svcs))
+	f.Get("/v1/invoices", auth, requirePermission(user.PermInvoicesRead), listInvoices(svcs))
+	f.Get("/v1/invoices/:id", auth, requirePermission(user.PermInvoicesRead), findInvoice(svcs))
+	f.Get("/v1/invoices/:id/pdf", auth, requirePermission(user.PermInvoicesRead), invoicePDF(svcs))
+	f.Get("/v1/invoices/:id/history", auth, requirePermission(user.PermInvoicesRead), invoiceHistory(svcs))
+	f.Post("/v1/invoices/:id/issue", auth, requirePermission(user.PermInvoicesWrite), changeInvoiceStatus(svcs.Billing.Issue))
+	f.Post("/v1/invoices/:id/void", auth, requirePermission(user.PermInvoicesWrite), changeInvoiceStatus(svcs.Billing.Void))
+	f.Post("/v1/invoices/:id/payments", auth, requirePermission(user.PermInvoicesWrite), payInvoice(svcs))
+	f.Post("/v1/invoices/:id/credit-notes", auth, requirePermission(user.PermInvoicesWrite), creditInvoice(svcs))
+	f.Get("/v1/invoices/:id/credit-notes", auth, requirePermission(user.PermInvoicesRead), listInvoiceCreditNotes(svcs))
+	f.Get("/v1/invoices/:id/reminders", auth, requirePermission(user.PermInvoicesRead), listInvoiceReminders(svcs))
+	f.Get("/v1/credit-notes/:id", auth, requirePermission(user.PermInvoicesRead), findCreditNote(svcs))
+	f.Post("/v1/tax-categories", auth, requirePermission(user.PermTaxesWrite), createTaxCategory(svcs))
+	f.Get("/v1/tax-categories", auth, requirePermission(user.PermInvoicesRead), listTaxCategories(svcs))
+	f.Post("/v1/tax-rates", auth, requirePermission(user.PermTaxesWrite), createTaxRate(svcs))
+	f.Get("/v1/tax-rates", auth, requirePermission(user.PermInvoicesRead), listTaxRates(svcs))
+	f.Post("/v1/exchange-rates", auth, requirePermission(user.PermExchangeRatesWrite), createExchangeRate(svcs))
+	f.Get("/v1/exchange-rates", auth, requirePermission(user.PermInvoicesRead), listExchangeRates(svcs))
+	f.Post("/v1/subscription-plans", auth, requirePermission(user.PermSubscriptionsWrite), createPlan(svcs))
+	f.Get("/v1/subscription-plans", auth, requirePermission(user.PermInvoicesRead), listPlans(svcs))
+	f.Post("/v1/subscriptions", auth, requirePermission(user.PermSubscriptionsWrite), subscribe(svcs))
+	f.Get("/v1/subscriptions/next-run", auth, requirePermission(user.PermInvoicesRead), nextRun(svcs))
+	f.Get("/v1/subscriptions/:id", auth, requirePermission(user.PermInvoicesRead), findSubscription(svcs))
+	f.Patch("/v1/subscriptions/:id", auth, requirePermission(user.PermSubscriptionsWrite), changeSubscription(svcs))
+	f.Post("/v1/subscriptions/:id/cancel", auth, requirePermission(user.PermSubscriptionsWrite), cancelSubscription(svcs))
+	f.Post("/v1/dunning-steps", auth, requirePermission(user.PermDunningWrite), createDunningStep(svcs))
+	f.Get("/v1/dunning-steps", auth, requirePermission(user.PermInvoicesRead), listDunningSteps(svcs))
+	f.Delete("/v1/dunning-steps/:id", auth, requirePermission(user.PermDunningWrite), deleteDunningStep(svcs))
+	f.Get("/v1/reports/aging", auth, requirePermission(user.PermInvoicesRead), agingReport(svcs))
+	f.Get("/v1/reports/revenue/products", auth, requirePermission(user.PermInvoicesRead), revenueByProduct(svcs))
+	f.Get("/v1/reports/revenue/months", auth, requirePermission(user.PermInvoicesRead), revenueByMonth(svcs))
+	f.Get("/v1/ledger/accounts", auth, requirePermission(user.PermInvoicesRead), listLedgerAccounts(svcs))
+	f.Get("/v1/ledger/entries", auth, requirePermission(user.PermInvoicesRead), listJournalEntries(svcs))
+	f.Get("/v1/ledger/trial-balance", auth, requirePermission(user.PermInvoicesRead), trialBalance(svcs))
 	f.Get("/swagger/*", swagger.WrapHandler)
 	return f
 }
@@ -72,16 +139,196 @@ func errorJSON(c *fiber.Ctx, httpStatus int, details detailsResp) error {
 	})
 }
 
-// authWare middleware for handlers that require user login.
-func authWare(c *fiber.Ctx) error {
-	token := c.Request().Header.Peek("Authorization")
-	_, err := jwt.Verify(string(token))
+// realm of the WWW-Authenticate challenges.
+const realm = "genesis"
+
+// Reasons of an invalid_token challenge, in its reason param, clients
+// refresh an expired token and sign in again on the others.
+const (
+	reasonExpired = "expired"
+	reasonRevoked = "revoked"
+	reasonInvalid = "invalid"
+)
+
+// authWare middleware for handlers that require authentication, either an
+// access token (Authorization: Bearer <jwt>, RFC 6750) or an API key
+// (Authorization: ApiKey <key>). Failures answer 401 with a
+// WWW-Authenticate challenge telling why. The principal is placed in the
+// request context, available to the next handlers through principalFrom,
+// with the claims of the access token through claimsFrom.
+func authWare(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scheme, credentials := authorization(string(c.Request().Header.Peek("Authorization")))
+		switch {
+		case strings.EqualFold(scheme, "Bearer"):
+		case strings.EqualFold(scheme, "ApiKey"):
+			return apiKeyAuth(c, svcs, credentials)
+		default:
+			// Without credentials the challenge has no error code.
+			c.Set(fiber.HeaderWWWAuthenticate, challenge("Bearer"))
+			return errorJSON(c, http.StatusUnauthorized, detailsResp{
+				Code:    "001",
+				Message: "You aren't authenticated",
+				Details: "Sign to access",
+			})
+		}
+		if !isToken68(credentials) {
+			c.Set(fiber.HeaderWWWAuthenticate, challenge("Bearer",
+				"error", "invalid_request",
+				"error_description", "The access token is malformed"))
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "001",
+				Message: "The access token is malformed",
+				Details: "Send it as Authorization: Bearer <token>",
+			})
+		}
+		claims, err := jwt.Verify(credentials)
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return invalidToken(c, reasonExpired, "The access token expired", "Refresh the token or sign in again")
+		}
+		if err != nil {
+			return invalidToken(c, reasonInvalid, "The access token is invalid", "Sign to access")
+		}
+		revoked, err := svcs.User.IsAccessRevoked(c.UserContext(), claims.ID)
+		if err != nil {
+			logger.Error("auth", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "001",
+				Message: "The token could not be checked",
+			})
+		}
+		if revoked {
+			return invalidToken(c, reasonRevoked, "The access token has been revoked", "Sign to access")
+		}
+		ctx := jwt.NewContext(c.UserContext(), claims)
+		c.SetUserContext(user.NewContext(ctx, user.Principal{
+			UserID:      claims.UserID,
+			Email:       claims.Email,
+			Permissions: claims.Permissions,
+		}))
+		return c.Next()
+	}
+}
+
+// invalidToken responds 401 with the invalid_token error of RFC 6750, and
+// the reason of the error.
+func invalidToken(c *fiber.Ctx, reason, message, details string) error {
+	c.Set(fiber.HeaderWWWAuthenticate, challenge("Bearer",
+		"error", "invalid_token",
+		"error_description", message,
+		"reason", reason))
+	return errorJSON(c, http.StatusUnauthorized, detailsResp{
+		Code:    "001",
+		Message: message,
+		Details: details,
+	})
+}
+
+// apiKeyAuth authenticates the request with an API key.
+func apiKeyAuth(c *fiber.Ctx, svcs *compose.Services, key string) error {
+	p, err := svcs.User.AuthenticateAPIKey(c.UserContext(), key)
+	if errors.Is(err, user.ErrAPIKeyNotFound) || errors.Is(err, user.ErrNotFound) {
+		err = user.ErrAPIKeyInvalid
+	}
+	if errors.Is(err, user.ErrAPIKeyInvalid) || errors.Is(err, user.ErrAPIKeyExpired) {
+		c.Set(fiber.HeaderWWWAuthenticate, challenge("ApiKey",
+			"error", "invalid_token",
+			"error_description", err.Error()))
+		return errorJSON(c, http.StatusUnauthorized, detailsResp{
+			Code:    "001",
+			Message: err.Error(),
+		})
+	}
 	if err != nil {
-		return errorJSON(c, http.StatusBadRequest, detailsResp{
+		logger.Error("auth", "err", err.Error())
+		return errorJSON(c, http.StatusInternalServerError, detailsResp{
 			Code:    "001",
-			Message: "You aren't authenticated",
-			Details: "Sign to access",
+			Message: "The API key could not be checked",
 		})
 	}
+	c.SetUserContext(user.NewContext(c.UserContext(), p))
 	return c.Next()
 }
+
+// authorization splits the Authorization header in its scheme and
+// credentials (RFC 7235), the credentials are empty if there are none.
+func authorization(header string) (scheme, credentials string) {
+	scheme, credentials, _ = strings.Cut(strings.TrimSpace(header), " ")
+	return scheme, strings.TrimLeft(credentials, " ")
+}
+
+// isToken68 reports whether s has the syntax of the token of a Bearer
+// credential (RFC 6750, section 2.1).
+func isToken68(s string) bool {
+	body := strings.TrimRight(s, "=")
+	if body == "" {
+		return false
+	}
+	for _, r := range body {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		case strings.ContainsRune("-._~+/", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// challenge returns a WWW-Authenticate challenge of scheme with the auth
+// params given as name and value pairs.
+func challenge(scheme string, params ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s realm=%q", scheme, realm)
+	for i := 0; i+1 < len(params); i += 2 {
+		fmt.Fprintf(&b, ", %s=%q", params[i], params[i+1])
+	}
+	return b.String()
+}
+
+// claimsFrom returns the claims verified by authWare, empty if the request
+// was authenticated with an API key.
+func claimsFrom(c *fiber.Ctx) jwt.Claims {
+	claims, _ := jwt.FromContext(c.UserContext())
+	return claims
+}
+
+// principalFrom returns the identity acting on the request, verified by
+// authWare.
+func principalFrom(c *fiber.Ctx) user.Principal {
+	p, _ := user.FromContext(c.UserContext())
+	return p
+}
+
+// requirePermission middleware for handlers that require a permission, it
+// must run after authWare.
+func requirePermission(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !principalFrom(c).Can(permission) {
+			c.Set(fiber.HeaderWWWAuthenticate, challenge("Bearer",
+				"error", "insufficient_scope",
+				"scope", permission))
+			return errorJSON(c, http.StatusForbidden, detailsResp{
+				Code:    "001",
+				Message: "You don't have permission to do this",
+				Details: "Permission required: " + permission,
+			})
+		}
+		return c.Next()
+	}
+}
+
+// requireSession middleware for handlers that require a user signed in with
+// an access token, API keys aren't accepted. It must run after authWare.
+func requireSession() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := jwt.FromContext(c.UserContext()); !ok {
+			return errorJSON(c, http.StatusForbidden, detailsResp{
+				Code:    "001",
+				Message: "An API key can't do this",
+				Details: "Sign in to access",
+			})
+		}
+		return c.Next()
+	}
+}
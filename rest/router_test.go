@@ -0,0 +1,251 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/compose"
+	"github.com/adrianolmedo/genesis/rest/jwt"
+	"github.com/adrianolmedo/genesis/user"
+
+	"github.com/gofiber/fiber/v2"
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+func TestRequirePermission(t *testing.T) {
+	tt := []struct {
+		name       string
+		principal  user.Principal
+		wantStatus int
+	}{
+		{
+			name:       "granted",
+			principal:  user.Principal{Permissions: []string{"users:read", "products:write"}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "denied",
+			principal:  user.Principal{Permissions: []string{"users:read"}},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "no-permissions",
+			principal:  user.Principal{},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tc := range tt {
+		app := fiber.New()
+		app.Get("/", func(c *fiber.Ctx) error {
+			c.SetUserContext(user.NewContext(c.UserContext(), tc.principal))
+			return c.Next()
+		}, requirePermission("products:write"), func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+		res, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != tc.wantStatus {
+			t.Errorf("%s: expected: %d, got: %d", tc.name, tc.wantStatus, res.StatusCode)
+		}
+		if tc.wantStatus == http.StatusForbidden {
+			want := `Bearer realm="genesis", error="insufficient_scope", scope="products:write"`
+			if got := res.Header.Get("WWW-Authenticate"); got != want {
+				t.Errorf("%s: want WWW-Authenticate %q, got %q", tc.name, want, got)
+			}
+		}
+	}
+}
+
+func TestRequireSession(t *testing.T) {
+	tt := []struct {
+		name       string
+		session    bool
+		wantStatus int
+	}{
+		{name: "access-token", session: true, wantStatus: http.StatusOK},
+		{name: "api-key", session: false, wantStatus: http.StatusForbidden},
+	}
+	for _, tc := range tt {
+		app := fiber.New()
+		app.Get("/", func(c *fiber.Ctx) error {
+			ctx := user.NewContext(c.UserContext(), user.Principal{UserID: 1})
+			if tc.session {
+				ctx = jwt.NewContext(ctx, jwt.Claims{UserID: 1})
+			}
+			c.SetUserContext(ctx)
+			return c.Next()
+		}, requireSession(), func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+		res, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != tc.wantStatus {
+			t.Errorf("%s: expected: %d, got: %d", tc.name, tc.wantStatus, res.StatusCode)
+		}
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	tt := []struct {
+		header          string
+		wantScheme      string
+		wantCredentials string
+	}{
+		{header: "Bearer eyJhbGciOi", wantScheme: "Bearer", wantCredentials: "eyJhbGciOi"},
+		{header: "bearer   eyJhbGciOi", wantScheme: "bearer", wantCredentials: "eyJhbGciOi"},
+		{header: "ApiKey gen_k3m9x2aq_secret", wantScheme: "ApiKey", wantCredentials: "gen_k3m9x2aq_secret"},
+		{header: "eyJhbGciOi", wantScheme: "eyJhbGciOi", wantCredentials: ""},
+		{header: "", wantScheme: "", wantCredentials: ""},
+	}
+	for _, tc := range tt {
+		scheme, credentials := authorization(tc.header)
+		if scheme != tc.wantScheme || credentials != tc.wantCredentials {
+			t.Errorf("%q: want (%q, %q), got (%q, %q)", tc.header, tc.wantScheme, tc.wantCredentials, scheme, credentials)
+		}
+	}
+}
+
+func TestAuthWare(t *testing.T) {
+	k, err := jwt.GenerateKey(jwt.EdDSA, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring := jwt.NewKeyRing(k)
+	ring.SetValidation(jwt.Validation{Issuer: "genesis", Audience: "api", ClockSkew: 30 * time.Second})
+	prev := jwt.Ring()
+	jwt.Use(ring)
+	t.Cleanup(func() { jwt.Use(prev) })
+
+	now := time.Now()
+	valid := gojwt.RegisteredClaims{
+		Issuer:    "genesis",
+		Audience:  gojwt.ClaimStrings{"api"},
+		IssuedAt:  gojwt.NewNumericDate(now),
+		NotBefore: gojwt.NewNumericDate(now),
+		ExpiresAt: gojwt.NewNumericDate(now.Add(time.Minute)),
+	}
+	sign := func(edit func(rc *gojwt.RegisteredClaims)) string {
+		rc := valid
+		edit(&rc)
+		token := gojwt.NewWithClaims(gojwt.SigningMethodEdDSA, jwt.Claims{RegisteredClaims: rc})
+		token.Header["kid"] = k.ID
+		s, err := token.SignedString(k.Private)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	const invalidToken = `Bearer realm="genesis", error="invalid_token"`
+	tt := []struct {
+		name          string
+		header        string
+		wantStatus    int
+		wantChallenge string // prefix of WWW-Authenticate
+	}{
+		{
+			name:          "no-header",
+			header:        "",
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="genesis"`,
+		},
+		{
+			name:          "other-scheme",
+			header:        "Basic dXNlcjpwYXNz",
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="genesis"`,
+		},
+		{
+			name:          "without-scheme",
+			header:        sign(func(rc *gojwt.RegisteredClaims) {}),
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="genesis"`,
+		},
+		{
+			name:          "empty-token",
+			header:        "Bearer ",
+			wantStatus:    http.StatusBadRequest,
+			wantChallenge: `Bearer realm="genesis", error="invalid_request"`,
+		},
+		{
+			name:          "malformed-token",
+			header:        "Bearer abc def",
+			wantStatus:    http.StatusBadRequest,
+			wantChallenge: `Bearer realm="genesis", error="invalid_request"`,
+		},
+		{
+			name:          "not-a-jwt",
+			header:        "Bearer abc.def.ghi",
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: invalidToken + `, error_description="The access token is invalid", reason="invalid"`,
+		},
+		{
+			name: "expired",
+			header: "Bearer " + sign(func(rc *gojwt.RegisteredClaims) {
+				rc.ExpiresAt = gojwt.NewNumericDate(now.Add(-time.Minute))
+			}),
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: invalidToken + `, error_description="The access token expired", reason="expired"`,
+		},
+		{
+			name: "not-valid-yet",
+			header: "Bearer " + sign(func(rc *gojwt.RegisteredClaims) {
+				rc.NotBefore = gojwt.NewNumericDate(now.Add(time.Minute))
+			}),
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: invalidToken + `, error_description="The access token is invalid"`,
+		},
+		{
+			name:          "other-issuer",
+			header:        "Bearer " + sign(func(rc *gojwt.RegisteredClaims) { rc.Issuer = "other" }),
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: invalidToken,
+		},
+		{
+			name:          "other-audience",
+			header:        "Bearer " + sign(func(rc *gojwt.RegisteredClaims) { rc.Audience = gojwt.ClaimStrings{"other"} }),
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: invalidToken,
+		},
+	}
+	app := fiber.New()
+	// Every case fails before the services are used.
+	app.Get("/", authWare(&compose.Services{}), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	for _, tc := range tt {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != tc.wantStatus {
+			t.Errorf("%s: expected: %d, got: %d", tc.name, tc.wantStatus, res.StatusCode)
+		}
+		if got := res.Header.Get("WWW-Authenticate"); !strings.HasPrefix(got, tc.wantChallenge) {
+			t.Errorf("%s: want WWW-Authenticate %q, got %q", tc.name, tc.wantChallenge, got)
+		}
+	}
+}
+
+func TestIsToken68(t *testing.T) {
+	for _, s := range []string{"abc", "a.b-c_d~e+f/g", "abc==", "eyJhbGciOi.eyJ1aWQiOjF9.c2ln"} {
+		if !isToken68(s) {
+			t.Errorf("%q: want token68", s)
+		}
+	}
+	for _, s := range []string{"", "==", "a b", "a=b", "a,b", `"abc"`} {
+		if isToken68(s) {
+			t.Errorf("%q: want not token68", s)
+		}
+	}
+}
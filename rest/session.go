@@ -0,0 +1,191 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/adrianolmedo/genesis/compose"
+	"github.com/adrianolmedo/genesis/logger"
+	"github.com/adrianolmedo/genesis/rest/jwt"
+	"github.com/adrianolmedo/genesis/user"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// refreshToken godoc
+//
+//	@Summary		Refresh token
+//	@Description	Exchange a refresh token for a new access/refresh token pair
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400				{object}	errorResp
+//	@Failure		401				{object}	errorResp
+//	@Failure		500				{object}	errorResp
+//	@Success		201				{object}	resp{data=dataTokenResp}
+//	@Param			refreshTokenReq	body		refreshTokenReq	true	"application/json"
+//	@Router			/token/refresh [post]
+func refreshToken(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		req := refreshTokenReq{}
+		err := c.BodyParser(&req)
+		if err != nil || req.RefreshToken == "" {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "A refreshToken is expected",
+			})
+		}
+		u, refresh, err := svcs.User.Refresh(ctx, req.RefreshToken)
+		if errors.Is(err, user.ErrTokenReused) {
+			return errorJSON(c, http.StatusUnauthorized, detailsResp{
+				Code:    "003",
+				Message: "The refresh token was already used",
+				Details: "The session has been revoked, sign in again",
+			})
+		}
+		// The user may have been deleted since the login.
+		if errors.Is(err, user.ErrSessionNotFound) ||
+			errors.Is(err, user.ErrSessionExpired) ||
+			errors.Is(err, user.ErrSessionRevoked) ||
+			errors.Is(err, user.ErrNotFound) {
+			return errorJSON(c, http.StatusUnauthorized, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+				Details: "Sign in again",
+			})
+		}
+		if err != nil {
+			logger.Error("refresh token", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The session could not be refreshed",
+			})
+		}
+		token, err := accessToken(ctx, svcs, u)
+		if err != nil {
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "004",
+				Message: "The token could not be generated",
+			})
+		}
+		return respJSON(c, http.StatusCreated, detailsResp{
+			Message: "Session refreshed",
+			Data:    newDataTokenResp(token, refresh),
+		})
+	}
+}
+
+// refreshTokenReq refresh token of a session.
+type refreshTokenReq struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// logoutUser godoc
+//
+//	@Summary		Logout user
+//	@Description	Close the session of the refresh token and revoke the access token
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Failure		401				{object}	errorResp
+//	@Failure		403				{object}	errorResp
+//	@Failure		500				{object}	errorResp
+//	@Success		200				{object}	resp
+//	@Param			refreshTokenReq	body		refreshTokenReq	false	"application/json"
+//	@Router			/logout [post]
+func logoutUser(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		req := refreshTokenReq{}
+		_ = c.BodyParser(&req) // the refresh token is optional
+		claims := claimsFrom(c)
+		err := svcs.User.Logout(ctx, claims.UserID, req.RefreshToken, claims.ID, claims.ExpiresAt.Time)
+		if errors.Is(err, user.ErrSessionInvalid) {
+			return errorJSON(c, http.StatusForbidden, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		if err != nil && !errors.Is(err, user.ErrSessionNotFound) {
+			logger.Error("logout", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The session could not be closed",
+			})
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "You are logged out",
+		})
+	}
+}
+
+// logoutAll godoc
+//
+//	@Summary		Logout everywhere
+//	@Description	Close every session of the user and revoke the access token
+//	@Tags			users
+//	@Produce		json
+//	@Failure		401	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp
+//	@Router			/logout/all [post]
+func logoutAll(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		claims := claimsFrom(c)
+		err := svcs.User.LogoutAll(ctx, claims.UserID, claims.ID, claims.ExpiresAt.Time)
+		if err != nil {
+			logger.Error("logout all", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The sessions could not be closed",
+			})
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "You are logged out from every session",
+		})
+	}
+}
+
+// accessToken generates an access token for u carrying its current roles
+// and permissions.
+func accessToken(ctx context.Context, svcs *compose.Services, u *user.User) (string, error) {
+	authz, err := svcs.User.Authorization(ctx, u.ID)
+	if err != nil {
+		return "", err
+	}
+	return jwt.Generate(jwt.Claims{
+		UserID:      u.ID,
+		Email:       u.Email,
+		Roles:       authz.Roles,
+		Permissions: authz.Permissions,
+	})
+}
+
+// respSession starts a session for u, authenticated, and responds with its
+// access and refresh tokens.
+func respSession(c *fiber.Ctx, svcs *compose.Services, u *user.User, message string) error {
+	ctx := c.UserContext()
+	token, err := accessToken(ctx, svcs, u)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, detailsResp{
+			Code:    "004",
+			Message: "The token could not be generated",
+		})
+	}
+	refresh, err := svcs.User.StartSession(ctx, u.ID)
+	if err != nil {
+		logger.Error("login", "err", err.Error())
+		return errorJSON(c, http.StatusInternalServerError, detailsResp{
+			Code:    "004",
+			Message: "The session could not be started",
+		})
+	}
+	return respJSON(c, http.StatusCreated, detailsResp{
+		Message: message,
+		Data:    newDataTokenResp(token, refresh),
+	})
+}
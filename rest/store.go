@@ -8,6 +8,7 @@ import (
 
 	"github.com/adrianolmedo/genesis/compose"
 	"github.com/adrianolmedo/genesis/logger"
+	"github.com/adrianolmedo/genesis/money"
 	"github.com/adrianolmedo/genesis/pgsql"
 	"github.com/adrianolmedo/genesis/store"
 
@@ -42,9 +43,23 @@ func addProduct(svcs *compose.Services) fiber.Handler {
 		product := &store.Product{
 			Name:         req.Name,
 			Observations: req.Observations,
-			Price:        req.Price,
+			Price:        money.New(req.Price, money.Currency(req.Currency)),
+			TaxCategory:  req.TaxCategory,
 		}
 		err = svcs.Store.Add(ctx, product)
+		if errors.Is(err, money.ErrUnknownCurrency) {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+				Details: "Expected an ISO 4217 code like EUR",
+			})
+		}
+		if errors.Is(err, store.ErrTaxCategoryNotFound) {
+			return errorJSON(c, http.StatusUnprocessableEntity, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
 		if err != nil {
 			return errorJSON(c, http.StatusInternalServerError, detailsResp{
 				Code:    "003",
@@ -58,6 +73,8 @@ func addProduct(svcs *compose.Services) fiber.Handler {
 				Name:         req.Name,
 				Observations: req.Observations,
 				Price:        req.Price,
+				Currency:     string(product.Price.Currency),
+				TaxCategory:  req.TaxCategory,
 			},
 		})
 	}
@@ -67,7 +84,9 @@ func addProduct(svcs *compose.Services) fiber.Handler {
 type addProductReq struct {
 	Name         string `json:"name"`
 	Observations string `json:"observations"`
-	Price        int64  `json:"price"`
+	Price        int64  `json:"price"`              // in minor units
+	Currency     string `json:"currency,omitempty"` // the default one if empty
+	TaxCategory  string `json:"taxCategory,omitempty"`
 }
 
 // productCardResp subset of Product fields.
@@ -76,6 +95,8 @@ type productCardResp struct {
 	Name         string `json:"name"`
 	Observations string `json:"observations"`
 	Price        int64  `json:"price"`
+	Currency     string `json:"currency"`
+	TaxCategory  string `json:"taxCategory,omitempty"`
 }
 
 // listProduct godoc
@@ -110,7 +131,9 @@ func listProducts(svcs *compose.Services) fiber.Handler {
 				ID:           p.ID,
 				Name:         p.Name,
 				Observations: p.Observations,
-				Price:        p.Price,
+				Price:        p.Price.Amount,
+				Currency:     string(p.Price.Currency),
+				TaxCategory:  p.TaxCategory,
 			}
 		}
 		for _, v := range products {
@@ -131,10 +154,11 @@ func listProducts(svcs *compose.Services) fiber.Handler {
 //	@Accept			json
 //	@Produce		json
 //	@Failure		400					{object}	errorResp
+//	@Failure		401					{object}	errorResp
 //	@Failure		500					{object}	errorResp
 //	@Success		201					{object}	resp{data=customerProfileResp}
 //	@Param			createCustomerReq	body		createCustomerReq	true	"application/json"
-//	@Router			/customer [post]
+//	@Router			/customers [post]
 func createCustomer(svcs *compose.Services) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		ctx := c.UserContext()
@@ -147,12 +171,15 @@ func createCustomer(svcs *compose.Services) fiber.Handler {
 				Details: "Check the JSON syntax in the structure",
 			})
 		}
-		err = svcs.Store.AddCustomer(ctx, &store.Customer{
-			FirstName: req.FirstName,
-			LastName:  req.LastName,
-			Email:     req.Email,
-			Password:  req.Password,
-		})
+		cx := &store.Customer{
+			FirstName:      req.FirstName,
+			LastName:       req.LastName,
+			Email:          req.Email,
+			Password:       req.Password,
+			BillingName:    req.BillingName,
+			BillingAddress: req.BillingAddress,
+		}
+		err = svcs.Store.AddCustomer(ctx, cx)
 		if err != nil {
 			return errorJSON(c, http.StatusInternalServerError, detailsResp{
 				Code:    "003",
@@ -162,9 +189,12 @@ func createCustomer(svcs *compose.Services) fiber.Handler {
 		return respJSON(c, http.StatusCreated, detailsResp{
 			Message: "Customer created",
 			Data: customerProfileResp{
-				FirstName: req.FirstName,
-				LastName:  req.LastName,
-				Email:     req.Email,
+				ID:             cx.ID,
+				FirstName:      cx.FirstName,
+				LastName:       cx.LastName,
+				Email:          cx.Email,
+				BillingName:    cx.BillingName,
+				BillingAddress: cx.BillingAddress,
 			},
 		})
 	}
@@ -172,18 +202,22 @@ func createCustomer(svcs *compose.Services) fiber.Handler {
 
 // customerProfileResp subset of Customer fields.
 type customerProfileResp struct {
-	ID        int64  `json:"id,omitempty"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	Email     string `json:"email"`
+	ID             int64  `json:"id,omitempty"`
+	FirstName      string `json:"firstName"`
+	LastName       string `json:"lastName"`
+	Email          string `json:"email"`
+	BillingName    string `json:"billingName,omitempty"`
+	BillingAddress string `json:"billingAddress,omitempty"`
 }
 
 // createCustomerReq subset of fields to request to create a Customer.
 type createCustomerReq struct {
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	Email     string `json:"email"`
-	Password  string `json:"password"`
+	FirstName      string `json:"firstName"`
+	LastName       string `json:"lastName"`
+	Email          string `json:"email"`
+	Password       string `json:"password"`
+	BillingName    string `json:"billingName" example:"Acme S.A. de C.V."`            // full name if empty
+	BillingAddress string `json:"billingAddress" example:"Av. Reforma 1, 06600 CDMX"` // printed on the invoices
 }
 
 // deleteCustomer godoc
@@ -195,6 +229,7 @@ type createCustomerReq struct {
 //	@Produce		json
 //	@Failure		400	{object}	errorResp
 //	@Failure		204	{object}	errorResp
+//	@Failure		401	{object}	errorResp
 //	@Failure		500	{object}	errorResp
 //	@Success		200	{object}	resp{data=customerProfileResp}
 //	@Param			id	path		int	true	"Customer id"
@@ -237,6 +272,7 @@ func deleteCustomer(svcs *compose.Services) fiber.Handler {
 //	@Accept			json
 //	@Produce		json
 //	@Failure		400			{object}	errorResp
+//	@Failure		401			{object}	errorResp
 //	@Failure		500			{object}	errorResp
 //	@Success		200			{object}	filterResp{links=pgsql.FilterLinks,meta=pgsql.FilterResult,data=[]customerProfileResp}
 //	@Param			limit		query		int		false	"Limit of pages"					example(2)
@@ -275,10 +311,12 @@ func listCustomers(svcs *compose.Services) fiber.Handler {
 		// assemble helper for transform to DTO
 		assemble := func(cx store.Customer) customerProfileResp {
 			return customerProfileResp{
-				ID:        cx.ID,
-				FirstName: cx.FirstName,
-				LastName:  cx.LastName,
-				Email:     cx.Email,
+				ID:             cx.ID,
+				FirstName:      cx.FirstName,
+				LastName:       cx.LastName,
+				Email:          cx.Email,
+				BillingName:    cx.BillingName,
+				BillingAddress: cx.BillingAddress,
 			}
 		}
 		data := make([]customerProfileResp, 0, len(customers))
@@ -333,7 +371,9 @@ func findProduct(svcs *compose.Services) fiber.Handler {
 				ID:           product.ID,
 				Name:         product.Name,
 				Observations: product.Observations,
-				Price:        product.Price,
+				Price:        product.Price.Amount,
+				Currency:     string(product.Price.Currency),
+				TaxCategory:  product.TaxCategory,
 			},
 		})
 	}
@@ -377,7 +417,8 @@ func updateProduct(svcs *compose.Services) fiber.Handler {
 			ID:           req.ID,
 			Name:         req.Name,
 			Observations: req.Observations,
-			Price:        req.Price,
+			Price:        money.New(req.Price, money.Currency(req.Currency)),
+			TaxCategory:  req.TaxCategory,
 		})
 		if errors.Is(err, store.ErrProductNotFound) {
 			return errorJSON(c, http.StatusNoContent, detailsResp{
@@ -385,6 +426,19 @@ func updateProduct(svcs *compose.Services) fiber.Handler {
 				Message: err.Error(),
 			})
 		}
+		if errors.Is(err, money.ErrUnknownCurrency) {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+				Details: "Expected an ISO 4217 code like EUR",
+			})
+		}
+		if errors.Is(err, store.ErrTaxCategoryNotFound) {
+			return errorJSON(c, http.StatusUnprocessableEntity, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
 		if err != nil {
 			return errorJSON(c, http.StatusInternalServerError, detailsResp{
 				Code:    "002",
@@ -404,7 +458,9 @@ type updateProductReq struct {
 	ID           int64  `json:"id"`
 	Name         string `json:"name"`
 	Observations string `json:"observations"`
-	Price        int64  `json:"price"`
+	Price        int64  `json:"price"`              // in minor units
+	Currency     string `json:"currency,omitempty"` // the default one if empty
+	TaxCategory  string `json:"taxCategory,omitempty"`
 }
 
 // deleteProduct godoc
@@ -0,0 +1,584 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/compose"
+	"github.com/adrianolmedo/genesis/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// createPlan godoc
+//
+//	@Summary		Create subscription plan
+//	@Description	Create a plan of products invoiced in advance every interval of months, at the price of the products then
+//	@Tags			subscriptions
+//	@Accept			json
+//	@Produce		json
+//	@Param			planReq	body		planReq	true	"application/json"
+//	@Failure		400		{object}	errorResp
+//	@Failure		401		{object}	errorResp
+//	@Failure		403		{object}	errorResp
+//	@Failure		422		{object}	errorResp
+//	@Failure		500		{object}	errorResp
+//	@Success		201		{object}	resp{data=planResp}
+//	@Router			/subscription-plans [post]
+func createPlan(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		req := planReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		p := &billing.Plan{Name: req.Name, Interval: req.IntervalMonths}
+		for _, it := range req.Items {
+			quantity := it.Quantity
+			if quantity == 0 {
+				quantity = 1
+			}
+			p.Items = append(p.Items, billing.PlanItem{ProductID: it.ProductID, Quantity: quantity})
+		}
+		err := svcs.Billing.CreatePlan(ctx, p)
+		switch {
+		case errors.Is(err, billing.ErrPlanNameCantBeEmpty),
+			errors.Is(err, billing.ErrInvalidInterval),
+			errors.Is(err, billing.ErrPlanItemsCantBeEmpty),
+			errors.Is(err, billing.ErrInvalidQuantity),
+			errors.Is(err, billing.ErrDuplicatePlanProduct):
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		case errors.Is(err, billing.ErrPlanProductNotFound), errors.Is(err, billing.ErrPlanCurrencyMismatch):
+			return errorJSON(c, http.StatusUnprocessableEntity, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		case err != nil:
+			logger.Error("create subscription plan", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The subscription plan could not be created",
+			})
+		}
+		return respJSON(c, http.StatusCreated, detailsResp{
+			Message: "Subscription plan created",
+			Data:    toPlanResp(*p),
+		})
+	}
+}
+
+// planReq fields to create a subscription plan.
+type planReq struct {
+	Name           string        `json:"name" example:"Team"`
+	IntervalMonths int           `json:"intervalMonths" example:"1"` // 1 to 12
+	Items          []planItemReq `json:"items"`
+}
+
+// planItemReq a product of a plan.
+type planItemReq struct {
+	ProductID int64 `json:"productId"`
+	Quantity  int   `json:"quantity,omitempty" example:"1"` // 1 if omitted
+}
+
+// planResp a subscription plan, the prices are in minor units of its
+// currency.
+type planResp struct {
+	ID             int64          `json:"id"`
+	Name           string         `json:"name" example:"Team"`
+	IntervalMonths int            `json:"intervalMonths" example:"1"`
+	Currency       string         `json:"currency,omitempty" example:"EUR"` // of the prices of its products
+	Items          []planItemResp `json:"items"`
+	CreatedAt      time.Time      `json:"createdAt"`
+}
+
+// planItemResp a product of a plan at its current price.
+type planItemResp struct {
+	ProductID   int64  `json:"productId"`
+	ProductName string `json:"productName,omitempty" example:"Seat"`
+	Quantity    int    `json:"quantity" example:"1"`
+	UnitPrice   int64  `json:"unitPrice" example:"3000"`
+	TaxCategory string `json:"taxCategory,omitempty" example:"standard"`
+}
+
+// toPlanResp converts a billing.Plan to its response.
+func toPlanResp(p billing.Plan) planResp {
+	items := make([]planItemResp, 0, len(p.Items))
+	for _, it := range p.Items {
+		items = append(items, planItemResp{
+			ProductID:   it.ProductID,
+			ProductName: it.ProductName,
+			Quantity:    it.Quantity,
+			UnitPrice:   it.UnitPrice.Amount,
+			TaxCategory: it.TaxCategory,
+		})
+	}
+	var currency string
+	if len(p.Items) > 0 {
+		currency = string(p.Items[0].UnitPrice.Currency)
+	}
+	return planResp{
+		ID:             p.ID,
+		Name:           p.Name,
+		IntervalMonths: p.Interval,
+		Currency:       currency,
+		Items:          items,
+		CreatedAt:      p.CreatedAt,
+	}
+}
+
+// listPlans godoc
+//
+//	@Summary		List subscription plans
+//	@Description	Get all the subscription plans with their products
+//	@Tags			subscriptions
+//	@Produce		json
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=[]planResp}
+//	@Router			/subscription-plans [get]
+func listPlans(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		plans, err := svcs.Billing.Plans(c.UserContext())
+		if err != nil {
+			logger.Error("list subscription plans", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The subscription plans could not be read",
+			})
+		}
+		list := make([]planResp, 0, len(plans))
+		for _, p := range plans {
+			list = append(list, toPlanResp(p))
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    list,
+		})
+	}
+}
+
+// subscribe godoc
+//
+//	@Summary		Subscribe
+//	@Description	Subscribe a customer to a plan from the anchor date, now if omitted. Each period is invoiced and issued when it starts, or kept as a draft if SUBSCRIPTION_DRAFT_INVOICES is set, and renews on the day of the month of the anchor
+//	@Tags			subscriptions
+//	@Accept			json
+//	@Produce		json
+//	@Param			subscriptionReq	body		subscriptionReq	true	"application/json"
+//	@Failure		400				{object}	errorResp
+//	@Failure		401				{object}	errorResp
+//	@Failure		403				{object}	errorResp
+//	@Failure		404				{object}	errorResp
+//	@Failure		422				{object}	errorResp
+//	@Failure		500				{object}	errorResp
+//	@Success		201				{object}	resp{data=subscriptionResp}
+//	@Router			/subscriptions [post]
+func subscribe(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		req := subscriptionReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		quantity := req.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+		sub := &billing.Subscription{
+			ClientID:     req.ClientID,
+			PlanID:       req.PlanID,
+			Quantity:     quantity,
+			Jurisdiction: req.Jurisdiction,
+		}
+		if req.Anchor != nil {
+			sub.Anchor = *req.Anchor
+		}
+		err := svcs.Billing.Subscribe(ctx, sub)
+		switch {
+		case errors.Is(err, billing.ErrClientIDCantBeEmpty), errors.Is(err, billing.ErrInvalidQuantity):
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		case errors.Is(err, billing.ErrCustomerDeleted):
+			return errorJSON(c, http.StatusUnprocessableEntity, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		case errors.Is(err, billing.ErrCustomerNotFound), errors.Is(err, billing.ErrPlanNotFound):
+			return errorJSON(c, http.StatusNotFound, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		case err != nil:
+			logger.Error("subscribe", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The subscription could not be created",
+			})
+		}
+		return respJSON(c, http.StatusCreated, detailsResp{
+			Message: "Subscription created",
+			Data:    toSubscriptionResp(*sub),
+		})
+	}
+}
+
+// subscriptionReq fields to subscribe a customer to a plan.
+type subscriptionReq struct {
+	ClientID     int64      `json:"clientId"`
+	PlanID       int64      `json:"planId"`
+	Quantity     int        `json:"quantity,omitempty" example:"5"`      // 1 if omitted
+	Jurisdiction string     `json:"jurisdiction,omitempty" example:"MX"` // the default one if omitted
+	Anchor       *time.Time `json:"anchor,omitempty"`                    // now if omitted
+}
+
+// subscriptionResp a subscription, nextPeriod is the first period not
+// invoiced and nextBillAt its start.
+type subscriptionResp struct {
+	ID             int64      `json:"id"`
+	ClientID       int64      `json:"clientId"`
+	PlanID         int64      `json:"planId"`
+	Quantity       int        `json:"quantity" example:"5"`
+	IntervalMonths int        `json:"intervalMonths" example:"1"`
+	Status         string     `json:"status" example:"active"`
+	Jurisdiction   string     `json:"jurisdiction,omitempty" example:"MX"`
+	Anchor         time.Time  `json:"anchor"`
+	NextPeriod     int        `json:"nextPeriod" example:"0"`
+	NextBillAt     time.Time  `json:"nextBillAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	CanceledAt     *time.Time `json:"canceledAt,omitempty"`
+}
+
+// toSubscriptionResp converts a billing.Subscription to its response.
+func toSubscriptionResp(s billing.Subscription) subscriptionResp {
+	return subscriptionResp{
+		ID:             s.ID,
+		ClientID:       s.ClientID,
+		PlanID:         s.PlanID,
+		Quantity:       s.Quantity,
+		IntervalMonths: s.Interval,
+		Status:         string(s.Status),
+		Jurisdiction:   s.Jurisdiction,
+		Anchor:         s.Anchor,
+		NextPeriod:     s.Next,
+		NextBillAt:     s.NextBillAt,
+		CreatedAt:      s.CreatedAt,
+		CanceledAt:     s.CanceledAt,
+	}
+}
+
+// findSubscription godoc
+//
+//	@Summary		Find subscription
+//	@Description	Get a subscription
+//	@Tags			subscriptions
+//	@Produce		json
+//	@Param			id	path		int	true	"Subscription id"
+//	@Failure		400	{object}	errorResp
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		404	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=subscriptionResp}
+//	@Router			/subscriptions/{id} [get]
+func findSubscription(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID subscription",
+			})
+		}
+		sub, err := svcs.Billing.FindSubscription(c.UserContext(), int64(id))
+		if err != nil {
+			return subscriptionError(c, "find subscription", "The subscription could not be read", err)
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    toSubscriptionResp(*sub),
+		})
+	}
+}
+
+// listCustomerSubscriptions godoc
+//
+//	@Summary		List customer subscriptions
+//	@Description	Get the subscriptions of a customer, canceled included
+//	@Tags			subscriptions
+//	@Produce		json
+//	@Param			id	path		int	true	"Customer id"
+//	@Failure		400	{object}	errorResp
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=[]subscriptionResp}
+//	@Router			/customers/{id}/subscriptions [get]
+func listCustomerSubscriptions(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID customer",
+			})
+		}
+		subs, err := svcs.Billing.Subscriptions(c.UserContext(), int64(id))
+		if err != nil {
+			logger.Error("list customer subscriptions", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The subscriptions could not be read",
+			})
+		}
+		list := make([]subscriptionResp, 0, len(subs))
+		for _, s := range subs {
+			list = append(list, toSubscriptionResp(s))
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    list,
+		})
+	}
+}
+
+// changeSubscription godoc
+//
+//	@Summary		Change subscription
+//	@Description	Change the plan, of the same interval, or the quantity of a subscription now. The rest of the period invoiced is prorated as charges and credits, invoiced with the next period
+//	@Tags			subscriptions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id						path		int						true	"Subscription id"
+//	@Param			changeSubscriptionReq	body		changeSubscriptionReq	true	"application/json"
+//	@Failure		400						{object}	errorResp
+//	@Failure		401						{object}	errorResp
+//	@Failure		403						{object}	errorResp
+//	@Failure		404						{object}	errorResp
+//	@Failure		409						{object}	errorResp
+//	@Failure		422						{object}	errorResp
+//	@Failure		500						{object}	errorResp
+//	@Success		200						{object}	resp{data=subscriptionChangeResp}
+//	@Router			/subscriptions/{id} [patch]
+func changeSubscription(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID subscription",
+			})
+		}
+		req := changeSubscriptionReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		sub, err := svcs.Billing.FindSubscription(ctx, int64(id))
+		if err != nil {
+			return subscriptionError(c, "change subscription", "The subscription could not be changed", err)
+		}
+		planID, quantity := sub.PlanID, sub.Quantity
+		if req.PlanID != 0 {
+			planID = req.PlanID
+		}
+		if req.Quantity != 0 {
+			quantity = req.Quantity
+		}
+		sub, adjustments, err := svcs.Billing.ChangeSubscription(ctx, int64(id), planID, quantity)
+		if err != nil {
+			return subscriptionError(c, "change subscription", "The subscription could not be changed", err)
+		}
+		list := make([]adjustmentResp, 0, len(adjustments))
+		for _, a := range adjustments {
+			list = append(list, adjustmentResp{
+				ID:          a.ID,
+				ProductID:   a.ProductID,
+				Description: a.Description,
+				Amount:      a.Amount,
+			})
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Subscription changed",
+			Data:    subscriptionChangeResp{Subscription: toSubscriptionResp(*sub), Adjustments: list},
+		})
+	}
+}
+
+// changeSubscriptionReq fields to change a subscription, those omitted
+// don't change.
+type changeSubscriptionReq struct {
+	PlanID   int64 `json:"planId,omitempty"`
+	Quantity int   `json:"quantity,omitempty" example:"8"`
+}
+
+// subscriptionChangeResp a subscription changed and the adjustments of the
+// change.
+type subscriptionChangeResp struct {
+	Subscription subscriptionResp `json:"subscription"`
+	Adjustments  []adjustmentResp `json:"adjustments"`
+}
+
+// adjustmentResp a prorated amount invoiced with the next period, a charge
+// if positive else a credit, in minor units of the currency.
+type adjustmentResp struct {
+	ID          int64  `json:"id"`
+	ProductID   int64  `json:"productId,omitempty"`
+	Description string `json:"description" example:"Seat, prorated 2024-04-16 to 2024-05-01"`
+	Amount      int64  `json:"amount" example:"1500"`
+}
+
+// cancelSubscription godoc
+//
+//	@Summary		Cancel subscription
+//	@Description	Cancel a subscription now, its next periods aren't invoiced and the period invoiced isn't refunded
+//	@Tags			subscriptions
+//	@Produce		json
+//	@Param			id	path		int	true	"Subscription id"
+//	@Failure		400	{object}	errorResp
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		404	{object}	errorResp
+//	@Failure		409	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=subscriptionResp}
+//	@Router			/subscriptions/{id}/cancel [post]
+func cancelSubscription(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID subscription",
+			})
+		}
+		sub, err := svcs.Billing.CancelSubscription(c.UserContext(), int64(id))
+		if err != nil {
+			return subscriptionError(c, "cancel subscription", "The subscription could not be canceled", err)
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Subscription canceled",
+			Data:    toSubscriptionResp(*sub),
+		})
+	}
+}
+
+// subscriptionError responds the error of a subscription request, message
+// if it's unexpected.
+func subscriptionError(c *fiber.Ctx, op, message string, err error) error {
+	switch {
+	case errors.Is(err, billing.ErrInvalidQuantity):
+		return errorJSON(c, http.StatusBadRequest, detailsResp{
+			Code:    "002",
+			Message: err.Error(),
+		})
+	case errors.Is(err, billing.ErrSubscriptionNotFound), errors.Is(err, billing.ErrPlanNotFound):
+		return errorJSON(c, http.StatusNotFound, detailsResp{
+			Code:    "002",
+			Message: err.Error(),
+		})
+	case errors.Is(err, billing.ErrSubscriptionCanceled):
+		return errorJSON(c, http.StatusConflict, detailsResp{
+			Code:    "003",
+			Message: err.Error(),
+		})
+	case errors.Is(err, billing.ErrPlanIntervalDiffers), errors.Is(err, billing.ErrPlanCurrencyMismatch),
+		errors.Is(err, billing.ErrAmountOverflow):
+		return errorJSON(c, http.StatusUnprocessableEntity, detailsResp{
+			Code:    "002",
+			Message: err.Error(),
+		})
+	}
+	logger.Error(op, "err", err.Error())
+	return errorJSON(c, http.StatusInternalServerError, detailsResp{
+		Code:    "003",
+		Message: message,
+	})
+}
+
+// nextRun godoc
+//
+//	@Summary		Next subscription run
+//	@Description	Dry run of the scheduler: the invoices it would generate at a time, now if omitted, computed but not created
+//	@Tags			subscriptions
+//	@Produce		json
+//	@Param			at	query		string	false	"RFC 3339 time or YYYY-MM-DD"
+//	@Failure		400	{object}	errorResp
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		422	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=[]renewalResp}
+//	@Router			/subscriptions/next-run [get]
+func nextRun(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		at := time.Now()
+		if s := c.Query("at"); s != "" {
+			t, _, err := parseDate(s)
+			if err != nil {
+				return errorJSON(c, http.StatusBadRequest, detailsResp{
+					Code:    "002",
+					Message: "Invalid at, expected RFC 3339 or YYYY-MM-DD",
+				})
+			}
+			at = t
+		}
+		renewals, err := svcs.Billing.PreviewDue(c.UserContext(), at)
+		if errors.Is(err, billing.ErrTaxRateNotFound) || errors.Is(err, billing.ErrAmountOverflow) {
+			return errorJSON(c, http.StatusUnprocessableEntity, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			logger.Error("next subscription run", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The next run could not be computed",
+			})
+		}
+		list := make([]renewalResp, 0, len(renewals))
+		for _, r := range renewals {
+			list = append(list, renewalResp{
+				SubscriptionID: r.Subscription.ID,
+				Period:         r.Period,
+				Start:          r.Start,
+				End:            r.End,
+				Invoice:        toInvoiceResp(r.Invoice),
+			})
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    list,
+		})
+	}
+}
+
+// renewalResp the invoice of a period of a subscription.
+type renewalResp struct {
+	SubscriptionID int64       `json:"subscriptionId"`
+	Period         int         `json:"period" example:"3"`
+	Start          time.Time   `json:"start"`
+	End            time.Time   `json:"end"`
+	Invoice        invoiceResp `json:"invoice"`
+}
@@ -0,0 +1,392 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/compose"
+	"github.com/adrianolmedo/genesis/logger"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// createTaxCategory godoc
+//
+//	@Summary		Create tax category
+//	@Description	Create a category to tax products alike, e.g. standard or reduced. Products without category aren't taxed
+//	@Tags			taxes
+//	@Accept			json
+//	@Produce		json
+//	@Param			taxCategoryReq	body		taxCategoryReq	true	"application/json"
+//	@Failure		400				{object}	errorResp
+//	@Failure		401				{object}	errorResp
+//	@Failure		403				{object}	errorResp
+//	@Failure		409				{object}	errorResp
+//	@Failure		500				{object}	errorResp
+//	@Success		201				{object}	resp{data=taxCategoryResp}
+//	@Router			/tax-categories [post]
+func createTaxCategory(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		req := taxCategoryReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		tc := &billing.TaxCategory{Code: req.Code, Name: req.Name}
+		err := svcs.Billing.CreateTaxCategory(ctx, tc)
+		switch {
+		case errors.Is(err, billing.ErrTaxCategoryCodeCantBeEmpty), errors.Is(err, billing.ErrTaxNameCantBeEmpty):
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		case errors.Is(err, billing.ErrTaxCategoryExists):
+			return errorJSON(c, http.StatusConflict, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		case err != nil:
+			logger.Error("create tax category", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The tax category could not be created",
+			})
+		}
+		return respJSON(c, http.StatusCreated, detailsResp{
+			Message: "Tax category created",
+			Data:    taxCategoryResp{Code: tc.Code, Name: tc.Name, CreatedAt: tc.CreatedAt},
+		})
+	}
+}
+
+// taxCategoryReq fields to create a tax category.
+type taxCategoryReq struct {
+	Code string `json:"code" example:"standard"`
+	Name string `json:"name" example:"Standard rate"`
+}
+
+// taxCategoryResp a tax category.
+type taxCategoryResp struct {
+	Code      string    `json:"code" example:"standard"`
+	Name      string    `json:"name" example:"Standard rate"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// listTaxCategories godoc
+//
+//	@Summary		List tax categories
+//	@Description	Get all the tax categories
+//	@Tags			taxes
+//	@Produce		json
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=[]taxCategoryResp}
+//	@Router			/tax-categories [get]
+func listTaxCategories(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		categories, err := svcs.Billing.TaxCategories(c.UserContext())
+		if err != nil {
+			logger.Error("list tax categories", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The tax categories could not be read",
+			})
+		}
+		list := make([]taxCategoryResp, 0, len(categories))
+		for _, tc := range categories {
+			list = append(list, taxCategoryResp{Code: tc.Code, Name: tc.Name, CreatedAt: tc.CreatedAt})
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    list,
+		})
+	}
+}
+
+// createTaxRate godoc
+//
+//	@Summary		Create tax rate
+//	@Description	Create the rate of a tax category in a jurisdiction from a date, until another one if validTo is given. The periods of a category in a jurisdiction can't overlap
+//	@Tags			taxes
+//	@Accept			json
+//	@Produce		json
+//	@Param			taxRateReq	body		taxRateReq	true	"application/json"
+//	@Failure		400			{object}	errorResp
+//	@Failure		401			{object}	errorResp
+//	@Failure		403			{object}	errorResp
+//	@Failure		409			{object}	errorResp
+//	@Failure		422			{object}	errorResp
+//	@Failure		500			{object}	errorResp
+//	@Success		201			{object}	resp{data=taxRateResp}
+//	@Router			/tax-rates [post]
+func createTaxRate(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		req := taxRateReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		t := &billing.TaxRate{
+			Category:     req.Category,
+			Jurisdiction: req.Jurisdiction,
+			Name:         req.Name,
+			Rate:         req.Rate,
+			ValidFrom:    req.ValidFrom,
+			ValidTo:      req.ValidTo,
+		}
+		err := svcs.Billing.CreateTaxRate(ctx, t)
+		switch {
+		case errors.Is(err, billing.ErrTaxCategoryCodeCantBeEmpty),
+			errors.Is(err, billing.ErrJurisdictionCantBeEmpty),
+			errors.Is(err, billing.ErrTaxNameCantBeEmpty),
+			errors.Is(err, billing.ErrInvalidTaxRate),
+			errors.Is(err, billing.ErrInvalidValidity):
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		case errors.Is(err, billing.ErrTaxCategoryNotFound):
+			return errorJSON(c, http.StatusUnprocessableEntity, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		case errors.Is(err, billing.ErrTaxRateOverlaps):
+			return errorJSON(c, http.StatusConflict, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		case err != nil:
+			logger.Error("create tax rate", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The tax rate could not be created",
+			})
+		}
+		return respJSON(c, http.StatusCreated, detailsResp{
+			Message: "Tax rate created",
+			Data:    toTaxRateResp(*t),
+		})
+	}
+}
+
+// taxRateReq fields to create a tax rate.
+type taxRateReq struct {
+	Category     string     `json:"category" example:"standard"`
+	Jurisdiction string     `json:"jurisdiction" example:"MX"`
+	Name         string     `json:"name" example:"IVA 16%"`
+	Rate         int        `json:"rate" example:"1600"` // basis points, 1600 = 16%
+	ValidFrom    time.Time  `json:"validFrom"`
+	ValidTo      *time.Time `json:"validTo,omitempty"` // open if omitted, excluded
+}
+
+// taxRateResp a tax rate.
+type taxRateResp struct {
+	ID           int64      `json:"id"`
+	Category     string     `json:"category" example:"standard"`
+	Jurisdiction string     `json:"jurisdiction" example:"MX"`
+	Name         string     `json:"name" example:"IVA 16%"`
+	Rate         int        `json:"rate" example:"1600"`
+	ValidFrom    time.Time  `json:"validFrom"`
+	ValidTo      *time.Time `json:"validTo,omitempty"`
+}
+
+// toTaxRateResp converts a billing.TaxRate to its response.
+func toTaxRateResp(t billing.TaxRate) taxRateResp {
+	return taxRateResp{
+		ID:           t.ID,
+		Category:     t.Category,
+		Jurisdiction: t.Jurisdiction,
+		Name:         t.Name,
+		Rate:         t.Rate,
+		ValidFrom:    t.ValidFrom,
+		ValidTo:      t.ValidTo,
+	}
+}
+
+// listTaxRates godoc
+//
+//	@Summary		List tax rates
+//	@Description	Get the tax rates of all the categories, jurisdictions and periods
+//	@Tags			taxes
+//	@Produce		json
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=[]taxRateResp}
+//	@Router			/tax-rates [get]
+func listTaxRates(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rates, err := svcs.Billing.TaxRates(c.UserContext())
+		if err != nil {
+			logger.Error("list tax rates", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The tax rates could not be read",
+			})
+		}
+		list := make([]taxRateResp, 0, len(rates))
+		for _, t := range rates {
+			list = append(list, toTaxRateResp(t))
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    list,
+		})
+	}
+}
+
+// createTaxExemption godoc
+//
+//	@Summary		Create tax exemption
+//	@Description	Exempt a customer of the taxes of a category, or of all of them if no category is given, in a jurisdiction from a date, until another one if validTo is given
+//	@Tags			taxes
+//	@Accept			json
+//	@Produce		json
+//	@Param			id					path		int					true	"Customer id, the client of the invoices"
+//	@Param			taxExemptionReq		body		taxExemptionReq		true	"application/json"
+//	@Failure		400					{object}	errorResp
+//	@Failure		401					{object}	errorResp
+//	@Failure		403					{object}	errorResp
+//	@Failure		422					{object}	errorResp
+//	@Failure		500					{object}	errorResp
+//	@Success		201					{object}	resp{data=taxExemptionResp}
+//	@Router			/customers/{id}/tax-exemptions [post]
+func createTaxExemption(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID customer",
+			})
+		}
+		req := taxExemptionReq{}
+		if err := c.BodyParser(&req); err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "The JSON structure is not correct",
+				Details: "Check the JSON syntax in the structure",
+			})
+		}
+		e := &billing.TaxExemption{
+			ClientID:     int64(id),
+			Category:     req.Category,
+			Jurisdiction: req.Jurisdiction,
+			Reason:       req.Reason,
+			ValidFrom:    req.ValidFrom,
+			ValidTo:      req.ValidTo,
+		}
+		err = svcs.Billing.CreateTaxExemption(ctx, e)
+		switch {
+		case errors.Is(err, billing.ErrJurisdictionCantBeEmpty),
+			errors.Is(err, billing.ErrExemptionReasonCantBeEmpty),
+			errors.Is(err, billing.ErrInvalidValidity):
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		case errors.Is(err, billing.ErrTaxCategoryNotFound):
+			return errorJSON(c, http.StatusUnprocessableEntity, detailsResp{
+				Code:    "002",
+				Message: err.Error(),
+			})
+		case err != nil:
+			logger.Error("create tax exemption", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The tax exemption could not be created",
+			})
+		}
+		return respJSON(c, http.StatusCreated, detailsResp{
+			Message: "Tax exemption created",
+			Data:    toTaxExemptionResp(*e),
+		})
+	}
+}
+
+// taxExemptionReq fields to exempt a customer of taxes.
+type taxExemptionReq struct {
+	Category     string     `json:"category,omitempty" example:"standard"` // all if omitted
+	Jurisdiction string     `json:"jurisdiction" example:"MX"`
+	Reason       string     `json:"reason" example:"Non-profit, certificate 123"`
+	ValidFrom    time.Time  `json:"validFrom"`
+	ValidTo      *time.Time `json:"validTo,omitempty"` // open if omitted, excluded
+}
+
+// taxExemptionResp a tax exemption of a customer.
+type taxExemptionResp struct {
+	ID           int64      `json:"id"`
+	CustomerID   int64      `json:"customerId"`
+	Category     string     `json:"category,omitempty" example:"standard"`
+	Jurisdiction string     `json:"jurisdiction" example:"MX"`
+	Reason       string     `json:"reason" example:"Non-profit, certificate 123"`
+	ValidFrom    time.Time  `json:"validFrom"`
+	ValidTo      *time.Time `json:"validTo,omitempty"`
+}
+
+// toTaxExemptionResp converts a billing.TaxExemption to its response.
+func toTaxExemptionResp(e billing.TaxExemption) taxExemptionResp {
+	return taxExemptionResp{
+		ID:           e.ID,
+		CustomerID:   e.ClientID,
+		Category:     e.Category,
+		Jurisdiction: e.Jurisdiction,
+		Reason:       e.Reason,
+		ValidFrom:    e.ValidFrom,
+		ValidTo:      e.ValidTo,
+	}
+}
+
+// listTaxExemptions godoc
+//
+//	@Summary		List tax exemptions
+//	@Description	Get the tax exemptions of a customer
+//	@Tags			taxes
+//	@Produce		json
+//	@Param			id	path		int	true	"Customer id, the client of the invoices"
+//	@Failure		400	{object}	errorResp
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp{data=[]taxExemptionResp}
+//	@Router			/customers/{id}/tax-exemptions [get]
+func listTaxExemptions(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID customer",
+			})
+		}
+		exemptions, err := svcs.Billing.TaxExemptions(c.UserContext(), int64(id))
+		if err != nil {
+			logger.Error("list tax exemptions", "err", err.Error())
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: "The tax exemptions could not be read",
+			})
+		}
+		list := make([]taxExemptionResp, 0, len(exemptions))
+		for _, e := range exemptions {
+			list = append(list, toTaxExemptionResp(e))
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "Ok",
+			Data:    list,
+		})
+	}
+}
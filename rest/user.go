@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/adrianolmedo/genesis/compose"
 	"github.com/adrianolmedo/genesis/logger"
@@ -19,13 +21,16 @@ import (
 // loginUser godoc
 //
 //	@Summary		Login user
-//	@Description	User authentication
+//	@Description	User authentication, users with MFA get a challenge to answer at /login/mfa
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Failure		400				{object}	errorResp
 //	@Failure		401				{object}	errorResp
+//	@Failure		403				{object}	errorResp
+//	@Failure		429				{object}	errorResp
 //	@Failure		500				{object}	errorResp
+//	@Success		200				{object}	resp{data=mfaChallengeResp}
 //	@Success		201				{object}	resp{data=dataTokenResp}
 //	@Param			userLoginReq	body		userLoginReq	true	"application/json"
 //	@Router			/login [post]
@@ -41,7 +46,7 @@ func loginUser(svcs *compose.Services) fiber.Handler {
 				Details: "Check the JSON syntax in the structure",
 			})
 		}
-		err = svcs.User.Login(ctx, req.Email, req.Password)
+		u, err := svcs.User.Login(ctx, req.Email, req.Password)
 		if errors.Is(err, context.DeadlineExceeded) {
 			logger.Warn("login timeout", "email", req.Email)
 			return errorJSON(c, http.StatusGatewayTimeout, detailsResp{
@@ -56,23 +61,56 @@ func loginUser(svcs *compose.Services) fiber.Handler {
 				Message: "Invalid email or password",
 			})
 		}
+		if errors.Is(err, user.ErrEmailNotVerified) {
+			return errorJSON(c, http.StatusForbidden, detailsResp{
+				Code:    "001",
+				Message: err.Error(),
+				Details: "Open the link sent to your email",
+			})
+		}
+		var blocked *user.LoginBlockedError
+		if errors.As(err, &blocked) {
+			retry := int(math.Ceil(time.Until(blocked.Until).Seconds()))
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(retry, 1)))
+			return errorJSON(c, http.StatusTooManyRequests, detailsResp{
+				Code:    "001",
+				Message: blocked.Error(),
+				Details: fmt.Sprintf("Try again after %s", blocked.Until.UTC().Format(time.RFC3339)),
+			})
+		}
 		if err != nil {
 			return errorJSON(c, http.StatusBadRequest, detailsResp{
 				Code:    "003",
 				Message: err.Error(),
 			})
 		}
-		token, err := jwt.Generate(req.Email)
+		mfa, err := svcs.User.MFARequired(ctx, u.ID)
 		if err != nil {
+			logger.Error("login", "err", err.Error())
 			return errorJSON(c, http.StatusInternalServerError, detailsResp{
-				Code:    "004",
-				Message: "The token could not be generated",
+				Code:    "003",
+				Message: "The login could not be completed",
 			})
 		}
-		return respJSON(c, http.StatusCreated, detailsResp{
-			Message: "You are logged",
-			Data:    dataTokenResp{token},
-		})
+		if mfa {
+			challenge, err := svcs.User.StartMFAChallenge(ctx, u.ID)
+			if err != nil {
+				logger.Error("login", "err", err.Error())
+				return errorJSON(c, http.StatusInternalServerError, detailsResp{
+					Code:    "004",
+					Message: "The MFA challenge could not be started",
+				})
+			}
+			return respJSON(c, http.StatusOK, detailsResp{
+				Message: "MFA code required",
+				Details: "Send the code of your authenticator app to /v1/login/mfa",
+				Data: mfaChallengeResp{
+					ChallengeToken: challenge,
+					ExpiresIn:      int(user.MFAChallengeTTL.Seconds()),
+				},
+			})
+		}
+		return respSession(c, svcs, u, "You are logged")
 	}
 }
 
@@ -82,8 +120,20 @@ type userLoginReq struct {
 	Password string `json:"password"`
 }
 
+// dataTokenResp access and refresh tokens of a session.
 type dataTokenResp struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn" example:"900"` // access token lifetime in seconds
+}
+
+// newDataTokenResp returns a dataTokenResp for an access and refresh token.
+func newDataTokenResp(access, refresh string) dataTokenResp {
+	return dataTokenResp{
+		Token:        access,
+		RefreshToken: refresh,
+		ExpiresIn:    int(jwt.AccessTokenTTL.Seconds()),
+	}
 }
 
 // signUpUser godoc
@@ -205,6 +255,7 @@ func findUser(svcs *compose.Services) fiber.Handler {
 //	@Produce		json
 //	@Param			id				path		int	true	"User id"
 //	@Failure		400				{object}	errorResp
+//	@Failure		403				{object}	errorResp
 //	@Failure		404				{object}	errorResp
 //	@Success		200				{object}	resp{data=userProfileResp}
 //	@Param			userUpdateReq	body		userUpdateReq	true	"application/json"
@@ -229,13 +280,19 @@ func updateUser(svcs *compose.Services) fiber.Handler {
 			})
 		}
 		userID := int64(id)
-		err = svcs.User.Update(ctx, user.User{
+		err = svcs.User.Update(ctx, principalFrom(c), user.User{
 			ID:        userID,
 			FirstName: req.FirstName,
 			LastName:  req.LastName,
 			Email:     req.Email,
 			Password:  req.Password,
 		})
+		if errors.Is(err, user.ErrForbidden) {
+			return errorJSON(c, http.StatusForbidden, detailsResp{
+				Code:    "001",
+				Message: err.Error(),
+			})
+		}
 		if errors.Is(err, user.ErrNotFound) {
 			return errorJSON(c, http.StatusNotFound, detailsResp{
 				Code:    "003",
@@ -340,6 +397,7 @@ func listUsers(svcs *compose.Services) fiber.Handler {
 //	@Produce		json
 //	@Param			id	path		int	true	"User id"
 //	@Failure		400	{object}	errorResp
+//	@Failure		403	{object}	errorResp
 //	@Failure		404	{object}	errorResp
 //	@Success		200	{object}	resp
 //	@Router			/users/{id} [delete]
@@ -353,7 +411,13 @@ func deleteUser(svcs *compose.Services) fiber.Handler {
 				Message: "Positive number expected for ID user",
 			})
 		}
-		err = svcs.User.Remove(ctx, int64(id))
+		err = svcs.User.Remove(ctx, principalFrom(c), int64(id))
+		if errors.Is(err, user.ErrForbidden) {
+			return errorJSON(c, http.StatusForbidden, detailsResp{
+				Code:    "001",
+				Message: err.Error(),
+			})
+		}
 		if errors.Is(err, user.ErrNotFound) {
 			return errorJSON(c, http.StatusNotFound, detailsResp{
 				Code:    "003",
@@ -372,3 +436,46 @@ func deleteUser(svcs *compose.Services) fiber.Handler {
 		})
 	}
 }
+
+// unlockUser godoc
+//
+//	@Summary		Unlock user
+//	@Description	Forget the failed logins of a user, unlocking its account
+//	@Tags			users
+//	@Produce		json
+//	@Param			id	path		int	true	"User id"
+//	@Failure		400	{object}	errorResp
+//	@Failure		401	{object}	errorResp
+//	@Failure		403	{object}	errorResp
+//	@Failure		404	{object}	errorResp
+//	@Failure		500	{object}	errorResp
+//	@Success		200	{object}	resp
+//	@Router			/users/{id}/lockout [delete]
+func unlockUser(svcs *compose.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		id, err := strconv.Atoi(c.Params("id"))
+		if id < 0 || err != nil {
+			return errorJSON(c, http.StatusBadRequest, detailsResp{
+				Code:    "002",
+				Message: "Positive number expected for ID user",
+			})
+		}
+		err = svcs.User.Unlock(ctx, principalFrom(c), int64(id))
+		if errors.Is(err, user.ErrNotFound) {
+			return errorJSON(c, http.StatusNotFound, detailsResp{
+				Code:    "003",
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			return errorJSON(c, http.StatusInternalServerError, detailsResp{
+				Code:    "003",
+				Message: fmt.Sprintf("Could not unlock user: %s", err),
+			})
+		}
+		return respJSON(c, http.StatusOK, detailsResp{
+			Message: "User unlocked",
+		})
+	}
+}
@@ -3,6 +3,35 @@
 SET session_replication_role = replica;
 
 TRUNCATE TABLE
+    api_key,
+    service_account,
+    mfa_challenge,
+    mfa_recovery_code,
+    user_mfa,
+    user_token,
+    login_attempt,
+    user_role,
+    revoked_token,
+    refresh_token,
+    journal_line,
+    journal_entry,
+    dunning_reminder,
+    dunning_step,
+    payment_terms,
+    invoice_tax,
+    tax_exemption,
+    tax_rate,
+    exchange_rate,
+    subscription_adjustment,
+    subscription,
+    subscription_plan_item,
+    subscription_plan,
+    credit_note_item,
+    credit_note,
+    payment_allocation,
+    payment,
+    invoice_status_history,
+    invoice_sequence,
     invoice_item,
     invoice_header,
     product,
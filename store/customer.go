@@ -2,6 +2,7 @@ package store
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/adrianolmedo/genesis"
 )
@@ -17,6 +18,11 @@ type Customer struct {
 	Password  string
 	Email     string
 
+	// BillingName and BillingAddress the customer is invoiced to, the
+	// name is its full name if empty.
+	BillingName    string
+	BillingAddress string
+
 	genesis.AuditFields
 }
 
@@ -28,6 +34,14 @@ func (c Customer) Validate() error {
 	return nil
 }
 
+// BilledName returns the name the customer is invoiced to.
+func (c Customer) BilledName() string {
+	if c.BillingName != "" {
+		return c.BillingName
+	}
+	return strings.TrimSpace(c.FirstName + " " + c.LastName)
+}
+
 // Customers collection of Customer.
 type Customers []Customer
 
@@ -0,0 +1,34 @@
+package store
+
+import "testing"
+
+func TestBilledName(t *testing.T) {
+	tt := []struct {
+		name  string
+		model Customer
+		want  string
+	}{
+		{
+			name:  "billing-name-test",
+			model: Customer{FirstName: "Ana", LastName: "Pérez", BillingName: "Acme S.A. de C.V."},
+			want:  "Acme S.A. de C.V.",
+		},
+		{
+			name:  "full-name-test",
+			model: Customer{FirstName: "Ana", LastName: "Pérez"},
+			want:  "Ana Pérez",
+		},
+		{
+			name:  "first-name-test",
+			model: Customer{FirstName: "Ana"},
+			want:  "Ana",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.model.BilledName(); got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
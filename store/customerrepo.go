@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/adrianolmedo/genesis"
@@ -30,12 +31,14 @@ func (r *CustomerRepo) Create(ctx context.Context, m *Customer) error {
 	m.UUID = genesis.NextUUID()
 	m.CreatedAt = time.Now()
 	id, err := r.q.CustomerCreate(ctx, dbgen.CustomerCreateParams{
-		Uuid:      uuid.Parse(m.UUID),
-		FirstName: m.FirstName,
-		LastName:  m.LastName,
-		Email:     m.Email,
-		Password:  m.Password,
-		CreatedAt: m.CreatedAt,
+		Uuid:           uuid.Parse(m.UUID),
+		FirstName:      m.FirstName,
+		LastName:       m.LastName,
+		Email:          m.Email,
+		Password:       m.Password,
+		BillingName:    m.BillingName,
+		BillingAddress: m.BillingAddress,
+		CreatedAt:      m.CreatedAt,
 	})
 	if err != nil {
 		return err
@@ -44,6 +47,19 @@ func (r *CustomerRepo) Create(ctx context.Context, m *Customer) error {
 	return nil
 }
 
+// ByID retrieves a customer by its ID, the deleted ones too.
+func (r *CustomerRepo) ByID(ctx context.Context, id int64) (*Customer, error) {
+	row, err := r.q.CustomerByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCustomerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := toDomainCustomer(row)
+	return &m, nil
+}
+
 // List retrieves a paginated list of customers from the database.
 func (r *CustomerRepo) List(ctx context.Context, p pgsql.Filter) (rows Customers, totalRows int64, err error) {
 	rowsDB, err := r.q.CustomerListAsc(ctx, dbgen.CustomerListAscParams{
@@ -65,22 +81,29 @@ func (r *CustomerRepo) List(ctx context.Context, p pgsql.Filter) (rows Customers
 func toDomainCustomers(rows []dbgen.Customer) Customers {
 	customers := make(Customers, 0, len(rows))
 	for _, row := range rows {
-		m := Customer{
-			ID:        row.ID,
-			UUID:      row.Uuid.String(),
-			FirstName: row.FirstName,
-			LastName:  row.LastName,
-			Email:     row.Email,
-			Password:  row.Password,
-		}
-		m.CreatedAt = row.CreatedAt
-		m.UpdatedAt = pgsql.NullTimeToPtr(row.UpdatedAt)
-		m.DeletedAt = pgsql.NullTimeToPtr(row.DeletedAt)
-		customers = append(customers, m)
+		customers = append(customers, toDomainCustomer(row))
 	}
 	return customers
 }
 
+// toDomainCustomer converts a dbgen.Customer to a Customer.
+func toDomainCustomer(row dbgen.Customer) Customer {
+	m := Customer{
+		ID:             row.ID,
+		UUID:           row.Uuid.String(),
+		FirstName:      row.FirstName,
+		LastName:       row.LastName,
+		Email:          row.Email,
+		Password:       row.Password,
+		BillingName:    row.BillingName,
+		BillingAddress: row.BillingAddress,
+	}
+	m.CreatedAt = row.CreatedAt
+	m.UpdatedAt = pgsql.NullTimeToPtr(row.UpdatedAt)
+	m.DeletedAt = pgsql.NullTimeToPtr(row.DeletedAt)
+	return m
+}
+
 // Delete soft deletes a customer by setting the DeletedAt field.
 func (r *CustomerRepo) Delete(ctx context.Context, id int64) error {
 	_, err := r.q.CustomerDelete(ctx, dbgen.CustomerDeleteParams{
@@ -95,3 +118,13 @@ func (r *CustomerRepo) Delete(ctx context.Context, id int64) error {
 	}
 	return nil
 }
+
+// DeleteAll deletes all customers from the storage (permanently), with
+// their invoices.
+func (r *CustomerRepo) DeleteAll(ctx context.Context) error {
+	err := r.q.CustomerDeleteAll(ctx)
+	if err != nil {
+		return fmt.Errorf("can't truncate table: %v", err)
+	}
+	return nil
+}
@@ -4,17 +4,22 @@ import (
 	"errors"
 
 	"github.com/adrianolmedo/genesis"
+	"github.com/adrianolmedo/genesis/money"
 )
 
 var ErrProductNotFound = errors.New("product not found")
 
+// ErrTaxCategoryNotFound the tax category of a product doesn't exist.
+var ErrTaxCategoryNotFound = errors.New("tax category not found")
+
 // Product domain model.
 type Product struct {
 	ID           int64
 	UUID         string
 	Name         string
 	Observations string
-	Price        int64
+	Price        money.Money
+	TaxCategory  string // code of the billing tax category, untaxed if empty
 
 	genesis.AuditFields
 }
@@ -24,6 +29,9 @@ func (p Product) Validate() error {
 	if p.Name == "" {
 		return errors.New("the product has no name")
 	}
+	if !p.Price.Currency.Valid() {
+		return money.ErrUnknownCurrency
+	}
 	return nil
 }
 
@@ -1,6 +1,10 @@
 package store
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/adrianolmedo/genesis/money"
+)
 
 func TestProduct(t *testing.T) {
 	tt := []struct {
@@ -15,7 +19,7 @@ func TestProduct(t *testing.T) {
 		},
 		{
 			name:        "empty-fields-test",
-			model:       Product{Name: "", Observations: "", Price: 0.0},
+			model:       Product{Name: "", Observations: "", Price: money.Money{}},
 			errExpected: true,
 		},
 		{
@@ -23,9 +27,14 @@ func TestProduct(t *testing.T) {
 			model: Product{
 				Name:         "Protein",
 				Observations: "Lorem ipsum",
-				Price:        03333},
+				Price:        money.New(3333, "EUR")},
 			errExpected: false,
 		},
+		{
+			name:        "unknown-currency-test",
+			model:       Product{Name: "Protein", Price: money.New(3333, "XYZ")},
+			errExpected: true,
+		},
 	}
 	for _, tc := range tt {
 		err := tc.model.Validate()
@@ -8,10 +8,13 @@ import (
 	"time"
 
 	"github.com/adrianolmedo/genesis"
+	"github.com/adrianolmedo/genesis/money"
 	"github.com/adrianolmedo/genesis/pgsql"
 	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/pborman/uuid"
 )
 
@@ -36,11 +39,13 @@ func (r *ProductRepo) Create(ctx context.Context, m *Product) error {
 		Uuid:         uuid.Parse(m.UUID),
 		Name:         m.Name,
 		Observations: m.Observations,
-		Price:        m.Price,
+		Price:        m.Price.Amount,
+		Currency:     string(m.Price.Currency),
+		TaxCategory:  pgtype.Text{String: m.TaxCategory, Valid: m.TaxCategory != ""},
 		CreatedAt:    m.CreatedAt,
 	})
 	if err != nil {
-		return err
+		return taxCategoryErr(err)
 	}
 	m.ID = id
 	return nil
@@ -59,7 +64,8 @@ func (r *ProductRepo) ByID(ctx context.Context, id int64) (*Product, error) {
 		UUID:         m.Uuid.String(),
 		Name:         m.Name,
 		Observations: m.Observations,
-		Price:        m.Price,
+		Price:        money.New(m.Price, money.Currency(m.Currency)),
+		TaxCategory:  m.TaxCategory.String,
 	}
 	p.CreatedAt = m.CreatedAt
 	p.UpdatedAt = pgsql.NullTimeToPtr(m.UpdatedAt)
@@ -73,14 +79,16 @@ func (r *ProductRepo) Update(ctx context.Context, m Product) error {
 		ID:           m.ID,
 		Name:         m.Name,
 		Observations: m.Observations,
-		Price:        m.Price,
+		Price:        m.Price.Amount,
+		Currency:     string(m.Price.Currency),
+		TaxCategory:  pgtype.Text{String: m.TaxCategory, Valid: m.TaxCategory != ""},
 		UpdatedAt:    pgsql.TimePtrToNull(m.UpdatedAt),
 	})
 	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
 		return ErrProductNotFound
 	}
 	if err != nil {
-		return err
+		return taxCategoryErr(err)
 	}
 	return nil
 }
@@ -103,7 +111,8 @@ func toDomainProducts(dbProducts []dbgen.Product) Products {
 			UUID:         m.Uuid.String(),
 			Name:         m.Name,
 			Observations: m.Observations,
-			Price:        m.Price,
+			Price:        money.New(m.Price, money.Currency(m.Currency)),
+			TaxCategory:  m.TaxCategory.String,
 		}
 		p.CreatedAt = m.CreatedAt
 		p.UpdatedAt = pgsql.NullTimeToPtr(m.UpdatedAt)
@@ -140,6 +149,16 @@ func (r *ProductRepo) HardDelete(ctx context.Context, id int64) error {
 	return nil
 }
 
+// taxCategoryErr maps the error of the database rejecting an unknown tax
+// category to ErrTaxCategoryNotFound.
+func taxCategoryErr(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == "product_tax_category_fk" {
+		return ErrTaxCategoryNotFound
+	}
+	return err
+}
+
 // DeleteAll deletes all products from the storage (permanently).
 func (r *ProductRepo) DeleteAll(ctx context.Context) error {
 	err := r.q.ProductDeleteAll(ctx)
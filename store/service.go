@@ -2,7 +2,10 @@ package store
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/password"
 	"github.com/adrianolmedo/genesis/pgsql"
 )
 
@@ -10,18 +13,23 @@ import (
 type Service struct {
 	productRepo  *ProductRepo
 	customerRepo *CustomerRepo
+	hasher       *password.Hasher
+	currency     money.Currency // of the prices given without one
 }
 
-// NewService creates a new store service with the provided repositories.
-func NewService(productRepo *ProductRepo, customerRepo *CustomerRepo) *Service {
+// NewService creates a new store service with the provided repositories,
+// the prices without a currency are in currency.
+func NewService(productRepo *ProductRepo, customerRepo *CustomerRepo, hasher *password.Hasher, currency money.Currency) *Service {
 	return &Service{
 		productRepo:  productRepo,
 		customerRepo: customerRepo,
+		hasher:       hasher,
+		currency:     currency,
 	}
 }
 
 func (s Service) Add(ctx context.Context, p *Product) error {
-	err := addProduct(p)
+	err := addProduct(p, s.currency)
 	if err != nil {
 		return err
 	}
@@ -31,7 +39,10 @@ func (s Service) Add(ctx context.Context, p *Product) error {
 // addProduct application logic for adding products to the store.
 // The application logic has been split into a smaller function for unit testing
 // purposes, and it should do so for the other methods of the Service.
-func addProduct(p *Product) error {
+func addProduct(p *Product, currency money.Currency) error {
+	if p.Price.Currency == "" {
+		p.Price.Currency = currency
+	}
 	err := p.Validate()
 	if err != nil {
 		return err
@@ -47,6 +58,9 @@ func (s Service) Find(ctx context.Context, id int64) (*Product, error) {
 }
 
 func (s Service) Update(ctx context.Context, p Product) error {
+	if p.Price.Currency == "" {
+		p.Price.Currency = s.currency
+	}
 	err := p.Validate()
 	if err != nil {
 		return err
@@ -58,10 +72,26 @@ func (s Service) List(ctx context.Context) (Products, error) {
 	return s.productRepo.All(ctx)
 }
 
+// AddCustomer registers a Customer, its password (optional) is stored hashed.
 func (s Service) AddCustomer(ctx context.Context, cx *Customer) error {
+	if cx.Password != "" {
+		hash, err := s.hasher.Hash(cx.Password)
+		if err != nil {
+			return fmt.Errorf("password hash: %w", err)
+		}
+		cx.Password = hash
+	}
 	return s.customerRepo.Create(ctx, cx)
 }
 
+// FindCustomer returns a customer, even if it was deleted.
+func (s Service) FindCustomer(ctx context.Context, id int64) (*Customer, error) {
+	if id == 0 {
+		return nil, ErrCustomerNotFound
+	}
+	return s.customerRepo.ByID(ctx, id)
+}
+
 func (s Service) ListCustomers(ctx context.Context, p pgsql.Filter) (customers Customers, total int64, err error) {
 	return s.customerRepo.List(ctx, p)
 }
@@ -3,6 +3,8 @@ package store
 import (
 	"strings"
 	"testing"
+
+	"github.com/adrianolmedo/genesis/money"
 )
 
 func TestAddProduct(t *testing.T) {
@@ -17,7 +19,7 @@ func TestAddProduct(t *testing.T) {
 			input: &Product{
 				Name:         "Coca-Cola",
 				Observations: "",
-				Price:        3,
+				Price:        money.New(300, "USD"),
 			},
 			errExpected:    false,
 			wantErrContain: "",
@@ -27,14 +29,14 @@ func TestAddProduct(t *testing.T) {
 			input: &Product{
 				Name:         "",
 				Observations: "Made in Venezuela",
-				Price:        2,
+				Price:        money.New(200, ""),
 			},
 			errExpected:    true,
 			wantErrContain: "the product has no name",
 		},
 	}
 	for _, tc := range tt {
-		err := addProduct(tc.input)
+		err := addProduct(tc.input, "EUR")
 		if (err != nil) != tc.errExpected {
 			t.Fatalf("%s: unexpected error value, %v", tc.name, err)
 		}
@@ -42,4 +44,13 @@ func TestAddProduct(t *testing.T) {
 			t.Fatalf("want error string %q to contain %q", err.Error(), tc.wantErrContain)
 		}
 	}
+
+	// A price without a currency is in the one of the store.
+	p := &Product{Name: "Pepsi", Price: money.New(250, "")}
+	if err := addProduct(p, "EUR"); err != nil {
+		t.Fatal(err)
+	}
+	if p.Price != money.New(250, "EUR") {
+		t.Errorf("want price 2.50 EUR, got %v", p.Price)
+	}
 }
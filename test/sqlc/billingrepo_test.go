@@ -2,9 +2,14 @@ package sqlc
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/pgsql"
 	"github.com/adrianolmedo/genesis/test"
 )
 
@@ -13,23 +18,34 @@ func TestCreateInvoice(t *testing.T) {
 		cleanProductsData(t)
 		cleanInvoiceItemsData(t)
 		cleanInvoiceHeadersData(t)
+		cleanCustomersData(t)
 	})
 	input := &billing.Invoice{
 		Header: &billing.InvoiceHeader{
 			ClientID: 1,
+			Currency: "EUR",
+			BillTo:   billing.BillTo{Name: "Ana Pérez", Email: "ana@example.com", Address: "Av. Reforma 1"},
+			Totals:   billing.Totals{Subtotal: 3, Total: 3},
 		},
 		Items: billing.ItemList{
-			billing.InvoiceItem{ProductID: 1},
+			billing.InvoiceItem{
+				ProductID:   1,
+				ProductName: "Coca-Cola",
+				Quantity:    1,
+				UnitPrice:   money.New(3, "EUR"),
+				Totals:      billing.Totals{Subtotal: 3, Total: 3},
+			},
 		},
 	}
 	ctx := test.Ctx(t)
 	db := openDB(ctx, t)
 	defer db.Close()
+	insertCustomersData(ctx, t, db)
 	insertProductsData(ctx, t, db)
 	//ih := NewInvoiceHeader(db)
 	//ii := NewInvoiceItem(db)
 	in := billing.NewRepo(db)
-	if err := in.CreateInvoice(ctx, input); err != nil {
+	if err := in.CreateInvoice(ctx, input, invoiceNumbers(t)); err != nil {
 		t.Fatal(err)
 	}
 	for _, item := range input.Items {
@@ -37,21 +53,253 @@ func TestCreateInvoice(t *testing.T) {
 			t.Errorf("invoice for product %d not added", item.ProductID)
 		}
 	}
+	got, err := in.ByID(ctx, input.Header.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Header.BillTo != input.Header.BillTo {
+		t.Errorf("want billed to %+v, got %+v", input.Header.BillTo, got.Header.BillTo)
+	}
+
+	// The client must be a customer.
+	input.Header.ClientID = 99
+	if err := in.CreateInvoice(ctx, input, invoiceNumbers(t)); !errors.Is(err, billing.ErrCustomerNotFound) {
+		t.Errorf("want error %v, got %v", billing.ErrCustomerNotFound, err)
+	}
+}
+
+func TestFindAndListInvoices(t *testing.T) {
+	t.Cleanup(func() {
+		cleanProductsData(t)
+		cleanInvoiceItemsData(t)
+		cleanInvoiceHeadersData(t)
+		cleanCustomersData(t)
+	})
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	insertCustomersData(ctx, t, db)
+	insertProductsData(ctx, t, db)
+	r := billing.NewRepo(db)
+	for _, clientID := range []int64{1, 1, 2} {
+		err := r.CreateInvoice(ctx, &billing.Invoice{
+			Header: &billing.InvoiceHeader{
+				ClientID: clientID,
+				Currency: "EUR",
+				Status:   billing.StatusDraft,
+				Totals:   billing.Totals{Subtotal: 3, Total: 3},
+			},
+			Items: billing.ItemList{
+				billing.InvoiceItem{
+					ProductID:   1,
+					ProductName: "Coca-Cola",
+					Quantity:    1,
+					UnitPrice:   money.New(3, "EUR"),
+					Totals:      billing.Totals{Subtotal: 3, Total: 3},
+				},
+			},
+		}, invoiceNumbers(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	inv, err := r.ByID(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Items) != 1 || inv.Items[0].ProductName != "Coca-Cola" {
+		t.Errorf("unexpected items %+v", inv.Items)
+	}
+	f, err := pgsql.NewFilter(10, 1, "id", "desc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, total, err := r.List(ctx, f, billing.ListFilter{ClientID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(rows) != 2 {
+		t.Fatalf("want 2 invoices of client 1, got %d (total %d)", len(rows), total)
+	}
+	if rows[0].Header.ID < rows[1].Header.ID {
+		t.Error("want invoices sorted by id desc")
+	}
+	if _, err := r.ByID(ctx, 99); !errors.Is(err, billing.ErrInvoiceHeaderNotFound) {
+		t.Errorf("want error %v, got %v", billing.ErrInvoiceHeaderNotFound, err)
+	}
+}
+
+func TestInvoiceTransition(t *testing.T) {
+	t.Cleanup(func() {
+		cleanProductsData(t)
+		cleanInvoiceItemsData(t)
+		cleanInvoiceHeadersData(t)
+		cleanCustomersData(t)
+	})
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	insertCustomersData(ctx, t, db)
+	insertProductsData(ctx, t, db)
+	r := billing.NewRepo(db)
+	inv := &billing.Invoice{
+		Header: &billing.InvoiceHeader{
+			ClientID: 1,
+			Currency: "EUR",
+			Totals:   billing.Totals{Subtotal: 3, Total: 3},
+		},
+		Items: billing.ItemList{
+			billing.InvoiceItem{
+				ProductID:   1,
+				ProductName: "Coca-Cola",
+				Quantity:    1,
+				UnitPrice:   money.New(3, "EUR"),
+				Totals:      billing.Totals{Subtotal: 3, Total: 3},
+			},
+		},
+	}
+	if err := r.CreateInvoice(ctx, inv, invoiceNumbers(t)); err != nil {
+		t.Fatal(err)
+	}
+	if inv.Header.Status != billing.StatusDraft {
+		t.Fatalf("want new invoice %s, got %s", billing.StatusDraft, inv.Header.Status)
+	}
+	id := inv.Header.ID
+	change := billing.StatusChange{By: billing.Actor{UserID: 1}, ChangedAt: time.Now()}
+	h, err := r.Transition(ctx, id, billing.StatusIssued, change)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Status != billing.StatusIssued {
+		t.Errorf("want status %s, got %s", billing.StatusIssued, h.Status)
+	}
+
+	// Paid and void invoices are final.
+	change.Reason = "Paid by transfer"
+	if _, err := r.Transition(ctx, id, billing.StatusPaid, change); err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Transition(ctx, id, billing.StatusVoid, change)
+	var terr *billing.TransitionError
+	if !errors.As(err, &terr) || terr.From != billing.StatusPaid {
+		t.Errorf("want *TransitionError from %s, got %v", billing.StatusPaid, err)
+	}
+
+	// An issued invoice keeps its items.
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+	err = r.CreateItem(ctx, tx, id, billing.ItemList{{ProductID: 1, ProductName: "Coca-Cola", Quantity: 1}})
+	if !errors.Is(err, billing.ErrInvoiceImmutable) {
+		t.Errorf("want error %v, got %v", billing.ErrInvoiceImmutable, err)
+	}
+
+	history, err := r.History(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("want 2 status changes, got %d", len(history))
+	}
+	if history[1].From != billing.StatusIssued || history[1].To != billing.StatusPaid ||
+		history[1].Reason != "Paid by transfer" {
+		t.Errorf("unexpected status change %+v", history[1])
+	}
+}
+
+// TestInvoiceNumbersConcurrent generates invoices concurrently, some of them
+// failing, and checks their numbers have no gaps or duplicates.
+func TestInvoiceNumbersConcurrent(t *testing.T) {
+	t.Cleanup(func() {
+		cleanProductsData(t)
+		cleanInvoiceItemsData(t)
+		cleanInvoiceHeadersData(t)
+		cleanCustomersData(t)
+	})
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	insertCustomersData(ctx, t, db)
+	insertProductsData(ctx, t, db)
+	r := billing.NewRepo(db)
+	numbers := invoiceNumbers(t)
+	const n = 100
+	now := time.Now()
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			productID := int64(1)
+			if i%5 == 0 {
+				productID = 99 // not found, the invoice is rolled back
+			}
+			errs <- r.CreateInvoice(ctx, &billing.Invoice{
+				Header: &billing.InvoiceHeader{ClientID: 1, Currency: "EUR", CreatedAt: now},
+				Items: billing.ItemList{
+					billing.InvoiceItem{ProductID: productID, ProductName: "Coca-Cola", Quantity: 1},
+				},
+			}, numbers)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+		}
+	}
+	if created != n-n/5 {
+		t.Fatalf("want %d invoices created, got %d", n-n/5, created)
+	}
+	got := make(map[string]bool, created)
+	for page := 1; ; page++ {
+		f, err := pgsql.NewFilter(pgsql.FilterMaxLimit, page, "id", "asc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, _, err := r.List(ctx, f, billing.ListFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rows.IsEmpty() {
+			break
+		}
+		for _, row := range rows {
+			got[row.Header.Number] = true
+		}
+	}
+	if len(got) != created {
+		t.Fatalf("want %d distinct numbers, got %d", created, len(got))
+	}
+	for seq := int64(1); seq <= int64(created); seq++ {
+		if num := numbers.Number(now, seq); !got[num] {
+			t.Errorf("number %s missing", num)
+		}
+	}
 }
 
 func TestCreateTxInvoiceHeader(t *testing.T) {
 	t.Cleanup(func() {
 		cleanInvoiceHeadersData(t)
+		cleanCustomersData(t)
 	})
 	ctx := test.Ctx(t)
 	db := openDB(ctx, t)
 	defer db.Close()
+	insertCustomersData(ctx, t, db)
 	tx, err := db.Begin(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
 	input := &billing.InvoiceHeader{
+		Number:   "TEST-1",
 		ClientID: 1,
+		Currency: "EUR",
 	}
 	r := billing.NewRepo(db)
 	if err := r.CreateHeader(ctx, tx, input); err != nil {
@@ -66,7 +314,20 @@ func TestCreateTxInvoiceHeader(t *testing.T) {
 	}
 }
 
+// invoiceNumbers returns the default format of the invoice numbers.
+func invoiceNumbers(t *testing.T) billing.NumberFormat {
+	t.Helper()
+	numbers, err := billing.ParseNumberFormat(billing.DefaultNumberFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return numbers
+}
+
+// cleanInvoiceHeadersData delete all rows of `invoice_header` table, and
+// the journal the invoices posted, their ids are reused.
 func cleanInvoiceHeadersData(t *testing.T) {
+	cleanLedgerData(t)
 	ctx := test.Ctx(t)
 	db := openDB(ctx, t)
 	defer db.Close()
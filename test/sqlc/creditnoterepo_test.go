@@ -0,0 +1,111 @@
+package sqlc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/test"
+)
+
+func TestCreateCreditNote(t *testing.T) {
+	t.Cleanup(func() {
+		cleanPaymentsData(t)
+		cleanProductsData(t)
+		cleanInvoiceItemsData(t)
+		cleanInvoiceHeadersData(t)
+		cleanCustomersData(t)
+	})
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	insertCustomersData(ctx, t, db)
+	insertProductsData(ctx, t, db)
+	r := billing.NewRepo(db)
+	now := time.Now()
+	inv := &billing.Invoice{
+		Header: &billing.InvoiceHeader{ClientID: 1, Currency: "EUR", Totals: billing.Totals{Subtotal: 3000, Total: 3000}},
+		Items: billing.ItemList{
+			billing.InvoiceItem{
+				ProductID:   1,
+				ProductName: "Coca-Cola",
+				Quantity:    3,
+				UnitPrice:   money.New(1000, "EUR"),
+				Totals:      billing.Totals{Subtotal: 3000, Total: 3000},
+			},
+		},
+	}
+	if err := r.CreateInvoice(ctx, inv, invoiceNumbers(t)); err != nil {
+		t.Fatal(err)
+	}
+	id := inv.Header.ID
+	cn := &billing.CreditNote{InvoiceID: id, Reason: "Returned"}
+	if err := r.CreateCreditNote(ctx, cn, creditNoteNumbers(t)); !errors.Is(err, billing.ErrInvoiceNotCreditable) {
+		t.Fatalf("want error %v for a draft, got %v", billing.ErrInvoiceNotCreditable, err)
+	}
+	change := billing.StatusChange{By: billing.Actor{UserID: 1}, ChangedAt: now}
+	if _, err := r.Transition(ctx, id, billing.StatusIssued, change); err != nil {
+		t.Fatal(err)
+	}
+
+	// Credits one unit, then the rest settles the invoice.
+	cn = &billing.CreditNote{
+		InvoiceID: id,
+		Reason:    "Returned",
+		Items:     billing.CreditNoteItems{{InvoiceItemID: inv.Items[0].ID, Quantity: 1}},
+	}
+	if err := r.CreateCreditNote(ctx, cn, creditNoteNumbers(t)); err != nil {
+		t.Fatal(err)
+	}
+	if cn.Total != 1000 || cn.Number != creditNoteNumbers(t).Number(cn.CreatedAt, 1) {
+		t.Errorf("unexpected credit note %s of %d", cn.Number, cn.Total)
+	}
+	assertInvoiceStatus(t, r, id, billing.StatusIssued)
+	// Credited in part it's still issued, but it can't be voided.
+	if _, err := r.Transition(ctx, id, billing.StatusVoid, change); !errors.Is(err, billing.ErrInvoiceCredited) {
+		t.Errorf("want error %v, got %v", billing.ErrInvoiceCredited, err)
+	}
+	assertInvoiceStatus(t, r, id, billing.StatusIssued)
+	if err := r.CreateCreditNote(ctx, &billing.CreditNote{InvoiceID: id, Reason: "Cancelled"}, creditNoteNumbers(t)); err != nil {
+		t.Fatal(err)
+	}
+	assertInvoiceStatus(t, r, id, billing.StatusPaid)
+	err := r.CreateCreditNote(ctx, &billing.CreditNote{InvoiceID: id, Reason: "Again"}, creditNoteNumbers(t))
+	if !errors.Is(err, billing.ErrNothingToCredit) {
+		t.Errorf("want error %v, got %v", billing.ErrNothingToCredit, err)
+	}
+
+	cns, err := r.CreditNotesByInvoice(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cns) != 2 || cns[1].Total != 2000 {
+		t.Fatalf("unexpected credit notes %+v", cns)
+	}
+	found, err := r.CreditNoteByID(ctx, cns[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Items) != 1 || found.Items[0].Quantity != 2 {
+		t.Errorf("unexpected items %+v", found.Items)
+	}
+	b, err := r.Balance(ctx, 1, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Credited != 3000 || b.Outstanding != 0 {
+		t.Errorf("unexpected balance %+v", *b)
+	}
+}
+
+// creditNoteNumbers returns the default format of the credit note numbers.
+func creditNoteNumbers(t *testing.T) billing.NumberFormat {
+	t.Helper()
+	numbers, err := billing.ParseNumberFormat(billing.DefaultCreditNoteNumberFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return numbers
+}
@@ -0,0 +1,81 @@
+package sqlc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/adrianolmedo/genesis/store"
+	"github.com/adrianolmedo/genesis/test"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestFindCustomer(t *testing.T) {
+	t.Cleanup(func() {
+		cleanCustomersData(t)
+	})
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	r := store.NewCustomerRepo(db)
+	cx := &store.Customer{
+		FirstName:      "Ana",
+		LastName:       "Pérez",
+		Email:          "ana@example.com",
+		BillingName:    "Acme S.A. de C.V.",
+		BillingAddress: "Av. Reforma 1, 06600 CDMX",
+	}
+	if err := r.Create(ctx, cx); err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.ByID(ctx, cx.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.BilledName() != "Acme S.A. de C.V." || got.BillingAddress != cx.BillingAddress || got.DeletedAt != nil {
+		t.Errorf("unexpected customer %+v", got)
+	}
+
+	// A deleted customer is still found, the invoices are billed to it.
+	if err := r.Delete(ctx, cx.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err = r.ByID(ctx, cx.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.DeletedAt == nil {
+		t.Error("want the customer deleted")
+	}
+	if _, err := r.ByID(ctx, cx.ID+100); !errors.Is(err, store.ErrCustomerNotFound) {
+		t.Errorf("want error %v, got %v", store.ErrCustomerNotFound, err)
+	}
+}
+
+// insertCustomersData add default `customer` data, the clients 1 and 2 of
+// the invoices.
+func insertCustomersData(ctx context.Context, t *testing.T, db *pgxpool.Pool) {
+	t.Helper()
+	r := store.NewCustomerRepo(db)
+	for _, cx := range []*store.Customer{
+		{FirstName: "Ana", LastName: "Pérez", Email: "ana@example.com"},
+		{FirstName: "Luis", LastName: "Gómez", Email: "luis@example.com"},
+	} {
+		if err := r.Create(ctx, cx); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// cleanCustomersData delete all rows of `customer` table, and their
+// invoices.
+func cleanCustomersData(t *testing.T) {
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	err := store.NewCustomerRepo(db).DeleteAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,172 @@
+package sqlc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/ledger"
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/store"
+	"github.com/adrianolmedo/genesis/test"
+)
+
+// notifierFunc is a billing.Notifier of a function.
+type notifierFunc func(r billing.Reminder) error
+
+func (f notifierFunc) Remind(_ context.Context, r billing.Reminder) error { return f(r) }
+
+func TestDunning(t *testing.T) {
+	t.Cleanup(func() {
+		cleanDunningData(t)
+		cleanLedgerData(t)
+		cleanPaymentsData(t)
+		cleanProductsData(t)
+		cleanInvoiceItemsData(t)
+		cleanInvoiceHeadersData(t)
+		cleanCustomersData(t)
+	})
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	insertCustomersData(ctx, t, db)
+	r := billing.NewRepo(db)
+	svc := billing.NewService(r, billing.Options{InvoiceNumbers: invoiceNumbers(t), PaymentTermsDays: 30})
+
+	products := store.NewProductRepo(db)
+	seat := &store.Product{Name: "Seat", Price: money.New(10000, "EUR")}
+	fee := &store.Product{Name: "Late fee", Price: money.New(0, "EUR")}
+	for _, p := range []*store.Product{seat, fee} {
+		if err := products.Create(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := svc.SetPaymentTerms(ctx, &billing.PaymentTerms{ClientID: 1, NetDays: 15}); err != nil {
+		t.Fatal(err)
+	}
+	steps := []*billing.DunningStep{
+		{DaysAfterDue: 3},
+		{DaysAfterDue: 14, LateFee: 500, FeeProductID: fee.ID},
+		{DaysAfterDue: 30, LateFeeRate: 150, FeeProductID: fee.ID},
+	}
+	for _, s := range steps {
+		if err := svc.CreateDunningStep(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := svc.CreateDunningStep(ctx, &billing.DunningStep{DaysAfterDue: 3}); !errors.Is(err, billing.ErrDunningStepExists) {
+		t.Errorf("want error %v, got %v", billing.ErrDunningStepExists, err)
+	}
+
+	// Due by the terms of the client, 20 days overdue: only the step of 14
+	// days is reminded.
+	now := time.Now().UTC()
+	inv := &billing.Invoice{
+		Header: &billing.InvoiceHeader{ClientID: 1, CreatedAt: now.AddDate(0, 0, -35)},
+		Items:  billing.ItemList{{ProductID: seat.ID, ProductName: "Seat", Quantity: 1, UnitPrice: money.New(10000, "EUR")}},
+	}
+	if err := svc.Generate(ctx, inv); err != nil {
+		t.Fatal(err)
+	}
+	if want := inv.Header.CreatedAt.AddDate(0, 0, 15); !inv.Header.DueAt.Equal(want) {
+		t.Errorf("want due at %s, got %s", want, inv.Header.DueAt)
+	}
+	if n, err := svc.QueueReminders(ctx, now); err != nil || n != 0 {
+		t.Errorf("want a draft not reminded, got %d, %v", n, err)
+	}
+	if _, err := svc.Issue(ctx, billing.Actor{UserID: 1}, inv.Header.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	var queued int
+	for range 2 {
+		n, err := svc.QueueReminders(ctx, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		queued += n
+	}
+	if queued != 1 {
+		t.Fatalf("want a reminder queued once, got %d", queued)
+	}
+
+	// The first attempt fails after the late fee is invoiced, the second
+	// one doesn't invoice it again.
+	var calls int
+	notifier := notifierFunc(func(rem billing.Reminder) error {
+		calls++
+		if calls == 1 {
+			return errors.New("mailbox unavailable")
+		}
+		return nil
+	})
+	sent, err := svc.SendReminders(ctx, now, notifier)
+	if err == nil || len(sent) != 0 {
+		t.Fatalf("want the first attempt failed, got %+v, %v", sent, err)
+	}
+	sent, err = svc.SendReminders(ctx, now, notifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || sent[0].DaysAfterDue != 14 || sent[0].AmountDue != 10000 || sent[0].LateFee != 500 {
+		t.Fatalf("unexpected reminders sent %+v", sent)
+	}
+	rems, err := svc.Reminders(ctx, inv.Header.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rems) != 1 || rems[0].Status != billing.ReminderSent || rems[0].Attempts != 2 || rems[0].FeeInvoiceID != sent[0].FeeInvoiceID {
+		t.Fatalf("unexpected reminders %+v", rems)
+	}
+	feeInv, err := svc.Find(ctx, sent[0].FeeInvoiceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feeInv.Header.ClientID != 1 || feeInv.Header.Total != 500 || feeInv.Header.Status != billing.StatusIssued {
+		t.Errorf("unexpected late fee invoice %+v", feeInv.Header)
+	}
+
+	// A paid invoice isn't reminded anymore, the late fee is still owed:
+	// it's in the receivables and, due by the terms of the client, 5 days
+	// overdue it's reminded as any other invoice.
+	pay := &billing.Payment{Amount: inv.Header.Total, Method: billing.MethodTransfer, ReceivedAt: now}
+	if err := svc.Pay(ctx, billing.Actor{UserID: 1}, inv.Header.ID, pay); err != nil {
+		t.Fatal(err)
+	}
+	tb, err := ledger.NewService(ledger.NewRepo(db)).TrialBalance(ctx, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range tb.Accounts {
+		if b.Code == ledger.AccountsReceivable && b.Net() != 500 {
+			t.Errorf("want the late fee of 500 receivable, got %d", b.Net())
+		}
+	}
+	if n, err := svc.QueueReminders(ctx, now.AddDate(0, 0, 20)); err != nil || n != 1 {
+		t.Errorf("want only the late fee reminded, got %d, %v", n, err)
+	}
+	rems, err = svc.Reminders(ctx, feeInv.Header.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rems) != 1 || rems[0].DaysAfterDue != 3 || rems[0].AmountDue != 500 {
+		t.Errorf("unexpected reminders of the late fee %+v", rems)
+	}
+	if rems, err = svc.Reminders(ctx, inv.Header.ID); err != nil || len(rems) != 1 {
+		t.Errorf("want the paid invoice not reminded again, got %+v, %v", rems, err)
+	}
+	if _, err := svc.Reminders(ctx, inv.Header.ID+100); !errors.Is(err, billing.ErrInvoiceHeaderNotFound) {
+		t.Errorf("want error %v, got %v", billing.ErrInvoiceHeaderNotFound, err)
+	}
+}
+
+func cleanDunningData(t *testing.T) {
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	err := billing.NewRepo(db).DeleteAllDunning(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,129 @@
+package sqlc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/ledger"
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/test"
+)
+
+func TestIssueInvoiceInOtherCurrency(t *testing.T) {
+	t.Cleanup(func() {
+		cleanExchangeRatesData(t)
+		cleanLedgerData(t)
+		cleanPaymentsData(t)
+		cleanProductsData(t)
+		cleanInvoiceItemsData(t)
+		cleanInvoiceHeadersData(t)
+		cleanCustomersData(t)
+	})
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	insertCustomersData(ctx, t, db)
+	insertProductsData(ctx, t, db)
+	svc := billing.NewService(billing.NewRepo(db), billing.Options{InvoiceNumbers: invoiceNumbers(t), Currency: "EUR"})
+
+	jan := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	rate := func(from, to money.Currency, value string, at time.Time) *billing.ExchangeRate {
+		r, err := money.ParseRate(from, to, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &billing.ExchangeRate{Rate: r, ValidFrom: at}
+	}
+	if err := svc.CreateExchangeRate(ctx, rate("EUR", "USD", "1.25", jan)); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.CreateExchangeRate(ctx, rate("EUR", "USD", "1.1", jan)); !errors.Is(err, billing.ErrExchangeRateExists) {
+		t.Errorf("want error %v, got %v", billing.ErrExchangeRateExists, err)
+	}
+	// Not valid yet when the invoice is issued.
+	if err := svc.CreateExchangeRate(ctx, rate("USD", "EUR", "0.5", time.Now().AddDate(0, 0, 1))); err != nil {
+		t.Fatal(err)
+	}
+
+	generate := func(price money.Money) *billing.Invoice {
+		t.Helper()
+		inv := &billing.Invoice{
+			Header: &billing.InvoiceHeader{ClientID: 1},
+			Items:  billing.ItemList{{ProductID: 1, ProductName: "Coca-Cola", Quantity: 2, UnitPrice: price}},
+		}
+		if err := svc.Generate(ctx, inv); err != nil {
+			t.Fatal(err)
+		}
+		return inv
+	}
+	usd := generate(money.New(1000, "USD"))
+	if usd.Header.Currency != "USD" || usd.Header.BaseCurrency != "EUR" || !usd.Header.ExchangeRate.IsZero() {
+		t.Errorf("want a draft in USD of base EUR without rate, got %+v", usd.Header)
+	}
+	h, err := svc.Issue(ctx, billing.Actor{UserID: 1}, usd.Header.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := money.Rate{From: "USD", To: "EUR", Value: 80_000_000}
+	if h.ExchangeRate != want {
+		t.Errorf("want the inverted rate %+v, got %+v", want, h.ExchangeRate)
+	}
+
+	// Paid in parts converted one by one, each cent is 0.8 EUR cents
+	// rounded to 1: the payment that settles it closes the receivable and
+	// the rounding is an exchange difference.
+	for _, amount := range []int64{1, 1, 1, h.Total - 3} {
+		pay := &billing.Payment{Amount: amount, Method: billing.MethodTransfer, ReceivedAt: time.Now()}
+		if err := svc.Pay(ctx, billing.Actor{UserID: 1}, h.ID, pay); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tb, err := ledger.NewService(ledger.NewRepo(db)).TrialBalance(ctx, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	receivable, err := h.ExchangeRate.Convert(money.New(h.Total, h.Currency))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cash int64
+	for _, b := range tb.Accounts {
+		switch b.Code {
+		case ledger.AccountsReceivable:
+			if b.Net() != 0 {
+				t.Errorf("want the receivable settled, got %d", b.Net())
+			}
+		case ledger.Cash:
+			cash = b.Net()
+		}
+	}
+	for _, b := range tb.Accounts {
+		if want := cash - receivable.Amount; b.Code == ledger.ExchangeDifference && b.Net() != want {
+			t.Errorf("want the exchange difference %d, got %d", want, b.Net())
+		}
+	}
+
+	jpy := generate(money.New(500, "JPY"))
+	if _, err := svc.Issue(ctx, billing.Actor{UserID: 1}, jpy.Header.ID, ""); !errors.Is(err, billing.ErrExchangeRateNotFound) {
+		t.Errorf("want error %v, got %v", billing.ErrExchangeRateNotFound, err)
+	}
+	got, err := svc.Find(ctx, jpy.Header.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Header.Status != billing.StatusDraft {
+		t.Errorf("want the invoice without rate kept as draft, got %s", got.Header.Status)
+	}
+}
+
+func cleanExchangeRatesData(t *testing.T) {
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	err := billing.NewRepo(db).DeleteAllExchangeRates(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
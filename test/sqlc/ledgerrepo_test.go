@@ -0,0 +1,203 @@
+package sqlc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/ledger"
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
+	"github.com/adrianolmedo/genesis/test"
+)
+
+func TestLedger(t *testing.T) {
+	t.Cleanup(func() {
+		cleanPaymentsData(t)
+		cleanProductsData(t)
+		cleanInvoiceItemsData(t)
+		cleanInvoiceHeadersData(t)
+		cleanCustomersData(t)
+	})
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	insertCustomersData(ctx, t, db)
+	insertProductsData(ctx, t, db)
+	r := billing.NewRepo(db)
+	svc := ledger.NewService(ledger.NewRepo(db))
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	before := now.Add(-time.Hour)
+
+	// Ten Coca-Colas at 16%, paid 1500 of which 500 are credit of the
+	// client, and one returned. Five Big-Colas issued and voided.
+	issue := func(inv *billing.Invoice, at time.Time) {
+		t.Helper()
+		if err := r.CreateInvoice(ctx, inv, invoiceNumbers(t)); err != nil {
+			t.Fatal(err)
+		}
+		change := billing.StatusChange{By: billing.Actor{UserID: 1}, ChangedAt: at}
+		if _, err := r.Transition(ctx, inv.Header.ID, billing.StatusIssued, change); err != nil {
+			t.Fatal(err)
+		}
+	}
+	colas := &billing.Invoice{
+		Header: &billing.InvoiceHeader{ClientID: 1, Currency: "EUR", Totals: billing.Totals{Subtotal: 1000, Tax: 160, Total: 1160}},
+		Items: billing.ItemList{{ProductID: 1, ProductName: "Coca-Cola", Quantity: 10, UnitPrice: money.New(100, "EUR"), TaxRate: 1600,
+			Totals: billing.Totals{Subtotal: 1000, Tax: 160, Total: 1160}}},
+	}
+	issue(colas, before)
+	p := &billing.Payment{Amount: 1500, Method: billing.MethodCash, ReceivedAt: before, CreatedAt: before,
+		Allocations: []billing.Allocation{{InvoiceID: colas.Header.ID, Amount: 1000}}}
+	if err := r.CreatePayment(ctx, p, []int64{colas.Header.ID}); err != nil {
+		t.Fatal(err)
+	}
+	cn := &billing.CreditNote{
+		InvoiceID: colas.Header.ID,
+		Reason:    "Returned",
+		Items:     billing.CreditNoteItems{{InvoiceItemID: colas.Items[0].ID, Quantity: 1}},
+		CreatedAt: before,
+	}
+	if err := r.CreateCreditNote(ctx, cn, creditNoteNumbers(t)); err != nil {
+		t.Fatal(err)
+	}
+	bigs := &billing.Invoice{
+		Header: &billing.InvoiceHeader{ClientID: 2, Currency: "EUR", Totals: billing.Totals{Subtotal: 500, Total: 500}},
+		Items: billing.ItemList{{ProductID: 2, ProductName: "Big-Cola", Quantity: 5, UnitPrice: money.New(100, "EUR"),
+			Totals: billing.Totals{Subtotal: 500, Total: 500}}},
+	}
+	issue(bigs, before)
+	change := billing.StatusChange{By: billing.Actor{UserID: 1}, ChangedAt: now}
+	if _, err := r.Transition(ctx, bigs.Header.ID, billing.StatusVoid, change); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("entries", func(t *testing.T) {
+		tt := []struct {
+			source string
+			id     int64
+			want   []ledger.Line
+		}{
+			{billing.SourceInvoice, colas.Header.ID, []ledger.Line{
+				{Account: ledger.AccountsReceivable, Debit: 1160}, {Account: ledger.Revenue, Credit: 1000}, {Account: ledger.TaxPayable, Credit: 160},
+			}},
+			{billing.SourcePayment, p.ID, []ledger.Line{
+				{Account: ledger.Cash, Debit: 1500}, {Account: ledger.AccountsReceivable, Credit: 1000}, {Account: ledger.CustomerCredit, Credit: 500},
+			}},
+			{billing.SourceCreditNote, cn.ID, []ledger.Line{
+				{Account: ledger.Revenue, Debit: 100}, {Account: ledger.TaxPayable, Debit: 16}, {Account: ledger.AccountsReceivable, Credit: 116},
+			}},
+			{billing.SourceInvoiceVoid, bigs.Header.ID, []ledger.Line{
+				{Account: ledger.AccountsReceivable, Credit: 500}, {Account: ledger.Revenue, Debit: 500},
+			}},
+		}
+		for _, tc := range tt {
+			entries, err := svc.Entries(ctx, tc.source, tc.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 || len(entries[0].Lines) != len(tc.want) {
+				t.Fatalf("want an entry of %s %d with lines %+v, got %+v", tc.source, tc.id, tc.want, entries)
+			}
+			for i, l := range entries[0].Lines {
+				l.ID = 0
+				if l != tc.want[i] {
+					t.Errorf("%s: want line %+v, got %+v", tc.source, tc.want[i], l)
+				}
+			}
+		}
+	})
+
+	t.Run("trial-balance", func(t *testing.T) {
+		tb, err := svc.TrialBalance(ctx, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tb.Debit != 3776 || tb.Credit != 3776 {
+			t.Errorf("want 3776 debit and credit, got %d and %d", tb.Debit, tb.Credit)
+		}
+		want := map[string]int64{
+			ledger.Cash:               1500,
+			ledger.AccountsReceivable: 44,
+			ledger.TaxPayable:         144,
+			ledger.CustomerCredit:     500,
+			ledger.Revenue:            900,
+		}
+		for _, b := range tb.Accounts {
+			if b.Net() != want[b.Code] {
+				t.Errorf("want %s balance %d, got %d", b.Code, want[b.Code], b.Net())
+			}
+		}
+
+		// Before the void the Big-Colas are revenue.
+		tb, err = svc.TrialBalance(ctx, before)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range tb.Accounts {
+			if b.Code == ledger.Revenue && b.Net() != 1400 {
+				t.Errorf("want revenue of 1400 before the void, got %d", b.Net())
+			}
+		}
+	})
+
+	t.Run("database-invariants", func(t *testing.T) {
+		// An unbalanced entry is rejected when the transaction commits,
+		// even if it's inserted without the checks of Go.
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback(ctx)
+		q := dbgen.New(tx)
+		id, err := q.JournalEntryCreate(ctx, dbgen.JournalEntryCreateParams{Source: "test", SourceID: 1, PostedAt: now, CreatedAt: now})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range []dbgen.JournalLineCreateParams{
+			{EntryID: id, AccountCode: ledger.Cash, Debit: 100},
+			{EntryID: id, AccountCode: ledger.Revenue, Credit: 99},
+		} {
+			if _, err := q.JournalLineCreate(ctx, l); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := ledger.Err(tx.Commit(ctx)); !errors.Is(err, ledger.ErrUnbalanced) {
+			t.Errorf("want error %v, got %v", ledger.ErrUnbalanced, err)
+		}
+
+		// The lines posted can't change.
+		_, err = db.Exec(ctx, `UPDATE journal_line SET debit = debit + 1 WHERE account_code = $1`, ledger.Cash)
+		if err := ledger.Err(err); !errors.Is(err, ledger.ErrJournalAppend) {
+			t.Errorf("want error %v, got %v", ledger.ErrJournalAppend, err)
+		}
+		_, err = db.Exec(ctx, `DELETE FROM journal_entry`)
+		if err := ledger.Err(err); !errors.Is(err, ledger.ErrJournalAppend) {
+			t.Errorf("want error %v, got %v", ledger.ErrJournalAppend, err)
+		}
+
+		// An event is posted once.
+		tx, err = db.Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback(ctx)
+		e := &ledger.Entry{Source: billing.SourcePayment, SourceID: p.ID, PostedAt: now}
+		e.Debit(ledger.Cash, 1)
+		e.Credit(ledger.CustomerCredit, 1)
+		if err := ledger.Post(ctx, dbgen.New(tx), e); !errors.Is(err, ledger.ErrEntryExists) {
+			t.Errorf("want error %v, got %v", ledger.ErrEntryExists, err)
+		}
+	})
+}
+
+func cleanLedgerData(t *testing.T) {
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	err := ledger.NewRepo(db).DeleteAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
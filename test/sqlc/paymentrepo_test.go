@@ -0,0 +1,104 @@
+package sqlc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/test"
+)
+
+func TestCreatePayment(t *testing.T) {
+	t.Cleanup(func() {
+		cleanPaymentsData(t)
+		cleanProductsData(t)
+		cleanInvoiceItemsData(t)
+		cleanInvoiceHeadersData(t)
+		cleanCustomersData(t)
+	})
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	insertCustomersData(ctx, t, db)
+	insertProductsData(ctx, t, db)
+	r := billing.NewRepo(db)
+	now := time.Now()
+	change := billing.StatusChange{By: billing.Actor{UserID: 1}, ChangedAt: now}
+	var ids []int64
+	for range 2 {
+		inv := &billing.Invoice{
+			Header: &billing.InvoiceHeader{ClientID: 1, Currency: "EUR", Totals: billing.Totals{Subtotal: 1000, Total: 1000}},
+			Items: billing.ItemList{
+				billing.InvoiceItem{
+					ProductID:   1,
+					ProductName: "Coca-Cola",
+					Quantity:    1,
+					UnitPrice:   money.New(1000, "EUR"),
+					Totals:      billing.Totals{Subtotal: 1000, Total: 1000},
+				},
+			},
+		}
+		if err := r.CreateInvoice(ctx, inv, invoiceNumbers(t)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Transition(ctx, inv.Header.ID, billing.StatusIssued, change); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, inv.Header.ID)
+	}
+
+	// Partial payment of the first invoice.
+	p := &billing.Payment{Amount: 400, Method: billing.MethodCash, ReceivedAt: now, CreatedAt: now}
+	if err := r.CreatePayment(ctx, p, ids[:1]); err != nil {
+		t.Fatal(err)
+	}
+	assertInvoiceStatus(t, r, ids[0], billing.StatusPartiallyPaid)
+
+	// Pays the rest of the first, part of the second and leaves credit.
+	p = &billing.Payment{
+		Amount:     2000,
+		Method:     billing.MethodTransfer,
+		ReceivedAt: now,
+		CreatedAt:  now,
+		Allocations: []billing.Allocation{
+			{InvoiceID: ids[0], Amount: 600},
+			{InvoiceID: ids[1], Amount: 900},
+		},
+	}
+	if err := r.CreatePayment(ctx, p, ids); err != nil {
+		t.Fatal(err)
+	}
+	assertInvoiceStatus(t, r, ids[0], billing.StatusPaid)
+	assertInvoiceStatus(t, r, ids[1], billing.StatusPartiallyPaid)
+
+	b, err := r.Balance(ctx, 1, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := billing.Balance{ClientID: 1, Invoiced: 2000, Paid: 1900, Outstanding: 100, Credit: 500}
+	if *b != want {
+		t.Errorf("want balance %+v, got %+v", want, *b)
+	}
+}
+
+func assertInvoiceStatus(t *testing.T, r *billing.Repo, id int64, want billing.Status) {
+	t.Helper()
+	inv, err := r.ByID(test.Ctx(t), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Header.Status != want {
+		t.Errorf("invoice %d: want status %s, got %s", id, want, inv.Header.Status)
+	}
+}
+
+func cleanPaymentsData(t *testing.T) {
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	err := billing.NewRepo(db).DeleteAllPayments(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/adrianolmedo/genesis/money"
 	"github.com/adrianolmedo/genesis/store"
 	"github.com/adrianolmedo/genesis/test"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -24,7 +25,7 @@ func TestCreateProduct(t *testing.T) {
 	input := &store.Product{
 		Name:         "Coca-Cola",
 		Observations: "",
-		Price:        3,
+		Price:        money.New(3, "EUR"),
 	}
 
 	if err := p.Create(ctx, input); err != nil {
@@ -108,7 +109,7 @@ func TestUpdateProduct(t *testing.T) {
 		ID:           1,
 		Name:         "Coca-Cola",
 		Observations: "",
-		Price:        3,
+		Price:        money.New(3, "EUR"),
 	}
 	p := store.NewProductRepo(db)
 	if err := p.Update(ctx, input); err != nil {
@@ -143,7 +144,7 @@ func insertProductsData(ctx context.Context, t *testing.T, db *pgxpool.Pool) {
 	if err := p.Create(ctx, &store.Product{
 		Name:         "Coca-Cola",
 		Observations: "",
-		Price:        3,
+		Price:        money.New(3, "EUR"),
 	}); err != nil {
 		t.Fatal(err)
 	}
@@ -151,7 +152,7 @@ func insertProductsData(ctx context.Context, t *testing.T, db *pgxpool.Pool) {
 	if err := p.Create(ctx, &store.Product{
 		Name:         "Big-Cola",
 		Observations: "Made in Venezuela",
-		Price:        2,
+		Price:        money.New(2, "EUR"),
 	}); err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,195 @@
+package sqlc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/billing/report"
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/test"
+)
+
+func TestReports(t *testing.T) {
+	t.Cleanup(func() {
+		cleanPaymentsData(t)
+		cleanProductsData(t)
+		cleanInvoiceItemsData(t)
+		cleanInvoiceHeadersData(t)
+		cleanCustomersData(t)
+	})
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	insertCustomersData(ctx, t, db)
+	insertProductsData(ctx, t, db)
+	r := billing.NewRepo(db)
+	svc := report.NewService(report.NewRepo(db), "EUR")
+
+	// The invoices of the fixture, ten Coca-Colas at 16% and five Big-Colas
+	// of Ana, two Big-Colas without due date and a Coca-Cola of Luis, and
+	// a draft of Luis that isn't revenue.
+	day := func(month time.Month, d int) time.Time { return time.Date(2024, month, d, 0, 0, 0, 0, time.UTC) }
+	ana := billing.BillTo{Name: "Ana Pérez"}
+	luis := billing.BillTo{Name: "Luis Gómez"}
+	cola := reportInvoice(ctx, t, r, 1, ana, day(1, 10), day(2, 9), 1, "Coca-Cola", 10, 300, 480, true)
+	reportInvoice(ctx, t, r, 1, ana, day(4, 20), day(5, 20), 2, "Big-Cola", 5, 200, 0, true)
+	old := reportInvoice(ctx, t, r, 2, luis, day(1, 5), time.Time{}, 2, "Big-Cola", 2, 200, 0, true)
+	reportInvoice(ctx, t, r, 2, luis, day(3, 20), day(4, 10), 1, "Coca-Cola", 1, 300, 0, true)
+	reportInvoice(ctx, t, r, 2, luis, day(3, 1), time.Time{}, 1, "Coca-Cola", 3, 333, 0, false)
+
+	// Two Coca-Colas returned in February and 1000 paid in March, the old
+	// invoice of Luis is paid in May.
+	cn := &billing.CreditNote{
+		InvoiceID: cola.Header.ID,
+		Reason:    "Returned",
+		Items:     billing.CreditNoteItems{{InvoiceItemID: cola.Items[0].ID, Quantity: 2}},
+		CreatedAt: day(2, 15),
+	}
+	if err := r.CreateCreditNote(ctx, cn, creditNoteNumbers(t)); err != nil {
+		t.Fatal(err)
+	}
+	if cn.Total != 696 || cn.Tax != 96 {
+		t.Fatalf("unexpected credit note of %d, tax %d", cn.Total, cn.Tax)
+	}
+	for _, p := range []struct {
+		invoice int64
+		amount  int64
+		at      time.Time
+	}{
+		{cola.Header.ID, 1000, day(3, 1)},
+		{old.Header.ID, 400, day(5, 5)},
+	} {
+		pay := &billing.Payment{Amount: p.amount, Method: billing.MethodCash, ReceivedAt: p.at, CreatedAt: p.at}
+		if err := r.CreatePayment(ctx, pay, []int64{p.invoice}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("aging", func(t *testing.T) {
+		at := day(4, 30).Add(12 * time.Hour)
+		got, err := svc.Aging(ctx, at, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []report.AgingRow{
+			{ClientID: 1, Name: "Ana Pérez", Invoices: 2, Buckets: report.Buckets{Current: 1000, Days61To90: 1784, Total: 2784}},
+			{ClientID: 2, Name: "Luis Gómez", Invoices: 2, Buckets: report.Buckets{Days1To30: 300, Over90: 400, Total: 700}},
+		}
+		if !reflect.DeepEqual(got.Rows, want) {
+			t.Errorf("want rows %+v, got %+v", want, got.Rows)
+		}
+		wantTotal := report.Buckets{Current: 1000, Days1To30: 300, Days61To90: 1784, Over90: 400, Total: 3484}
+		if got.Total != wantTotal {
+			t.Errorf("want total %+v, got %+v", wantTotal, got.Total)
+		}
+
+		// Once paid in May the old invoice isn't owed.
+		got, err = svc.Aging(ctx, day(5, 31), 2, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = []report.AgingRow{
+			{ClientID: 2, Name: "Luis Gómez", Invoices: 1, Buckets: report.Buckets{Days31To60: 300, Total: 300}},
+		}
+		if !reflect.DeepEqual(got.Rows, want) {
+			t.Errorf("want rows %+v, got %+v", want, got.Rows)
+		}
+	})
+
+	t.Run("revenue-by-product", func(t *testing.T) {
+		got, err := svc.RevenueByProduct(ctx, report.Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []report.ProductRevenue{
+			{ProductID: 1, ProductName: "Coca-Cola", Quantity: 9, Amounts: report.Amounts{Net: 2700, Tax: 384, Total: 3084}},
+			{ProductID: 2, ProductName: "Big-Cola", Quantity: 7, Amounts: report.Amounts{Net: 1400, Total: 1400}},
+		}
+		if !reflect.DeepEqual(got.Rows, want) {
+			t.Errorf("want rows %+v, got %+v", want, got.Rows)
+		}
+		if wantTotal := (report.Amounts{Net: 4100, Tax: 384, Total: 4484}); got.Total != wantTotal {
+			t.Errorf("want total %+v, got %+v", wantTotal, got.Total)
+		}
+
+		// February and March, the return and the Coca-Cola of Luis.
+		got, err = svc.RevenueByProduct(ctx, report.Filter{From: day(2, 1), To: day(4, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = []report.ProductRevenue{
+			{ProductID: 1, ProductName: "Coca-Cola", Quantity: -1, Amounts: report.Amounts{Net: -300, Tax: -96, Total: -396}},
+		}
+		if !reflect.DeepEqual(got.Rows, want) {
+			t.Errorf("want rows %+v, got %+v", want, got.Rows)
+		}
+	})
+
+	t.Run("revenue-by-month", func(t *testing.T) {
+		got, err := svc.RevenueByMonth(ctx, report.Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []report.MonthRevenue{
+			{Month: "2024-01", Invoices: 2, Invoiced: 3400, Amounts: report.Amounts{Net: 3400, Tax: 480, Total: 3880}},
+			{Month: "2024-02", CreditNotes: 1, Credited: 600, Amounts: report.Amounts{Net: -600, Tax: -96, Total: -696}},
+			{Month: "2024-03", Invoices: 1, Invoiced: 300, Amounts: report.Amounts{Net: 300, Total: 300}},
+			{Month: "2024-04", Invoices: 1, Invoiced: 1000, Amounts: report.Amounts{Net: 1000, Total: 1000}},
+		}
+		if !reflect.DeepEqual(got.Rows, want) {
+			t.Errorf("want rows %+v, got %+v", want, got.Rows)
+		}
+		wantTotal := report.MonthRevenue{Invoices: 4, CreditNotes: 1, Invoiced: 4700, Credited: 600,
+			Amounts: report.Amounts{Net: 4100, Tax: 384, Total: 4484}}
+		if got.Total != wantTotal {
+			t.Errorf("want total %+v, got %+v", wantTotal, got.Total)
+		}
+
+		got, err = svc.RevenueByMonth(ctx, report.Filter{ClientID: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = []report.MonthRevenue{
+			{Month: "2024-01", Invoices: 1, Invoiced: 400, Amounts: report.Amounts{Net: 400, Total: 400}},
+			{Month: "2024-03", Invoices: 1, Invoiced: 300, Amounts: report.Amounts{Net: 300, Total: 300}},
+		}
+		if !reflect.DeepEqual(got.Rows, want) {
+			t.Errorf("want rows %+v, got %+v", want, got.Rows)
+		}
+	})
+}
+
+// reportInvoice creates an invoice of a line at created, issued if issue,
+// due at due unless it's zero.
+func reportInvoice(ctx context.Context, t *testing.T, r *billing.Repo, clientID int64, to billing.BillTo,
+	created, due time.Time, productID int64, name string, quantity int, price, tax int64, issue bool) *billing.Invoice {
+	t.Helper()
+	subtotal := int64(quantity) * price
+	totals := billing.Totals{Subtotal: subtotal, Tax: tax, Total: subtotal + tax}
+	inv := &billing.Invoice{
+		Header: &billing.InvoiceHeader{ClientID: clientID, Currency: "EUR", BillTo: to, CreatedAt: created, DueAt: due, Totals: totals},
+		Items: billing.ItemList{
+			billing.InvoiceItem{
+				ProductID:   productID,
+				ProductName: name,
+				Quantity:    quantity,
+				UnitPrice:   money.New(price, "EUR"),
+				TaxRate:     int(tax * billing.MaxTaxRate / subtotal),
+				Totals:      totals,
+			},
+		},
+	}
+	if err := r.CreateInvoice(ctx, inv, invoiceNumbers(t)); err != nil {
+		t.Fatal(err)
+	}
+	if issue {
+		change := billing.StatusChange{By: billing.Actor{UserID: 1}, ChangedAt: created}
+		if _, err := r.Transition(ctx, inv.Header.ID, billing.StatusIssued, change); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return inv
+}
@@ -0,0 +1,155 @@
+package sqlc
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/store"
+	"github.com/adrianolmedo/genesis/test"
+)
+
+func TestRenewSubscriptions(t *testing.T) {
+	t.Cleanup(func() {
+		cleanSubscriptionsData(t)
+		cleanLedgerData(t)
+		cleanProductsData(t)
+		cleanInvoiceItemsData(t)
+		cleanInvoiceHeadersData(t)
+		cleanCustomersData(t)
+	})
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	insertCustomersData(ctx, t, db)
+	r := billing.NewRepo(db)
+	svc := billing.NewService(r, billing.Options{InvoiceNumbers: invoiceNumbers(t)})
+
+	products := store.NewProductRepo(db)
+	seat := &store.Product{Name: "Seat", Price: money.New(3000, "EUR")}
+	storage := &store.Product{Name: "Storage", Price: money.New(100, "EUR")}
+	for _, p := range []*store.Product{seat, storage} {
+		if err := products.Create(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	basic := &billing.Plan{Name: "Basic", Interval: 1, Items: []billing.PlanItem{{ProductID: seat.ID, Quantity: 1}}}
+	team := &billing.Plan{Name: "Team", Interval: 1, Items: []billing.PlanItem{
+		{ProductID: seat.ID, Quantity: 1},
+		{ProductID: storage.ID, Quantity: 10},
+	}}
+	yearly := &billing.Plan{Name: "Yearly", Interval: 12, Items: []billing.PlanItem{{ProductID: seat.ID, Quantity: 1}}}
+	for _, p := range []*billing.Plan{basic, team, yearly} {
+		if err := svc.CreatePlan(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	unknown := &billing.Plan{Name: "Unknown", Interval: 1, Items: []billing.PlanItem{{ProductID: storage.ID + 100, Quantity: 1}}}
+	if err := svc.CreatePlan(ctx, unknown); !errors.Is(err, billing.ErrPlanProductNotFound) {
+		t.Errorf("want error %v, got %v", billing.ErrPlanProductNotFound, err)
+	}
+
+	// Three periods have started, the replicas renew at the same time.
+	now := time.Now().UTC()
+	anchor := now.AddDate(0, -2, -1)
+	sub := &billing.Subscription{ClientID: 1, PlanID: basic.ID, Quantity: 2, Anchor: anchor}
+	if err := svc.Subscribe(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var renewed []billing.Renewal
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rs, err := svc.RenewDue(ctx, now)
+			if err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			renewed = append(renewed, rs...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if len(renewed) != 3 {
+		t.Fatalf("want 3 periods renewed once, got %d", len(renewed))
+	}
+	seen := map[int]bool{}
+	for _, rn := range renewed {
+		if seen[rn.Period] || rn.Invoice.Header.Total != 6000 || rn.Invoice.Header.Status != billing.StatusIssued {
+			t.Errorf("unexpected renewal of period %d: %+v", rn.Period, rn.Invoice.Header)
+		}
+		seen[rn.Period] = true
+	}
+	got, err := svc.FindSubscription(ctx, sub.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start, _ := got.Period(3); got.Next != 3 || !got.NextBillAt.Equal(start) {
+		t.Errorf("want period 3 next, got %+v", got)
+	}
+	if rs, err := svc.RenewDue(ctx, now); err != nil || len(rs) != 0 {
+		t.Errorf("want nothing due, got %+v, %v", rs, err)
+	}
+
+	// A mid-cycle change is prorated and invoiced with the next period.
+	if _, _, err := svc.ChangeSubscription(ctx, sub.ID, yearly.ID, 2); !errors.Is(err, billing.ErrPlanIntervalDiffers) {
+		t.Errorf("want error %v, got %v", billing.ErrPlanIntervalDiffers, err)
+	}
+	_, adjustments, err := svc.ChangeSubscription(ctx, sub.ID, team.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(adjustments) != 2 || adjustments[0].Amount >= 0 || adjustments[1].Amount <= 0 {
+		t.Fatalf("want a credit of seats and a charge of storage, got %+v", adjustments)
+	}
+	preview, err := svc.PreviewDue(ctx, got.NextBillAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preview) != 1 || preview[0].Period != 3 || preview[0].Invoice.Header.ID != 0 {
+		t.Fatalf("unexpected preview %+v", preview)
+	}
+	// Kept as a draft to be issued by hand.
+	drafts := billing.NewService(r, billing.Options{InvoiceNumbers: invoiceNumbers(t), DraftRenewals: true})
+	renewed, err = drafts.RenewDue(ctx, got.NextBillAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credit := -adjustments[0].Amount
+	charge := adjustments[1].Amount
+	want := 3000 + 10*100 + charge - credit
+	if len(renewed) != 1 || renewed[0].Invoice.Header.Total != want || preview[0].Invoice.Header.Total != want ||
+		renewed[0].Invoice.Header.Status != billing.StatusDraft {
+		t.Fatalf("want a total of %d, got %+v", want, renewed)
+	}
+	pending, err := r.PendingAdjustments(ctx, sub.ID)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("want the adjustments invoiced, got %+v, %v", pending, err)
+	}
+
+	if _, err := svc.CancelSubscription(ctx, sub.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CancelSubscription(ctx, sub.ID); !errors.Is(err, billing.ErrSubscriptionCanceled) {
+		t.Errorf("want error %v, got %v", billing.ErrSubscriptionCanceled, err)
+	}
+	if rs, err := svc.RenewDue(ctx, now.AddDate(1, 0, 0)); err != nil || len(rs) != 0 {
+		t.Errorf("want a canceled subscription not renewed, got %+v, %v", rs, err)
+	}
+}
+
+func cleanSubscriptionsData(t *testing.T) {
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	err := billing.NewRepo(db).DeleteAllSubscriptions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,134 @@
+package sqlc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/billing"
+	"github.com/adrianolmedo/genesis/money"
+	"github.com/adrianolmedo/genesis/store"
+	"github.com/adrianolmedo/genesis/test"
+)
+
+func TestGenerateTaxedInvoice(t *testing.T) {
+	t.Cleanup(func() {
+		cleanTaxesData(t)
+		cleanProductsData(t)
+		cleanInvoiceItemsData(t)
+		cleanInvoiceHeadersData(t)
+		cleanCustomersData(t)
+	})
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	insertCustomersData(ctx, t, db)
+	r := billing.NewRepo(db)
+	svc := billing.NewService(r, billing.Options{
+		InvoiceNumbers: invoiceNumbers(t),
+		Jurisdiction:   "MX",
+		TaxRounding:    billing.RoundPerLine,
+	})
+
+	// The standard category is seeded, the reduced one is kept between runs.
+	err := svc.CreateTaxCategory(ctx, &billing.TaxCategory{Code: "reduced", Name: "Reduced rate"})
+	if err != nil && !errors.Is(err, billing.ErrTaxCategoryExists) {
+		t.Fatal(err)
+	}
+	jan := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	rates := []billing.TaxRate{
+		{Category: "standard", Jurisdiction: "MX", Name: "IVA 16%", Rate: 1600, ValidFrom: jan},
+		{Category: "reduced", Jurisdiction: "MX", Name: "IVA 8%", Rate: 800, ValidFrom: jan},
+		{Category: "standard", Jurisdiction: "US-CA", Name: "Sales tax", Rate: 725, ValidFrom: jan},
+	}
+	for i := range rates {
+		if err := svc.CreateTaxRate(ctx, &rates[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	overlap := billing.TaxRate{Category: "standard", Jurisdiction: "MX", Name: "IVA 15%", Rate: 1500, ValidFrom: jan.AddDate(1, 0, 0)}
+	if err := svc.CreateTaxRate(ctx, &overlap); !errors.Is(err, billing.ErrTaxRateOverlaps) {
+		t.Errorf("want error %v, got %v", billing.ErrTaxRateOverlaps, err)
+	}
+	unknown := billing.TaxRate{Category: "luxury", Jurisdiction: "MX", Name: "IEPS", Rate: 800, ValidFrom: jan}
+	if err := svc.CreateTaxRate(ctx, &unknown); !errors.Is(err, billing.ErrTaxCategoryNotFound) {
+		t.Errorf("want error %v, got %v", billing.ErrTaxCategoryNotFound, err)
+	}
+	exemption := billing.TaxExemption{ClientID: 2, Category: "reduced", Jurisdiction: "MX", Reason: "Certificate 123", ValidFrom: jan}
+	if err := svc.CreateTaxExemption(ctx, &exemption); err != nil {
+		t.Fatal(err)
+	}
+
+	products := store.NewProductRepo(db)
+	laptop := &store.Product{Name: "Laptop", Price: money.New(10000, "EUR"), TaxCategory: "standard"}
+	book := &store.Product{Name: "Book", Price: money.New(500, "EUR"), TaxCategory: "reduced"}
+	card := &store.Product{Name: "Gift card", Price: money.New(300, "EUR")}
+	for _, p := range []*store.Product{laptop, book, card} {
+		if err := products.Create(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := products.Create(ctx, &store.Product{Name: "Wine", Price: money.New(1000, "EUR"), TaxCategory: "luxury"}); !errors.Is(err, store.ErrTaxCategoryNotFound) {
+		t.Errorf("want error %v, got %v", store.ErrTaxCategoryNotFound, err)
+	}
+
+	items := func() billing.ItemList {
+		return billing.ItemList{
+			{ProductID: laptop.ID, ProductName: laptop.Name, Quantity: 1, UnitPrice: laptop.Price, TaxCategory: laptop.TaxCategory},
+			{ProductID: book.ID, ProductName: book.Name, Quantity: 2, UnitPrice: book.Price, TaxCategory: book.TaxCategory},
+			{ProductID: card.ID, ProductName: card.Name, Quantity: 1, UnitPrice: card.Price},
+		}
+	}
+	tt := []struct {
+		name      string
+		header    billing.InvoiceHeader
+		wantTax   int64
+		wantLines int
+	}{
+		{name: "default-jurisdiction", header: billing.InvoiceHeader{ClientID: 1}, wantTax: 1600 + 80, wantLines: 3},
+		{name: "exempt-client", header: billing.InvoiceHeader{ClientID: 2}, wantTax: 1600, wantLines: 3},
+		{name: "other-jurisdiction", header: billing.InvoiceHeader{ClientID: 1, Jurisdiction: "US-CA"}},
+	}
+	for _, tc := range tt {
+		h := tc.header
+		inv := &billing.Invoice{Header: &h, Items: items()}
+		err := svc.Generate(ctx, inv)
+		if tc.wantLines == 0 {
+			// US-CA has no rate of the reduced category.
+			if !errors.Is(err, billing.ErrTaxRateNotFound) {
+				t.Errorf("%s: want error %v, got %v", tc.name, billing.ErrTaxRateNotFound, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		got, err := svc.Find(ctx, inv.Header.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Header.Tax != tc.wantTax || got.Header.Jurisdiction != "MX" || got.Header.TaxRounding != billing.RoundPerLine {
+			t.Errorf("%s: unexpected header %+v", tc.name, got.Header)
+		}
+		if len(got.Taxes) != tc.wantLines {
+			t.Fatalf("%s: unexpected breakdown %+v", tc.name, got.Taxes)
+		}
+		var sum int64
+		for _, l := range got.Taxes {
+			sum += l.Tax
+		}
+		if sum != got.Header.Tax || got.Items[0].TaxName != "IVA 16%" {
+			t.Errorf("%s: unexpected breakdown %+v of items %+v", tc.name, got.Taxes, got.Items)
+		}
+	}
+}
+
+func cleanTaxesData(t *testing.T) {
+	ctx := test.Ctx(t)
+	db := openDB(ctx, t)
+	defer db.Close()
+	err := billing.NewRepo(db).DeleteAllTaxes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
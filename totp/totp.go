@@ -0,0 +1,126 @@
+// Package totp generates and validates time-based one-time passwords (RFC
+// 6238) as used by authenticator apps: HMAC-SHA1, 6 digits, 30 seconds.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidCode the code doesn't match the secret at the given time.
+	ErrInvalidCode = errors.New("invalid code")
+
+	// ErrMalformedSecret the secret isn't base32.
+	ErrMalformedSecret = errors.New("malformed secret")
+)
+
+const (
+	// Digits of a code.
+	Digits = 6
+
+	// Period each code is valid for.
+	Period = 30 * time.Second
+
+	// Skew is the number of periods before and after the current one whose
+	// codes are accepted, to tolerate clock drift and typing time.
+	Skew = 1
+
+	// secretSize in bytes, as recommended by RFC 4226 for HMAC-SHA1.
+	secretSize = 20
+)
+
+// b32 is the encoding of secrets, without padding as expected by the apps.
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random base32 secret.
+func NewSecret() (string, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(b), nil
+}
+
+// URI returns the provisioning URI of secret (otpauth://), usually shown as
+// a QR code so the app can add the account.
+func URI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(Digits))
+	v.Set("period", fmt.Sprint(int(Period.Seconds())))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// Counter returns the time step of t.
+func Counter(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// Code returns the code of secret at t.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decode(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, Counter(t), Digits), nil
+}
+
+// Validate checks code against secret at t, accepting the codes of Skew
+// periods around it. It returns the time step of the matching code, so the
+// caller can reject a code used before.
+func Validate(secret, code string, t time.Time) (int64, error) {
+	key, err := decode(secret)
+	if err != nil {
+		return 0, err
+	}
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return 0, ErrInvalidCode
+	}
+	now := Counter(t)
+	for c := now - Skew; c <= now+Skew; c++ {
+		want := hotp(key, c, Digits)
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return c, nil
+		}
+	}
+	return 0, ErrInvalidCode
+}
+
+func decode(secret string) ([]byte, error) {
+	s := strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := b32.DecodeString(strings.TrimRight(s, "="))
+	if err != nil || len(key) == 0 {
+		return nil, ErrMalformedSecret
+	}
+	return key, nil
+}
+
+// hotp is the HOTP value of counter (RFC 4226 section 5.3).
+func hotp(key []byte, counter int64, digits int) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for range digits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, bin%mod)
+}
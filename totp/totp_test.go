@@ -0,0 +1,79 @@
+package totp
+
+import (
+	"encoding/base32"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHOTP uses the SHA1 test vectors of RFC 6238 appendix B.
+func TestHOTP(t *testing.T) {
+	key := []byte("12345678901234567890")
+	tt := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "94287082"},
+		{unix: 1111111109, want: "07081804"},
+		{unix: 1111111111, want: "14050471"},
+		{unix: 1234567890, want: "89005924"},
+		{unix: 2000000000, want: "69279037"},
+		{unix: 20000000000, want: "65353130"},
+	}
+	for _, tc := range tt {
+		got := hotp(key, Counter(time.Unix(tc.unix, 0)), 8)
+		if got != tc.want {
+			t.Errorf("at %d: want %s, got %s", tc.unix, tc.want, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+	code, err := Code(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "050471" {
+		t.Fatalf("want code 050471, got %s", code)
+	}
+	tt := []struct {
+		name    string
+		at      time.Time
+		wantErr error
+	}{
+		{name: "now", at: now, wantErr: nil},
+		{name: "previous-period", at: now.Add(Period), wantErr: nil},
+		{name: "next-period", at: now.Add(-Period), wantErr: nil},
+		{name: "too-late", at: now.Add(2 * Period), wantErr: ErrInvalidCode},
+	}
+	for _, tc := range tt {
+		counter, err := Validate(secret, code, tc.at)
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: want error %v, got %v", tc.name, tc.wantErr, err)
+		}
+		if err == nil && counter != Counter(now) {
+			t.Errorf("%s: want counter %d, got %d", tc.name, Counter(now), counter)
+		}
+	}
+	if _, err := Validate("not base32!", code, now); !errors.Is(err, ErrMalformedSecret) {
+		t.Errorf("want error %v, got %v", ErrMalformedSecret, err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := URI("Genesis", "example@gmail.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Genesis:example@gmail.com?") {
+		t.Errorf("unexpected URI %s", uri)
+	}
+	if !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("expected the secret in the URI %s", uri)
+	}
+}
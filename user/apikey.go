@@ -0,0 +1,136 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAPIKeyInvalid                 = errors.New("invalid api key")
+	ErrAPIKeyExpired                 = errors.New("api key expired")
+	ErrAPIKeyNotFound                = errors.New("api key not found")
+	ErrAPIKeyNameCantBeEmpty         = errors.New("api key name can't be empty")
+	ErrAPIKeyExpiryInPast            = errors.New("api key expiry must be in the future")
+	ErrScopeNotGranted               = errors.New("scope not granted to you")
+	ErrServiceAccountNotFound        = errors.New("service account not found")
+	ErrServiceAccountNameCantBeEmpty = errors.New("service account name can't be empty")
+)
+
+// apiKeyTag starts every API key, so a leaked key is easy to recognize.
+const apiKeyTag = "gen_"
+
+// apiKeyPrefixLen is the length of the public part of an API key, the
+// key is looked up by it.
+const apiKeyPrefixLen = 8
+
+// apiKeyTouchInterval limits how often the last use of an API key is
+// written, a key used on every request doesn't write on each of them.
+const apiKeyTouchInterval = time.Minute
+
+// APIKey grants access to the API without a session, to a User or to a
+// ServiceAccount. The key is shown once on creation, only its hash and
+// prefix are stored.
+type APIKey struct {
+	ID               int64
+	Prefix           string
+	Hash             string
+	Name             string
+	UserID           int64 // 0 if owned by a service account
+	ServiceAccountID int64 // 0 if owned by a user
+	Scopes           []string
+	ExpiresAt        *time.Time // never expires if nil
+	LastUsedAt       *time.Time
+	RevokedAt        *time.Time
+	CreatedAt        time.Time
+}
+
+// Check returns an error if the key can't be used at now.
+func (k APIKey) Check(now time.Time) error {
+	if k.RevokedAt != nil {
+		return ErrAPIKeyInvalid
+	}
+	if k.ExpiresAt != nil && !now.Before(*k.ExpiresAt) {
+		return ErrAPIKeyExpired
+	}
+	return nil
+}
+
+// touchDue reports whether the last use of the key should be written.
+func (k APIKey) touchDue(now time.Time) bool {
+	return k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= apiKeyTouchInterval
+}
+
+// ServiceAccount is a non-human identity, e.g. another service, which
+// accesses the API with its API keys.
+type ServiceAccount struct {
+	ID          int64
+	Name        string
+	Description string
+	CreatedAt   time.Time
+}
+
+// newAPIKey generates the plain key, as gen_<prefix>_<secret>, and sets
+// the prefix and hash of k.
+func newAPIKey(k *APIKey) (string, error) {
+	b := make([]byte, 5+32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	prefix := recoveryEncoding.EncodeToString(b[:5])
+	plain := apiKeyTag + prefix + "_" + base64.RawURLEncoding.EncodeToString(b[5:])
+	k.Prefix = prefix
+	k.Hash = hashAPIKey(plain)
+	return plain, nil
+}
+
+// parseAPIKey returns the prefix of a plain key, to look it up.
+func parseAPIKey(plain string) (string, error) {
+	rest, ok := strings.CutPrefix(plain, apiKeyTag)
+	if !ok || len(rest) <= apiKeyPrefixLen+1 || rest[apiKeyPrefixLen] != '_' {
+		return "", ErrAPIKeyInvalid
+	}
+	return rest[:apiKeyPrefixLen], nil
+}
+
+// matches reports whether plain is the key of k, in constant time.
+func (k APIKey) matches(plain string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashAPIKey(plain)), []byte(k.Hash)) == 1
+}
+
+// hashAPIKey returns the hash an API key is stored by. The keys are random
+// with enough entropy, a fast hash is enough.
+func hashAPIKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// grantScopes returns ErrScopeNotGranted if a scope isn't held by the
+// actor, a key can't grant more than its creator holds.
+func grantScopes(actor Principal, scopes []string) error {
+	for _, s := range scopes {
+		if !actor.Can(s) {
+			return fmt.Errorf("%w: %s", ErrScopeNotGranted, s)
+		}
+	}
+	return nil
+}
+
+// keyPermissions returns the permissions of a key, the scopes of the key
+// still held by its owner.
+func keyPermissions(scopes, held []string) []string {
+	perms := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		if slices.Contains(held, s) {
+			perms = append(perms, s)
+		}
+	}
+	return perms
+}
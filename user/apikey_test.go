@@ -0,0 +1,97 @@
+package user
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	k := &APIKey{}
+	plain, err := newAPIKey(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(plain, apiKeyTag+k.Prefix+"_") {
+		t.Errorf("key %q doesn't start with its prefix %q", plain, k.Prefix)
+	}
+	prefix, err := parseAPIKey(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix != k.Prefix {
+		t.Errorf("want prefix %q, got %q", k.Prefix, prefix)
+	}
+	if !k.matches(plain) {
+		t.Error("the key doesn't match its hash")
+	}
+	if k.matches(plain + "x") {
+		t.Error("an altered key matches the hash")
+	}
+	if strings.Contains(k.Hash, plain) {
+		t.Error("the plain key is stored")
+	}
+}
+
+func TestParseAPIKey(t *testing.T) {
+	for _, plain := range []string{"", "gen_", "gen_k3m9x2aq", "gen_k3m9x2aq_", "gen_k3m9x2aqsecret", "k3m9x2aq_secret"} {
+		if _, err := parseAPIKey(plain); !errors.Is(err, ErrAPIKeyInvalid) {
+			t.Errorf("%q: want error %v, got %v", plain, ErrAPIKeyInvalid, err)
+		}
+	}
+}
+
+func TestAPIKeyCheck(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+	tt := []struct {
+		name    string
+		key     APIKey
+		wantErr error
+	}{
+		{name: "no-expiry", key: APIKey{}, wantErr: nil},
+		{name: "not-expired", key: APIKey{ExpiresAt: &future}, wantErr: nil},
+		{name: "expired", key: APIKey{ExpiresAt: &now}, wantErr: ErrAPIKeyExpired},
+		{name: "revoked", key: APIKey{RevokedAt: &past}, wantErr: ErrAPIKeyInvalid},
+	}
+	for _, tc := range tt {
+		err := tc.key.Check(now)
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: want error %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestAPIKeyTouchDue(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-time.Second)
+	old := now.Add(-apiKeyTouchInterval)
+	if !(APIKey{}).touchDue(now) {
+		t.Error("want the first use recorded")
+	}
+	if (APIKey{LastUsedAt: &recent}).touchDue(now) {
+		t.Error("want a recent use not recorded again")
+	}
+	if !(APIKey{LastUsedAt: &old}).touchDue(now) {
+		t.Error("want an old use recorded again")
+	}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	actor := Principal{UserID: 1, Permissions: []string{PermUsersRead, PermProductsWrite}}
+	if err := grantScopes(actor, []string{PermProductsWrite}); err != nil {
+		t.Errorf("want scope granted, got %v", err)
+	}
+	if err := grantScopes(actor, []string{PermRolesWrite}); !errors.Is(err, ErrScopeNotGranted) {
+		t.Errorf("want error %v, got %v", ErrScopeNotGranted, err)
+	}
+
+	// A scope the owner lost isn't granted by the key anymore.
+	perms := keyPermissions([]string{PermProductsWrite, PermRolesWrite}, actor.Permissions)
+	if !slices.Equal(perms, []string{PermProductsWrite}) {
+		t.Errorf("want permissions %v, got %v", []string{PermProductsWrite}, perms)
+	}
+}
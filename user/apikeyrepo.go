@@ -0,0 +1,196 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adrianolmedo/genesis/pgsql"
+	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// APIKeyRepo manages the storage of API keys and service accounts.
+type APIKeyRepo struct {
+	q *dbgen.Queries // methods generated by sqlc
+}
+
+// NewAPIKeyRepo creates a new APIKey repository instance.
+func NewAPIKeyRepo(db dbgen.DBTX) *APIKeyRepo {
+	return &APIKeyRepo{
+		q: dbgen.New(db),
+	}
+}
+
+// CreateServiceAccount stores a new ServiceAccount.
+func (r *APIKeyRepo) CreateServiceAccount(ctx context.Context, sa *ServiceAccount) error {
+	id, err := r.q.ServiceAccountCreate(ctx, dbgen.ServiceAccountCreateParams{
+		Name:        sa.Name,
+		Description: sa.Description,
+		CreatedAt:   sa.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	sa.ID = id
+	return nil
+}
+
+// ServiceAccount get a ServiceAccount by its ID.
+func (r *APIKeyRepo) ServiceAccount(ctx context.Context, id int64) (*ServiceAccount, error) {
+	row, err := r.q.ServiceAccountByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrServiceAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toDomainServiceAccount(row), nil
+}
+
+// ServiceAccounts returns all service accounts.
+func (r *APIKeyRepo) ServiceAccounts(ctx context.Context) ([]ServiceAccount, error) {
+	rows, err := r.q.ServiceAccountAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sas := make([]ServiceAccount, 0, len(rows))
+	for _, row := range rows {
+		sas = append(sas, *toDomainServiceAccount(row))
+	}
+	return sas, nil
+}
+
+// Create stores a new APIKey.
+func (r *APIKeyRepo) Create(ctx context.Context, k *APIKey) error {
+	id, err := r.q.APIKeyCreate(ctx, dbgen.APIKeyCreateParams{
+		Prefix:           k.Prefix,
+		KeyHash:          k.Hash,
+		Name:             k.Name,
+		UserID:           nullID(k.UserID),
+		ServiceAccountID: nullID(k.ServiceAccountID),
+		Scopes:           k.Scopes,
+		ExpiresAt:        pgsql.TimePtrToNull(k.ExpiresAt),
+		CreatedAt:        k.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	k.ID = id
+	return nil
+}
+
+// ByPrefix get an APIKey by the public prefix of the key.
+func (r *APIKeyRepo) ByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	row, err := r.q.APIKeyByPrefix(ctx, prefix)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toDomainAPIKey(row), nil
+}
+
+// ByID get an APIKey by its ID.
+func (r *APIKeyRepo) ByID(ctx context.Context, id int64) (*APIKey, error) {
+	row, err := r.q.APIKeyByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toDomainAPIKey(row), nil
+}
+
+// ByUser returns the API keys of a User, revoked and expired included.
+func (r *APIKeyRepo) ByUser(ctx context.Context, userID int64) ([]APIKey, error) {
+	rows, err := r.q.APIKeyByUser(ctx, nullID(userID))
+	if err != nil {
+		return nil, err
+	}
+	return toDomainAPIKeys(rows), nil
+}
+
+// ByServiceAccount returns the API keys of a ServiceAccount, revoked and
+// expired included.
+func (r *APIKeyRepo) ByServiceAccount(ctx context.Context, serviceAccountID int64) ([]APIKey, error) {
+	rows, err := r.q.APIKeyByServiceAccount(ctx, nullID(serviceAccountID))
+	if err != nil {
+		return nil, err
+	}
+	return toDomainAPIKeys(rows), nil
+}
+
+// Revoke an APIKey, revoking it twice has no effect.
+func (r *APIKeyRepo) Revoke(ctx context.Context, id int64, now time.Time) error {
+	_, err := r.q.APIKeyRevoke(ctx, dbgen.APIKeyRevokeParams{
+		RevokedAt: sql.NullTime{Time: now, Valid: true},
+		ID:        id,
+	})
+	return err
+}
+
+// Touch records the last use of an APIKey.
+func (r *APIKeyRepo) Touch(ctx context.Context, id int64, now time.Time) error {
+	return r.q.APIKeyTouch(ctx, dbgen.APIKeyTouchParams{
+		LastUsedAt: sql.NullTime{Time: now, Valid: true},
+		ID:         id,
+	})
+}
+
+// DeleteAll deletes all API keys and service accounts (permanently).
+func (r *APIKeyRepo) DeleteAll(ctx context.Context) error {
+	err := r.q.APIKeyDeleteAll(ctx)
+	if err != nil {
+		return fmt.Errorf("can't truncate table: %v", err)
+	}
+	return nil
+}
+
+// nullID converts an ID to a nullable column, 0 is NULL.
+func nullID(id int64) pgtype.Int8 {
+	return pgtype.Int8{Int64: id, Valid: id != 0}
+}
+
+// toDomainAPIKey converts a dbgen.ApiKey to an APIKey.
+func toDomainAPIKey(row dbgen.ApiKey) *APIKey {
+	return &APIKey{
+		ID:               row.ID,
+		Prefix:           row.Prefix,
+		Hash:             row.KeyHash,
+		Name:             row.Name,
+		UserID:           row.UserID.Int64,
+		ServiceAccountID: row.ServiceAccountID.Int64,
+		Scopes:           row.Scopes,
+		ExpiresAt:        pgsql.NullTimeToPtr(row.ExpiresAt),
+		LastUsedAt:       pgsql.NullTimeToPtr(row.LastUsedAt),
+		RevokedAt:        pgsql.NullTimeToPtr(row.RevokedAt),
+		CreatedAt:        row.CreatedAt,
+	}
+}
+
+// toDomainAPIKeys converts dbgen.ApiKey rows to API keys.
+func toDomainAPIKeys(rows []dbgen.ApiKey) []APIKey {
+	keys := make([]APIKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, *toDomainAPIKey(row))
+	}
+	return keys
+}
+
+// toDomainServiceAccount converts a dbgen.ServiceAccount to a
+// ServiceAccount.
+func toDomainServiceAccount(row dbgen.ServiceAccount) *ServiceAccount {
+	return &ServiceAccount{
+		ID:          row.ID,
+		Name:        row.Name,
+		Description: row.Description,
+		CreatedAt:   row.CreatedAt,
+	}
+}
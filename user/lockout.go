@@ -0,0 +1,105 @@
+package user
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrLoginThrottled = errors.New("too many failed logins, wait before trying again")
+	ErrAccountLocked  = errors.New("account locked by too many failed logins")
+)
+
+// LoginBlockedError is returned by Login while the logins of an email are
+// blocked, it wraps ErrLoginThrottled or ErrAccountLocked.
+type LoginBlockedError struct {
+	Err   error
+	Until time.Time
+}
+
+func (e *LoginBlockedError) Error() string { return e.Err.Error() }
+
+func (e *LoginBlockedError) Unwrap() error { return e.Err }
+
+// LoginAttempts are the failed logins of an email since its last successful
+// login. They are tracked by email, known or not, so guessing passwords of
+// any account is slowed down the same way.
+type LoginAttempts struct {
+	Email         string
+	Failures      int
+	LastFailureAt *time.Time
+	BlockedUntil  *time.Time
+}
+
+// LockoutPolicy decides how long the logins of an email are blocked after
+// a failure. Each failure doubles the back-off delay, MaxFailures in a row
+// lock the account for Lockout.
+type LockoutPolicy struct {
+	MaxFailures int
+	Delay       time.Duration // back-off after the first failure
+	MaxDelay    time.Duration
+	Lockout     time.Duration
+
+	// Window after which the failures are forgotten.
+	Window time.Duration
+}
+
+// DefaultLockoutPolicy waits 1s, 2s, 4s and 8s after the first failures
+// and locks the account for 15 minutes on the fifth one.
+var DefaultLockoutPolicy = LockoutPolicy{
+	MaxFailures: 5,
+	Delay:       time.Second,
+	MaxDelay:    30 * time.Second,
+	Lockout:     15 * time.Minute,
+	Window:      time.Hour,
+}
+
+// check returns a *LoginBlockedError if a login with the attempts a isn't
+// allowed at now.
+func (p LockoutPolicy) check(a LoginAttempts, now time.Time) error {
+	if a.BlockedUntil == nil || !now.Before(*a.BlockedUntil) {
+		return nil
+	}
+	err := ErrLoginThrottled
+	if a.Failures >= p.MaxFailures {
+		err = ErrAccountLocked
+	}
+	return &LoginBlockedError{Err: err, Until: *a.BlockedUntil}
+}
+
+// fail records a failed login at now in a, it reports whether the failure
+// locked the account.
+func (p LockoutPolicy) fail(a *LoginAttempts, now time.Time) bool {
+	if p.forget(*a, now) {
+		a.Failures = 0
+	}
+	a.Failures++
+	a.LastFailureAt = &now
+	if a.Failures >= p.MaxFailures {
+		until := now.Add(p.Lockout)
+		a.BlockedUntil = &until
+		return true
+	}
+	delay := p.Delay << (a.Failures - 1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	until := now.Add(delay)
+	a.BlockedUntil = &until
+	return false
+}
+
+// forget reports whether the failures of a no longer count at now, because
+// they are old or its lockout is over.
+func (p LockoutPolicy) forget(a LoginAttempts, now time.Time) bool {
+	if a.LastFailureAt != nil && now.Sub(*a.LastFailureAt) >= p.Window {
+		return true
+	}
+	return a.Failures >= p.MaxFailures && a.BlockedUntil != nil && !now.Before(*a.BlockedUntil)
+}
+
+// loginKey is the email the attempts are tracked by.
+func loginKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
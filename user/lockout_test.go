@@ -0,0 +1,60 @@
+package user
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLockoutPolicy(t *testing.T) {
+	p := DefaultLockoutPolicy
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	a := LoginAttempts{Email: "example@gmail.com"}
+
+	// Each failure doubles the back-off.
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		if locked := p.fail(&a, now); locked {
+			t.Fatalf("failure %d: unexpected lockout", i+1)
+		}
+		if got := a.BlockedUntil.Sub(now); got != want {
+			t.Errorf("failure %d: want back-off %s, got %s", i+1, want, got)
+		}
+		if err := p.check(a, now); !errors.Is(err, ErrLoginThrottled) {
+			t.Errorf("failure %d: want error %v, got %v", i+1, ErrLoginThrottled, err)
+		}
+		now = now.Add(want)
+		if err := p.check(a, now); err != nil {
+			t.Errorf("failure %d: unexpected error after the back-off: %v", i+1, err)
+		}
+	}
+
+	// The fifth one locks the account.
+	if locked := p.fail(&a, now); !locked {
+		t.Fatal("expected lockout")
+	}
+	err := p.check(a, now.Add(p.Lockout-time.Second))
+	var blocked *LoginBlockedError
+	if !errors.As(err, &blocked) || !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("want error %v, got %v", ErrAccountLocked, err)
+	}
+	if !blocked.Until.Equal(now.Add(p.Lockout)) {
+		t.Errorf("want locked until %s, got %s", now.Add(p.Lockout), blocked.Until)
+	}
+
+	// After the lockout the failures start over.
+	now = now.Add(p.Lockout)
+	if err := p.check(a, now); err != nil {
+		t.Fatalf("unexpected error after the lockout: %v", err)
+	}
+	p.fail(&a, now)
+	if a.Failures != 1 {
+		t.Errorf("want 1 failure after the lockout, got %d", a.Failures)
+	}
+
+	// Old failures are forgotten.
+	a.Failures = 3
+	p.fail(&a, now.Add(p.Window))
+	if a.Failures != 1 {
+		t.Errorf("want 1 failure after the window, got %d", a.Failures)
+	}
+}
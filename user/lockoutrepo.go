@@ -0,0 +1,99 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adrianolmedo/genesis/pgsql"
+	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LoginAttemptRepo manages the storage of failed logins.
+type LoginAttemptRepo struct {
+	db *pgxpool.Pool
+	q  *dbgen.Queries // for non-tx operations
+}
+
+// NewLoginAttemptRepo creates a new LoginAttempt repository instance.
+func NewLoginAttemptRepo(db *pgxpool.Pool) *LoginAttemptRepo {
+	return &LoginAttemptRepo{
+		db: db,
+		q:  dbgen.New(db),
+	}
+}
+
+// ByEmail get the failed logins of an email, none if it has no failures.
+func (r *LoginAttemptRepo) ByEmail(ctx context.Context, email string) (LoginAttempts, error) {
+	row, err := r.q.LoginAttemptByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return LoginAttempts{Email: email}, nil
+	}
+	if err != nil {
+		return LoginAttempts{}, err
+	}
+	return toDomainLoginAttempts(row), nil
+}
+
+// Fail records a failed login of an email at now following p, inside a
+// transaction that locks its attempts so concurrent failures are all
+// counted. It reports whether the failure locked the account.
+func (r *LoginAttemptRepo) Fail(ctx context.Context, email string, p LockoutPolicy, now time.Time) (LoginAttempts, bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return LoginAttempts{}, false, err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+	q := r.q.WithTx(tx)
+	if err := q.LoginAttemptInit(ctx, email); err != nil {
+		return LoginAttempts{}, false, err
+	}
+	row, err := q.LoginAttemptByEmailForUpdate(ctx, email)
+	if err != nil {
+		return LoginAttempts{}, false, err
+	}
+	a := toDomainLoginAttempts(row)
+	locked := p.fail(&a, now)
+	err = q.LoginAttemptUpdate(ctx, dbgen.LoginAttemptUpdateParams{
+		Email:         email,
+		Failures:      int32(a.Failures),
+		LastFailureAt: pgsql.TimePtrToNull(a.LastFailureAt),
+		BlockedUntil:  pgsql.TimePtrToNull(a.BlockedUntil),
+	})
+	if err != nil {
+		return LoginAttempts{}, false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return LoginAttempts{}, false, err
+	}
+	return a, locked, nil
+}
+
+// Reset forgets the failed logins of an email.
+func (r *LoginAttemptRepo) Reset(ctx context.Context, email string) error {
+	return r.q.LoginAttemptDelete(ctx, email)
+}
+
+// DeleteAll deletes all failed logins (permanently).
+func (r *LoginAttemptRepo) DeleteAll(ctx context.Context) error {
+	err := r.q.LoginAttemptDeleteAll(ctx)
+	if err != nil {
+		return fmt.Errorf("can't truncate table: %v", err)
+	}
+	return nil
+}
+
+// toDomainLoginAttempts converts a dbgen.LoginAttempt to LoginAttempts.
+func toDomainLoginAttempts(row dbgen.LoginAttempt) LoginAttempts {
+	return LoginAttempts{
+		Email:         row.Email,
+		Failures:      int(row.Failures),
+		LastFailureAt: pgsql.NullTimeToPtr(row.LastFailureAt),
+		BlockedUntil:  pgsql.NullTimeToPtr(row.BlockedUntil),
+	}
+}
@@ -0,0 +1,149 @@
+package user
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/adrianolmedo/genesis/totp"
+)
+
+var (
+	ErrMFANotEnrolled     = errors.New("mfa not enrolled")
+	ErrMFAAlreadyEnabled  = errors.New("mfa already enabled")
+	ErrMFAInvalidCode     = errors.New("invalid mfa code")
+	ErrChallengeNotFound  = errors.New("mfa challenge not found")
+	ErrChallengeExpired   = errors.New("mfa challenge expired, login again")
+	ErrMFACodeCantBeEmpty = errors.New("mfa code can't be empty")
+)
+
+const (
+	// MFAIssuer is the account issuer shown by the authenticator apps.
+	MFAIssuer = "Genesis"
+
+	// MFAChallengeTTL is the time to enter the code after the password.
+	MFAChallengeTTL = 5 * time.Minute
+
+	// maxChallengeFailures wrong codes invalidate the challenge.
+	maxChallengeFailures = 5
+
+	// recoveryCodes generated when MFA is enabled.
+	recoveryCodes = 10
+)
+
+// MFA is the TOTP second factor of a User. It's enabled once a first code
+// is verified.
+type MFA struct {
+	UserID    int64
+	Secret    string
+	EnabledAt *time.Time
+
+	// LastCounter is the time step of the last code used, a code can't be
+	// used twice.
+	LastCounter int64
+	CreatedAt   time.Time
+}
+
+// Enabled reports whether the codes are required to login.
+func (m MFA) Enabled() bool {
+	return m.EnabledAt != nil
+}
+
+// verifyCode checks a TOTP code at now and returns its time step.
+func (m MFA) verifyCode(code string, now time.Time) (int64, error) {
+	counter, err := totp.Validate(m.Secret, code, now)
+	if errors.Is(err, totp.ErrInvalidCode) {
+		return 0, ErrMFAInvalidCode
+	}
+	if err != nil {
+		return 0, err
+	}
+	if counter <= m.LastCounter {
+		return 0, ErrMFAInvalidCode
+	}
+	return counter, nil
+}
+
+// MFAEnrolment is what a User adds to its authenticator app, either by the
+// URI (as QR code) or by typing the secret.
+type MFAEnrolment struct {
+	Secret string
+	URI    string
+}
+
+// MFAChallenge is the pending second step of a login, identified by an
+// opaque token. Only the hash of the token is stored.
+type MFAChallenge struct {
+	UserID    int64
+	Hash      string
+	Failures  int
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// Check returns an error if the challenge can't be answered at now.
+func (c MFAChallenge) Check(now time.Time) error {
+	if !now.Before(c.ExpiresAt) || c.Failures >= maxChallengeFailures {
+		return ErrChallengeExpired
+	}
+	return nil
+}
+
+// newMFAChallenge creates an MFAChallenge for userID. It returns the plain
+// token, to be handed to the client, and the model with its hash.
+func newMFAChallenge(userID int64, now time.Time) (string, *MFAChallenge, error) {
+	plain, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+	return plain, &MFAChallenge{
+		UserID:    userID,
+		Hash:      hashToken(plain),
+		ExpiresAt: now.Add(MFAChallengeTTL),
+		CreatedAt: now,
+	}, nil
+}
+
+// isTOTPCode reports whether code looks like a TOTP code rather than a
+// recovery code.
+func isTOTPCode(code string) bool {
+	code = strings.TrimSpace(code)
+	if len(code) != totp.Digits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// recoveryEncoding of the recovery codes is Crockford's base32, without
+// letters easy to confuse (i, l, o, u) when read from paper.
+var recoveryEncoding = base32.NewEncoding("0123456789abcdefghjkmnpqrstvwxyz").WithPadding(base32.NoPadding)
+
+// newRecoveryCodes returns the plain recovery codes, to be shown once, and
+// their hashes to be stored.
+func newRecoveryCodes() (plain, hashes []string, err error) {
+	for range recoveryCodes {
+		b := make([]byte, 10) // 80 bits
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		s := recoveryEncoding.EncodeToString(b)
+		code := s[:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:]
+		plain = append(plain, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return plain, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code ignoring case, spaces and dashes.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return hashToken(code)
+}
@@ -0,0 +1,92 @@
+package user
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adrianolmedo/genesis/totp"
+)
+
+func TestMFAVerifyCode(t *testing.T) {
+	secret, err := totp.NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	code, err := totp.Code(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := MFA{Secret: secret}
+	counter, err := m.verifyCode(code, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A used code can't be used again.
+	m.LastCounter = counter
+	if _, err := m.verifyCode(code, now); !errors.Is(err, ErrMFAInvalidCode) {
+		t.Errorf("want error %v for a used code, got %v", ErrMFAInvalidCode, err)
+	}
+
+	// A code of the past is no longer valid.
+	m.LastCounter = 0
+	if _, err := m.verifyCode(code, now.Add(2*totp.Period)); !errors.Is(err, ErrMFAInvalidCode) {
+		t.Errorf("want error %v for an old code, got %v", ErrMFAInvalidCode, err)
+	}
+}
+
+func TestMFAChallengeCheck(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	_, c, err := newMFAChallenge(1, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt := []struct {
+		name     string
+		at       time.Time
+		failures int
+		wantErr  error
+	}{
+		{name: "valid", at: now, failures: 0, wantErr: nil},
+		{name: "expired", at: now.Add(MFAChallengeTTL), failures: 0, wantErr: ErrChallengeExpired},
+		{name: "too-many-failures", at: now, failures: maxChallengeFailures, wantErr: ErrChallengeExpired},
+	}
+	for _, tc := range tt {
+		c.Failures = tc.failures
+		err := c.Check(tc.at)
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: want error %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodes || len(hashes) != recoveryCodes {
+		t.Fatalf("want %d codes, got %d codes and %d hashes", recoveryCodes, len(codes), len(hashes))
+	}
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		if isTOTPCode(code) {
+			t.Errorf("recovery code %q taken as a TOTP code", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicated recovery code %q", code)
+		}
+		seen[code] = true
+		// Typed without dashes and in upper case it's the same code.
+		typed := strings.ToUpper(strings.ReplaceAll(code, "-", ""))
+		if hashRecoveryCode(typed) != hashes[i] {
+			t.Errorf("recovery code %q typed as %q doesn't match its hash", code, typed)
+		}
+	}
+	if !isTOTPCode(" 123456 ") {
+		t.Error("expected a TOTP code")
+	}
+}
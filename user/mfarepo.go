@@ -0,0 +1,248 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adrianolmedo/genesis/pgsql"
+	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MFARepo manages the storage of TOTP secrets, recovery codes and login
+// challenges.
+type MFARepo struct {
+	db *pgxpool.Pool
+	q  *dbgen.Queries // for non-tx operations
+}
+
+// NewMFARepo creates a new MFA repository instance.
+func NewMFARepo(db *pgxpool.Pool) *MFARepo {
+	return &MFARepo{
+		db: db,
+		q:  dbgen.New(db),
+	}
+}
+
+// Enrol stores a new secret for a User, replacing a pending one.
+func (r *MFARepo) Enrol(ctx context.Context, m *MFA) error {
+	return r.q.MFAEnrol(ctx, dbgen.MFAEnrolParams{
+		UserID:    m.UserID,
+		Secret:    m.Secret,
+		CreatedAt: m.CreatedAt,
+	})
+}
+
+// ByUser get the MFA of a User.
+func (r *MFARepo) ByUser(ctx context.Context, userID int64) (*MFA, error) {
+	row, err := r.q.MFAByUser(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrMFANotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toDomainMFA(row), nil
+}
+
+// Enable enables the MFA of a User, marking counter as used, and replaces
+// its recovery codes by hashes.
+func (r *MFARepo) Enable(ctx context.Context, userID, counter int64, hashes []string, now time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+	q := r.q.WithTx(tx)
+	err = q.MFAEnable(ctx, dbgen.MFAEnableParams{
+		UserID:      userID,
+		EnabledAt:   sql.NullTime{Time: now, Valid: true},
+		LastCounter: counter,
+	})
+	if err != nil {
+		return err
+	}
+	if err := q.RecoveryCodeDeleteByUser(ctx, userID); err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		err := q.RecoveryCodeCreate(ctx, dbgen.RecoveryCodeCreateParams{
+			UserID:    userID,
+			CodeHash:  h,
+			CreatedAt: now,
+		})
+		if err != nil {
+			return fmt.Errorf("recovery code: %w", err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// Disable removes the MFA and recovery codes of a User.
+func (r *MFARepo) Disable(ctx context.Context, userID int64) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+	q := r.q.WithTx(tx)
+	if err := q.RecoveryCodeDeleteByUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := q.MFADelete(ctx, userID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// UseCode verifies a TOTP code of a User at now and marks it as used,
+// inside a transaction that locks its MFA so the same code can't be used
+// twice concurrently.
+func (r *MFARepo) UseCode(ctx context.Context, userID int64, code string, now time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+	if err := useCode(ctx, r.q.WithTx(tx), userID, code, now); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// CreateChallenge stores a new login challenge. Expired challenges are
+// purged.
+func (r *MFARepo) CreateChallenge(ctx context.Context, c *MFAChallenge) error {
+	if err := r.q.MFAChallengeDeleteExpired(ctx, c.CreatedAt); err != nil {
+		return fmt.Errorf("purge mfa challenges: %v", err)
+	}
+	return r.q.MFAChallengeCreate(ctx, dbgen.MFAChallengeCreateParams{
+		TokenHash: c.Hash,
+		UserID:    c.UserID,
+		ExpiresAt: c.ExpiresAt,
+		CreatedAt: c.CreatedAt,
+	})
+}
+
+// AnswerChallenge answers the login challenge identified by hash with a
+// TOTP or recovery code at now, inside a transaction that locks the
+// challenge. On success the challenge is deleted and the User ID is
+// returned with whether a recovery code was used. A wrong code counts as a
+// failure of the challenge and returns ErrMFAInvalidCode.
+func (r *MFARepo) AnswerChallenge(ctx context.Context, hash, code string, now time.Time) (int64, bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+	q := r.q.WithTx(tx)
+	row, err := q.MFAChallengeByHashForUpdate(ctx, hash)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, ErrChallengeNotFound
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	c := toDomainMFAChallenge(row)
+	if err := c.Check(now); err != nil {
+		if err := q.MFAChallengeDelete(ctx, hash); err != nil {
+			return 0, false, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return 0, false, err
+		}
+		return 0, false, err
+	}
+	recovery := !isTOTPCode(code)
+	if recovery {
+		_, err = q.RecoveryCodeUse(ctx, dbgen.RecoveryCodeUseParams{
+			UserID:   c.UserID,
+			CodeHash: hashRecoveryCode(code),
+			UsedAt:   sql.NullTime{Time: now, Valid: true},
+		})
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			err = ErrMFAInvalidCode
+		}
+	} else {
+		err = useCode(ctx, q, c.UserID, code, now)
+	}
+	if errors.Is(err, ErrMFAInvalidCode) {
+		if err := q.MFAChallengeFail(ctx, hash); err != nil {
+			return 0, false, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return 0, false, err
+		}
+		return 0, false, ErrMFAInvalidCode
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if err := q.MFAChallengeDelete(ctx, hash); err != nil {
+		return 0, false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, false, err
+	}
+	return c.UserID, recovery, nil
+}
+
+// DeleteAll deletes all MFA data (permanently).
+func (r *MFARepo) DeleteAll(ctx context.Context) error {
+	err := r.q.MFADeleteAll(ctx)
+	if err != nil {
+		return fmt.Errorf("can't truncate table: %v", err)
+	}
+	return nil
+}
+
+// useCode verifies and marks as used a TOTP code of an enabled MFA, q must
+// run inside a transaction.
+func useCode(ctx context.Context, q *dbgen.Queries, userID int64, code string, now time.Time) error {
+	row, err := q.MFAByUserForUpdate(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return ErrMFANotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	m := toDomainMFA(row)
+	if !m.Enabled() {
+		return ErrMFANotEnrolled
+	}
+	counter, err := m.verifyCode(code, now)
+	if err != nil {
+		return err
+	}
+	return q.MFAUseCounter(ctx, dbgen.MFAUseCounterParams{
+		UserID:      userID,
+		LastCounter: counter,
+	})
+}
+
+// toDomainMFA converts a dbgen.UserMfa to an MFA.
+func toDomainMFA(row dbgen.UserMfa) *MFA {
+	return &MFA{
+		UserID:      row.UserID,
+		Secret:      row.Secret,
+		EnabledAt:   pgsql.NullTimeToPtr(row.EnabledAt),
+		LastCounter: row.LastCounter,
+		CreatedAt:   row.CreatedAt,
+	}
+}
+
+// toDomainMFAChallenge converts a dbgen.MfaChallenge to an MFAChallenge.
+func toDomainMFAChallenge(row dbgen.MfaChallenge) MFAChallenge {
+	return MFAChallenge{
+		UserID:    row.UserID,
+		Hash:      row.TokenHash,
+		Failures:  int(row.Failures),
+		ExpiresAt: row.ExpiresAt,
+		CreatedAt: row.CreatedAt,
+	}
+}
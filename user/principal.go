@@ -0,0 +1,51 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// ErrForbidden the principal isn't allowed to act on the resource.
+var ErrForbidden = errors.New("forbidden: you can only modify your own account")
+
+// Principal is the authenticated identity acting on a request, a User
+// signed in with an access token or the owner of an API key.
+type Principal struct {
+	UserID           int64 // 0 for a service account
+	ServiceAccountID int64 // 0 for a user
+	APIKeyID         int64 // 0 unless authenticated with an API key
+	Email            string
+	Permissions      []string
+}
+
+// Can reports whether the principal holds permission.
+func (p Principal) Can(permission string) bool {
+	return slices.Contains(p.Permissions, permission)
+}
+
+// authorize returns ErrForbidden unless the principal is the owner of the
+// User account userID, signed in and not with an API key of it, or holds
+// the permission to manage any user.
+func authorize(actor Principal, userID int64) error {
+	if actor.UserID != 0 && actor.UserID == userID && actor.APIKeyID == 0 {
+		return nil
+	}
+	if actor.Can(PermUsersWrite) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+type principalKey struct{}
+
+// NewContext returns a copy of ctx carrying the principal.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal carried by ctx, if any.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
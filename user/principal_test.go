@@ -0,0 +1,52 @@
+package user
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	tt := []struct {
+		name    string
+		actor   Principal
+		userID  int64
+		wantErr error
+	}{
+		{
+			name:    "owner",
+			actor:   Principal{UserID: 1},
+			userID:  1,
+			wantErr: nil,
+		},
+		{
+			name:    "other-user",
+			actor:   Principal{UserID: 1},
+			userID:  2,
+			wantErr: ErrForbidden,
+		},
+		{
+			name:    "api-key-of-the-owner",
+			actor:   Principal{UserID: 1, APIKeyID: 3},
+			userID:  1,
+			wantErr: ErrForbidden,
+		},
+		{
+			name:    "admin",
+			actor:   Principal{UserID: 1, Permissions: []string{PermUsersWrite}},
+			userID:  2,
+			wantErr: nil,
+		},
+		{
+			name:    "anonymous",
+			actor:   Principal{},
+			userID:  0,
+			wantErr: ErrForbidden,
+		},
+	}
+	for _, tc := range tt {
+		err := authorize(tc.actor, tc.userID)
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: want error %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
@@ -48,19 +48,28 @@ func (r *Repo) Create(ctx context.Context, m *User) error {
 	return nil
 }
 
-// ByLogin get a User from its login data.
-func (r *Repo) ByLogin(ctx context.Context, email, pass string) error {
-	id, err := r.q.UserByLogin(ctx, dbgen.UserByLoginParams{
-		Email:    email,
-		Password: pass,
-	})
-	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) || id == 0 {
-		return ErrNotFound
+// ByEmail get a User from its email.
+func (r *Repo) ByEmail(ctx context.Context, email string) (*User, error) {
+	m, err := r.q.UserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
 	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	u := &User{
+		ID:        m.ID,
+		UUID:      m.Uuid.String(),
+		FirstName: m.FirstName,
+		LastName:  m.LastName,
+		Email:     m.Email,
+		Password:  m.Password,
+	}
+	u.CreatedAt = m.CreatedAt
+	u.UpdatedAt = pgsql.NullTimeToPtr(m.UpdatedAt)
+	u.DeletedAt = pgsql.NullTimeToPtr(m.DeletedAt)
+	u.VerifiedAt = pgsql.NullTimeToPtr(m.VerifiedAt)
+	return u, nil
 }
 
 // ByID get a User from its id.
@@ -83,6 +92,7 @@ func (r *Repo) ByID(ctx context.Context, id int64) (*User, error) {
 	u.CreatedAt = m.CreatedAt
 	u.UpdatedAt = pgsql.NullTimeToPtr(m.UpdatedAt)
 	u.DeletedAt = pgsql.NullTimeToPtr(m.DeletedAt)
+	u.VerifiedAt = pgsql.NullTimeToPtr(m.VerifiedAt)
 	return u, nil
 }
 
@@ -106,6 +116,22 @@ func (r *Repo) Update(ctx context.Context, m User) error {
 	return nil
 }
 
+// UpdatePassword replaces the password hash of a user.
+func (r *Repo) UpdatePassword(ctx context.Context, id int64, hash string) error {
+	_, err := r.q.UserUpdatePassword(ctx, dbgen.UserUpdatePasswordParams{
+		ID:        id,
+		Password:  hash,
+		UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 // List returns a paginated list of users.
 // It returns a pgsql.FilterResult containing the paginated users and total rows.
 // If an error occurs during the query, it returns an empty FilterResult and the error.
@@ -140,6 +166,7 @@ func toDomainUsers(rows []dbgen.User) Users {
 		m.CreatedAt = row.CreatedAt
 		m.UpdatedAt = pgsql.NullTimeToPtr(row.UpdatedAt)
 		m.DeletedAt = pgsql.NullTimeToPtr(row.DeletedAt)
+		m.VerifiedAt = pgsql.NullTimeToPtr(row.VerifiedAt)
 		users = append(users, m)
 	}
 	return users
@@ -0,0 +1,64 @@
+package user
+
+import (
+	"errors"
+	"slices"
+)
+
+var (
+	ErrRoleNotFound    = errors.New("role not found")
+	ErrRoleNotAssigned = errors.New("role not assigned to the user")
+	ErrRoleCantBeEmpty = errors.New("role name can't be empty")
+)
+
+// Permissions seeded by the migrations, checked per route.
+const (
+	PermUsersRead     = "users:read"
+	PermUsersWrite    = "users:write" // manage any user account
+	PermProductsWrite = "products:write"
+	PermInvoicesRead  = "invoices:read"
+	PermInvoicesWrite = "invoices:write"
+	PermRolesWrite    = "roles:write"
+	PermTaxesWrite    = "taxes:write" // tax categories, rates and exemptions
+
+	PermServiceAccountsWrite = "service_accounts:write"
+
+	PermSubscriptionsWrite = "subscriptions:write" // plans and subscriptions
+	PermDunningWrite       = "dunning:write"       // dunning policy and payment terms
+
+	PermExchangeRatesWrite = "exchange_rates:write"
+
+	PermCustomersRead  = "customers:read"
+	PermCustomersWrite = "customers:write"
+)
+
+// RoleAdmin is the role seeded with every permission.
+const RoleAdmin = "admin"
+
+// Role groups permissions assignable to users.
+type Role struct {
+	ID          int64
+	Name        string
+	Description string
+	Permissions []string
+}
+
+// Roles collection of Role.
+type Roles []Role
+
+// IsEmpty return true if is empty.
+func (rs Roles) IsEmpty() bool {
+	return len(rs) == 0
+}
+
+// Authorization holds the roles of a User and the permissions granted by
+// them.
+type Authorization struct {
+	Roles       []string
+	Permissions []string
+}
+
+// Can reports whether the authorization grants permission.
+func (a Authorization) Can(permission string) bool {
+	return slices.Contains(a.Permissions, permission)
+}
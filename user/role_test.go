@@ -0,0 +1,24 @@
+package user
+
+import "testing"
+
+func TestAuthorizationCan(t *testing.T) {
+	authz := Authorization{
+		Roles:       []string{RoleAdmin},
+		Permissions: []string{PermProductsWrite, PermUsersRead},
+	}
+	tt := []struct {
+		name       string
+		permission string
+		want       bool
+	}{
+		{name: "granted", permission: PermProductsWrite, want: true},
+		{name: "not-granted", permission: PermRolesWrite, want: false},
+		{name: "empty", permission: "", want: false},
+	}
+	for _, tc := range tt {
+		if got := authz.Can(tc.permission); got != tc.want {
+			t.Errorf("%s: Can(%q): want %t, got %t", tc.name, tc.permission, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RoleRepo manages the storage of roles, permissions and their assignment
+// to users.
+type RoleRepo struct {
+	q *dbgen.Queries // methods generated by sqlc
+}
+
+// NewRoleRepo creates a new Role repository instance.
+func NewRoleRepo(db dbgen.DBTX) *RoleRepo {
+	return &RoleRepo{
+		q: dbgen.New(db),
+	}
+}
+
+// All returns all roles with their permissions.
+func (r *RoleRepo) All(ctx context.Context) (Roles, error) {
+	rows, err := r.q.RoleAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	perms, err := r.q.RolePermissionAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byRole := make(map[string][]string, len(rows))
+	for _, p := range perms {
+		byRole[p.RoleName] = append(byRole[p.RoleName], p.PermissionName)
+	}
+	roles := make(Roles, 0, len(rows))
+	for _, row := range rows {
+		roles = append(roles, Role{
+			ID:          row.ID,
+			Name:        row.Name,
+			Description: row.Description,
+			Permissions: byRole[row.Name],
+		})
+	}
+	return roles, nil
+}
+
+// ByName get a Role from its name, without its permissions.
+func (r *RoleRepo) ByName(ctx context.Context, name string) (*Role, error) {
+	row, err := r.q.RoleByName(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrRoleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Role{
+		ID:          row.ID,
+		Name:        row.Name,
+		Description: row.Description,
+	}, nil
+}
+
+// Assign a role to a user, assigning it twice has no effect.
+func (r *RoleRepo) Assign(ctx context.Context, userID, roleID int64) error {
+	return r.q.UserRoleAssign(ctx, dbgen.UserRoleAssignParams{
+		UserID: userID,
+		RoleID: roleID,
+	})
+}
+
+// Revoke a role from a user.
+func (r *RoleRepo) Revoke(ctx context.Context, userID, roleID int64) error {
+	_, err := r.q.UserRoleRevoke(ctx, dbgen.UserRoleRevokeParams{
+		UserID: userID,
+		RoleID: roleID,
+	})
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return ErrRoleNotAssigned
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Authorization returns the roles of a user and the permissions granted by
+// them.
+func (r *RoleRepo) Authorization(ctx context.Context, userID int64) (Authorization, error) {
+	roles, err := r.q.UserRoleNames(ctx, userID)
+	if err != nil {
+		return Authorization{}, err
+	}
+	perms, err := r.q.UserPermissionNames(ctx, userID)
+	if err != nil {
+		return Authorization{}, err
+	}
+	return Authorization{
+		Roles:       roles,
+		Permissions: perms,
+	}, nil
+}
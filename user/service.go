@@ -4,40 +4,519 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"regexp"
 	"time"
 
+	"github.com/adrianolmedo/genesis"
+	"github.com/adrianolmedo/genesis/logger"
+	"github.com/adrianolmedo/genesis/mail"
+	"github.com/adrianolmedo/genesis/password"
 	"github.com/adrianolmedo/genesis/pgsql"
+	"github.com/adrianolmedo/genesis/totp"
 )
 
 // Service provides User application operations.
 type Service struct {
-	repo *Repo
+	repo     *Repo
+	sessions *SessionRepo
+	roles    *RoleRepo
+	attempts *LoginAttemptRepo
+	mfa      *MFARepo
+	tokens   *TokenRepo
+	apiKeys  *APIKeyRepo
+	lockout  LockoutPolicy
+	hasher   *password.Hasher
+	mailer   mail.Mailer
+	dummy    string // hash verified on the login of unknown emails
+	signer   tokenSigner
+	opts     Options
+	now      func() time.Time
+}
+
+// Options of the account flows of the Service.
+type Options struct {
+	// RequireVerifiedEmail blocks the login of the users who haven't
+	// verified their email.
+	RequireVerifiedEmail bool
+
+	// BaseURL of the API, for the links sent by email.
+	BaseURL string
+
+	// TokenKey signs the tokens sent by email.
+	TokenKey []byte
+
+	// Hasher of the passwords, password.Default() if nil.
+	Hasher *password.Hasher
+
+	// Mailer sends the emails of the account flows, they are discarded
+	// if nil.
+	Mailer mail.Mailer
+
+	// Lockout throttles the failed logins, DefaultLockoutPolicy if zero.
+	Lockout LockoutPolicy
+}
+
+// Repos the Service stores its data in.
+type Repos struct {
+	Users    *Repo
+	Sessions *SessionRepo
+	Roles    *RoleRepo
+	Attempts *LoginAttemptRepo
+	MFA      *MFARepo
+	Tokens   *TokenRepo
+	APIKeys  *APIKeyRepo
 }
 
 // NewService creates a new User service instance.
-func NewService(repo *Repo) *Service {
+func NewService(r Repos, opts Options) *Service {
+	if opts.Hasher == nil {
+		opts.Hasher = password.Default()
+	}
+	if opts.Mailer == nil {
+		opts.Mailer = mail.NewWriter(io.Discard, "")
+	}
+	if opts.Lockout == (LockoutPolicy{}) {
+		opts.Lockout = DefaultLockoutPolicy
+	}
+	// With the preferred algorithm, so an unknown email takes as long as
+	// a wrong password.
+	dummy, err := opts.Hasher.Hash("genesis-dummy-password")
+	if err != nil {
+		logger.Warn("dummy password hash", "err", err.Error())
+	}
 	return &Service{
-		repo: repo,
+		repo:     r.Users,
+		sessions: r.Sessions,
+		roles:    r.Roles,
+		attempts: r.Attempts,
+		mfa:      r.MFA,
+		tokens:   r.Tokens,
+		apiKeys:  r.APIKeys,
+		lockout:  opts.Lockout,
+		hasher:   opts.Hasher,
+		mailer:   opts.Mailer,
+		dummy:    dummy,
+		signer:   tokenSigner{key: opts.TokenKey},
+		opts:     opts,
+		now:      time.Now,
 	}
 }
 
-func (s Service) Login(ctx context.Context, email, password string) error {
+// SetClock replaces the clock of the service, time.Now by default, the
+// time of sessions, lockouts and MFA codes is taken from it.
+func (s *Service) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// Login checks the credentials of a User. The stored hash is fetched by email
+// and verified here, if it was produced with an old algorithm or weaker
+// parameters it's replaced by a new one. Failed logins of the email are
+// throttled and lock the account following the LockoutPolicy, while
+// blocked it returns a *LoginBlockedError. If Options.RequireVerifiedEmail
+// is set, users with an unverified email get ErrEmailNotVerified.
+func (s Service) Login(ctx context.Context, email, pass string) (*User, error) {
 	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 	if err := validateEmail(email); err != nil {
+		return nil, err
+	}
+	key := loginKey(email)
+	now := s.now()
+	attempts, err := s.attempts.ByEmail(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.lockout.check(attempts, now); err != nil {
+		return nil, err
+	}
+	u, err := s.repo.ByEmail(ctx, email)
+	if errors.Is(err, ErrNotFound) {
+		// Verified anyway, the time of the answer doesn't tell whether
+		// the email has an account.
+		_, _ = s.hasher.Verify(s.dummy, pass)
+		return nil, s.loginFailed(ctx, key, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rehash, err := s.hasher.Verify(u.Password, pass)
+	if errors.Is(err, password.ErrMismatch) {
+		return nil, s.loginFailed(ctx, key, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if attempts.Failures > 0 {
+		if err := s.attempts.Reset(ctx, key); err != nil {
+			logger.Warn("login attempts reset", "email", key, "err", err.Error())
+		}
+	}
+	if s.opts.RequireVerifiedEmail && u.VerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
+	if rehash {
+		// The login has already succeeded, a failed upgrade is retried
+		// on the next one.
+		if err := s.rehash(ctx, u.ID, pass); err != nil {
+			logger.Warn("password rehash", "user", u.ID, "err", err.Error())
+		}
+	}
+	return u, nil
+}
+
+// loginFailed records a failed login of email and returns the error of the
+// login: ErrNotFound, or a *LoginBlockedError if the failure locked the
+// account.
+func (s Service) loginFailed(ctx context.Context, email string, now time.Time) error {
+	attempts, locked, err := s.attempts.Fail(ctx, email, s.lockout, now)
+	if err != nil {
+		logger.Error("login attempts", "email", email, "err", err.Error())
+		return ErrNotFound
+	}
+	if !locked {
+		return ErrNotFound
+	}
+	logger.Warn("security", "event", "account_locked", "email", email,
+		"failures", attempts.Failures, "until", *attempts.BlockedUntil)
+	return &LoginBlockedError{Err: ErrAccountLocked, Until: *attempts.BlockedUntil}
+}
+
+// Unlock forgets the failed logins of a User, unlocking its account. actor
+// is the one unlocking it.
+func (s Service) Unlock(ctx context.Context, actor Principal, userID int64) error {
+	u, err := s.Find(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.attempts.Reset(ctx, loginKey(u.Email)); err != nil {
+		return err
+	}
+	logger.Info("security", "event", "account_unlocked", "user", u.ID, "by", actor.UserID)
+	return nil
+}
+
+// StartSession opens a new session (refresh token family) for a User
+// and returns its first refresh token.
+func (s Service) StartSession(ctx context.Context, userID int64) (string, error) {
+	plain, t, err := newRefreshToken(userID, genesis.NextUUID(), s.now())
+	if err != nil {
+		return "", err
+	}
+	if err := s.sessions.Create(ctx, t); err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// Refresh exchanges a refresh token for a new one of the same session and
+// returns the User it belongs to. A refresh token can be exchanged only once,
+// presenting it again revokes the whole session and returns ErrTokenReused.
+func (s Service) Refresh(ctx context.Context, refreshToken string) (*User, string, error) {
+	plain, next, err := newRefreshToken(0, "", s.now())
+	if err != nil {
+		return nil, "", err
+	}
+	err = s.sessions.Rotate(ctx, hashToken(refreshToken), next)
+	if errors.Is(err, ErrTokenReused) {
+		logger.Warn("security", "event", "refresh_token_reused", "user", next.UserID)
+		return nil, "", err
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	u, err := s.repo.ByID(ctx, next.UserID)
+	if err != nil {
+		return nil, "", err
+	}
+	return u, plain, nil
+}
+
+// Logout closes the session of refreshToken (if given), ErrSessionInvalid
+// if it isn't of the User, and revokes the access token jti until it
+// expires.
+func (s Service) Logout(ctx context.Context, userID int64, refreshToken, jti string, expiresAt time.Time) error {
+	if refreshToken != "" {
+		t, err := s.sessions.ByHash(ctx, hashToken(refreshToken))
+		if err != nil {
+			return err
+		}
+		if t.UserID != userID {
+			logger.Warn("security", "event", "logout_foreign_session", "user", userID, "owner", t.UserID)
+			return ErrSessionInvalid
+		}
+		if err := s.sessions.RevokeFamily(ctx, t.FamilyID); err != nil {
+			return err
+		}
+	}
+	return s.sessions.RevokeAccess(ctx, jti, expiresAt)
+}
+
+// LogoutAll closes every session of the User and revokes the access token
+// jti. Other access tokens still alive expire on their own.
+func (s Service) LogoutAll(ctx context.Context, userID int64, jti string, expiresAt time.Time) error {
+	if err := s.sessions.RevokeByUser(ctx, userID); err != nil {
+		return err
+	}
+	return s.sessions.RevokeAccess(ctx, jti, expiresAt)
+}
+
+// IsAccessRevoked reports whether the access token jti was revoked.
+func (s Service) IsAccessRevoked(ctx context.Context, jti string) (bool, error) {
+	return s.sessions.IsAccessRevoked(ctx, jti)
+}
+
+// EnrolMFA generates a new TOTP secret for a User. MFA isn't required to
+// login until the secret is activated with ActivateMFA.
+func (s Service) EnrolMFA(ctx context.Context, userID int64) (MFAEnrolment, error) {
+	u, err := s.Find(ctx, userID)
+	if err != nil {
+		return MFAEnrolment{}, err
+	}
+	m, err := s.mfa.ByUser(ctx, userID)
+	if err != nil && !errors.Is(err, ErrMFANotEnrolled) {
+		return MFAEnrolment{}, err
+	}
+	if m != nil && m.Enabled() {
+		return MFAEnrolment{}, ErrMFAAlreadyEnabled
+	}
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return MFAEnrolment{}, err
+	}
+	err = s.mfa.Enrol(ctx, &MFA{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: s.now(),
+	})
+	if err != nil {
+		return MFAEnrolment{}, err
+	}
+	return MFAEnrolment{
+		Secret: secret,
+		URI:    totp.URI(MFAIssuer, u.Email, secret),
+	}, nil
+}
+
+// ActivateMFA verifies a first code of the enrolled secret and enables MFA
+// for the User. It returns the recovery codes, they can't be retrieved
+// again.
+func (s Service) ActivateMFA(ctx context.Context, userID int64, code string) ([]string, error) {
+	if code == "" {
+		return nil, ErrMFACodeCantBeEmpty
+	}
+	m, err := s.mfa.ByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if m.Enabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	now := s.now()
+	counter, err := m.verifyCode(code, now)
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfa.Enable(ctx, userID, counter, hashes, now); err != nil {
+		return nil, err
+	}
+	logger.Info("security", "event", "mfa_enabled", "user", userID)
+	return codes, nil
+}
+
+// DisableMFA disables MFA for the User, a current code is required.
+func (s Service) DisableMFA(ctx context.Context, userID int64, code string) error {
+	if code == "" {
+		return ErrMFACodeCantBeEmpty
+	}
+	if err := s.mfa.UseCode(ctx, userID, code, s.now()); err != nil {
+		return err
+	}
+	if err := s.mfa.Disable(ctx, userID); err != nil {
+		return err
+	}
+	logger.Info("security", "event", "mfa_disabled", "user", userID)
+	return nil
+}
+
+// MFARequired reports whether the User must answer an MFA challenge to
+// login.
+func (s Service) MFARequired(ctx context.Context, userID int64) (bool, error) {
+	m, err := s.mfa.ByUser(ctx, userID)
+	if errors.Is(err, ErrMFANotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return m.Enabled(), nil
+}
+
+// StartMFAChallenge opens the second step of the login of a User whose
+// password was checked, and returns the challenge token.
+func (s Service) StartMFAChallenge(ctx context.Context, userID int64) (string, error) {
+	plain, c, err := newMFAChallenge(userID, s.now())
+	if err != nil {
+		return "", err
+	}
+	if err := s.mfa.CreateChallenge(ctx, c); err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// AnswerMFAChallenge completes a login with a TOTP or recovery code and
+// returns the User. Each recovery code can be used once.
+func (s Service) AnswerMFAChallenge(ctx context.Context, challenge, code string) (*User, error) {
+	if code == "" {
+		return nil, ErrMFACodeCantBeEmpty
+	}
+	userID, recovery, err := s.mfa.AnswerChallenge(ctx, hashToken(challenge), code, s.now())
+	if errors.Is(err, ErrMFAInvalidCode) {
+		logger.Warn("security", "event", "mfa_failed")
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if recovery {
+		logger.Warn("security", "event", "mfa_recovery_code_used", "user", userID)
+	}
+	return s.repo.ByID(ctx, userID)
+}
+
+// rehash replaces the password hash of a User by one of the preferred
+// algorithm and parameters.
+func (s Service) rehash(ctx context.Context, id int64, pass string) error {
+	hash, err := s.hasher.Hash(pass)
+	if err != nil {
 		return err
 	}
-	return s.repo.ByLogin(ctx, email, password)
+	return s.repo.UpdatePassword(ctx, id, hash)
 }
 
-// SignUp to register a User.
+// SignUp to register a User. A link to verify the email is sent to it, if
+// it can't be sent the User is registered anyway.
 func (s Service) SignUp(ctx context.Context, u *User) error {
 	err := signUp(u)
 	if err != nil {
 		return err
 	}
-	return s.repo.Create(ctx, u)
+	u.Password, err = s.hasher.Hash(u.Password)
+	if err != nil {
+		return fmt.Errorf("password hash: %w", err)
+	}
+	if err := s.repo.Create(ctx, u); err != nil {
+		return err
+	}
+	if err := s.sendVerification(ctx, u); err != nil {
+		logger.Error("verification email", "user", u.ID, "err", err.Error())
+	}
+	return nil
+}
+
+// sendVerification sends a link to verify the email of a User.
+func (s Service) sendVerification(ctx context.Context, u *User) error {
+	plain, t, err := s.signer.newToken(u.ID, PurposeVerifyEmail, VerifyEmailTTL, s.now())
+	if err != nil {
+		return err
+	}
+	if err := s.tokens.Create(ctx, t); err != nil {
+		return err
+	}
+	link := s.opts.BaseURL + "/v1/users/verify?token=" + url.QueryEscape(plain)
+	return s.mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen this link to verify your email:\n\n%s\n\n"+
+			"The link expires in %s.\n", u.FirstName, link, VerifyEmailTTL),
+	})
+}
+
+// VerifyEmail marks the email of a User as verified with the token sent to
+// it. A token can be used once.
+func (s Service) VerifyEmail(ctx context.Context, token string) error {
+	hash, err := s.signer.open(token, PurposeVerifyEmail)
+	if err != nil {
+		return err
+	}
+	userID, err := s.tokens.VerifyEmail(ctx, hash, s.now())
+	if err != nil {
+		return err
+	}
+	logger.Info("security", "event", "email_verified", "user", userID)
+	return nil
+}
+
+// ForgotPassword sends a token to reset the password to the User of email.
+// Unknown emails are ignored without error, so the response doesn't tell
+// which emails are registered.
+func (s Service) ForgotPassword(ctx context.Context, email string) error {
+	if err := validateEmail(email); err != nil {
+		return err
+	}
+	u, err := s.repo.ByEmail(ctx, email)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	plain, t, err := s.signer.newToken(u.ID, PurposeResetPassword, ResetPasswordTTL, s.now())
+	if err != nil {
+		return err
+	}
+	if err := s.tokens.Create(ctx, t); err != nil {
+		return err
+	}
+	err = s.mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. "+
+			"To choose a new one send this token with it to %s/v1/password/reset:\n\n%s\n\n"+
+			"The token expires in %s. If it wasn't you, ignore this email.\n",
+			u.FirstName, s.opts.BaseURL, plain, ResetPasswordTTL),
+	})
+	if err != nil {
+		return err
+	}
+	logger.Info("security", "event", "password_reset_requested", "user", u.ID)
+	return nil
+}
+
+// ResetPassword replaces the password of a User with the token sent to it
+// by ForgotPassword. Every session of the User is closed and its failed
+// logins forgotten.
+func (s Service) ResetPassword(ctx context.Context, token, newPassword string) error {
+	if newPassword == "" {
+		return ErrPasswordCantBeEmpty
+	}
+	hash, err := s.signer.open(token, PurposeResetPassword)
+	if err != nil {
+		return err
+	}
+	pass, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return fmt.Errorf("password hash: %w", err)
+	}
+	userID, err := s.tokens.ResetPassword(ctx, hash, pass, s.now())
+	if err != nil {
+		return err
+	}
+	logger.Info("security", "event", "password_reset", "user", userID)
+	if err := s.sessions.RevokeByUser(ctx, userID); err != nil {
+		return fmt.Errorf("revoke sessions: %w", err)
+	}
+	u, err := s.repo.ByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.attempts.Reset(ctx, loginKey(u.Email))
 }
 
 // signUp applicaction logic for regitser a User. Has been split into
@@ -63,8 +542,12 @@ func (s Service) Find(ctx context.Context, id int64) (*User, error) {
 	return s.repo.ByID(ctx, id)
 }
 
-// Update application logic for update a User.
-func (s Service) Update(ctx context.Context, u User) error {
+// Update application logic for update a User. The actor can only update
+// its own account unless it's allowed to manage any user.
+func (s Service) Update(ctx context.Context, actor Principal, u User) error {
+	if err := authorize(actor, u.ID); err != nil {
+		return err
+	}
 	err := u.Validate()
 	if err != nil {
 		return err
@@ -73,6 +556,10 @@ func (s Service) Update(ctx context.Context, u User) error {
 	if err != nil {
 		return err
 	}
+	u.Password, err = s.hasher.Hash(u.Password)
+	if err != nil {
+		return fmt.Errorf("password hash: %w", err)
+	}
 	return s.repo.Update(ctx, u)
 }
 
@@ -81,14 +568,204 @@ func (s Service) List(ctx context.Context, f pgsql.Filter) (rows Users, total in
 	return s.repo.List(ctx, f)
 }
 
-// Remove delete User by its ID.
-func (s Service) Remove(ctx context.Context, id int64) error {
+// Remove delete User by its ID. The actor can only remove its own account
+// unless it's allowed to manage any user.
+func (s Service) Remove(ctx context.Context, actor Principal, id int64) error {
 	if id == 0 {
 		return ErrNotFound
 	}
+	if err := authorize(actor, id); err != nil {
+		return err
+	}
 	return s.repo.Delete(ctx, id)
 }
 
+// Roles lists the roles with their permissions.
+func (s Service) Roles(ctx context.Context) (Roles, error) {
+	return s.roles.All(ctx)
+}
+
+// Authorization returns the roles and permissions of a User, to be carried
+// in its access token.
+func (s Service) Authorization(ctx context.Context, userID int64) (Authorization, error) {
+	return s.roles.Authorization(ctx, userID)
+}
+
+// AssignRole assigns the role with name to a User.
+func (s Service) AssignRole(ctx context.Context, userID int64, name string) error {
+	if name == "" {
+		return ErrRoleCantBeEmpty
+	}
+	if _, err := s.Find(ctx, userID); err != nil {
+		return err
+	}
+	role, err := s.roles.ByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	return s.roles.Assign(ctx, userID, role.ID)
+}
+
+// RevokeRole revokes the role with name from a User.
+func (s Service) RevokeRole(ctx context.Context, userID int64, name string) error {
+	role, err := s.roles.ByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	return s.roles.Revoke(ctx, userID, role.ID)
+}
+
+// CreateServiceAccount registers a ServiceAccount, its API keys are
+// created with CreateAPIKey.
+func (s Service) CreateServiceAccount(ctx context.Context, sa *ServiceAccount) error {
+	if sa.Name == "" {
+		return ErrServiceAccountNameCantBeEmpty
+	}
+	sa.CreatedAt = s.now()
+	return s.apiKeys.CreateServiceAccount(ctx, sa)
+}
+
+// ServiceAccounts lists the service accounts.
+func (s Service) ServiceAccounts(ctx context.Context) ([]ServiceAccount, error) {
+	return s.apiKeys.ServiceAccounts(ctx)
+}
+
+// CreateAPIKey creates an API key for the actor, or for the service account
+// k.ServiceAccountID if the actor is allowed to manage them. It returns the
+// plain key, it can't be retrieved again. The scopes of the key must be
+// held by the actor.
+func (s Service) CreateAPIKey(ctx context.Context, actor Principal, k *APIKey) (string, error) {
+	if k.Name == "" {
+		return "", ErrAPIKeyNameCantBeEmpty
+	}
+	now := s.now()
+	if k.ExpiresAt != nil && !k.ExpiresAt.After(now) {
+		return "", ErrAPIKeyExpiryInPast
+	}
+	if err := grantScopes(actor, k.Scopes); err != nil {
+		return "", err
+	}
+	if k.ServiceAccountID != 0 {
+		if !actor.Can(PermServiceAccountsWrite) {
+			return "", ErrForbidden
+		}
+		if _, err := s.apiKeys.ServiceAccount(ctx, k.ServiceAccountID); err != nil {
+			return "", err
+		}
+		k.UserID = 0
+	} else {
+		if actor.UserID == 0 {
+			return "", ErrForbidden
+		}
+		k.UserID = actor.UserID
+	}
+	if k.Scopes == nil {
+		k.Scopes = []string{}
+	}
+	k.CreatedAt = now
+	plain, err := newAPIKey(k)
+	if err != nil {
+		return "", err
+	}
+	if err := s.apiKeys.Create(ctx, k); err != nil {
+		return "", err
+	}
+	logger.Info("security", "event", "api_key_created", "key", k.ID, "user", k.UserID,
+		"service_account", k.ServiceAccountID, "by", actor.UserID)
+	return plain, nil
+}
+
+// APIKeys lists the API keys of the actor.
+func (s Service) APIKeys(ctx context.Context, actor Principal) ([]APIKey, error) {
+	if actor.UserID == 0 {
+		return s.apiKeys.ByServiceAccount(ctx, actor.ServiceAccountID)
+	}
+	return s.apiKeys.ByUser(ctx, actor.UserID)
+}
+
+// ServiceAccountAPIKeys lists the API keys of a ServiceAccount.
+func (s Service) ServiceAccountAPIKeys(ctx context.Context, serviceAccountID int64) ([]APIKey, error) {
+	if _, err := s.apiKeys.ServiceAccount(ctx, serviceAccountID); err != nil {
+		return nil, err
+	}
+	return s.apiKeys.ByServiceAccount(ctx, serviceAccountID)
+}
+
+// RevokeAPIKey revokes an API key. The actor can only revoke its own keys,
+// unless it's allowed to manage any user or the service accounts.
+func (s Service) RevokeAPIKey(ctx context.Context, actor Principal, id int64) error {
+	k, err := s.apiKeys.ByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if k.UserID != 0 {
+		err = authorize(actor, k.UserID)
+	} else if !actor.Can(PermServiceAccountsWrite) {
+		err = ErrForbidden
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.apiKeys.Revoke(ctx, k.ID, s.now()); err != nil {
+		return err
+	}
+	logger.Info("security", "event", "api_key_revoked", "key", k.ID, "by", actor.UserID)
+	return nil
+}
+
+// AuthenticateAPIKey returns the principal of a plain API key. The
+// permissions of a user key are the scopes its owner still holds, those of
+// a service account key are its scopes.
+func (s Service) AuthenticateAPIKey(ctx context.Context, plain string) (Principal, error) {
+	prefix, err := parseAPIKey(plain)
+	if err != nil {
+		return Principal{}, err
+	}
+	k, err := s.apiKeys.ByPrefix(ctx, prefix)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return Principal{}, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	if !k.matches(plain) {
+		logger.Warn("security", "event", "api_key_mismatch", "prefix", prefix)
+		return Principal{}, ErrAPIKeyInvalid
+	}
+	now := s.now()
+	if err := k.Check(now); err != nil {
+		return Principal{}, err
+	}
+	p := Principal{
+		ServiceAccountID: k.ServiceAccountID,
+		APIKeyID:         k.ID,
+		Permissions:      k.Scopes,
+	}
+	if k.UserID != 0 {
+		u, err := s.repo.ByID(ctx, k.UserID)
+		if errors.Is(err, ErrNotFound) {
+			return Principal{}, ErrAPIKeyInvalid // of a deleted user
+		}
+		if err != nil {
+			return Principal{}, err
+		}
+		auth, err := s.roles.Authorization(ctx, u.ID)
+		if err != nil {
+			return Principal{}, err
+		}
+		p.UserID = u.ID
+		p.Email = u.Email
+		p.Permissions = keyPermissions(k.Scopes, auth.Permissions)
+	}
+	if k.touchDue(now) {
+		// The key is valid anyway, the use is recorded on the next one.
+		if err := s.apiKeys.Touch(ctx, k.ID, now); err != nil {
+			logger.Warn("api key touch", "key", k.ID, "err", err.Error())
+		}
+	}
+	return p, nil
+}
+
 // validateEmail helper to check email pattern.
 func validateEmail(email string) error {
 	validEmail, err := regexp.MatchString(`^([a-zA-Z0-9])+([a-zA-Z0-9\._-])*@([a-zA-Z0-9_-])+([a-zA-Z0-9\._-]+)+$`, email)
@@ -0,0 +1,85 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionExpired  = errors.New("session expired")
+	ErrSessionRevoked  = errors.New("session revoked")
+	ErrSessionInvalid  = errors.New("the session isn't of the user")
+
+	// ErrTokenReused a refresh token already rotated was presented again,
+	// which means it was stolen or replayed. Its whole family is revoked.
+	ErrTokenReused = errors.New("refresh token reused")
+)
+
+// RefreshTokenTTL is the lifetime of a refresh token. Every rotation issues
+// a new one with a full lifetime.
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+// RefreshToken is an opaque, single-use credential to obtain a new access
+// token. Each rotation issues a new token in the same family, the family
+// represents one login session. Only the hash of the token is stored.
+type RefreshToken struct {
+	ID        int64
+	UserID    int64
+	FamilyID  string
+	Hash      string
+	ExpiresAt time.Time
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// Check returns an error if the token can't be exchanged at now.
+func (t RefreshToken) Check(now time.Time) error {
+	if t.RevokedAt != nil {
+		return ErrSessionRevoked
+	}
+	if t.RotatedAt != nil {
+		return ErrTokenReused
+	}
+	if !now.Before(t.ExpiresAt) {
+		return ErrSessionExpired
+	}
+	return nil
+}
+
+// newRefreshToken creates a RefreshToken for userID in family. It returns
+// the plain token, to be handed to the client, and the model with its hash.
+func newRefreshToken(userID int64, family string, now time.Time) (string, *RefreshToken, error) {
+	plain, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+	return plain, &RefreshToken{
+		UserID:    userID,
+		FamilyID:  family,
+		Hash:      hashToken(plain),
+		ExpiresAt: now.Add(RefreshTokenTTL),
+		CreatedAt: now,
+	}, nil
+}
+
+// newToken returns a random opaque token of 256 bits.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex SHA-256 of a random token. Tokens have enough
+// entropy, a slow password hash isn't needed and a fast one allows lookup.
+func hashToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
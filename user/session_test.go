@@ -0,0 +1,68 @@
+package user
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenCheck(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Minute)
+	tt := []struct {
+		name    string
+		token   RefreshToken
+		wantErr error
+	}{
+		{
+			name:    "valid",
+			token:   RefreshToken{ExpiresAt: now.Add(time.Hour)},
+			wantErr: nil,
+		},
+		{
+			name:    "expired",
+			token:   RefreshToken{ExpiresAt: now},
+			wantErr: ErrSessionExpired,
+		},
+		{
+			name:    "rotated",
+			token:   RefreshToken{ExpiresAt: now.Add(time.Hour), RotatedAt: &before},
+			wantErr: ErrTokenReused,
+		},
+		{
+			name:    "revoked",
+			token:   RefreshToken{ExpiresAt: now.Add(time.Hour), RotatedAt: &before, RevokedAt: &before},
+			wantErr: ErrSessionRevoked,
+		},
+	}
+	for _, tc := range tt {
+		err := tc.token.Check(now)
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: want error %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestNewRefreshToken(t *testing.T) {
+	now := time.Now()
+	plain, m, err := newRefreshToken(1, "family", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Hash != hashToken(plain) {
+		t.Error("the stored hash doesn't belong to the plain token")
+	}
+	if m.Hash == plain {
+		t.Error("the plain token must not be stored")
+	}
+	if !m.ExpiresAt.Equal(now.Add(RefreshTokenTTL)) {
+		t.Errorf("want expiration %v, got %v", now.Add(RefreshTokenTTL), m.ExpiresAt)
+	}
+	other, _, err := newRefreshToken(1, "family", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == plain {
+		t.Error("refresh tokens must be random")
+	}
+}
@@ -0,0 +1,173 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adrianolmedo/genesis/pgsql"
+	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pborman/uuid"
+)
+
+// SessionRepo manages the storage of refresh tokens and revoked access
+// tokens.
+type SessionRepo struct {
+	db *pgxpool.Pool
+	q  *dbgen.Queries // for non-tx operations
+}
+
+// NewSessionRepo creates a new Session repository instance.
+func NewSessionRepo(db *pgxpool.Pool) *SessionRepo {
+	return &SessionRepo{
+		db: db,
+		q:  dbgen.New(db),
+	}
+}
+
+// Create stores a new refresh token.
+func (r *SessionRepo) Create(ctx context.Context, m *RefreshToken) error {
+	id, err := r.q.RefreshTokenCreate(ctx, dbgen.RefreshTokenCreateParams{
+		UserID:    m.UserID,
+		FamilyID:  uuid.Parse(m.FamilyID),
+		TokenHash: m.Hash,
+		ExpiresAt: m.ExpiresAt,
+		CreatedAt: m.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	m.ID = id
+	return nil
+}
+
+// ByHash get a refresh token from its hash.
+func (r *SessionRepo) ByHash(ctx context.Context, hash string) (*RefreshToken, error) {
+	row, err := r.q.RefreshTokenByHash(ctx, hash)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toDomainRefreshToken(row), nil
+}
+
+// Rotate exchanges the refresh token identified by hash for next, inside a
+// transaction that locks the old one, so two concurrent exchanges of the
+// same token can't both succeed. next inherits the user and family of the
+// old token. If the old token was already rotated, the whole family is
+// revoked and ErrTokenReused is returned.
+func (r *SessionRepo) Rotate(ctx context.Context, hash string, next *RefreshToken) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+	q := r.q.WithTx(tx)
+	row, err := q.RefreshTokenByHashForUpdate(ctx, hash)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	old := toDomainRefreshToken(row)
+	next.UserID = old.UserID
+	next.FamilyID = old.FamilyID
+	now := next.CreatedAt
+	if err := old.Check(now); err != nil {
+		if !errors.Is(err, ErrTokenReused) {
+			return err
+		}
+		if err := q.RefreshTokenRevokeFamily(ctx, dbgen.RefreshTokenRevokeFamilyParams{
+			FamilyID:  row.FamilyID,
+			RevokedAt: sql.NullTime{Time: now, Valid: true},
+		}); err != nil {
+			return fmt.Errorf("revoke family: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+		return ErrTokenReused
+	}
+	err = q.RefreshTokenRotate(ctx, dbgen.RefreshTokenRotateParams{
+		ID:        old.ID,
+		RotatedAt: sql.NullTime{Time: now, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	next.ID, err = q.RefreshTokenCreate(ctx, dbgen.RefreshTokenCreateParams{
+		UserID:    next.UserID,
+		FamilyID:  row.FamilyID,
+		TokenHash: next.Hash,
+		ExpiresAt: next.ExpiresAt,
+		CreatedAt: next.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RevokeFamily revokes every refresh token of a session.
+func (r *SessionRepo) RevokeFamily(ctx context.Context, family string) error {
+	return r.q.RefreshTokenRevokeFamily(ctx, dbgen.RefreshTokenRevokeFamilyParams{
+		FamilyID:  uuid.Parse(family),
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+}
+
+// RevokeByUser revokes every refresh token of a user (all sessions).
+func (r *SessionRepo) RevokeByUser(ctx context.Context, userID int64) error {
+	return r.q.RefreshTokenRevokeByUser(ctx, dbgen.RefreshTokenRevokeByUserParams{
+		UserID:    userID,
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+}
+
+// RevokeAccess adds the jti of an access token to the revocation list until
+// it expires. Entries of already expired tokens are purged.
+func (r *SessionRepo) RevokeAccess(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := r.q.RevokedTokenDeleteExpired(ctx, time.Now()); err != nil {
+		return fmt.Errorf("purge revoked tokens: %v", err)
+	}
+	return r.q.RevokedTokenCreate(ctx, dbgen.RevokedTokenCreateParams{
+		Jti:       jti,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// IsAccessRevoked reports whether the access token jti is revoked.
+func (r *SessionRepo) IsAccessRevoked(ctx context.Context, jti string) (bool, error) {
+	return r.q.RevokedTokenExists(ctx, jti)
+}
+
+// DeleteAll deletes all refresh tokens (permanently).
+func (r *SessionRepo) DeleteAll(ctx context.Context) error {
+	err := r.q.RefreshTokenDeleteAll(ctx)
+	if err != nil {
+		return fmt.Errorf("can't truncate table: %v", err)
+	}
+	return nil
+}
+
+// toDomainRefreshToken converts a dbgen.RefreshToken to a RefreshToken.
+func toDomainRefreshToken(row dbgen.RefreshToken) *RefreshToken {
+	return &RefreshToken{
+		ID:        row.ID,
+		UserID:    row.UserID,
+		FamilyID:  row.FamilyID.String(),
+		Hash:      row.TokenHash,
+		ExpiresAt: row.ExpiresAt,
+		RotatedAt: pgsql.NullTimeToPtr(row.RotatedAt),
+		RevokedAt: pgsql.NullTimeToPtr(row.RevokedAt),
+		CreatedAt: row.CreatedAt,
+	}
+}
@@ -0,0 +1,105 @@
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTokenInvalid         = errors.New("invalid token")
+	ErrTokenExpired         = errors.New("token expired")
+	ErrEmailNotVerified     = errors.New("email not verified")
+	ErrPasswordCantBeEmpty  = errors.New("password can't be empty")
+	ErrTokenKeyCantBeEmpty  = errors.New("token key can't be empty")
+	errTokenPurposeMismatch = errors.New("token of another purpose")
+)
+
+// Purposes of the tokens sent by email, a token is only valid for its own.
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+)
+
+// Lifetime of the tokens sent by email.
+const (
+	VerifyEmailTTL   = 48 * time.Hour
+	ResetPasswordTTL = time.Hour
+)
+
+// Token is a single-use token sent by email to prove the User owns the
+// address. Only the hash of the token is stored.
+type Token struct {
+	ID        int64
+	UserID    int64
+	Purpose   string
+	Hash      string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// Check returns an error if the token can't be used for purpose at now.
+func (t Token) Check(purpose string, now time.Time) error {
+	if t.Purpose != purpose {
+		return errTokenPurposeMismatch
+	}
+	if t.UsedAt != nil {
+		return ErrTokenInvalid
+	}
+	if !now.Before(t.ExpiresAt) {
+		return ErrTokenExpired
+	}
+	return nil
+}
+
+// tokenSigner signs the tokens sent by email with an HMAC bound to their
+// purpose, so a forged or altered token is rejected without querying the
+// storage and a token can't be used for another purpose.
+type tokenSigner struct {
+	key []byte
+}
+
+// newToken creates a Token of purpose for userID valid for ttl. It returns
+// the plain signed token, to be sent by email, and the model with its hash.
+func (s tokenSigner) newToken(userID int64, purpose string, ttl time.Duration, now time.Time) (string, *Token, error) {
+	if len(s.key) == 0 {
+		return "", nil, ErrTokenKeyCantBeEmpty
+	}
+	random, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+	return random + "." + s.sign(purpose, random), &Token{
+		UserID:    userID,
+		Purpose:   purpose,
+		Hash:      hashToken(random),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}, nil
+}
+
+// open verifies the signature of a plain token for purpose and returns the
+// hash it's stored by.
+func (s tokenSigner) open(plain, purpose string) (string, error) {
+	if len(s.key) == 0 {
+		return "", ErrTokenKeyCantBeEmpty
+	}
+	random, sig, ok := strings.Cut(plain, ".")
+	if !ok || random == "" {
+		return "", ErrTokenInvalid
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.sign(purpose, random))) {
+		return "", ErrTokenInvalid
+	}
+	return hashToken(random), nil
+}
+
+func (s tokenSigner) sign(purpose, random string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(purpose + "." + random))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,110 @@
+package user
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenSigner(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := tokenSigner{key: []byte("secret")}
+	plain, tk, err := s.newToken(1, PurposeResetPassword, ResetPasswordTTL, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tk.ExpiresAt.Equal(now.Add(ResetPasswordTTL)) {
+		t.Errorf("want expiration %s, got %s", now.Add(ResetPasswordTTL), tk.ExpiresAt)
+	}
+	tt := []struct {
+		name     string
+		signer   tokenSigner
+		token    string
+		purpose  string
+		wantHash string
+		wantErr  error
+	}{
+		{
+			name:     "valid",
+			signer:   s,
+			token:    plain,
+			purpose:  PurposeResetPassword,
+			wantHash: tk.Hash,
+			wantErr:  nil,
+		},
+		{
+			name:    "other-purpose",
+			signer:  s,
+			token:   plain,
+			purpose: PurposeVerifyEmail,
+			wantErr: ErrTokenInvalid,
+		},
+		{
+			name:    "other-key",
+			signer:  tokenSigner{key: []byte("other")},
+			token:   plain,
+			purpose: PurposeResetPassword,
+			wantErr: ErrTokenInvalid,
+		},
+		{
+			name:    "tampered",
+			signer:  s,
+			token:   "x" + plain,
+			purpose: PurposeResetPassword,
+			wantErr: ErrTokenInvalid,
+		},
+		{
+			name:    "unsigned",
+			signer:  s,
+			token:   "abc",
+			purpose: PurposeResetPassword,
+			wantErr: ErrTokenInvalid,
+		},
+	}
+	for _, tc := range tt {
+		hash, err := tc.signer.open(tc.token, tc.purpose)
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: want error %v, got %v", tc.name, tc.wantErr, err)
+		}
+		if hash != tc.wantHash {
+			t.Errorf("%s: want hash %q, got %q", tc.name, tc.wantHash, hash)
+		}
+	}
+}
+
+func TestTokenCheck(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Minute)
+	tt := []struct {
+		name    string
+		token   Token
+		wantErr error
+	}{
+		{
+			name:    "valid",
+			token:   Token{Purpose: PurposeVerifyEmail, ExpiresAt: now.Add(time.Hour)},
+			wantErr: nil,
+		},
+		{
+			name:    "expired",
+			token:   Token{Purpose: PurposeVerifyEmail, ExpiresAt: now},
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name:    "used",
+			token:   Token{Purpose: PurposeVerifyEmail, ExpiresAt: now.Add(time.Hour), UsedAt: &before},
+			wantErr: ErrTokenInvalid,
+		},
+		{
+			name:    "other-purpose",
+			token:   Token{Purpose: PurposeResetPassword, ExpiresAt: now.Add(time.Hour)},
+			wantErr: errTokenPurposeMismatch,
+		},
+	}
+	for _, tc := range tt {
+		err := tc.token.Check(PurposeVerifyEmail, now)
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: want error %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adrianolmedo/genesis/pgsql"
+	"github.com/adrianolmedo/genesis/pgsql/sqlc/dbgen"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TokenRepo manages the storage of the tokens sent by email.
+type TokenRepo struct {
+	db *pgxpool.Pool
+	q  *dbgen.Queries // for non-tx operations
+}
+
+// NewTokenRepo creates a new Token repository instance.
+func NewTokenRepo(db *pgxpool.Pool) *TokenRepo {
+	return &TokenRepo{
+		db: db,
+		q:  dbgen.New(db),
+	}
+}
+
+// Create stores a new token. Expired tokens are purged.
+func (r *TokenRepo) Create(ctx context.Context, t *Token) error {
+	if err := r.q.UserTokenDeleteExpired(ctx, t.CreatedAt); err != nil {
+		return fmt.Errorf("purge tokens: %v", err)
+	}
+	id, err := r.q.UserTokenCreate(ctx, dbgen.UserTokenCreateParams{
+		UserID:    t.UserID,
+		Purpose:   t.Purpose,
+		TokenHash: t.Hash,
+		ExpiresAt: t.ExpiresAt,
+		CreatedAt: t.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	t.ID = id
+	return nil
+}
+
+// VerifyEmail uses the verification token identified by hash and marks the
+// email of its User as verified, it returns the User ID.
+func (r *TokenRepo) VerifyEmail(ctx context.Context, hash string, now time.Time) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+	q := r.q.WithTx(tx)
+	t, err := useToken(ctx, q, hash, PurposeVerifyEmail, now)
+	if err != nil {
+		return 0, err
+	}
+	_, err = q.UserVerifyEmail(ctx, dbgen.UserVerifyEmailParams{
+		ID:         t.UserID,
+		VerifiedAt: sql.NullTime{Time: now, Valid: true},
+	})
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return t.UserID, tx.Commit(ctx)
+}
+
+// ResetPassword uses the reset token identified by hash and replaces the
+// password hash of its User, it returns the User ID.
+func (r *TokenRepo) ResetPassword(ctx context.Context, hash, passwordHash string, now time.Time) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+	q := r.q.WithTx(tx)
+	t, err := useToken(ctx, q, hash, PurposeResetPassword, now)
+	if err != nil {
+		return 0, err
+	}
+	_, err = q.UserUpdatePassword(ctx, dbgen.UserUpdatePasswordParams{
+		ID:        t.UserID,
+		Password:  passwordHash,
+		UpdatedAt: sql.NullTime{Time: now, Valid: true},
+	})
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return t.UserID, tx.Commit(ctx)
+}
+
+// DeleteAll deletes all tokens (permanently).
+func (r *TokenRepo) DeleteAll(ctx context.Context) error {
+	err := r.q.UserTokenDeleteAll(ctx)
+	if err != nil {
+		return fmt.Errorf("can't truncate table: %v", err)
+	}
+	return nil
+}
+
+// useToken checks the token identified by hash for purpose and marks it,
+// and every other token of the same User and purpose, as used. q must run
+// inside a transaction.
+func useToken(ctx context.Context, q *dbgen.Queries, hash, purpose string, now time.Time) (*Token, error) {
+	row, err := q.UserTokenByHashForUpdate(ctx, hash)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	t := toDomainToken(row)
+	err = t.Check(purpose, now)
+	if errors.Is(err, errTokenPurposeMismatch) {
+		return nil, ErrTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = q.UserTokenUseAll(ctx, dbgen.UserTokenUseAllParams{
+		UserID:  t.UserID,
+		Purpose: purpose,
+		UsedAt:  sql.NullTime{Time: now, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// toDomainToken converts a dbgen.UserToken to a Token.
+func toDomainToken(row dbgen.UserToken) *Token {
+	return &Token{
+		ID:        row.ID,
+		UserID:    row.UserID,
+		Purpose:   row.Purpose,
+		Hash:      row.TokenHash,
+		ExpiresAt: row.ExpiresAt,
+		UsedAt:    pgsql.NullTimeToPtr(row.UsedAt),
+		CreatedAt: row.CreatedAt,
+	}
+}
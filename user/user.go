@@ -2,6 +2,7 @@ package user
 
 import (
 	"errors"
+	"time"
 
 	"github.com/adrianolmedo/genesis"
 )
@@ -20,6 +21,9 @@ type User struct {
 	Email     string
 	Password  string
 
+	// VerifiedAt is when the User proved to own the email, nil until then.
+	VerifiedAt *time.Time
+
 	genesis.AuditFields
 }
 